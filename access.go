@@ -0,0 +1,127 @@
+package cstorage
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// stripeCount is number of stripes of accessBuffer
+	stripeCount = 16
+	// stripeSize is number of accesses which are recorded in a stripe before it is handed to drain
+	stripeSize = 64
+	// pendingStripes is number of full stripes waiting for drain, beyond which stripes are dropped
+	pendingStripes = 16
+)
+
+// access is Get hit recorded by accessBuffer. key is kept to tell if node still holds the key when it is applied.
+type access struct {
+	n   *node
+	key string
+}
+
+// stripe is part of accessBuffer, which is padded to its own cache line so stripes don't share one.
+type stripe struct {
+	mutex    sync.Mutex
+	accesses []access
+	_        [32]byte
+}
+
+// accessBuffer is lossy buffer of accesses, see CStorageConfig.BufferedAccess.
+// Accesses are appended to one of stripes, taken in turn by next and skipping stripes locked by others, so concurrent Gets rarely wait for each other.
+// Full stripes are sent to drain which applies them to eviction policy under write lock.
+// If every stripe is locked, or drain is behind, accesses are dropped instead of blocking Get, and counted by dropped.
+// next and dropped are accessed atomically.
+type accessBuffer struct {
+	stripes [stripeCount]stripe
+	full    chan []access
+	next    uint32
+	dropped int64
+}
+
+func newAccessBuffer() *accessBuffer {
+	return &accessBuffer{full: make(chan []access, pendingStripes)}
+}
+
+// record function is to record access of node n. It is safe under read lock.
+func (b *accessBuffer) record(n *node) {
+	next := atomic.AddUint32(&b.next, 1)
+	for i := uint32(0); i < stripeCount; i++ {
+		st := &b.stripes[(next+i)%stripeCount]
+		if !st.mutex.TryLock() {
+			continue
+		}
+		st.accesses = append(st.accesses, access{n: n, key: n.key})
+		if len(st.accesses) == stripeSize {
+			select {
+			case b.full <- st.accesses:
+			default:
+				atomic.AddInt64(&b.dropped, stripeSize)
+			}
+			st.accesses = make([]access, 0, stripeSize)
+		}
+		st.mutex.Unlock()
+		return
+	}
+	atomic.AddInt64(&b.dropped, 1)
+}
+
+// drain applies full stripes of s.buffer to eviction policy until done is closed.
+func (s *CStorage) drain(done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case accesses := <-s.buffer.full:
+			s.mutex.Lock()
+			s.apply(accesses)
+			for pending := true; pending; {
+				select {
+				case accesses = <-s.buffer.full:
+					s.apply(accesses)
+				default:
+					pending = false
+				}
+			}
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// apply applies accesses to eviction policy. Accesses of nodes which are removed, or reused for another key, since they are recorded are skipped.
+// Caller should hold the mutex.
+func (s *CStorage) apply(accesses []access) {
+	for _, a := range accesses {
+		if n, ok := s.table[a.key]; ok && n == a.n && !n.pinned {
+			s.policy.access(n)
+		}
+	}
+}
+
+// getShared is fast path of Get under read lock, which is only possible with PolicySIEVE or CStorageConfig.BufferedAccess.
+// ok is false if Get should take the slow path under write lock; e.g. key is missing or expired, or it needs to be modified.
+func (s *CStorage) getShared(key string) (data []byte, hit, ok bool) {
+	if (s.config.Policy != PolicySIEVE && s.buffer == nil) || s.lfu != nil {
+		return nil, false, false
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	n, found := s.table[key]
+	if !found || n.sliding || n.once || n.schema < s.config.SchemaVersion {
+		return nil, false, false
+	}
+	if now := s.now(); n.ttl.Before(now) || s.refreshDue(n, now) || !s.intact(n) || (s.sealer != nil && !s.sealer.known(s.stored(n))) {
+		return nil, false, false
+	}
+
+	if s.config.Policy == PolicySIEVE {
+		atomic.StoreInt32(&n.visited, 1)
+	} else if !n.pinned {
+		s.buffer.record(n)
+	}
+	atomic.AddInt64(&s.stats.Hits, 1)
+	s.counters.hit(n)
+	return s.value(n), true, true
+}
@@ -0,0 +1,128 @@
+package cstorage
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBufferedAccess(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, BufferedAccess: true}
+	cache := New(config)
+	defer cache.Close()
+
+	for i := 0; i < 10; i++ {
+		cache.Put(strconv.Itoa(i), []byte("v"))
+	}
+
+	// accesses are applied in background, so get the oldest key until it is not the victim anymore
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		for i := 0; i < stripeCount*stripeSize; i++ {
+			if _, hit := cache.Get("0"); !hit {
+				t.Fatal("expected hit of key 0")
+			}
+		}
+		cache.mutex.Lock()
+		victim := cache.policy.victim().key
+		cache.mutex.Unlock()
+		if victim != "0" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("accesses of key 0 are not applied")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cache.Put("10", []byte("v"))
+	if _, hit := cache.Get("0"); !hit {
+		t.Error("accessed key 0 should survive eviction")
+	}
+	if _, hit := cache.Get("1"); hit {
+		t.Error("key 1 should be evicted")
+	}
+}
+
+func TestBufferedAccessSkipsRemoved(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, BufferedAccess: true}
+	cache := New(config)
+	defer cache.Close()
+
+	cache.Put("a", []byte("v"))
+	cache.Put("b", []byte("v"))
+	cache.mutex.Lock()
+	stale := []access{{n: cache.table["a"], key: "a"}}
+	cache.mutex.Unlock()
+
+	cache.Delete("a")
+	cache.Put("c", []byte("v"))
+
+	cache.mutex.Lock()
+	cache.apply(stale)
+	victim := cache.policy.victim().key
+	cache.mutex.Unlock()
+	if victim != "b" {
+		t.Errorf("access of removed key should be skipped, victim is %q", victim)
+	}
+}
+
+func TestBufferedAccessConcurrentGet(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 100
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, BufferedAccess: true}
+	cache := New(config)
+
+	for i := 0; i < 100; i++ {
+		cache.Put(strconv.Itoa(i), []byte("v"))
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				cache.Get(strconv.Itoa((i + g) % 150))
+				if i%100 == 0 {
+					cache.Put(strconv.Itoa(100+i%50), []byte("w"))
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	st := cache.Stats()
+	if st.Hits+st.Misses != 8000 {
+		t.Errorf("every get should be counted, got %+v", st)
+	}
+	if st.Size > capacity {
+		t.Errorf("size %d is over capacity", st.Size)
+	}
+	if err := cache.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+}
+
+func BenchmarkParallelGetBuffered(b *testing.B) {
+	config := CStorageConfig{Ttl: time.Hour, Capacity: 1000, BufferedAccess: true}
+	cache := New(config)
+	defer cache.Close()
+	for i := 0; i < 1000; i++ {
+		cache.Put(strconv.Itoa(i), []byte("v"))
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			cache.Get(strconv.Itoa(i % 1000))
+			i++
+		}
+	})
+}
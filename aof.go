@@ -0,0 +1,455 @@
+package cstorage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AOFSync is fsync policy of append-only log, see CStorageConfig.AOFPath.
+type AOFSync int
+
+const (
+	// AOFSyncEverySecond syncs log to disk every second, so crash of the machine loses writes of the last second at most. It is the default.
+	AOFSyncEverySecond AOFSync = iota
+	// AOFSyncAlways syncs log to disk on every write, so no acknowledged write is lost, at the cost of latency of every write.
+	AOFSyncAlways
+	// AOFSyncNever leaves syncing to operating system. Writes survive crash of the process, but not crash of the machine.
+	AOFSyncNever
+)
+
+// aofOp is operation of an append-only log record.
+type aofOp byte
+
+const (
+	aofPut aofOp = iota + 1
+	aofDelete
+	aofClear
+	// aofPutExt is put with extension of the entry, see encodeExtension. It is written only in snapshot of version 2 and later.
+	aofPutExt
+)
+
+// aofRewriteSize is default of CStorageConfig.AOFRewriteSize.
+const aofRewriteSize = 64 << 20
+
+// aof is append-only log of writes. Records are appended under the mutex of CStorage; each is framed by length and CRC,
+// so torn record at the end of log by crash is detected and dropped on replay.
+// Record is op(1) | sealed(1) | flags(4) | expires at in unix nano(8) | key length(uvarint) | key | data.
+type aof struct {
+	path     string
+	file     *os.File
+	sync     AOFSync
+	maxSize  int64
+	size     int64
+	rewrote  int64
+	dirty    bool
+	frame    []byte
+	err      error
+	rewrites chan struct{}
+	// rewriting holds records appended while log is rewritten, which are appended to the new log. It is nil when not rewriting.
+	rewriting *bytes.Buffer
+	// rewrite serializes rewrites by background worker and RewriteAOF
+	rewrite sync.Mutex
+}
+
+// Open function returns CStorage of config, like New. If CStorageConfig.AOFPath is set, it replays the log at the path to restore keys
+// written before restart, and appends every write to it after that. Torn record at the end of log, which is written when crashed, is dropped.
+// It returns error if config is invalid, or if the log can't be opened or read.
+func Open(config CStorageConfig) (*CStorage, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	s := New(config)
+	if config.AOFPath == "" {
+		return s, nil
+	}
+	if err := s.openAOF(config.AOFPath); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// RewriteAOF function compacts append-only log into a put record of each key, which is done in background when log grows beyond
+// CStorageConfig.AOFRewriteSize. Writes are not blocked while keys are written, except the short moment new log replaces old one.
+// It returns ErrClosed if CStorage is closed, and does nothing if AOFPath is not set.
+func (s *CStorage) RewriteAOF() error {
+	s.mutex.RLock()
+	a, closed := s.aof, s.closed
+	s.mutex.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+	if a == nil {
+		return nil
+	}
+
+	a.rewrite.Lock()
+	defer a.rewrite.Unlock()
+	start := time.Now()
+
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return ErrClosed
+	}
+	items := s.items()
+	a.rewriting = &bytes.Buffer{}
+	s.mutex.Unlock()
+
+	f, err := os.CreateTemp(filepath.Dir(a.path), filepath.Base(a.path)+".tmp*")
+	if err != nil {
+		s.abortRewrite(a)
+		return err
+	}
+	defer os.Remove(f.Name())
+	size, err := s.writeItems(f, items)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		s.abortRewrite(a)
+		s.logAOF("cstorage: log rewritten", err)
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		f.Close()
+		return ErrClosed
+	}
+	n, err := f.Write(a.rewriting.Bytes())
+	size += int64(n)
+	a.rewriting = nil
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), a.path)
+	}
+	if err != nil {
+		f.Close()
+		s.logAOF("cstorage: log rewritten", err)
+		return err
+	}
+	syncDir(a.path)
+	a.file.Close()
+	a.file, a.size, a.rewrote, a.dirty = f, size, size, false
+	s.logAOF("cstorage: log rewritten", nil, "keys", len(items), "bytes", size, "elapsed", time.Since(start))
+	return nil
+}
+
+// abortRewrite stops collecting records for failed rewrite.
+func (s *CStorage) abortRewrite(a *aof) {
+	s.mutex.Lock()
+	a.rewriting = nil
+	s.mutex.Unlock()
+}
+
+// writeItems writes put record of each item to w, and returns bytes written.
+func (s *CStorage) writeItems(w io.Writer, items []item) (size int64, err error) {
+	bw := bufio.NewWriter(w)
+	var frame []byte
+	for _, it := range items {
+		if frame, err = s.encodeRecord(frame[:0], aofPut, it.key, it.data, it.expiresAt, it.flags, nil); err != nil {
+			return size, err
+		}
+		if _, err := bw.Write(frame); err != nil {
+			return size, err
+		}
+		size += int64(len(frame))
+	}
+	return size, bw.Flush()
+}
+
+// openAOF replays log at path, and opens it for appending.
+func (s *CStorage) openAOF(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	start := time.Now()
+	s.mutex.Lock()
+	count, size, err := s.replay(f, info.Size())
+	s.mutex.Unlock()
+	if err == nil {
+		err = f.Truncate(size)
+	}
+	if err == nil {
+		_, err = f.Seek(size, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("cstorage: replay %s: %w", path, err)
+	}
+	s.logAOF("cstorage: log replayed", nil, "records", count, "bytes", size, "elapsed", time.Since(start))
+
+	maxSize := s.config.AOFRewriteSize
+	if maxSize == 0 {
+		maxSize = aofRewriteSize
+	}
+	a := &aof{path: path, file: f, sync: s.config.AOFSync, maxSize: maxSize, size: size, rewrote: size, rewrites: make(chan struct{}, 1)}
+	s.mutex.Lock()
+	s.aof = a
+	s.spawn(func(done <-chan struct{}) {
+		s.aofLoop(a, done)
+	})
+	s.mutex.Unlock()
+	return nil
+}
+
+// replay applies records of log of total bytes from r, and returns number of records and size of log up to the last intact record.
+// Caller should hold the mutex.
+func (s *CStorage) replay(r io.Reader, total int64) (count int, size int64, err error) {
+	br := bufio.NewReader(r)
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return count, size, nil
+			}
+			return count, size, err
+		}
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		if size+int64(len(header))+length > total {
+			return count, size, nil
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(br, payload); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return count, size, nil
+			}
+			return count, size, err
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+			// torn record by crash, and the rest can't be trusted either
+			return count, size, nil
+		}
+		if err := s.applyRecord(payload); err != nil {
+			return count, size, err
+		}
+		size += int64(len(header) + len(payload))
+		count++
+	}
+}
+
+// applyRecord applies a record of log. Caller should hold the mutex.
+func (s *CStorage) applyRecord(payload []byte) error {
+	op, rec, err := decodeRecord(payload)
+	if err != nil {
+		return err
+	}
+	switch op {
+	case aofPut:
+		// put which is expired or rejected still replaces older data of key
+		put, err := s.restore(rec)
+		if err != nil {
+			return err
+		}
+		if !put {
+			s.delete(rec.Key)
+		}
+	case aofDelete:
+		s.delete(rec.Key)
+	case aofClear:
+		s.reset()
+	default:
+		return fmt.Errorf("cstorage: unknown log operation %d", op)
+	}
+	return nil
+}
+
+// decodeRecord parses payload of a record of log or snapshot.
+func decodeRecord(payload []byte) (op aofOp, rec record, err error) {
+	if len(payload) < 14 {
+		return 0, rec, errors.New("cstorage: record is too short")
+	}
+	op, rec.Sealed, rec.Flags = aofOp(payload[0]), payload[1] == 1, binary.BigEndian.Uint32(payload[2:6])
+	if expires := int64(binary.BigEndian.Uint64(payload[6:14])); expires != 0 {
+		rec.ExpiresAt = time.Unix(0, expires)
+	}
+	length, n := binary.Uvarint(payload[14:])
+	if n <= 0 || uint64(len(payload)-14-n) < length {
+		return 0, rec, errors.New("cstorage: record has invalid key")
+	}
+	rec.Key = string(payload[14+n : 14+n+int(length)])
+	rec.Data = payload[14+n+int(length):]
+	if op == aofPutExt {
+		length, n := binary.Uvarint(rec.Data)
+		if n <= 0 || uint64(len(rec.Data)-n) < length {
+			return 0, rec, errors.New("cstorage: record has invalid extension")
+		}
+		if err := decodeExtension(rec.Data[n:n+int(length)], &rec); err != nil {
+			return 0, rec, err
+		}
+		rec.Data = rec.Data[n+int(length):]
+	}
+	return op, rec, nil
+}
+
+// encodeRecord appends framed record to frame. Data is sealed if CStorageConfig.Encryption is set. Snapshot consists of put records as well.
+// ext is extension of aofPutExt, which is written between key and data with its length(uvarint).
+func (s *CStorage) encodeRecord(frame []byte, op aofOp, key string, data []byte, expiresAt time.Time, flags uint32, ext []byte) ([]byte, error) {
+	var sealed byte
+	if (op == aofPut || op == aofPutExt) && s.sealer != nil {
+		var err error
+		if data, err = s.sealer.seal(data); err != nil {
+			return frame, err
+		}
+		sealed = 1
+	}
+	var expires int64
+	if !expiresAt.IsZero() {
+		expires = expiresAt.UnixNano()
+	}
+
+	var fixed [14 + binary.MaxVarintLen64]byte
+	fixed[0], fixed[1] = byte(op), sealed
+	binary.BigEndian.PutUint32(fixed[2:], flags)
+	binary.BigEndian.PutUint64(fixed[6:], uint64(expires))
+	n := 14 + binary.PutUvarint(fixed[14:], uint64(len(key)))
+
+	start := len(frame)
+	frame = append(frame, make([]byte, 8)...)
+	frame = append(frame, fixed[:n]...)
+	frame = append(frame, key...)
+	if op == aofPutExt {
+		var length [binary.MaxVarintLen64]byte
+		frame = append(frame, length[:binary.PutUvarint(length[:], uint64(len(ext)))]...)
+		frame = append(frame, ext...)
+	}
+	frame = append(frame, data...)
+	payload := frame[start+8:]
+	binary.BigEndian.PutUint32(frame[start:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[start+4:], crc32.ChecksumIEEE(payload))
+	return frame, nil
+}
+
+// logPut appends put of n to log. Functions which change n after put(e.g. flags) call it again, so the last record of key is complete.
+// Caller should hold the mutex.
+func (s *CStorage) logPut(n *node) {
+	if s.aof != nil {
+		s.appendRecord(aofPut, n.key, s.value(n), n.ttl, n.flags)
+	}
+}
+
+// logRemoved appends deletion of n to log, unless n is removed by expiry which replay does by itself. Caller should hold the mutex.
+func (s *CStorage) logRemoved(n *node) {
+	if s.aof != nil && !n.ttl.Before(s.now()) {
+		s.appendRecord(aofDelete, n.key, nil, time.Time{}, 0)
+	}
+}
+
+// logClear appends Clear to log. Caller should hold the mutex.
+func (s *CStorage) logClear() {
+	if s.aof != nil {
+		s.appendRecord(aofClear, "", nil, time.Time{}, 0)
+	}
+}
+
+// appendRecord writes record to log. Error is kept and returned by Close, since writes don't return error. Caller should hold the mutex.
+func (s *CStorage) appendRecord(op aofOp, key string, data []byte, expiresAt time.Time, flags uint32) {
+	a := s.aof
+	frame, err := s.encodeRecord(a.frame[:0], op, key, data, expiresAt, flags, nil)
+	a.frame = frame
+	if err == nil {
+		_, err = a.file.Write(frame)
+	}
+	if err == nil && a.sync == AOFSyncAlways {
+		err = a.file.Sync()
+	}
+	if err != nil {
+		if a.err == nil {
+			s.logAOF("cstorage: log write failed", err)
+		}
+		a.err = err
+		return
+	}
+
+	a.dirty = a.sync == AOFSyncEverySecond
+	a.size += int64(len(frame))
+	if a.rewriting != nil {
+		a.rewriting.Write(frame)
+	} else if a.size > a.maxSize && a.size > 2*a.rewrote {
+		select {
+		case a.rewrites <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// aofLoop syncs log every second, and rewrites it when it is grown.
+func (s *CStorage) aofLoop(a *aof, done <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mutex.Lock()
+			if a.dirty {
+				a.dirty = false
+				if err := a.file.Sync(); err != nil {
+					s.logAOF("cstorage: log write failed", err)
+				}
+			}
+			s.mutex.Unlock()
+		case <-a.rewrites:
+			s.RewriteAOF()
+		case <-done:
+			return
+		}
+	}
+}
+
+// closeAOF syncs and closes log, and returns the first error of writing it. Caller should hold the mutex.
+func (s *CStorage) closeAOF() error {
+	a := s.aof
+	if a == nil {
+		return nil
+	}
+	s.aof = nil
+	err := a.err
+	if serr := a.file.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := a.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// syncDir syncs directory of path, so rename of file in it is durable. It is best effort, since some platforms can't sync directory.
+func syncDir(path string) {
+	if d, err := os.Open(filepath.Dir(path)); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// logAOF logs event of append-only log, or Warn with err if it failed.
+func (s *CStorage) logAOF(msg string, err error, args ...interface{}) {
+	if s.logs == nil {
+		return
+	}
+	if err != nil {
+		s.logs.logger.Warn(msg, append(args, "error", err)...)
+		return
+	}
+	s.logs.logger.Info(msg, args...)
+}
@@ -0,0 +1,155 @@
+package cstorage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+func TestAOF(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	clock := testutil.NewClock(time.Now())
+	path := filepath.Join(t.TempDir(), "cstorage.aof")
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock, AOFPath: path, AOFSync: AOFSyncAlways}
+
+	s, err := Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put("a", []byte("1"))
+	s.PutWithTtl("b", []byte("2"), time.Minute)
+	s.PutWithTtl("short", []byte("3"), time.Second)
+	s.Delete("a")
+	s.PutWithFlags("c", []byte("4"), 7)
+	s.Increment("n", 2)
+	s.Increment("n", 3)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(2 * time.Second)
+	s, err = Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, hit := s.Get("a"); hit {
+		t.Error("deleted a should not be replayed")
+	}
+	if _, hit := s.Get("short"); hit {
+		t.Error("expired key should not be replayed")
+	}
+	if remaining, _ := s.Ttl("b"); remaining != time.Minute-2*time.Second {
+		t.Errorf("b should keep its expiration, got %v", remaining)
+	}
+	if data, flags, _ := s.GetWithFlags("c"); string(data) != "4" || flags != 7 {
+		t.Errorf("c should be replayed with flags, got %q %d", data, flags)
+	}
+	if data, _ := s.Get("n"); string(data) != "5" {
+		t.Errorf("counter should be replayed, got %q", data)
+	}
+
+	s.Clear()
+	s.Put("d", []byte("5"))
+	s.Close()
+	s, err = Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if size := s.Stats().Size; size != 1 {
+		t.Errorf("only d should be left after Clear, got %d keys", size)
+	}
+}
+
+func TestAOFTornRecord(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	path := filepath.Join(t.TempDir(), "cstorage.aof")
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, AOFPath: path}
+
+	s, err := Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put("a", []byte("1"))
+	s.Close()
+
+	// crash in the middle of writing a record
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 20, 1, 2, 3, 4, 1})
+	f.Close()
+
+	s, err = Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := s.Get("a"); string(data) != "1" {
+		t.Errorf("a should be replayed before torn record, got %q", data)
+	}
+	// torn record is truncated, so records after it are replayed next time
+	s.Put("b", []byte("2"))
+	s.Close()
+	s, err = Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if data, _ := s.Get("b"); string(data) != "2" {
+		t.Errorf("b should be replayed after torn record is dropped, got %q", data)
+	}
+}
+
+func TestRewriteAOF(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	path := filepath.Join(t.TempDir(), "cstorage.aof")
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, AOFPath: path, Encryption: NewKeys(1, make([]byte, 32))}
+
+	s, err := Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		s.Put("a", []byte("1"))
+		s.Put("b", []byte("2"))
+	}
+	s.Delete("b")
+	before, _ := os.Stat(path)
+	if err := s.RewriteAOF(); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size()/50 {
+		t.Errorf("log should be compacted, got %d bytes from %d", after.Size(), before.Size())
+	}
+	s.Put("c", []byte("3"))
+	s.Close()
+
+	s, err = Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if data, _ := s.Get("a"); string(data) != "1" {
+		t.Errorf("a should be replayed from rewritten log, got %q", data)
+	}
+	if _, hit := s.Get("b"); hit {
+		t.Error("deleted b should not be in rewritten log")
+	}
+	if data, _ := s.Get("c"); string(data) != "3" {
+		t.Errorf("c should be appended to rewritten log, got %q", data)
+	}
+
+	config.Encryption = nil
+	if _, err := Open(config); err == nil {
+		t.Error("sealed log should not be replayed without the key")
+	}
+}
@@ -0,0 +1,43 @@
+package cstorage
+
+// Append function adds data to the end of existing data of key, atomically with respect to other operations.
+// It returns false if key doesn't exist, or joined data is not put(e.g. longer than CStorageConfig.MaxValueBytes). Like memcached, ttl of the key is kept.
+func (s *CStorage) Append(key string, data []byte) (hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.concat(key, data, false)
+}
+
+// Prepend function is same as Append, but it adds data to the beginning of existing data.
+func (s *CStorage) Prepend(key string, data []byte) (hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.concat(key, data, true)
+}
+
+// concat is internal function of Append and Prepend. New slice is made, so slices returned by Get before are not modified.
+// Caller should hold the mutex.
+func (s *CStorage) concat(key string, data []byte, prepend bool) bool {
+	n, ok := s.get(key)
+	if !ok {
+		return false
+	}
+
+	old := s.value(n)
+	joined := make([]byte, 0, len(old)+len(data))
+	if prepend {
+		joined = append(append(joined, data...), old...)
+	} else {
+		joined = append(append(joined, old...), data...)
+	}
+
+	ttl := n.ttl
+	if n, _ = s.put(key, joined, n.lifetime, n.sliding); n == nil {
+		return false
+	}
+	s.reschedule(n, ttl)
+	s.logPut(n)
+	return true
+}
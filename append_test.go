@@ -0,0 +1,37 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAppend(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	if cache.Append("log", []byte("b")) || cache.Prepend("log", []byte("a")) {
+		t.Error("missing key shouldn't be appended")
+	}
+	if _, hit := cache.Get("log"); hit {
+		t.Error("missing key shouldn't be created")
+	}
+
+	cache.PutWithTtl("log", []byte("b"), time.Minute)
+	_, before, _ := cache.GetWithExpiration("log")
+	old, _ := cache.Get("log")
+
+	cache.Append("log", []byte("c"))
+	cache.Prepend("log", []byte("a"))
+	data, after, _ := cache.GetWithExpiration("log")
+	if string(data) != "abc" {
+		t.Errorf("expected abc, got %s", data)
+	}
+	if !after.Equal(before) {
+		t.Errorf("ttl should be kept, got %v and %v", before, after)
+	}
+	if string(old) != "b" {
+		t.Errorf("data returned before shouldn't be modified, got %s", old)
+	}
+}
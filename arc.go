@@ -0,0 +1,160 @@
+package cstorage
+
+// segments of arc, stored in node.segment
+const (
+	arcT1 uint8 = iota
+	arcT2
+	arcB1
+	arcB2
+)
+
+// arc is Adaptive Replacement Cache policy.
+// - t1: nodes accessed once recently, t2: nodes accessed more than once recently
+// - b1, b2: ghost lists which remember keys recently evicted from t1 and t2, without data
+// - target: target size of t1. Ghost hit in b1 means t1 was too small, so target grows, and ghost hit in b2 shrinks it
+// Key which hits ghost list is put into t2 directly, since it is seen before.
+type arc struct {
+	capacity int64
+	target   int64
+	t1, t2   list
+	b1, b2   list
+	ghosts   map[string]*node
+	pending  string
+	fromB2   bool
+	nodes    *nodes
+}
+
+// newARC makes arc for capacity. Ghosts are nodes of ns as well, since lists link nodes of single nodes.
+func newARC(capacity int64, ns *nodes) *arc {
+	return &arc{capacity: capacity, t1: newList(ns), t2: newList(ns), b1: newList(ns), b2: newList(ns), ghosts: make(map[string]*node), nodes: ns}
+}
+
+func (p *arc) adapt(key string) {
+	g, ok := p.ghosts[key]
+	if !ok {
+		// make room in ghost lists for the key, which will be added to t1
+		if p.t1.len+p.b1.len >= p.capacity && p.b1.tail != 0 {
+			p.dropGhost(&p.b1)
+		} else if p.t1.len+p.t2.len+p.b1.len+p.b2.len >= 2*p.capacity && p.b2.tail != 0 {
+			p.dropGhost(&p.b2)
+		}
+		p.pending = ""
+		return
+	}
+
+	if g.segment == arcB1 {
+		p.target += max64(p.b2.len/max64(p.b1.len, 1), 1)
+		if p.target > p.capacity {
+			p.target = p.capacity
+		}
+		p.b1.remove(g)
+		p.fromB2 = false
+	} else {
+		p.target -= max64(p.b1.len/max64(p.b2.len, 1), 1)
+		if p.target < 0 {
+			p.target = 0
+		}
+		p.b2.remove(g)
+		p.fromB2 = true
+	}
+	delete(p.ghosts, key)
+	p.nodes.release(g)
+	p.pending = key
+}
+
+func (p *arc) add(n *node) {
+	if n.key == p.pending {
+		n.segment = arcT2
+		p.t2.pushHead(n)
+	} else {
+		n.segment = arcT1
+		p.t1.pushHead(n)
+	}
+	p.pending = ""
+}
+
+func (p *arc) access(n *node) {
+	if n.segment == arcT2 {
+		p.t2.setHead(n)
+		return
+	}
+	p.t1.remove(n)
+	n.segment = arcT2
+	p.t2.pushHead(n)
+}
+
+func (p *arc) remove(n *node, evicted bool) {
+	from := n.segment
+	if from == arcT2 {
+		p.t2.remove(n)
+	} else {
+		p.t1.remove(n)
+	}
+	if !evicted {
+		return
+	}
+
+	g := p.nodes.alloc()
+	g.key = n.key
+	if from == arcT2 {
+		g.segment = arcB2
+		p.b2.pushHead(g)
+	} else {
+		g.segment = arcB1
+		p.b1.pushHead(g)
+	}
+	p.ghosts[g.key] = g
+	p.trim()
+}
+
+// trim keeps ghost lists bounded; t1+b1 within capacity, and every lists within twice of capacity.
+func (p *arc) trim() {
+	for p.t1.len+p.b1.len > p.capacity && p.b1.tail != 0 {
+		p.dropGhost(&p.b1)
+	}
+	for p.t1.len+p.t2.len+p.b1.len+p.b2.len > 2*p.capacity && p.b2.tail != 0 {
+		p.dropGhost(&p.b2)
+	}
+}
+
+func (p *arc) dropGhost(l *list) {
+	g := l.last()
+	l.remove(g)
+	delete(p.ghosts, g.key)
+	p.nodes.release(g)
+}
+
+func (p *arc) victim() *node {
+	if p.t1.tail != 0 && (p.t1.len > p.target || (p.t1.len == p.target && p.fromB2 && p.pending != "") || p.t2.tail == 0) {
+		return p.t1.last()
+	}
+	return p.t2.last()
+}
+
+func (p *arc) each(fn func(n *node) bool) {
+	if p.t1.each(fn) {
+		p.t2.each(fn)
+	}
+}
+
+func (p *arc) reset() {
+	for _, g := range p.ghosts {
+		p.nodes.release(g)
+	}
+	*p = *newARC(p.capacity, p.nodes)
+}
+
+func (p *arc) resize(capacity int64) {
+	p.capacity = capacity
+	if p.target > capacity {
+		p.target = capacity
+	}
+	p.trim()
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,56 @@
+package cstorage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestARC(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Policy: PolicyARC}
+	cache := New(config)
+
+	for i := 0; i < 5; i++ {
+		key := "hot" + strconv.Itoa(i)
+		cache.Put(key, []byte(key))
+		cache.Get(key)
+	}
+	for i := 0; i < 100; i++ {
+		key := "scan" + strconv.Itoa(i)
+		cache.Put(key, []byte(key))
+	}
+	for i := 0; i < 5; i++ {
+		if _, hit := cache.Get("hot" + strconv.Itoa(i)); !hit {
+			t.Errorf("hot%d is frequent, it should survive scan", i)
+		}
+	}
+
+	p := cache.policy.(*arc)
+	if p.t1.len+p.t2.len != 10 || p.t1.len+p.b1.len > capacity {
+		t.Errorf("unexpected list sizes t1=%d t2=%d b1=%d", p.t1.len, p.t2.len, p.b1.len)
+	}
+
+	cache.Put("scan90", []byte("again"))
+	if p.target == 0 {
+		t.Error("ghost hit in b1 should grow target of t1")
+	}
+	if n := cache.table["scan90"]; n == nil || n.segment != arcT2 {
+		t.Error("key which hit ghost list should be put into t2")
+	}
+
+	cache.Delete("hot0")
+	if _, ok := p.ghosts["hot0"]; ok {
+		t.Error("deleted key should not be remembered as ghost")
+	}
+
+	cache.Clear()
+	if p.t1.len+p.t2.len+p.b1.len+p.b2.len != 0 || len(p.ghosts) != 0 {
+		t.Error("clear should reset every lists")
+	}
+}
+
+func BenchmarkHitRatioARC(b *testing.B) {
+	benchmarkHitRatio(b, CStorageConfig{Policy: PolicyARC})
+}
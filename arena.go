@@ -0,0 +1,91 @@
+package cstorage
+
+// arena stores data in large byte slabs, see CStorageConfig.ArenaSlabBytes. Node refers its data by arenaRef, which has no pointer,
+// so millions of small values are few objects for GC to mark instead of millions.
+// Slabs are append only; data which is replaced or removed leaves garbage, which is reclaimed by compaction into new slabs.
+// Since slabs are never overwritten, slices into them stay valid(and immutable) even after compaction, until GC frees the old slab.
+type arena struct {
+	slabSize int
+	slabs    [][]byte
+	// bytes written to slabs, and bytes of them still referred by nodes
+	size int64
+	live int64
+}
+
+// arenaRef is location of data in arena. slab is index of slab plus 1, so zero value means data is not in arena.
+type arenaRef struct {
+	slab uint32
+	off  uint32
+	len  uint32
+}
+
+// defaultArenaCompact is CStorageConfig.ArenaCompact if it is not set.
+const defaultArenaCompact = 0.5
+
+func newArena(slabSize int) *arena {
+	return &arena{slabSize: slabSize}
+}
+
+// alloc copies data into arena. ok is false if data is larger than quarter of slab, which is better allocated by itself than wasting tail of slabs.
+func (a *arena) alloc(data []byte) (r arenaRef, ok bool) {
+	if len(data) == 0 || len(data) > a.slabSize/4 {
+		return arenaRef{}, false
+	}
+
+	last := len(a.slabs) - 1
+	if last < 0 || len(a.slabs[last])+len(data) > a.slabSize {
+		a.slabs = append(a.slabs, make([]byte, 0, a.slabSize))
+		last++
+	}
+	off := len(a.slabs[last])
+	a.slabs[last] = append(a.slabs[last], data...)
+	a.size += int64(len(data))
+	a.live += int64(len(data))
+	return arenaRef{slab: uint32(last + 1), off: uint32(off), len: uint32(len(data))}, true
+}
+
+// get returns data of r. Capacity of returned slice is limited, so append to it doesn't overwrite next data.
+func (a *arena) get(r arenaRef) []byte {
+	end := r.off + r.len
+	return a.slabs[r.slab-1][r.off:end:end]
+}
+
+// free marks data of r as garbage. Data is still readable until compaction.
+func (a *arena) free(r arenaRef) {
+	a.live -= int64(r.len)
+}
+
+// fragmented returns true if ratio of garbage exceeds threshold. Arena of single slab is never fragmented, since compaction wouldn't shrink it.
+func (a *arena) fragmented(threshold float64) bool {
+	return len(a.slabs) > 1 && float64(a.size-a.live) > threshold*float64(a.size)
+}
+
+// stored returns data of node as it is stored, which may be in arena. Caller should hold the mutex, read lock is enough.
+func (s *CStorage) stored(n *node) []byte {
+	if n.ref.slab != 0 {
+		return s.arena.get(n.ref)
+	}
+	return n.data
+}
+
+// compact moves data of every node into new slabs if arena is fragmented more than CStorageConfig.ArenaCompact.
+// Nodes which are not in the table(e.g. returned by get after it is evicted as once key) keep refering old slabs, so it should be called
+// only when nothing holds such node, e.g. before put. Caller should hold the mutex.
+func (s *CStorage) compact() {
+	threshold := s.config.ArenaCompact
+	if threshold == 0 {
+		threshold = defaultArenaCompact
+	}
+	if s.arena == nil || !s.arena.fragmented(threshold) {
+		return
+	}
+
+	old := s.arena
+	s.arena = newArena(old.slabSize)
+	for _, n := range s.table {
+		if n.ref.slab != 0 {
+			n.ref, _ = s.arena.alloc(old.get(n.ref))
+		}
+	}
+	s.stats.Compactions++
+}
@@ -0,0 +1,59 @@
+package cstorage
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestArena(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 100
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, ArenaSlabBytes: 1024, Checksum: ChecksumAlways, ZeroCopy: true}
+	cache := New(config)
+
+	value := func(i, round int) []byte {
+		return bytes.Repeat([]byte(strconv.Itoa(i+round)), 10)
+	}
+	cache.Put("key", value(0, 0))
+	held, _ := cache.Get("key")
+
+	// every round replaces data of every key, which leaves garbage in slabs
+	for round := 0; round < 10; round++ {
+		for i := 0; i < 50; i++ {
+			cache.Put(strconv.Itoa(i), value(i, round))
+		}
+	}
+	for i := 0; i < 50; i++ {
+		if data, hit := cache.Get(strconv.Itoa(i)); !hit || !bytes.Equal(data, value(i, 9)) {
+			t.Errorf("%d: expected %q, got %q, %v", i, value(i, 9), data, hit)
+		}
+	}
+	if st := cache.Stats(); st.Compactions == 0 || st.Corrupted != 0 {
+		t.Errorf("expected compactions without corruption, got %+v", st)
+	}
+	if len(cache.arena.slabs) > 2 {
+		t.Errorf("compaction should keep slabs few, got %d", len(cache.arena.slabs))
+	}
+	// slice returned before compaction still has the data
+	if !bytes.Equal(held, value(0, 0)) {
+		t.Errorf("data returned before compaction is changed, got %q", held)
+	}
+
+	large := make([]byte, 512)
+	cache.Put("large", large)
+	if data, _ := cache.Get("large"); !bytes.Equal(data, large) {
+		t.Error("large data should be put outside arena")
+	}
+	cache.mutex.RLock()
+	if n := cache.table["large"]; n.ref.slab != 0 || n.data == nil {
+		t.Error("large data should be allocated by itself")
+	}
+	cache.mutex.RUnlock()
+
+	cache.Clear()
+	if len(cache.arena.slabs) != 0 {
+		t.Errorf("clear should drop slabs, got %d", len(cache.arena.slabs))
+	}
+}
@@ -0,0 +1,86 @@
+package cstorage
+
+import "time"
+
+// Entry structure is key-value pair used by batch operations.
+// If Ttl is zero, Ttl of CStorageConfig will be used. Meta and Flags are optional metadata, same as PutWithMeta and PutWithFlags.
+// Hash is optional hash of Key by KeyHash, which is computed by CStorage if zero.
+// ExpiresAt is used by Warm instead of Ttl if it is not zero, so entries keep their original expiration.
+type Entry struct {
+	Key       string
+	Data      []byte
+	Ttl       time.Duration
+	Meta      map[string]string
+	Flags     uint32
+	Hash      uint64
+	ExpiresAt time.Time
+}
+
+// GetMulti function is batch version of Get. It acquires the lock only once for all keys.
+// Returned map only contains keys which are hit.
+func (s *CStorage) GetMulti(keys []string) (hits map[string][]byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	hits = make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if n, ok := s.get(key); ok {
+			hits[key] = s.value(n)
+		}
+	}
+	return hits
+}
+
+// GetMultiHashed function is same as GetMulti, but hashes of keys by KeyHash are given by caller, so keys are not hashed again.
+// hashes should have same length as keys.
+func (s *CStorage) GetMultiHashed(keys []string, hashes []uint64) (hits map[string][]byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	hits = make(map[string][]byte, len(keys))
+	for i, key := range keys {
+		if n, ok := s.getHashed(key, hashes[i]); ok {
+			hits[key] = s.value(n)
+		}
+	}
+	return hits
+}
+
+// PutMulti function is batch version of Put. It acquires the lock only once for all entries.
+// Returned map tells each key was hit(update) or not(insert), same as return value of Put.
+func (s *CStorage) PutMulti(entries []Entry) (hits map[string]bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	hits = make(map[string]bool, len(entries))
+	for _, e := range entries {
+		ttl := e.Ttl
+		if ttl == 0 {
+			ttl = s.config.Ttl
+		}
+		h := e.Hash
+		if h == 0 {
+			h = s.hash(e.Key)
+		}
+		n, hit := s.putMeta(e.Key, h, e.Data, ttl, s.config.Sliding, copyMeta(e.Meta))
+		if n != nil && e.Flags != 0 {
+			n.flags = e.Flags
+			s.logPut(n)
+		}
+		hits[e.Key] = hit
+	}
+	return hits
+}
+
+// DeleteMulti function is batch version of Delete. It acquires the lock only once for all keys.
+// Returned map tells each key existed or not, same as return value of Delete.
+func (s *CStorage) DeleteMulti(keys []string) (hits map[string]bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	hits = make(map[string]bool, len(keys))
+	for _, key := range keys {
+		hits[key] = s.delete(key)
+	}
+	return hits
+}
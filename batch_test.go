@@ -0,0 +1,39 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.Put("key1", []byte("1jqoweijgn3120nvc0qjew0j"))
+
+	hits := cache.PutMulti([]Entry{
+		{Key: "key1", Data: []byte("1")},
+		{Key: "key2", Data: []byte("2")},
+		{Key: "key3", Data: []byte("3"), Ttl: time.Millisecond},
+	})
+	if !hits["key1"] || hits["key2"] || hits["key3"] {
+		t.Errorf("only key1 should be hit, got %v", hits)
+	}
+
+	time.Sleep(time.Millisecond * 10)
+
+	got := cache.GetMulti([]string{"key1", "key2", "key3", "key4"})
+	if len(got) != 2 || string(got["key1"]) != "1" || string(got["key2"]) != "2" {
+		t.Errorf("expected key1 and key2, got %v", got)
+	}
+
+	deleted := cache.DeleteMulti([]string{"key1", "key4"})
+	if !deleted["key1"] || deleted["key4"] {
+		t.Errorf("only key1 should be deleted, got %v", deleted)
+	}
+	if cache.Size() != 1 {
+		t.Errorf("size should be 1, got %d", cache.Size())
+	}
+}
@@ -0,0 +1,161 @@
+// Package benchmarks is standard benchmark suite of caches, so regressions of CStorage are caught and CStorage can be compared with
+// other caches(e.g. ristretto, bigcache, freecache) under the same workloads. Any cache can join by wrapping it with Cache, e.g.
+//
+//	benchmarks.Factory{Name: "ristretto", New: func(capacity int) benchmarks.Cache {
+//		c, _ := ristretto.NewCache(&ristretto.Config{NumCounters: int64(capacity) * 10, MaxCost: int64(capacity), BufferItems: 64})
+//		return ristrettoCache{c}
+//	}}
+//
+// Adapters of third party caches are not included, since this module doesn't depend on them; they take a few lines in caller's module.
+//
+// Bench runs workloads as Go benchmarks, serially and in parallel, and reports hit ratio as well as time and allocations.
+// Run does the same without testing package, and Report prints results as table, e.g. for comparison in CI.
+package benchmarks
+
+import (
+	"math/rand"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cocm1324/cstorage/compat"
+)
+
+// Cache is the interface of caches under benchmark, same as compat.Cache. Implementation must be safe for concurrent use.
+type Cache = compat.Cache
+
+// Factory structure makes Cache under benchmark. New returns empty Cache which holds about capacity keys.
+type Factory struct {
+	Name string
+	New  func(capacity int) Cache
+}
+
+// Workload structure describes access pattern of benchmark.
+// - Keys: number of distinct keys
+// - Capacity: capacity given to Factory.New. It is less than Keys, so hit ratio tells how well the cache chooses keys to keep.
+// - WriteRatio: ratio of Set among operations, between 0 and 1. Get miss is followed by Set as cache-aside, which is not counted as operation.
+// - ValueSize: size of value in bytes
+// - Skew: exponent of zipfian distribution of keys, which should be larger than 1. Larger skew makes few keys hotter.
+type Workload struct {
+	Name       string
+	Keys       int
+	Capacity   int
+	WriteRatio float64
+	ValueSize  int
+	Skew       float64
+}
+
+// Workloads are standard workloads of the suite.
+var Workloads = []Workload{
+	{Name: "zipf-read", Keys: 100000, Capacity: 10000, WriteRatio: 0, ValueSize: 128, Skew: 1.1},
+	{Name: "read-heavy", Keys: 100000, Capacity: 10000, WriteRatio: 0.1, ValueSize: 128, Skew: 1.1},
+	{Name: "mixed", Keys: 100000, Capacity: 10000, WriteRatio: 0.5, ValueSize: 128, Skew: 1.1},
+	{Name: "write-heavy", Keys: 100000, Capacity: 10000, WriteRatio: 0.9, ValueSize: 128, Skew: 1.1},
+}
+
+// ops generates operations of workload.
+type ops struct {
+	keys  []string
+	value []byte
+	write float64
+	rand  *rand.Rand
+	zipf  *rand.Zipf
+}
+
+// names returns keys of workload, which are shared by ops of the workload.
+func names(w Workload) []string {
+	keys := make([]string, w.Keys)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+func newOps(w Workload, keys []string, seed int64) *ops {
+	r := rand.New(rand.NewSource(seed))
+	return &ops{keys: keys, value: make([]byte, w.ValueSize), write: w.WriteRatio, rand: r, zipf: rand.NewZipf(r, w.Skew, 1, uint64(w.Keys-1))}
+}
+
+// do runs one operation against cache, and returns whether it was Get and whether it hit.
+func (o *ops) do(cache Cache) (get, hit bool) {
+	key := o.keys[o.zipf.Uint64()]
+	if o.rand.Float64() < o.write {
+		cache.Set(key, o.value)
+		return false, false
+	}
+	if _, hit = cache.Get(key); !hit {
+		cache.Set(key, o.value)
+	}
+	return true, hit
+}
+
+// warm fills cache by running operations of workload as many as its capacity, so benchmark doesn't measure cold cache.
+func (o *ops) warm(cache Cache, capacity int) {
+	for i := 0; i < capacity; i++ {
+		o.do(cache)
+	}
+}
+
+// Bench function runs every Workloads against Cache made by factory as sub-benchmarks, named by workload, serially and in parallel.
+// Besides time and allocations, it reports hit ratio of Get.
+func Bench(b *testing.B, factory Factory) {
+	for _, w := range Workloads {
+		w := w
+		b.Run(w.Name, func(b *testing.B) {
+			benchSerial(b, factory.New(w.Capacity), w)
+		})
+		b.Run(w.Name+"-parallel", func(b *testing.B) {
+			benchParallel(b, factory.New(w.Capacity), w)
+		})
+	}
+}
+
+func benchSerial(b *testing.B, cache Cache, w Workload) {
+	o := newOps(w, names(w), 1)
+	o.warm(cache, w.Capacity)
+
+	var gets, hits int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		get, hit := o.do(cache)
+		if get {
+			gets++
+		}
+		if hit {
+			hits++
+		}
+	}
+	reportHitRatio(b, gets, hits)
+}
+
+func benchParallel(b *testing.B, cache Cache, w Workload) {
+	keys := names(w)
+	newOps(w, keys, 1).warm(cache, w.Capacity)
+
+	var seed, gets, hits int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		o := newOps(w, keys, atomic.AddInt64(&seed, 1))
+		var g, h int64
+		for pb.Next() {
+			get, hit := o.do(cache)
+			if get {
+				g++
+			}
+			if hit {
+				h++
+			}
+		}
+		atomic.AddInt64(&gets, g)
+		atomic.AddInt64(&hits, h)
+	})
+	reportHitRatio(b, gets, hits)
+}
+
+func reportHitRatio(b *testing.B, gets, hits int64) {
+	if gets > 0 {
+		b.ReportMetric(float64(hits)/float64(gets), "hit-ratio")
+	}
+}
@@ -0,0 +1,56 @@
+package benchmarks
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/cocm1324/cstorage"
+)
+
+func TestRun(t *testing.T) {
+	w := Workload{Name: "small", Keys: 1000, Capacity: 100, WriteRatio: 0.1, ValueSize: 16, Skew: 1.1}
+
+	lru := Run(CStorage("lru", cstorage.CStorageConfig{}), w, 10000)
+	if lru.Ops != 10000 || lru.Throughput <= 0 {
+		t.Errorf("expected measured run, got %+v", lru)
+	}
+	if lru.HitRatio <= 0 || lru.HitRatio >= 1 {
+		t.Errorf("expected hit ratio between 0 and 1, got %v", lru.HitRatio)
+	}
+
+	// map never evicts, so its hit ratio is upper bound
+	if m := Run(Map(), w, 10000); m.HitRatio < lru.HitRatio {
+		t.Errorf("map should hit at least as often as lru, got %v and %v", m.HitRatio, lru.HitRatio)
+	}
+}
+
+func TestReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Report(&buf, []Result{{Cache: "lru", Workload: "mixed", Throughput: 1000, HitRatio: 0.5}}); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 2 || !strings.Contains(lines[1], "0.5000") {
+		t.Errorf("unexpected report %q", buf.String())
+	}
+}
+
+func BenchmarkLRU(b *testing.B) {
+	Bench(b, CStorage("lru", cstorage.CStorageConfig{}))
+}
+
+func BenchmarkTinyLFU(b *testing.B) {
+	Bench(b, CStorage("tinylfu", cstorage.CStorageConfig{TinyLFU: true}))
+}
+
+func BenchmarkSIEVE(b *testing.B) {
+	Bench(b, CStorage("sieve", cstorage.CStorageConfig{Policy: cstorage.PolicySIEVE}))
+}
+
+func BenchmarkBufferedAccess(b *testing.B) {
+	Bench(b, CStorage("buffered", cstorage.CStorageConfig{BufferedAccess: true}))
+}
+
+func BenchmarkMap(b *testing.B) {
+	Bench(b, Map())
+}
@@ -0,0 +1,59 @@
+package benchmarks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+// CStorage function returns Factory of CStorage made by config, whose Capacity is given by workload. Ttl is an hour if not set.
+func CStorage(name string, config cstorage.CStorageConfig) Factory {
+	return Factory{Name: name, New: func(capacity int) Cache {
+		c := config
+		c.Capacity = int64(capacity)
+		if c.Ttl == 0 {
+			c.Ttl = time.Hour
+		}
+		return storage{cstorage.New(c)}
+	}}
+}
+
+// storage adapts CStorage to Cache.
+type storage struct {
+	s *cstorage.CStorage
+}
+
+func (s storage) Set(key string, value []byte) {
+	s.s.Put(key, value)
+}
+
+func (s storage) Get(key string) ([]byte, bool) {
+	return s.s.Get(key)
+}
+
+// Map function returns Factory of map under lock which never evicts, as reference of the cost of locking and hashing alone.
+// Since it ignores capacity, its hit ratio is upper bound of any cache, and its memory grows to every keys of workload.
+func Map() Factory {
+	return Factory{Name: "map", New: func(capacity int) Cache {
+		return &lockedMap{m: make(map[string][]byte, capacity)}
+	}}
+}
+
+type lockedMap struct {
+	mutex sync.RWMutex
+	m     map[string][]byte
+}
+
+func (l *lockedMap) Set(key string, value []byte) {
+	l.mutex.Lock()
+	l.m[key] = value
+	l.mutex.Unlock()
+}
+
+func (l *lockedMap) Get(key string) ([]byte, bool) {
+	l.mutex.RLock()
+	value, ok := l.m[key]
+	l.mutex.RUnlock()
+	return value, ok
+}
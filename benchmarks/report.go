@@ -0,0 +1,82 @@
+package benchmarks
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"text/tabwriter"
+	"time"
+)
+
+// Result structure is outcome of Run.
+// - Throughput: operations per second
+// - AllocsPerOp, BytesPerOp: heap allocations of the whole process per operation, so nothing else should run meanwhile
+// - HitRatio: ratio of hits among Get
+type Result struct {
+	Cache       string
+	Workload    string
+	Ops         int
+	Elapsed     time.Duration
+	Throughput  float64
+	AllocsPerOp float64
+	BytesPerOp  float64
+	HitRatio    float64
+}
+
+// Run function is to run ops operations of workload serially against Cache made by factory, after warming it up, and measures them.
+func Run(factory Factory, w Workload, ops int) Result {
+	cache := factory.New(w.Capacity)
+	o := newOps(w, names(w), 1)
+	o.warm(cache, w.Capacity)
+
+	var gets, hits int
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < ops; i++ {
+		get, hit := o.do(cache)
+		if get {
+			gets++
+		}
+		if hit {
+			hits++
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	r := Result{Cache: factory.Name, Workload: w.Name, Ops: ops, Elapsed: elapsed}
+	if elapsed > 0 {
+		r.Throughput = float64(ops) / elapsed.Seconds()
+	}
+	if ops > 0 {
+		r.AllocsPerOp = float64(after.Mallocs-before.Mallocs) / float64(ops)
+		r.BytesPerOp = float64(after.TotalAlloc-before.TotalAlloc) / float64(ops)
+	}
+	if gets > 0 {
+		r.HitRatio = float64(hits) / float64(gets)
+	}
+	return r
+}
+
+// RunAll function is to Run every Workloads against every factory, in the order of workloads, then factories.
+func RunAll(factories []Factory, ops int) []Result {
+	var results []Result
+	for _, w := range Workloads {
+		for _, f := range factories {
+			results = append(results, Run(f, w, ops))
+		}
+	}
+	return results
+}
+
+// Report function is to write results to w as table.
+func Report(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKLOAD\tCACHE\tOPS/S\tALLOCS/OP\tB/OP\tHIT RATIO")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%.0f\t%.2f\t%.1f\t%.4f\n", r.Workload, r.Cache, r.Throughput, r.AllocsPerOp, r.BytesPerOp, r.HitRatio)
+	}
+	return tw.Flush()
+}
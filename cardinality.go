@@ -0,0 +1,105 @@
+package cstorage
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// hllPrecision is number of bits of hash which choose register of HyperLogLog. 2^12 registers give about 1.6% of error.
+const hllPrecision = 12
+
+// cardinality counts distinct keys ever written with HyperLogLog, and remembers the count at the start of current window,
+// so growth of distinct keys can be checked every CStorageConfig.CardinalityWindow.
+type cardinality struct {
+	registers [1 << hllPrecision]uint8
+	start     time.Time
+	last      float64
+}
+
+// add counts key of hash h.
+func (c *cardinality) add(h uint64) {
+	i := h >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(h<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rank > c.registers[i] {
+		c.registers[i] = rank
+	}
+}
+
+// estimate returns estimated number of distinct keys. Linear counting is used for small cardinality.
+func (c *cardinality) estimate() float64 {
+	m := float64(len(c.registers))
+	var sum float64
+	var zeros int
+	for _, r := range c.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	e := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+	return e
+}
+
+// count counts key of hash h written to namespace(empty for CStorage itself), and checks growth of distinct keys if window has passed.
+// capacity is capacity of the namespace, growth is reported only if distinct keys are more than it. Caller should hold the mutex.
+func (s *CStorage) count(c *cardinality, namespace string, h uint64, capacity int64) {
+	if c == nil {
+		return
+	}
+	c.add(h)
+
+	now := s.now()
+	if c.start.IsZero() {
+		c.start = now
+		return
+	}
+	if now.Sub(c.start) < s.config.CardinalityWindow {
+		return
+	}
+
+	estimate := c.estimate()
+	last := c.last
+	c.start = now
+	c.last = estimate
+	if last == 0 || s.config.OnCardinalityGrowth == nil {
+		return
+	}
+
+	threshold := s.config.CardinalityGrowth
+	if threshold == 0 {
+		threshold = 0.5
+	}
+	growth := (estimate - last) / last
+	if growth >= threshold && estimate > float64(capacity) {
+		s.config.OnCardinalityGrowth(namespace, uint64(estimate), growth)
+	}
+}
+
+// Cardinality function returns estimated number of distinct keys ever written to CStorage, including keys of namespaces.
+// It is 0 if CStorageConfig.CardinalityWindow is not set.
+func (s *CStorage) Cardinality() uint64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.cardinality == nil {
+		return 0
+	}
+	return uint64(s.cardinality.estimate())
+}
+
+// Cardinality function returns estimated number of distinct keys ever written to Namespace.
+// It is 0 if CStorageConfig.CardinalityWindow is not set.
+func (ns *Namespace) Cardinality() uint64 {
+	ns.s.mutex.RLock()
+	defer ns.s.mutex.RUnlock()
+
+	if ns.cardinality == nil {
+		return 0
+	}
+	return uint64(ns.cardinality.estimate())
+}
@@ -0,0 +1,52 @@
+package cstorage
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+func TestCardinality(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+
+	clock := testutil.NewClock(time.Now())
+
+	var alerts []string
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock, CardinalityWindow: time.Millisecond * 10,
+		OnCardinalityGrowth: func(namespace string, cardinality uint64, growth float64) {
+			alerts = append(alerts, namespace)
+		}}
+	cache := New(config)
+
+	for i := 0; i < 10000; i++ {
+		cache.Put(strconv.Itoa(i%5000), []byte{})
+	}
+	if c := cache.Cardinality(); math.Abs(float64(c)-5000) > 5000*0.05 {
+		t.Errorf("cardinality should be about 5000, got %d", c)
+	}
+
+	// bounded keys of storage and ever growing keys of namespace
+	events := cache.Namespace("events")
+	for round := 0; round < 3; round++ {
+		clock.Advance(time.Millisecond * 15)
+		for i := 0; i < 1000; i++ {
+			cache.Put(strconv.Itoa(i), []byte{})
+			events.Put(strconv.Itoa(round*1000+i), []byte{})
+		}
+	}
+	if len(alerts) == 0 {
+		t.Fatal("growth of events should be alerted")
+	}
+	for _, namespace := range alerts {
+		if namespace != "events" {
+			t.Errorf("only events should be alerted, got %q", namespace)
+		}
+	}
+	if c := events.Cardinality(); math.Abs(float64(c)-3000) > 3000*0.05 {
+		t.Errorf("cardinality of events should be about 3000, got %d", c)
+	}
+}
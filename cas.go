@@ -0,0 +1,80 @@
+package cstorage
+
+import "bytes"
+
+// CompareAndSwap function puts new only if current data of key is equal to old. It returns swapped=true if data is put.
+// Compare and put are done under same lock, so it is atomic with respect to other operations.
+// swapped is false if new is not put(e.g. longer than CStorageConfig.MaxValueBytes), same as PutE.
+// New data is put with lifetime and sliding of the key, so ttl given by PutWithTtl or PutSliding is kept.
+func (s *CStorage) CompareAndSwap(key string, old, new []byte) (swapped bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, ok := s.get(key)
+	if !ok || !bytes.Equal(s.value(n), old) {
+		return false
+	}
+
+	n, _ = s.put(key, new, n.lifetime, n.sliding)
+	return n != nil
+}
+
+// CompareAndDelete function deletes key only if current data of key is equal to old. It returns deleted=true if key is deleted.
+func (s *CStorage) CompareAndDelete(key string, old []byte) (deleted bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, ok := s.get(key)
+	if !ok || !bytes.Equal(s.value(n), old) {
+		return false
+	}
+
+	return s.delete(key)
+}
+
+// GetWithVersion function is same as Get, but it also returns version of data.
+// Version is changed whenever data of key is put, so it can be used for CompareAndSwapVersion and CompareAndDeleteVersion.
+// Version is never 0 for existing key, unless CounterVersion is disabled by CStorageConfig.DisableCounters.
+func (s *CStorage) GetWithVersion(key string) (data []byte, version uint64, hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, ok := s.get(key)
+	if !ok {
+		return nil, 0, false
+	}
+
+	return s.value(n), s.counters.versionOf(n), true
+}
+
+// CompareAndSwapVersion function puts new only if current version of key is equal to version.
+// It returns new version and swapped=true if data is put. It is cheaper than CompareAndSwap for large data.
+// swapped is false if new is not put, same as CompareAndSwap.
+func (s *CStorage) CompareAndSwapVersion(key string, version uint64, new []byte) (newVersion uint64, swapped bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, ok := s.get(key)
+	if !ok || !s.counters.on(CounterVersion) || s.counters.versionOf(n) != version {
+		return 0, false
+	}
+
+	// n is retired if new is heavier than capacity, so version is read from the node put
+	if n, _ = s.put(key, new, n.lifetime, n.sliding); n == nil {
+		return 0, false
+	}
+	return s.counters.versionOf(n), true
+}
+
+// CompareAndDeleteVersion function deletes key only if current version of key is equal to version.
+func (s *CStorage) CompareAndDeleteVersion(key string, version uint64) (deleted bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, ok := s.get(key)
+	if !ok || !s.counters.on(CounterVersion) || s.counters.versionOf(n) != version {
+		return false
+	}
+
+	return s.delete(key)
+}
@@ -0,0 +1,101 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+func TestCompareAndSwap(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.Put("key1", []byte("1"))
+	if cache.CompareAndSwap("key1", []byte("2"), []byte("3")) {
+		t.Error("data is not 2, it should not be swapped")
+	}
+	if !cache.CompareAndSwap("key1", []byte("1"), []byte("3")) {
+		t.Error("data is 1, it should be swapped")
+	}
+	if cache.CompareAndDelete("key1", []byte("1")) {
+		t.Error("data is not 1, it should not be deleted")
+	}
+	if !cache.CompareAndDelete("key1", []byte("3")) {
+		t.Error("data is 3, it should be deleted")
+	}
+}
+
+func TestCompareAndSwapVersion(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.Put("key1", []byte("1"))
+	_, version, _ := cache.GetWithVersion("key1")
+
+	newVersion, ok := cache.CompareAndSwapVersion("key1", version, []byte("2"))
+	if !ok || newVersion == version {
+		t.Error("version is same, it should be swapped with new version")
+	}
+	if _, ok := cache.CompareAndSwapVersion("key1", version, []byte("3")); ok {
+		t.Error("version is stale, it should not be swapped")
+	}
+	if cache.CompareAndDeleteVersion("key1", version) {
+		t.Error("version is stale, it should not be deleted")
+	}
+	if !cache.CompareAndDeleteVersion("key1", newVersion) {
+		t.Error("version is same, it should be deleted")
+	}
+}
+
+func TestCompareAndSwapKeepsTtl(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock}
+	cache := New(config)
+
+	cache.PutWithTtl("key1", []byte("1"), time.Second)
+	if !cache.CompareAndSwap("key1", []byte("1"), []byte("2")) {
+		t.Fatal("data is 1, it should be swapped")
+	}
+	if remaining, _ := cache.Ttl("key1"); remaining != time.Second {
+		t.Errorf("ttl of the key should be kept, got %v", remaining)
+	}
+	_, version, _ := cache.GetWithVersion("key1")
+	cache.CompareAndSwapVersion("key1", version, []byte("3"))
+	if remaining, _ := cache.Ttl("key1"); remaining != time.Second {
+		t.Errorf("ttl of the key should be kept by version, got %v", remaining)
+	}
+
+	cache.PutSliding("key2", []byte("1"))
+	cache.CompareAndSwap("key2", []byte("1"), []byte("2"))
+	clock.Advance(ttl / 2)
+	cache.Get("key2")
+	clock.Advance(ttl / 2)
+	if _, hit := cache.Get("key2"); !hit {
+		t.Error("key2 should be kept sliding")
+	}
+}
+
+func TestCompareAndSwapVersionHeavierThanCapacity(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Weigher: func(key string, data []byte) int64 {
+		return int64(len(data))
+	}}
+	cache := New(config)
+
+	cache.Put("key1", []byte("1"))
+	_, version, _ := cache.GetWithVersion("key1")
+	if newVersion, swapped := cache.CompareAndSwapVersion("key1", version, []byte("12345678901")); swapped || newVersion != 0 {
+		t.Errorf("data heavier than capacity should not be swapped, got %d %v", newVersion, swapped)
+	}
+	if _, hit := cache.Get("key1"); hit {
+		t.Error("key1 is evicted by heavy data")
+	}
+}
@@ -0,0 +1,49 @@
+package cstorage
+
+import (
+	"hash/crc32"
+	"sync/atomic"
+)
+
+// Checksum is mode of integrity verification of data, which is set by CStorageConfig.Checksum.
+// Checksum of data is computed when data is put, and verified on Get. If it doesn't match, data is treated as corrupted;
+// key is removed, Get misses, and Stats.Corrupted is counted. It catches bugs such as caller modifying slice returned by Get
+// or reusing buffer given to Put, and memory corruption.
+type Checksum int
+
+const (
+	// ChecksumOff doesn't compute checksum. It is the default.
+	ChecksumOff Checksum = iota
+	// ChecksumAlways verifies checksum on every Get.
+	ChecksumAlways
+	// ChecksumSampled verifies checksum on one of every CStorageConfig.ChecksumSample Gets, to reduce cost for large data.
+	ChecksumSampled
+)
+
+// castagnoli is CRC-32C table, which is accelerated by hardware on most platforms.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// seal computes checksum of data of node. Caller should hold the mutex.
+func (s *CStorage) seal(n *node) {
+	if s.config.Checksum != ChecksumOff {
+		n.sum = crc32.Checksum(s.stored(n), castagnoli)
+	}
+}
+
+// intact returns false if data of node doesn't match its checksum. Data is verified only if it is sampled.
+// It is called under read lock as well, so sampling counter is accessed atomically.
+func (s *CStorage) intact(n *node) bool {
+	switch s.config.Checksum {
+	case ChecksumOff:
+		return true
+	case ChecksumSampled:
+		every := int64(s.config.ChecksumSample)
+		if every <= 0 {
+			every = 100
+		}
+		if atomic.AddInt64(&s.reads, 1)%every != 0 {
+			return true
+		}
+	}
+	return crc32.Checksum(s.stored(n), castagnoli) == n.sum
+}
@@ -0,0 +1,52 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChecksum(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+
+	for _, policy := range []Policy{PolicyLRU, PolicySIEVE} {
+		config := CStorageConfig{Ttl: ttl, Capacity: capacity, Policy: policy, Checksum: ChecksumAlways, ZeroCopy: true}
+		cache := New(config)
+
+		buf := []byte("data")
+		cache.Put("key", buf)
+		if _, hit := cache.Get("key"); !hit {
+			t.Fatal("intact data should be hit")
+		}
+
+		// caller reuses buffer given to Put, which is only possible with ZeroCopy
+		buf[0] = 'X'
+		if _, hit := cache.Get("key"); hit {
+			t.Errorf("policy %d: corrupted data should be miss", policy)
+		}
+		if st := cache.Stats(); st.Corrupted != 1 || st.Size != 0 {
+			t.Errorf("policy %d: corrupted key should be removed, got %+v", policy, st)
+		}
+	}
+}
+
+func TestChecksumSampled(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Checksum: ChecksumSampled, ChecksumSample: 4, ZeroCopy: true}
+	cache := New(config)
+
+	buf := []byte("data")
+	cache.Put("key", buf)
+	buf[0] = 'X'
+
+	var hits int
+	for i := 0; i < 4; i++ {
+		if _, hit := cache.Get("key"); hit {
+			hits++
+		}
+	}
+	if hits != 3 {
+		t.Errorf("corruption should be found by 4th get, got %d hits", hits)
+	}
+}
@@ -0,0 +1,479 @@
+// Package client provides Client which shards keys across multiple remote cstorage servers, so the cache can be scaled horizontally.
+// Keys are placed on servers by consistent hash ring with virtual nodes, so adding or removing a server only moves about 1/N of keys.
+// Options.Placement can place related keys(e.g. of a tenant) together, so GetMulti of them is served by one server.
+// With Options.Copies, each key is kept on several servers, and reads prefer servers in the zone of the caller, falling back to the others on failure.
+// With Options.SlowStart, share of keys of newly added server is ramped up gradually, so its cold cache doesn't spike load of backend.
+// With Options.ReadRepair, some reads compare every copy, return the newest one and repair stale copies in background.
+//
+// Each server is reached through Node. HTTPNode speaks REST API of httpapi(e.g. cmd/cstorage-server),
+// and Backend of tiered/redis satisfies Node as well, for servers speaking Redis protocol.
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+// ErrNoNodes is returned when Client has no node to route key.
+var ErrNoNodes = errors.New("client: no nodes")
+
+// Node interface is a remote cstorage server. Implementations should be safe for concurrent use.
+type Node interface {
+	Get(ctx context.Context, key string) (data []byte, hit bool, err error)
+	Put(ctx context.Context, key string, data []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// StampedNode interface is Node which keeps hybrid logical clock timestamp of data(see cstorage.CStorageConfig.HLC), so the newest of copies
+// can be told and stale copies can be repaired by read repair. PutStamped puts data with stamp, unless the node has newer data of key.
+type StampedNode interface {
+	Node
+	GetStamped(ctx context.Context, key string) (data []byte, stamp cstorage.Timestamp, hit bool, err error)
+	PutStamped(ctx context.Context, key string, data []byte, stamp cstorage.Timestamp) error
+}
+
+// Options structure is configuration of Client.
+// - Replicas: number of points of each node on the ring, DefaultReplicas if 0
+// - Placement: optional function which returns placement key of key, which is hashed on the ring instead of key, so keys of the same placement key are on the same node(e.g. tenant of "tenant/user" keys). Key itself is hashed if it returns empty string
+// - Copies: number of nodes each key is stored on, 1 if 0. Copies are the node of key and the next nodes on the ring(see Ring.GetN)
+// - Zone: availability zone of the caller(e.g. from instance metadata). Reads go to copies on nodes of the same zone first, and to the other zones only when they fail
+// - SlowStart: duration over which node added after Client started routing keys is ramped up from 1 point to Replicas points on the ring, so cold node gets small share of keys first and misses don't spike load of backend. Nodes are added with full share if 0
+// - Clock: source of current time for SlowStart, time.Now if nil
+// - ReadRepair: fraction of Gets which read every copy of key, from 0 to 1. The newest copy is returned, and stale or missing copies are repaired in background. It works only if Copies is more than 1 and nodes are StampedNode
+type Options struct {
+	Replicas   int
+	Placement  func(key string) string
+	Copies     int
+	Zone       string
+	SlowStart  time.Duration
+	Clock      cstorage.Clock
+	ReadRepair float64
+}
+
+// Stats structure is counters of Client.
+// - LocalReads, RemoteReads: reads served by nodes in Options.Zone, and by nodes in other zones
+// - Fallbacks: reads retried on next copy because a node failed
+// - Warming: progress of ramp of nodes in SlowStart, from 0 to 1
+// - Divergences: Gets of ReadRepair which found copies of key differ
+// - Repairs, RepairErrors: stale copies repaired, and those which failed
+type Stats struct {
+	LocalReads   int64
+	RemoteReads  int64
+	Fallbacks    int64
+	Warming      map[string]float64
+	Divergences  int64
+	Repairs      int64
+	RepairErrors int64
+}
+
+// Client structure routes each key to Nodes by Ring. It is safe for concurrent use, and nodes can be added or removed while it is used.
+// Keys which moved by Add or Remove simply miss on their new node, as for any cache-aside usage.
+type Client struct {
+	options Options
+
+	mutex sync.RWMutex
+	ring  *Ring
+	nodes map[string]Node
+	zones map[string]string
+	stats Stats
+
+	// warming has when each node in SlowStart was added, and ring is ramped again at nextRamp.
+	// Nodes added before routing is 1 are added with full share, since they all start cold anyway
+	routing  int32
+	warming  map[string]time.Time
+	nextRamp time.Time
+}
+
+// target is a node which has copy of key.
+type target struct {
+	name  string
+	node  Node
+	local bool
+}
+
+// New function returns Client without nodes.
+func New(options Options) *Client {
+	if options.Copies <= 0 {
+		options.Copies = 1
+	}
+	return &Client{
+		options: options,
+		ring:    NewRing(options.Replicas),
+		nodes:   make(map[string]Node),
+		zones:   make(map[string]string),
+		warming: make(map[string]time.Time),
+	}
+}
+
+// Add function adds node with name, which decides its position on the ring. If name is already added, its node is replaced without moving keys.
+// If Options.SlowStart is set and Client has already routed keys, share of keys of the node is ramped up over SlowStart.
+func (c *Client) Add(name string, node Node) {
+	c.AddInZone(name, "", node)
+}
+
+// AddInZone function is same as Add, but node is in zone, so callers in zone read from it before nodes of other zones.
+func (c *Client) AddInZone(name, zone string, node Node) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, found := c.nodes[name]
+	c.nodes[name] = node
+	c.zones[name] = zone
+	if found {
+		return
+	}
+	if c.options.SlowStart > 0 && atomic.LoadInt32(&c.routing) == 1 {
+		now := c.now()
+		c.warming[name] = now
+		c.ring.SetPoints(name, 1)
+		if c.nextRamp.IsZero() || now.Before(c.nextRamp) {
+			c.nextRamp = now
+		}
+		return
+	}
+	c.ring.Add(name)
+}
+
+// Remove function removes node of name, and returns it so the caller can close it. Keys of the node move to the others.
+func (c *Client) Remove(name string) (Node, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	node, ok := c.nodes[name]
+	if !ok {
+		return nil, false
+	}
+	delete(c.nodes, name)
+	delete(c.zones, name)
+	delete(c.warming, name)
+	c.ring.Remove(name)
+	return node, true
+}
+
+// Nodes function returns names of nodes in lexical order.
+func (c *Client) Nodes() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.ring.Nodes()
+}
+
+// Locate function returns name and Node which key belongs to, which has the first copy of key.
+func (c *Client) Locate(key string) (string, Node, error) {
+	c.startRouting()
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	name, ok := c.ring.Get(c.placement(key))
+	if !ok {
+		return "", nil, ErrNoNodes
+	}
+	return name, c.nodes[name], nil
+}
+
+// Stats function returns counters of Client.
+func (c *Client) Stats() Stats {
+	c.ramp()
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	warming := make(map[string]float64, len(c.warming))
+	for name, added := range c.warming {
+		warming[name] = c.progress(added, c.now())
+	}
+	return Stats{
+		LocalReads:   atomic.LoadInt64(&c.stats.LocalReads),
+		RemoteReads:  atomic.LoadInt64(&c.stats.RemoteReads),
+		Fallbacks:    atomic.LoadInt64(&c.stats.Fallbacks),
+		Warming:      warming,
+		Divergences:  atomic.LoadInt64(&c.stats.Divergences),
+		Repairs:      atomic.LoadInt64(&c.stats.Repairs),
+		RepairErrors: atomic.LoadInt64(&c.stats.RepairErrors),
+	}
+}
+
+// Get function gets data of key from the nearest node which has copy of key. If the node fails, the next one is tried.
+// Some of Gets read every copy instead, if Options.ReadRepair is set.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	targets, err := c.route(key)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(targets) > 1 && c.options.ReadRepair > 0 && rand.Float64() < c.options.ReadRepair {
+		if nodes, ok := stamped(targets); ok {
+			return c.getRepair(ctx, key, targets, nodes)
+		}
+	}
+	return c.get(ctx, key, targets)
+}
+
+// GetMulti function gets data of keys, and returns data of keys which hit. Keys are grouped by their node, and nodes are asked concurrently,
+// so keys placed together by Placement cost requests to only one node. If a key fails on every copy, the first error is returned with hits of the others.
+func (c *Client) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	routes := make(map[string][]target, len(keys))
+	groups := make(map[string][]string)
+	for _, key := range keys {
+		targets, err := c.route(key)
+		if err != nil {
+			return nil, err
+		}
+		routes[key] = targets
+		groups[targets[0].name] = append(groups[targets[0].name], key)
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	var first error
+	hits := make(map[string][]byte, len(keys))
+	for _, keys := range groups {
+		wg.Add(1)
+		go func(keys []string) {
+			defer wg.Done()
+			for _, key := range keys {
+				data, hit, err := c.get(ctx, key, routes[key])
+				mutex.Lock()
+				if err != nil && first == nil {
+					first = err
+				}
+				if hit {
+					hits[key] = data
+				}
+				mutex.Unlock()
+			}
+		}(keys)
+	}
+	wg.Wait()
+	return hits, first
+}
+
+// Put function puts data of key to every node which has copy of key. If ttl is not positive, ttl of the node is used.
+// If some of them fail, the first error is returned, and the others have data.
+func (c *Client) Put(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	targets, err := c.route(key)
+	if err != nil {
+		return err
+	}
+	return each(targets, func(node Node) error { return node.Put(ctx, key, data, ttl) })
+}
+
+// Delete function deletes key from every node which has copy of key.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	targets, err := c.route(key)
+	if err != nil {
+		return err
+	}
+	return each(targets, func(node Node) error { return node.Delete(ctx, key) })
+}
+
+// placement returns key which is hashed on the ring for key.
+func (c *Client) placement(key string) string {
+	if c.options.Placement != nil {
+		if p := c.options.Placement(key); p != "" {
+			return p
+		}
+	}
+	return key
+}
+
+// route returns nodes which have copy of key, nodes in Options.Zone first and in order on the ring otherwise.
+func (c *Client) route(key string) ([]target, error) {
+	c.startRouting()
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	names := c.ring.GetN(c.placement(key), c.options.Copies)
+	if len(names) == 0 {
+		return nil, ErrNoNodes
+	}
+	targets := make([]target, len(names))
+	for i, name := range names {
+		targets[i] = target{name: name, node: c.nodes[name], local: c.zones[name] == c.options.Zone}
+	}
+	sort.SliceStable(targets, func(i, j int) bool { return targets[i].local && !targets[j].local })
+	return targets, nil
+}
+
+// startRouting marks that Client started routing keys, and ramps nodes in SlowStart.
+func (c *Client) startRouting() {
+	if atomic.LoadInt32(&c.routing) == 0 {
+		atomic.StoreInt32(&c.routing, 1)
+	}
+	c.ramp()
+}
+
+// ramp raises points of nodes in SlowStart by their progress. Ring is changed only once per a point of ramp,
+// so requests take the write lock rarely even while nodes are warming.
+func (c *Client) ramp() {
+	c.mutex.RLock()
+	warming, next := len(c.warming) > 0, c.nextRamp
+	c.mutex.RUnlock()
+	if !warming {
+		return
+	}
+	now := c.now()
+	if now.Before(next) {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for name, added := range c.warming {
+		progress := c.progress(added, now)
+		c.ring.SetPoints(name, int(progress*float64(c.ring.replicas)))
+		if progress >= 1 {
+			delete(c.warming, name)
+		}
+	}
+	c.nextRamp = now.Add(c.options.SlowStart / time.Duration(c.ring.replicas))
+}
+
+// progress returns progress of ramp of node added at added, from 0 to 1.
+func (c *Client) progress(added, now time.Time) float64 {
+	progress := float64(now.Sub(added)) / float64(c.options.SlowStart)
+	if progress > 1 {
+		return 1
+	}
+	if progress < 0 {
+		return 0
+	}
+	return progress
+}
+
+func (c *Client) now() time.Time {
+	if c.options.Clock != nil {
+		return c.options.Clock.Now()
+	}
+	return time.Now()
+}
+
+// get gets data of key from targets in order, until one of them answers.
+func (c *Client) get(ctx context.Context, key string, targets []target) (data []byte, hit bool, err error) {
+	for i, t := range targets {
+		if i > 0 {
+			atomic.AddInt64(&c.stats.Fallbacks, 1)
+		}
+		if data, hit, err = t.node.Get(ctx, key); err == nil {
+			if t.local {
+				atomic.AddInt64(&c.stats.LocalReads, 1)
+			} else {
+				atomic.AddInt64(&c.stats.RemoteReads, 1)
+			}
+			return data, hit, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, false, err
+}
+
+// repairTimeout is timeout of repairing a copy, which is done after Get returns.
+const repairTimeout = 10 * time.Second
+
+// reply is answer of a copy to getRepair.
+type reply struct {
+	data  []byte
+	stamp cstorage.Timestamp
+	hit   bool
+	err   error
+}
+
+// newer returns true if r is newer than o. Ties are broken by data, as cstorage.PutWithTimestamp does.
+func (r reply) newer(o reply) bool {
+	if r.hit != o.hit {
+		return r.hit
+	}
+	return o.stamp.Before(r.stamp) || (r.stamp == o.stamp && bytes.Compare(r.data, o.data) > 0)
+}
+
+// stamped returns nodes of targets as StampedNode, or false if some of them are not.
+func stamped(targets []target) ([]StampedNode, bool) {
+	nodes := make([]StampedNode, len(targets))
+	for i, t := range targets {
+		node, ok := t.node.(StampedNode)
+		if !ok {
+			return nil, false
+		}
+		nodes[i] = node
+	}
+	return nodes, true
+}
+
+// getRepair reads every copy of key from nodes of targets, returns the newest one, and repairs the others in background.
+func (c *Client) getRepair(ctx context.Context, key string, targets []target, nodes []StampedNode) ([]byte, bool, error) {
+	replies := make([]reply, len(targets))
+	var wg sync.WaitGroup
+	for i := range nodes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := &replies[i]
+			r.data, r.stamp, r.hit, r.err = nodes[i].GetStamped(ctx, key)
+		}(i)
+	}
+	wg.Wait()
+
+	newest := -1
+	for i, r := range replies {
+		if r.err == nil && (newest < 0 || r.newer(replies[newest])) {
+			newest = i
+		}
+	}
+	if newest < 0 {
+		return nil, false, replies[0].err
+	}
+	if targets[newest].local {
+		atomic.AddInt64(&c.stats.LocalReads, 1)
+	} else {
+		atomic.AddInt64(&c.stats.RemoteReads, 1)
+	}
+	best := replies[newest]
+	if !best.hit {
+		return nil, false, nil
+	}
+
+	// copies of the same data are not divergent even if their timestamps differ, since each node stamps writes by its own clock
+	divergent := false
+	for i, r := range replies {
+		if r.err != nil || (r.hit && bytes.Equal(r.data, best.data)) {
+			continue
+		}
+		divergent = true
+		go c.repair(nodes[i], key, best)
+	}
+	if divergent {
+		atomic.AddInt64(&c.stats.Divergences, 1)
+	}
+	return best.data, true, nil
+}
+
+// repair puts the newest copy of key to node which has stale one.
+func (c *Client) repair(node StampedNode, key string, best reply) {
+	ctx, cancel := context.WithTimeout(context.Background(), repairTimeout)
+	defer cancel()
+	if err := node.PutStamped(ctx, key, best.data, best.stamp); err != nil {
+		atomic.AddInt64(&c.stats.RepairErrors, 1)
+		return
+	}
+	atomic.AddInt64(&c.stats.Repairs, 1)
+}
+
+// each calls f with node of each target concurrently, and returns the first error.
+func each(targets []target, f func(node Node) error) error {
+	if len(targets) == 1 {
+		return f(targets[0].node)
+	}
+	errs := make(chan error, len(targets))
+	for _, t := range targets {
+		go func(node Node) { errs <- f(node) }(t.node)
+	}
+	var first error
+	for range targets {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,289 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/httpapi"
+	"github.com/cocm1324/cstorage/internal/resp/resptest"
+	"github.com/cocm1324/cstorage/testutil"
+	"github.com/cocm1324/cstorage/tiered/redis"
+)
+
+func newServer(t *testing.T) (*cstorage.CStorage, *httptest.Server) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 1000
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := cstorage.New(config)
+	srv := httptest.NewServer(httpapi.NewHandler(cache))
+	t.Cleanup(srv.Close)
+	return cache, srv
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	c := New(Options{})
+	if _, _, err := c.Get(ctx, "a"); err != ErrNoNodes {
+		t.Errorf("expected ErrNoNodes, got %v", err)
+	}
+
+	caches := make(map[string]*cstorage.CStorage)
+	for _, name := range []string{"a", "b", "c"} {
+		cache, srv := newServer(t)
+		caches[name] = cache
+		c.Add(name, NewHTTPNode(srv.URL+"/", srv.Client()))
+	}
+
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("user/%d", i)
+		if err := c.Put(ctx, key, []byte(key), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, cache := range caches {
+		if size := cache.Stats().Size; size < 50 {
+			t.Errorf("keys should be sharded, %s has %d of 300", name, size)
+		}
+	}
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("user/%d", i)
+		name, _, _ := c.Locate(key)
+		if _, hit := caches[name].Get(key); !hit {
+			t.Fatalf("%s should be stored on %s", key, name)
+		}
+		if data, hit, err := c.Get(ctx, key); !hit || err != nil || string(data) != key {
+			t.Fatalf("%s should hit, got %q %v %v", key, data, hit, err)
+		}
+	}
+
+	if err := c.Delete(ctx, "user/1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, "user/1"); err != nil {
+		t.Errorf("deleting missing key should not fail, got %v", err)
+	}
+	if _, hit, err := c.Get(ctx, "user/1"); hit || err != nil {
+		t.Errorf("user/1 should miss, got %v %v", hit, err)
+	}
+
+	onB := 0
+	for i := 2; i < 300; i++ {
+		if name, _, _ := c.Locate(fmt.Sprintf("user/%d", i)); name == "b" {
+			onB++
+		}
+	}
+	if _, ok := c.Remove("b"); !ok {
+		t.Error("b should be removed")
+	}
+	if _, ok := c.Remove("b"); ok {
+		t.Error("b should be removed only once")
+	}
+	hits := 0
+	for i := 2; i < 300; i++ {
+		if _, hit, _ := c.Get(ctx, fmt.Sprintf("user/%d", i)); hit {
+			hits++
+		}
+	}
+	if expected := 298 - onB; hits != expected {
+		t.Errorf("only keys of removed node should miss, expected %d hits, got %d", expected, hits)
+	}
+}
+
+func TestPlacement(t *testing.T) {
+	ctx := context.Background()
+	c := New(Options{Placement: func(key string) string {
+		tenant, _, _ := strings.Cut(key, "/")
+		return tenant
+	}})
+	caches := make(map[string]*cstorage.CStorage)
+	for _, name := range []string{"a", "b", "c"} {
+		cache, srv := newServer(t)
+		caches[name] = cache
+		c.Add(name, NewHTTPNode(srv.URL, srv.Client()))
+	}
+
+	var keys []string
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("tenant-1/user/%d", i)
+		keys = append(keys, key)
+		if err := c.Put(ctx, key, []byte(key), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	name, _, _ := c.Locate("tenant-1")
+	if size := caches[name].Stats().Size; size != 100 {
+		t.Errorf("keys of tenant-1 should be placed together on %s, got %d of 100", name, size)
+	}
+
+	keys = append(keys, "tenant-1/missing")
+	hits, err := c.GetMulti(ctx, keys)
+	if err != nil || len(hits) != 100 || string(hits["tenant-1/user/7"]) != "tenant-1/user/7" {
+		t.Errorf("keys of tenant-1 should hit except missing one, got %d hits %v", len(hits), err)
+	}
+	for other, cache := range caches {
+		if other != name && cache.Stats().Hits+cache.Stats().Misses != 0 {
+			t.Errorf("GetMulti of tenant-1 should not ask %s", other)
+		}
+	}
+}
+
+func TestZones(t *testing.T) {
+	ctx := context.Background()
+	c := New(Options{Copies: 3, Zone: "zone-1"})
+	caches := make(map[string]*cstorage.CStorage)
+	servers := make(map[string]*httptest.Server)
+	for name, zone := range map[string]string{"a": "zone-1", "b": "zone-2", "c": "zone-2"} {
+		cache, srv := newServer(t)
+		caches[name], servers[name] = cache, srv
+		c.AddInZone(name, zone, NewHTTPNode(srv.URL, srv.Client()))
+	}
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("user/%d", i)
+		if err := c.Put(ctx, key, []byte(key), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, cache := range caches {
+		if size := cache.Stats().Size; size != 10 {
+			t.Errorf("every node should have copy of every key, %s has %d", name, size)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if _, hit, err := c.Get(ctx, fmt.Sprintf("user/%d", i)); !hit || err != nil {
+			t.Fatalf("user/%d should hit, got %v %v", i, hit, err)
+		}
+	}
+	if stats := c.Stats(); stats.LocalReads != 10 || stats.RemoteReads != 0 || caches["b"].Stats().Hits+caches["c"].Stats().Hits != 0 {
+		t.Errorf("reads should be served in zone-1, got %+v", stats)
+	}
+
+	servers["a"].Close()
+	for i := 0; i < 10; i++ {
+		if data, hit, err := c.Get(ctx, fmt.Sprintf("user/%d", i)); !hit || err != nil || string(data) != fmt.Sprintf("user/%d", i) {
+			t.Fatalf("user/%d should be read from other zone, got %q %v %v", i, data, hit, err)
+		}
+	}
+	if stats := c.Stats(); stats.RemoteReads != 10 || stats.Fallbacks != 10 {
+		t.Errorf("reads should fall back to zone-2, got %+v", stats)
+	}
+}
+
+func TestSlowStart(t *testing.T) {
+	clock := testutil.NewClock(time.Now())
+	c := New(Options{SlowStart: 10 * time.Minute, Clock: clock})
+	for _, name := range []string{"a", "b", "c"} {
+		c.Add(name, NewHTTPNode("http://"+name, nil))
+	}
+	if stats := c.Stats(); len(stats.Warming) != 0 {
+		t.Errorf("initial nodes should not be warmed, got %v", stats.Warming)
+	}
+	c.Locate("user/0")
+	c.Add("d", NewHTTPNode("http://d", nil))
+	share := func() int {
+		count := 0
+		for i := 0; i < 10000; i++ {
+			if name, _, _ := c.Locate(fmt.Sprintf("user/%d", i)); name == "d" {
+				count++
+			}
+		}
+		return count
+	}
+
+	if count := share(); count > 200 {
+		t.Errorf("cold node should have few keys, got %d", count)
+	}
+	clock.Advance(5 * time.Minute)
+	if count := share(); count < 1000 || count > 2500 {
+		t.Errorf("node should have about half of its share in the middle of ramp, got %d", count)
+	}
+	if progress := c.Stats().Warming["d"]; progress != 0.5 {
+		t.Errorf("progress of d should be 0.5, got %v", progress)
+	}
+	clock.Advance(5 * time.Minute)
+	if count := share(); count < 1500 || count > 3500 {
+		t.Errorf("node should have full share after ramp, got %d", count)
+	}
+	if stats := c.Stats(); len(stats.Warming) != 0 {
+		t.Errorf("d should be warmed, got %v", stats.Warming)
+	}
+}
+
+func TestReadRepair(t *testing.T) {
+	ctx := context.Background()
+	c := New(Options{Copies: 3, ReadRepair: 1})
+	caches := make(map[string]*cstorage.CStorage)
+	for _, name := range []string{"a", "b", "c"} {
+		cache := cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 1000, HLC: true})
+		srv := httptest.NewServer(httpapi.NewHandler(cache))
+		t.Cleanup(srv.Close)
+		caches[name] = cache
+		c.Add(name, NewHTTPNode(srv.URL, srv.Client()))
+	}
+
+	c.Put(ctx, "k", []byte("old"), 0)
+	if data, hit, err := c.Get(ctx, "k"); !hit || err != nil || string(data) != "old" {
+		t.Fatalf("k should hit, got %q %v %v", data, hit, err)
+	}
+	if stats := c.Stats(); stats.Divergences != 0 {
+		t.Errorf("same copies should not diverge, got %+v", stats)
+	}
+
+	// b has newer write which the others missed, and c lost its copy
+	caches["b"].Put("k", []byte("new"))
+	caches["c"].Delete("k")
+	if data, hit, err := c.Get(ctx, "k"); !hit || err != nil || string(data) != "new" {
+		t.Errorf("the newest copy should be returned, got %q %v %v", data, hit, err)
+	}
+	testutil.Eventually(t, func() bool { return c.Stats().Repairs == 2 }, "stale copies should be repaired")
+	for name, cache := range caches {
+		if data, _ := cache.Get("k"); string(data) != "new" {
+			t.Errorf("%s should have the newest copy, got %q", name, data)
+		}
+	}
+	if stats := c.Stats(); stats.Divergences != 1 || stats.RepairErrors != 0 {
+		t.Errorf("divergence should be counted once, got %+v", stats)
+	}
+	c.Get(ctx, "k")
+	if stats := c.Stats(); stats.Divergences != 1 {
+		t.Errorf("repaired copies should not diverge, got %+v", stats)
+	}
+}
+
+func TestHTTPNodeError(t *testing.T) {
+	ctx := context.Background()
+	_, srv := newServer(t)
+	n := NewHTTPNode(srv.URL, nil)
+	if err := n.Put(ctx, "a", []byte("1"), -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+	if _, _, err := n.Get(ctx, "a"); err == nil {
+		t.Error("closed server should fail")
+	}
+}
+
+func TestRedisNode(t *testing.T) {
+	srv, err := resptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	b := redis.New(srv.Addr, 2)
+	defer b.Close()
+
+	ctx := context.Background()
+	c := New(Options{})
+	c.Add("redis", b)
+	if err := c.Put(ctx, "a", []byte("1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if data, hit, err := c.Get(ctx, "a"); !hit || err != nil || string(data) != "1" {
+		t.Errorf("a should hit, got %q %v %v", data, hit, err)
+	}
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/httpapi"
+)
+
+// HTTPNode structure is Node of server serving REST API of httpapi.
+type HTTPNode struct {
+	baseURL string
+	client  *http.Client
+}
+
+var _ StampedNode = (*HTTPNode)(nil)
+
+// NewHTTPNode function returns Node of server at baseURL(e.g. http://cache-1:8080), using client, http.DefaultClient if client is nil.
+func NewHTTPNode(baseURL string, client *http.Client) *HTTPNode {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPNode{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+// Get sends GET /keys/{key}.
+func (n *HTTPNode) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, _, hit, err := n.GetStamped(ctx, key)
+	return data, hit, err
+}
+
+// GetStamped sends GET /keys/{key}, and returns timestamp of data in httpapi.TimestampHeader, zero if it has none.
+func (n *HTTPNode) GetStamped(ctx context.Context, key string) ([]byte, cstorage.Timestamp, bool, error) {
+	resp, err := n.do(ctx, http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, cstorage.Timestamp{}, false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var stamp cstorage.Timestamp
+		if h := resp.Header.Get(httpapi.TimestampHeader); h != "" {
+			if stamp, err = cstorage.ParseTimestamp(h); err != nil {
+				return nil, cstorage.Timestamp{}, false, err
+			}
+		}
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, cstorage.Timestamp{}, false, err
+		}
+		return data, stamp, true, nil
+	case http.StatusNotFound:
+		return nil, cstorage.Timestamp{}, false, nil
+	}
+	return nil, cstorage.Timestamp{}, false, status(resp)
+}
+
+// Put sends PUT /keys/{key}, with ttl parameter if ttl is positive.
+func (n *HTTPNode) Put(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	resp, err := n.do(ctx, http.MethodPut, key, data, ttl, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return status(resp)
+	}
+	return nil
+}
+
+// PutStamped sends PUT /keys/{key} with httpapi.TimestampHeader. Server refusing data older than its own is not an error.
+func (n *HTTPNode) PutStamped(ctx context.Context, key string, data []byte, stamp cstorage.Timestamp) error {
+	resp, err := n.do(ctx, http.MethodPut, key, data, 0, stamp.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusPreconditionFailed {
+		return status(resp)
+	}
+	return nil
+}
+
+// Delete sends DELETE /keys/{key}. Missing key is not an error.
+func (n *HTTPNode) Delete(ctx context.Context, key string) error {
+	resp, err := n.do(ctx, http.MethodDelete, key, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return status(resp)
+	}
+	return nil
+}
+
+func (n *HTTPNode) do(ctx context.Context, method, key string, data []byte, ttl time.Duration, stamp string) (*http.Response, error) {
+	u := n.baseURL + "/keys/" + url.PathEscape(key)
+	if ttl > 0 {
+		u += "?ttl=" + ttl.String()
+	}
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if stamp != "" {
+		req.Header.Set(httpapi.TimestampHeader, stamp)
+	}
+	return n.client.Do(req)
+}
+
+// status returns error of unexpected response, with first line of its body.
+func status(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	msg := strings.SplitN(strings.TrimSpace(string(body)), "\n", 2)[0]
+	return fmt.Errorf("client: %s %s: %s %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, msg)
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/cocm1324/cstorage"
+)
+
+// DefaultReplicas is number of virtual nodes of each node in Ring, when it is not given.
+const DefaultReplicas = 160
+
+// Ring structure is consistent hash ring with virtual nodes. Each node is placed at Replicas points on the ring,
+// and key belongs to the node of the first point at or after hash of the key. When node is added or removed,
+// only keys of its points move, which is about 1/N of keys for N nodes. It is not safe for concurrent use.
+type Ring struct {
+	replicas int
+	points   []uint64
+	owners   map[uint64]string
+	// nodes has number of points of each node, which is replicas unless it is set by SetPoints
+	nodes map[string]int
+}
+
+// NewRing function returns empty Ring which places each node at replicas points, DefaultReplicas if replicas is not positive.
+func NewRing(replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	return &Ring{replicas: replicas, owners: make(map[uint64]string), nodes: make(map[string]int)}
+}
+
+// Add function places nodes on the ring. Nodes already on the ring are ignored.
+func (r *Ring) Add(nodes ...string) {
+	for _, node := range nodes {
+		if _, ok := r.nodes[node]; ok {
+			continue
+		}
+		r.place(node, 0, r.replicas)
+	}
+	r.sort()
+}
+
+// SetPoints function places node at only the first points of its points, between 1 and replicas, adding node if it is not on the ring.
+// Points of a node are always the same, so raising points only moves keys to the node, and node can be ramped up gradually
+// from a small share of keys to its full share.
+func (r *Ring) SetPoints(node string, points int) {
+	if points < 1 {
+		points = 1
+	}
+	if points > r.replicas {
+		points = r.replicas
+	}
+	current, ok := r.nodes[node]
+	if ok && points == current {
+		return
+	}
+	if ok && points < current {
+		r.Remove(node)
+		current = 0
+	}
+	r.place(node, current, points)
+	r.sort()
+}
+
+// Remove function removes node from the ring.
+func (r *Ring) Remove(node string) {
+	if _, ok := r.nodes[node]; !ok {
+		return
+	}
+	delete(r.nodes, node)
+	points := r.points[:0]
+	for _, p := range r.points {
+		if r.owners[p] == node {
+			delete(r.owners, p)
+			continue
+		}
+		points = append(points, p)
+	}
+	r.points = points
+	// points which other nodes lost by collision are taken back
+	for other, n := range r.nodes {
+		r.place(other, 0, n)
+	}
+	r.sort()
+}
+
+// place places node at its points from from to to.
+func (r *Ring) place(node string, from, to int) {
+	r.nodes[node] = to
+	for i := from; i < to; i++ {
+		p := cstorage.KeyHash(node + "#" + strconv.Itoa(i))
+		// on collision, the smaller name wins, so the ring doesn't depend on order of Add
+		if owner, ok := r.owners[p]; ok {
+			if owner < node {
+				continue
+			}
+		} else {
+			r.points = append(r.points, p)
+		}
+		r.owners[p] = node
+	}
+}
+
+func (r *Ring) sort() {
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+}
+
+// Get function returns node of key, or false if the ring is empty.
+func (r *Ring) Get(key string) (node string, ok bool) {
+	if len(r.points) == 0 {
+		return "", false
+	}
+	h := cstorage.KeyHash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]], true
+}
+
+// GetN function returns up to n distinct nodes of key, which are the node of key and the next nodes clockwise on the ring.
+// They are where copies of key are kept when key is replicated on n nodes.
+func (r *Ring) GetN(key string, n int) []string {
+	if len(r.points) == 0 || n <= 0 {
+		return nil
+	}
+	if n > len(r.nodes) {
+		n = len(r.nodes)
+	}
+	h := cstorage.KeyHash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	nodes := make([]string, 0, n)
+	for j := 0; j < len(r.points) && len(nodes) < n; j++ {
+		node := r.owners[r.points[(i+j)%len(r.points)]]
+		if !contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Nodes function returns nodes on the ring in lexical order.
+func (r *Ring) Nodes() []string {
+	nodes := make([]string, 0, len(r.nodes))
+	for node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+func contains(nodes []string, node string) bool {
+	for _, n := range nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
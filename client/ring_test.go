@@ -0,0 +1,135 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	r := NewRing(0)
+	if _, ok := r.Get("a"); ok {
+		t.Error("empty ring should have no node")
+	}
+	r.Add("node-a", "node-b", "node-c")
+	r.Add("node-a")
+	if nodes := r.Nodes(); len(nodes) != 3 || nodes[0] != "node-a" {
+		t.Errorf("unexpected nodes %v", nodes)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 30000; i++ {
+		node, _ := r.Get(fmt.Sprintf("key-%d", i))
+		counts[node]++
+	}
+	for node, count := range counts {
+		if count < 7000 || count > 13000 {
+			t.Errorf("keys should be spread evenly, %s has %d of 30000", node, count)
+		}
+	}
+}
+
+func TestRingMinimalMovement(t *testing.T) {
+	r := NewRing(0)
+	r.Add("node-a", "node-b", "node-c")
+	before := make(map[string]string)
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before[key], _ = r.Get(key)
+	}
+
+	r.Add("node-d")
+	moved := 0
+	for key, node := range before {
+		after, _ := r.Get(key)
+		if after != node {
+			moved++
+			if after != "node-d" {
+				t.Fatalf("%s moved from %s to %s, not to the new node", key, node, after)
+			}
+		}
+	}
+	// about 1/4 of keys should move to the new node
+	if moved < 1500 || moved > 3500 {
+		t.Errorf("expected about 2500 keys to move, got %d", moved)
+	}
+
+	r.Remove("node-d")
+	for key, node := range before {
+		if after, _ := r.Get(key); after != node {
+			t.Fatalf("%s should be back on %s after remove, got %s", key, node, after)
+		}
+	}
+}
+
+func TestRingOrder(t *testing.T) {
+	a, b := NewRing(10), NewRing(10)
+	a.Add("x", "y", "z")
+	b.Add("z", "x")
+	b.Add("y")
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		na, _ := a.Get(key)
+		nb, _ := b.Get(key)
+		if na != nb {
+			t.Fatalf("ring should not depend on order of Add, %s is on %s and %s", key, na, nb)
+		}
+	}
+}
+
+func TestRingGetN(t *testing.T) {
+	r := NewRing(0)
+	if nodes := r.GetN("a", 2); len(nodes) != 0 {
+		t.Errorf("empty ring should have no node, got %v", nodes)
+	}
+	r.Add("node-a", "node-b", "node-c")
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		nodes := r.GetN(key, 2)
+		if first, _ := r.Get(key); len(nodes) != 2 || nodes[0] != first || nodes[1] == first {
+			t.Fatalf("%s should have its node and another one, got %v", key, nodes)
+		}
+	}
+	if nodes := r.GetN("a", 5); len(nodes) != 3 {
+		t.Errorf("copies should be limited by number of nodes, got %v", nodes)
+	}
+}
+
+func TestRingSetPoints(t *testing.T) {
+	r := NewRing(0)
+	r.Add("node-a", "node-b", "node-c")
+	share := func() (map[string]bool, int) {
+		on := make(map[string]bool)
+		for i := 0; i < 10000; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			if node, _ := r.Get(key); node == "node-d" {
+				on[key] = true
+			}
+		}
+		return on, len(on)
+	}
+
+	r.SetPoints("node-d", 1)
+	if _, count := share(); count > 200 {
+		t.Errorf("node of 1 point should have few keys, got %d", count)
+	}
+	r.SetPoints("node-d", DefaultReplicas/2)
+	half, count := share()
+	if count < 1000 || count > 2500 {
+		t.Errorf("node of half points should have about 1/7 of keys, got %d", count)
+	}
+	r.SetPoints("node-d", DefaultReplicas)
+	full, count := share()
+	if count < 1500 || count > 3500 {
+		t.Errorf("node of full points should have about 1/4 of keys, got %d", count)
+	}
+	for key := range half {
+		if !full[key] {
+			t.Fatalf("%s should stay on node-d when points are raised", key)
+		}
+	}
+
+	r.SetPoints("node-d", 1)
+	if _, count := share(); count > 200 {
+		t.Errorf("points should be lowered, got %d keys", count)
+	}
+}
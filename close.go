@@ -0,0 +1,67 @@
+package cstorage
+
+import "errors"
+
+// ErrClosed is returned by functions which return error, after CStorage is closed by Close.
+var ErrClosed = errors.New("cstorage: closed")
+
+// Close function stops internal goroutines of CStorage, and writes snapshot to CStorageConfig.SnapshotPath and SnapshotDir if they are set.
+// Append-only log of CStorageConfig.AOFPath is synced and closed, and error of writing it, if any, is returned.
+// Channels of Subscribe and Watch are closed. After Close, CStorage is empty; Get family functions miss, Put family functions don't put, and functions which return error return ErrClosed.
+// Calling Close again returns ErrClosed.
+func (s *CStorage) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return ErrClosed
+	}
+	s.closed = true
+	if s.done != nil {
+		close(s.done)
+	}
+	for len(s.subscribers) > 0 {
+		s.unsubscribe(s.subscribers[0])
+	}
+	for key, watchers := range s.watchers {
+		for len(watchers) > 0 {
+			watchers = without(watchers, watchers[0])
+		}
+		delete(s.watchers, key)
+	}
+	s.mutex.Unlock()
+
+	s.workers.Wait()
+
+	s.mutex.Lock()
+	var items []item
+	if s.config.SnapshotPath != "" || s.config.SnapshotDir != "" {
+		items = s.items()
+	}
+	s.reset()
+	err := s.closeAOF()
+	s.mutex.Unlock()
+
+	if s.config.SnapshotPath != "" {
+		if serr := s.saveSnapshot(s.config.SnapshotPath, items); err == nil {
+			err = serr
+		}
+	}
+	if s.config.SnapshotDir != "" {
+		if serr := s.saveGeneration(items); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// spawn starts internal goroutine fn. fn should return when done is closed, and Close waits for it. Caller should hold the mutex.
+func (s *CStorage) spawn(fn func(done <-chan struct{})) {
+	if s.done == nil {
+		s.done = make(chan struct{})
+	}
+	s.workers.Add(1)
+	go func(done <-chan struct{}) {
+		defer s.workers.Done()
+		fn(done)
+	}(s.done)
+}
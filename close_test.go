@@ -0,0 +1,91 @@
+package cstorage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClose(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.Put("a", []byte("1"))
+	stopped := make(chan struct{})
+	cache.mutex.Lock()
+	cache.spawn(func(done <-chan struct{}) {
+		<-done
+		close(stopped)
+	})
+	cache.mutex.Unlock()
+
+	if err := cache.Close(); err != nil {
+		t.Fatalf("close should succeed, got %v", err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Errorf("internal goroutine should be stopped by Close")
+	}
+
+	if _, hit := cache.Get("a"); hit {
+		t.Errorf("get should miss after close")
+	}
+	cache.Put("b", []byte("2"))
+	if cache.Size() != 0 {
+		t.Errorf("put should not put after close, got size %d", cache.Size())
+	}
+	if _, err := cache.PutPinned("c", []byte("3")); !errors.Is(err, ErrClosed) {
+		t.Errorf("PutPinned should return ErrClosed, got %v", err)
+	}
+	if _, err := cache.Increment("n", 1); !errors.Is(err, ErrClosed) {
+		t.Errorf("Increment should return ErrClosed, got %v", err)
+	}
+	if err := cache.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("second close should return ErrClosed, got %v", err)
+	}
+}
+
+func TestCloseSnapshot(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, SnapshotPath: path}
+	cache := New(config)
+
+	cache.Put("a", []byte("1"))
+	cache.Put("b", []byte("2"))
+	cache.PutWithTtl("expired", []byte("3"), time.Nanosecond)
+	cache.Get("a")
+	if err := cache.Close(); err != nil {
+		t.Fatalf("close should succeed, got %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("snapshot should be written, got %v", err)
+	}
+	defer f.Close()
+
+	restored := New(CStorageConfig{Ttl: ttl, Capacity: capacity})
+	count, err := restored.ReadSnapshot(f)
+	if err != nil || count != 2 {
+		t.Fatalf("2 keys should be read, got %d, %v", count, err)
+	}
+
+	var keys []string
+	restored.IterateLRU(func(key string, data []byte, expiresAt time.Time) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if len(keys) != 2 || keys[0] != "b" || keys[1] != "a" {
+		t.Errorf("eviction order should be kept, got %v", keys)
+	}
+	if remaining, _ := restored.Ttl("a"); remaining > ttl || remaining < ttl-time.Minute {
+		t.Errorf("remaining ttl should be kept, got %v", remaining)
+	}
+}
@@ -0,0 +1,233 @@
+// Package cluster broadcasts invalidations between instances of a service, so when one instance updates an entity,
+// every instance drops it from its local CStorage. Bus deletes keys locally and publishes them through Transport,
+// and applies invalidations published by other instances.
+//
+// Transports of Redis pub/sub(NewRedis) and NATS(NewNATS) are included, and they speak the protocols directly without client libraries.
+// Messages are delivered at most once by both, so invalidations published while an instance is disconnected are lost;
+// set Options.ClearOnReconnect to clear local cache when subscription is established again.
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/signing"
+)
+
+// ErrClosed is returned by Bus after Close.
+var ErrClosed = errors.New("cluster: closed")
+
+// Transport interface delivers messages to every instance, including the sender. Implementations should be safe for concurrent use.
+// - Publish: sends message to every Subscription
+// - Subscribe: returns Subscription once it is established, so messages published after it returns are received
+type Transport interface {
+	Publish(ctx context.Context, message []byte) error
+	Subscribe(ctx context.Context) (Subscription, error)
+}
+
+// Subscription interface receives messages of Transport.
+// - Receive: blocks until next message, and returns error if subscription is broken or closed
+// - Close: closes subscription, and makes blocked Receive return
+type Subscription interface {
+	Receive() (message []byte, err error)
+	Close() error
+}
+
+// Options structure is configuration of Bus.
+// - Node: id of this instance, which is sent with messages so instance ignores its own. Random if empty.
+// - Keyring: optional signing.Keyring which signs messages, and messages which are not signed by accepted keys are dropped
+// - MaxAge: maximum age of signed message, see signing.Keyring.Open
+// - ClearOnReconnect: if true, local cache is cleared when subscription is established again after it was broken, since invalidations published meanwhile are lost
+// - ReconnectDelay: delay before subscribing again after subscription is broken. 1s if not set.
+// - OnError: optional function called with errors of background subscription, such as broken connection or malformed message
+type Options struct {
+	Node             string
+	Keyring          *signing.Keyring
+	MaxAge           time.Duration
+	ClearOnReconnect bool
+	ReconnectDelay   time.Duration
+	OnError          func(err error)
+}
+
+// message is invalidation sent between instances. Op is "delete" with Keys, or "clear".
+type message struct {
+	Node string   `json:"node"`
+	Op   string   `json:"op"`
+	Keys []string `json:"keys,omitempty"`
+}
+
+// Bus structure is invalidation bus of local CStorage.
+type Bus struct {
+	cache     *cstorage.CStorage
+	transport Transport
+	options   Options
+
+	mutex  sync.Mutex
+	sub    Subscription
+	closed bool
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New function subscribes transport, and returns Bus which applies invalidations of other instances to cache in background until Close.
+// It returns error if the first subscription fails.
+func New(ctx context.Context, cache *cstorage.CStorage, transport Transport, options Options) (*Bus, error) {
+	if options.Node == "" {
+		var id [8]byte
+		rand.Read(id[:])
+		options.Node = hex.EncodeToString(id[:])
+	}
+	if options.ReconnectDelay == 0 {
+		options.ReconnectDelay = time.Second
+	}
+
+	sub, err := transport.Subscribe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	loop, cancel := context.WithCancel(context.Background())
+	b := &Bus{cache: cache, transport: transport, options: options, sub: sub, cancel: cancel, done: make(chan struct{})}
+	go b.receive(loop, sub)
+	return b, nil
+}
+
+// Delete function deletes keys from local cache, and publishes them to other instances.
+// Local cache is changed even if publishing fails, and the error is returned.
+func (b *Bus) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		b.cache.Delete(key)
+	}
+	return b.publish(ctx, message{Op: "delete", Keys: keys})
+}
+
+// Clear function clears local cache, and publishes it to other instances.
+func (b *Bus) Clear(ctx context.Context) error {
+	b.cache.Clear()
+	return b.publish(ctx, message{Op: "clear"})
+}
+
+// Close function stops receiving invalidations. It doesn't close transport.
+func (b *Bus) Close() error {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return ErrClosed
+	}
+	b.closed = true
+	b.cancel()
+	sub := b.sub
+	b.mutex.Unlock()
+
+	if sub != nil {
+		sub.Close()
+	}
+	<-b.done
+	return nil
+}
+
+func (b *Bus) publish(ctx context.Context, m message) error {
+	b.mutex.Lock()
+	closed := b.closed
+	b.mutex.Unlock()
+	if closed {
+		return ErrClosed
+	}
+
+	m.Node = b.options.Node
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if b.options.Keyring != nil {
+		payload = b.options.Keyring.Seal(payload)
+	}
+	return b.transport.Publish(ctx, payload)
+}
+
+// receive applies messages of sub, and subscribes again when it is broken, until ctx is canceled.
+func (b *Bus) receive(ctx context.Context, sub Subscription) {
+	defer close(b.done)
+	for {
+		for {
+			payload, err := sub.Receive()
+			if err != nil {
+				if ctx.Err() == nil {
+					b.error(err)
+				}
+				break
+			}
+			b.apply(payload)
+		}
+		sub.Close()
+
+		for sub = nil; sub == nil; {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(b.options.ReconnectDelay):
+			}
+			var err error
+			if sub, err = b.transport.Subscribe(ctx); err != nil {
+				b.error(err)
+			}
+		}
+		if !b.swap(sub) {
+			sub.Close()
+			return
+		}
+		if b.options.ClearOnReconnect {
+			b.cache.Clear()
+		}
+	}
+}
+
+// swap replaces current subscription with sub, so Close can close it. It returns false if Bus is closed meanwhile.
+func (b *Bus) swap(sub Subscription) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return false
+	}
+	b.sub = sub
+	return true
+}
+
+// apply applies message of other instance to local cache.
+func (b *Bus) apply(payload []byte) {
+	if b.options.Keyring != nil {
+		var err error
+		if payload, err = b.options.Keyring.Open(payload, b.options.MaxAge); err != nil {
+			b.error(err)
+			return
+		}
+	}
+
+	var m message
+	if err := json.Unmarshal(payload, &m); err != nil {
+		b.error(err)
+		return
+	}
+	if m.Node == b.options.Node {
+		return
+	}
+	switch m.Op {
+	case "delete":
+		for _, key := range m.Keys {
+			b.cache.Delete(key)
+		}
+	case "clear":
+		b.cache.Clear()
+	}
+}
+
+func (b *Bus) error(err error) {
+	if b.options.OnError != nil {
+		b.options.OnError(err)
+	}
+}
@@ -0,0 +1,187 @@
+package cluster
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/signing"
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+// hub is in-memory Transport.
+type hub struct {
+	mutex sync.Mutex
+	subs  map[*memorySubscription]bool
+}
+
+func newHub() *hub {
+	return &hub{subs: make(map[*memorySubscription]bool)}
+}
+
+func (h *hub) Publish(ctx context.Context, message []byte) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for sub := range h.subs {
+		sub.messages <- message
+	}
+	return nil
+}
+
+func (h *hub) Subscribe(ctx context.Context) (Subscription, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	sub := &memorySubscription{h: h, messages: make(chan []byte, 16), closed: make(chan struct{})}
+	h.subs[sub] = true
+	return sub, nil
+}
+
+// disconnect breaks every subscriptions.
+func (h *hub) disconnect() {
+	h.mutex.Lock()
+	subs := h.subs
+	h.subs = make(map[*memorySubscription]bool)
+	h.mutex.Unlock()
+	for sub := range subs {
+		sub.Close()
+	}
+}
+
+func (h *hub) len() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.subs)
+}
+
+type memorySubscription struct {
+	h        *hub
+	messages chan []byte
+	closed   chan struct{}
+	once     sync.Once
+}
+
+func (s *memorySubscription) Receive() ([]byte, error) {
+	select {
+	case m := <-s.messages:
+		return m, nil
+	case <-s.closed:
+		return nil, io.EOF
+	}
+}
+
+func (s *memorySubscription) Close() error {
+	s.once.Do(func() {
+		close(s.closed)
+		s.h.mutex.Lock()
+		delete(s.h.subs, s)
+		s.h.mutex.Unlock()
+	})
+	return nil
+}
+
+func newCache() *cstorage.CStorage {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity}
+	return cstorage.New(config)
+}
+
+// eventually waits until cond is true.
+
+// testBus runs common test of two instances on transport.
+func testBus(t *testing.T, transport Transport, options Options) {
+	ctx := context.Background()
+	a, b := newCache(), newCache()
+	busA, err := New(ctx, a, transport, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busA.Close()
+	busB, err := New(ctx, b, transport, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busB.Close()
+
+	for _, c := range []*cstorage.CStorage{a, b} {
+		c.Put("user:1", []byte("old"))
+		c.Put("user:2", []byte("old"))
+		c.Put("user:3", []byte("old"))
+	}
+
+	if err := busA.Delete(ctx, "user:1", "user:2"); err != nil {
+		t.Fatal(err)
+	}
+	testutil.Eventually(t, func() bool { return b.Stats().Size == 1 }, "keys should be deleted from other instance")
+	if _, hit := a.Get("user:1"); hit {
+		t.Error("key should be deleted from local cache")
+	}
+
+	if err := busB.Clear(ctx); err != nil {
+		t.Fatal(err)
+	}
+	testutil.Eventually(t, func() bool { return a.Stats().Size == 0 }, "other instance should be cleared")
+}
+
+func TestBus(t *testing.T) {
+	testBus(t, newHub(), Options{})
+}
+
+func TestBusSigned(t *testing.T) {
+	keyring := signing.NewKeyring(signing.Key{ID: "k1", Secret: []byte("secret")})
+	testBus(t, newHub(), Options{Keyring: keyring, MaxAge: time.Minute})
+}
+
+func TestBusRejectsUnsigned(t *testing.T) {
+	ctx := context.Background()
+	h := newHub()
+	errs := make(chan error, 1)
+	keyring := signing.NewKeyring(signing.Key{ID: "k1", Secret: []byte("secret")})
+	c := newCache()
+	bus, err := New(ctx, c, h, Options{Keyring: keyring, OnError: func(err error) { errs <- err }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bus.Close()
+
+	c.Put("a", []byte("1"))
+	h.Publish(ctx, []byte(`{"node":"other","op":"clear"}`))
+	if err := <-errs; err == nil {
+		t.Error("unsigned message should be rejected")
+	}
+	if c.Stats().Size != 1 {
+		t.Error("unsigned message should not be applied")
+	}
+}
+
+func TestBusReconnect(t *testing.T) {
+	ctx := context.Background()
+	h := newHub()
+	c := newCache()
+	bus, err := New(ctx, c, h, Options{ClearOnReconnect: true, ReconnectDelay: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Put("a", []byte("1"))
+	h.disconnect()
+	testutil.Eventually(t, func() bool { return h.len() == 1 }, "bus should subscribe again")
+	testutil.Eventually(t, func() bool { return c.Stats().Size == 0 }, "cache should be cleared on reconnect")
+
+	c.Put("b", []byte("2"))
+	h.Publish(ctx, []byte(`{"node":"other","op":"delete","keys":["b"]}`))
+	testutil.Eventually(t, func() bool { _, hit := c.Get("b"); return !hit }, "messages should be applied after reconnect")
+
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if h.len() != 0 {
+		t.Error("subscription should be closed by Close")
+	}
+	if err := bus.Delete(ctx, "a"); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
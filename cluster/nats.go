@@ -0,0 +1,215 @@
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATS structure is Transport of NATS core publish/subscribe on subject. It speaks the text protocol of NATS directly,
+// without authentication and TLS. Messages are published through single connection which is dialed again when it is broken,
+// and each Subscription has its own connection.
+type NATS struct {
+	addr    string
+	subject string
+
+	mutex sync.Mutex
+	conn  *natsConn
+}
+
+var _ Transport = (*NATS)(nil)
+
+// NewNATS function returns Transport of NATS server at addr(host:port) on subject.
+func NewNATS(addr, subject string) *NATS {
+	return &NATS{addr: addr, subject: subject}
+}
+
+// Publish sends PUB, and waits until the server processes it by PING, so error of the server is returned.
+// If the connection is broken(e.g. closed by the server while idle), it is dialed again once.
+func (n *NATS) Publish(ctx context.Context, message []byte) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	for retry := true; ; retry = false {
+		fresh := n.conn == nil
+		if fresh {
+			conn, err := dialNATS(ctx, n.addr)
+			if err != nil {
+				return err
+			}
+			n.conn = conn
+		}
+		err := n.conn.publish(ctx, n.subject, message)
+		if err == nil {
+			return nil
+		}
+		n.conn.close()
+		n.conn = nil
+		if fresh || !retry {
+			return err
+		}
+	}
+}
+
+// Subscribe sends SUB on new connection, and returns after the server confirms it by PING.
+func (n *NATS) Subscribe(ctx context.Context) (Subscription, error) {
+	conn, err := dialNATS(ctx, n.addr)
+	if err != nil {
+		return nil, err
+	}
+	conn.setDeadline(ctx)
+	fmt.Fprintf(conn.w, "SUB %s 1\r\n", n.subject)
+	if err := conn.ping(); err != nil {
+		conn.close()
+		return nil, err
+	}
+	conn.conn.SetDeadline(time.Time{})
+	return &natsSubscription{conn: conn}, nil
+}
+
+// Close closes connection of publishing.
+func (n *NATS) Close() error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.conn != nil {
+		n.conn.close()
+		n.conn = nil
+	}
+	return nil
+}
+
+type natsSubscription struct {
+	conn *natsConn
+}
+
+// Receive returns payload of next MSG, answering PING of the server meanwhile.
+func (s *natsSubscription) Receive() ([]byte, error) {
+	for {
+		payload, err := s.conn.next()
+		if err != nil || payload != nil {
+			return payload, err
+		}
+	}
+}
+
+func (s *natsSubscription) Close() error {
+	return s.conn.close()
+}
+
+// natsConn is connection to NATS server.
+type natsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+	once sync.Once
+}
+
+// dialNATS connects to addr, reads INFO of the server and sends CONNECT.
+func dialNATS(ctx context.Context, addr string) (*natsConn, error) {
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &natsConn{conn: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+	conn.setDeadline(ctx)
+
+	line, err := conn.line()
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.close()
+		if err == nil {
+			err = fmt.Errorf("cluster: expected INFO from NATS server, got %q", line)
+		}
+		return nil, err
+	}
+	conn.w.WriteString("CONNECT {\"verbose\":false,\"pedantic\":false,\"name\":\"cstorage\"}\r\n")
+	return conn, nil
+}
+
+// setDeadline sets deadline of ctx to the connection, or clears it if ctx has no deadline.
+func (c *natsConn) setDeadline(ctx context.Context) {
+	deadline, _ := ctx.Deadline()
+	c.conn.SetDeadline(deadline)
+}
+
+func (c *natsConn) publish(ctx context.Context, subject string, message []byte) error {
+	c.setDeadline(ctx)
+	fmt.Fprintf(c.w, "PUB %s %d\r\n", subject, len(message))
+	c.w.Write(message)
+	c.w.WriteString("\r\n")
+	return c.ping()
+}
+
+// ping sends buffered commands with PING, and waits PONG, so commands before it are processed by the server.
+func (c *natsConn) ping() error {
+	c.w.WriteString("PING\r\n")
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	for {
+		line, err := c.line()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			c.w.WriteString("PONG\r\n")
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New("cluster: NATS " + strings.TrimSpace(line[1:]))
+		}
+	}
+}
+
+// next reads next protocol message. It returns payload of MSG, or nil if it was other message.
+func (c *natsConn) next() ([]byte, error) {
+	line, err := c.line()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasPrefix(line, "MSG "):
+		// MSG <subject> <sid> [reply-to] <size>
+		fields := strings.Fields(line)
+		size, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil || len(fields) < 4 || size < 0 {
+			return nil, fmt.Errorf("cluster: malformed NATS message %q", line)
+		}
+		payload := make([]byte, size+2)
+		if _, err := io.ReadFull(c.r, payload); err != nil {
+			return nil, err
+		}
+		return payload[:size], nil
+	case line == "PING":
+		c.w.WriteString("PONG\r\n")
+		return nil, c.w.Flush()
+	case strings.HasPrefix(line, "-ERR"):
+		return nil, errors.New("cluster: NATS " + strings.TrimSpace(line[1:]))
+	}
+	return nil, nil
+}
+
+// line reads a line without CRLF.
+func (c *natsConn) line() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (c *natsConn) close() error {
+	var err error
+	c.once.Do(func() {
+		err = c.conn.Close()
+	})
+	return err
+}
@@ -0,0 +1,94 @@
+package cluster
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// natsServer understands CONNECT, SUB, PUB and PING of NATS protocol.
+type natsServer struct {
+	addr     string
+	listener net.Listener
+	mutex    sync.Mutex
+	subs     map[string][]natsClient
+}
+
+type natsClient struct {
+	conn net.Conn
+	sid  string
+}
+
+func newNATSServer(t *testing.T) *natsServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &natsServer{addr: l.Addr().String(), listener: l, subs: make(map[string][]natsClient)}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(c)
+		}
+	}()
+	return s
+}
+
+func (s *natsServer) write(c net.Conn, text string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	io.WriteString(c, text)
+}
+
+func (s *natsServer) handle(c net.Conn) {
+	defer c.Close()
+	s.write(c, "INFO {\"server_id\":\"test\"}\r\n")
+	r := bufio.NewReader(c)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "SUB":
+			s.mutex.Lock()
+			s.subs[fields[1]] = append(s.subs[fields[1]], natsClient{conn: c, sid: fields[2]})
+			s.mutex.Unlock()
+		case "PUB":
+			size, _ := strconv.Atoi(fields[2])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			s.mutex.Lock()
+			subs := s.subs[fields[1]]
+			s.mutex.Unlock()
+			for _, sub := range subs {
+				s.write(sub.conn, fmt.Sprintf("MSG %s %s %d\r\n%s", fields[1], sub.sid, size, payload))
+			}
+		case "PING":
+			s.write(c, "PONG\r\n")
+		}
+	}
+}
+
+func TestNATS(t *testing.T) {
+	srv := newNATSServer(t)
+	defer srv.listener.Close()
+
+	transport := NewNATS(srv.addr, "invalidations")
+	defer transport.Close()
+	testBus(t, transport, Options{})
+}
@@ -0,0 +1,93 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cocm1324/cstorage/internal/resp"
+)
+
+// Redis structure is Transport of Redis pub/sub on channel. Messages are published through pooled connections,
+// and each Subscription has its own connection, since subscribed connection can't run other commands.
+type Redis struct {
+	addr    string
+	channel string
+	client  *resp.Client
+}
+
+var _ Transport = (*Redis)(nil)
+
+// NewRedis function returns Transport of Redis server at addr(host:port) on channel.
+func NewRedis(addr, channel string) *Redis {
+	return &Redis{addr: addr, channel: channel, client: resp.NewClient(addr, 2)}
+}
+
+// Publish runs PUBLISH.
+func (r *Redis) Publish(ctx context.Context, message []byte) error {
+	_, err := r.client.Do(ctx, "PUBLISH", r.channel, string(message))
+	return err
+}
+
+// Subscribe runs SUBSCRIBE on new connection, and returns after the server confirms it.
+func (r *Redis) Subscribe(ctx context.Context) (Subscription, error) {
+	conn, err := resp.Dial(ctx, r.addr)
+	if err != nil {
+		return nil, err
+	}
+	v, err := conn.Do(ctx, "SUBSCRIBE", r.channel)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if kind, _ := reply(v); kind != "subscribe" {
+		conn.Close()
+		return nil, fmt.Errorf("cluster: unexpected reply of SUBSCRIBE %v", v)
+	}
+	// deadline of ctx is only for subscribing, messages are waited without deadline
+	conn.SetDeadline(time.Time{})
+	return &redisSubscription{conn: conn}, nil
+}
+
+// Close closes idle connections of publishing. Subscriptions should be closed by themselves.
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+type redisSubscription struct {
+	conn *resp.Conn
+	once sync.Once
+}
+
+// Receive returns payload of next "message" push, skipping other pushes.
+func (s *redisSubscription) Receive() ([]byte, error) {
+	for {
+		v, err := s.conn.Receive()
+		if err != nil {
+			return nil, err
+		}
+		if kind, payload := reply(v); kind == "message" {
+			return payload, nil
+		}
+	}
+}
+
+func (s *redisSubscription) Close() error {
+	var err error
+	s.once.Do(func() {
+		err = s.conn.Close()
+	})
+	return err
+}
+
+// reply returns kind and payload of push of subscribed connection, e.g. ["message", channel, payload].
+func reply(v interface{}) (kind string, payload []byte) {
+	arr, _ := v.([]interface{})
+	if len(arr) != 3 {
+		return "", nil
+	}
+	k, _ := arr[0].([]byte)
+	payload, _ = arr[2].([]byte)
+	return string(k), payload
+}
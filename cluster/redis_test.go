@@ -0,0 +1,19 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/cocm1324/cstorage/internal/resp/resptest"
+)
+
+func TestRedis(t *testing.T) {
+	srv, err := resptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	transport := NewRedis(srv.Addr, "invalidations")
+	defer transport.Close()
+	testBus(t, transport, Options{})
+}
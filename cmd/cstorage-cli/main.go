@@ -0,0 +1,51 @@
+// Command cstorage-cli is command line tool for CStorage.
+//
+// Usage:
+//
+//	cstorage-cli validate-config <config.json>
+//	cstorage-cli dry-run <config.json>
+//
+// validate-config checks the config file itself, and dry-run additionally checks listener and persistence paths
+// as the daemon would at start, without serving.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cocm1324/cstorage/internal/daemon"
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		usage()
+	}
+
+	c, err := daemon.Load(os.Args[2])
+	if err != nil {
+		fail(err)
+	}
+
+	switch os.Args[1] {
+	case "validate-config":
+		err = c.Validate()
+	case "dry-run":
+		err = c.DryRun()
+	default:
+		usage()
+	}
+	if err != nil {
+		fail(err)
+	}
+	fmt.Println("ok")
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: cstorage-cli validate-config|dry-run <config.json>")
+	os.Exit(2)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
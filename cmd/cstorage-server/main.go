@@ -0,0 +1,117 @@
+// Command cstorage-server serves a CStorage over HTTP with httpapi.
+//
+// Usage:
+//
+//	cstorage-server -config config.json
+//
+// Config file is same as cstorage-cli, see internal/daemon.Config.
+//
+// Sending SIGUSR2 upgrades the server without downtime. The server stops accepting, writes contents of the cache to handoff snapshot
+// in data_dir, and starts new process of the same binary which inherits the listening socket and reads the snapshot.
+// Connections which come in meanwhile are queued by the kernel. If new process can't be started, the server keeps serving.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/httpapi"
+	"github.com/cocm1324/cstorage/internal/daemon"
+)
+
+func main() {
+	path := flag.String("config", "config.json", "path of config file")
+	flag.Parse()
+
+	c, err := daemon.Load(*path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if c.Listen == "" {
+		c.Listen = ":7070"
+	}
+	sc, _ := c.Storage()
+
+	cache := cstorage.New(sc)
+	if handoff := os.Getenv(daemon.EnvHandoff); handoff != "" {
+		count, err := daemon.ReadHandoff(handoff, cache)
+		if err != nil {
+			log.Printf("handoff snapshot %s: %v", handoff, err)
+		}
+		os.Remove(handoff)
+		log.Printf("restored %d keys from previous process", count)
+	}
+
+	var handler http.Handler = httpapi.NewHandler(cache)
+	if c.ShedThreshold > 0 {
+		handler = httpapi.NewShedder(handler, c.ShedThreshold, nil)
+	}
+	if a := c.Authenticator(); a != nil {
+		handler = httpapi.NewAuth(handler, a)
+	}
+
+	l, err := daemon.Listen(c.Listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	upgrade := make(chan os.Signal, 1)
+	if len(upgradeSignals) > 0 {
+		signal.Notify(upgrade, upgradeSignals...)
+	}
+
+	for {
+		srv := &http.Server{Handler: handler}
+		done := make(chan error, 1)
+		go func() { done <- srv.Serve(l) }()
+		log.Printf("cstorage-server listening on %s", l.Addr())
+
+		select {
+		case err := <-done:
+			log.Fatal(err)
+		case <-upgrade:
+		}
+
+		if l, err = restart(srv, l, cache, c.HandoffPath()); err != nil {
+			log.Fatal(err)
+		}
+		if l == nil {
+			return
+		}
+	}
+}
+
+// restart hands listener and cache over to new process. It returns listener to keep serving with if new process can't be started,
+// or nil if new process took over.
+func restart(srv *http.Server, l net.Listener, cache *cstorage.CStorage, handoff string) (net.Listener, error) {
+	f, err := daemon.ListenerFile(l)
+	if err != nil {
+		log.Printf("upgrade: %v", err)
+		return l, nil
+	}
+	defer f.Close()
+
+	// stop accepting and wait for requests in flight, so snapshot has every writes
+	srv.Shutdown(context.Background())
+
+	count, err := daemon.WriteHandoff(handoff, cache)
+	if err == nil {
+		var p *os.Process
+		if p, err = daemon.Exec(f, handoff); err == nil {
+			log.Printf("upgrade: handed %d keys over to process %d", count, p.Pid)
+			return nil, nil
+		}
+	}
+
+	log.Printf("upgrade: %v, keep serving", err)
+	os.Remove(handoff)
+	return net.FileListener(f)
+}
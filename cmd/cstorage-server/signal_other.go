@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import "os"
+
+// upgradeSignals are signals which trigger warm restart. Warm restart is not supported on this platform.
+var upgradeSignals []os.Signal
@@ -0,0 +1,11 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradeSignals are signals which trigger warm restart.
+var upgradeSignals = []os.Signal{syscall.SIGUSR2}
@@ -0,0 +1,89 @@
+// Command cstorage-simulate replays access trace against configurations of CStorage and prints hit ratio of each, see simulate package.
+//
+// Usage:
+//
+//	cstorage-simulate -trace trace.txt -policies lru,slru,arc,tinylfu -capacities 1000,10000 -ttls 0,10m
+//
+// Every combination of policies, capacities and ttls is simulated. Ttl 0 means keys don't expire. Trace "-" is read from standard input.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cocm1324/cstorage/simulate"
+)
+
+func main() {
+	path := flag.String("trace", "-", "path of trace file, - for standard input")
+	names := flag.String("policies", "lru,slru,arc,sieve,tinylfu", "comma separated policies, see simulate.ParsePolicy")
+	sizes := flag.String("capacities", "1000", "comma separated capacities")
+	lifetimes := flag.String("ttls", "0", "comma separated ttls, 0 for no expiration")
+	flag.Parse()
+
+	var policies []simulate.Policy
+	for _, name := range split(*names) {
+		p, err := simulate.ParsePolicy(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		policies = append(policies, p)
+	}
+
+	var capacities []int64
+	for _, field := range split(*sizes) {
+		capacity, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || capacity <= 0 {
+			log.Fatalf("invalid capacity %q", field)
+		}
+		capacities = append(capacities, capacity)
+	}
+
+	var ttls []time.Duration
+	for _, field := range split(*lifetimes) {
+		ttl, err := time.ParseDuration(field)
+		if err != nil || ttl < 0 {
+			log.Fatalf("invalid ttl %q", field)
+		}
+		ttls = append(ttls, ttl)
+	}
+
+	var r io.Reader = os.Stdin
+	if *path != "-" {
+		f, err := os.Open(*path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	}
+	trace, err := simulate.ReadTrace(r)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "POLICY\tCAPACITY\tTTL\tHITS\tMISSES\tEXPIRED\tEVICTED\tHIT RATIO")
+	for _, result := range simulate.Run(trace, policies, capacities, ttls) {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%d\t%d\t%d\t%.4f\n", result.Policy, result.Capacity, result.Ttl, result.Hits, result.Misses, result.Expired, result.Evicted, result.HitRatio())
+	}
+	w.Flush()
+}
+
+// split splits comma separated list, skipping empty fields.
+func split(list string) []string {
+	var fields []string
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
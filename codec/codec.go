@@ -0,0 +1,93 @@
+// Package codec provides serialization codecs for values stored in CStorage, which only holds []byte.
+// Transitional codec allows to migrate from one format to other without flushing the cache:
+// new writes use new format, while old format entries are still readable by fallback chain.
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Codec interface converts value from and into bytes.
+type Codec interface {
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSON is Codec with encoding/json.
+var JSON Codec = jsonCodec{}
+
+// Gob is Codec with encoding/gob. Note that gob needs concrete types to be registered for interface values.
+var Gob Codec = gobCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                               { return "json" }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// ErrUndecodable is returned by Transitional when none of codecs can decode data.
+var ErrUndecodable = errors.New("codec: no codec could decode data")
+
+// Transitional structure is Codec for migrating formats. It marshals with Primary only,
+// and unmarshals with Primary first and then with Fallbacks in order, until one succeeds.
+// Codecs should reject data of other formats with error, otherwise fallback chain can't work.
+type Transitional struct {
+	Primary   Codec
+	Fallbacks []Codec
+}
+
+// NewTransitional function returns Transitional which writes with primary and reads with primary and fallbacks.
+func NewTransitional(primary Codec, fallbacks ...Codec) *Transitional {
+	return &Transitional{Primary: primary, Fallbacks: fallbacks}
+}
+
+// Name returns name of Primary.
+func (t *Transitional) Name() string {
+	return t.Primary.Name()
+}
+
+// Marshal encodes v with Primary.
+func (t *Transitional) Marshal(v interface{}) ([]byte, error) {
+	return t.Primary.Marshal(v)
+}
+
+// Unmarshal decodes data with Primary, then with Fallbacks.
+func (t *Transitional) Unmarshal(data []byte, v interface{}) error {
+	_, err := t.Decode(data, v)
+	return err
+}
+
+// Decode function is same as Unmarshal, but it also returns the codec which decoded data.
+// If it is not Primary, caller may put the value again so the entry is rewritten in new format.
+func (t *Transitional) Decode(data []byte, v interface{}) (used Codec, err error) {
+	errs := make([]string, 0, len(t.Fallbacks)+1)
+	for _, c := range append([]Codec{t.Primary}, t.Fallbacks...) {
+		err := c.Unmarshal(data, v)
+		if err == nil {
+			return c, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", c.Name(), err))
+	}
+	return nil, fmt.Errorf("%w (%v)", ErrUndecodable, errs)
+}
@@ -0,0 +1,40 @@
+package codec
+
+import (
+	"errors"
+	"testing"
+)
+
+type user struct {
+	Name string
+	Age  int
+}
+
+func TestTransitional(t *testing.T) {
+	old, err := JSON.Marshal(user{Name: "alice", Age: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewTransitional(Gob, JSON)
+	fresh, err := c.Marshal(user{Name: "bob", Age: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var u user
+	used, err := c.Decode(fresh, &u)
+	if err != nil || used != Gob || u.Name != "bob" {
+		t.Errorf("new format should be decoded by primary, got %v %v %+v", used, err, u)
+	}
+
+	u = user{}
+	used, err = c.Decode(old, &u)
+	if err != nil || used != JSON || u.Name != "alice" || u.Age != 30 {
+		t.Errorf("old format should be decoded by fallback, got %v %v %+v", used, err, u)
+	}
+
+	if err := c.Unmarshal([]byte{0xff, 0x00}, &u); !errors.Is(err, ErrUndecodable) {
+		t.Errorf("garbage should not be decoded, got %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/cocm1324/cstorage/codec/msgpack
+
+go 1.25.0
+
+require (
+	github.com/cocm1324/cstorage v0.0.0
+	github.com/shamaton/msgpack/v2 v2.2.0
+)
+
+replace github.com/cocm1324/cstorage => ../../
@@ -0,0 +1,18 @@
+// Package msgpack provides codec.Codec of MessagePack, which is more compact than JSON and faster to decode.
+//
+// It is a separate module, so users of the core module don't depend on MessagePack library.
+package msgpack
+
+import (
+	"github.com/cocm1324/cstorage/codec"
+	"github.com/shamaton/msgpack/v2"
+)
+
+// Codec is codec.Codec with MessagePack. Structs are encoded as maps by field names, so fields can be added without breaking old data.
+var Codec codec.Codec = msgpackCodec{}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string                               { return "msgpack" }
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error)      { return msgpack.Marshal(v) }
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error { return msgpack.Unmarshal(data, v) }
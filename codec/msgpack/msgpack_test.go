@@ -0,0 +1,36 @@
+package msgpack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/codec"
+)
+
+type user struct {
+	Name string
+	Age  int
+	Tags []string
+}
+
+func TestCodec(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity}
+	users := codec.NewTyped[user](cstorage.New(config), Codec)
+
+	if err := users.Put("alice", user{Name: "alice", Age: 30, Tags: []string{"admin"}}); err != nil {
+		t.Fatal(err)
+	}
+	u, hit, err := users.Get("alice")
+	if err != nil || !hit || u.Name != "alice" || u.Age != 30 || len(u.Tags) != 1 {
+		t.Errorf("alice should be decoded, got %+v %v %v", u, hit, err)
+	}
+
+	old, _ := codec.JSON.Marshal(user{Name: "bob"})
+	var v user
+	if err := Codec.Unmarshal(old, &v); err == nil {
+		t.Error("JSON should not be decoded as MessagePack, so Transitional can fall back")
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/cocm1324/cstorage/codec/protobuf
+
+go 1.25.0
+
+require (
+	github.com/cocm1324/cstorage v0.0.0
+	google.golang.org/protobuf v1.36.11
+)
+
+replace github.com/cocm1324/cstorage => ../../
@@ -0,0 +1,47 @@
+// Package protobuf provides codec.Codec of Protocol Buffers, for values which are generated messages(e.g. codec.Typed[*pb.User]).
+//
+// It is a separate module, so users of the core module don't depend on protobuf.
+package protobuf
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/cocm1324/cstorage/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec is codec.Codec with protobuf wire format. Marshal takes proto.Message, and Unmarshal takes proto.Message or pointer to it,
+// which is allocated if it is nil.
+var Codec codec.Codec = protobufCodec{}
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string { return "protobuf" }
+
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf: %T is not proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+	// v is pointer to message pointer, e.g. **pb.User of codec.Typed[*pb.User]
+	p := reflect.ValueOf(v)
+	if p.Kind() != reflect.Ptr || p.IsNil() || p.Elem().Kind() != reflect.Ptr {
+		return fmt.Errorf("protobuf: %T is not proto.Message or pointer to it", v)
+	}
+	if p.Elem().IsNil() {
+		p.Elem().Set(reflect.New(p.Elem().Type().Elem()))
+	}
+	m, ok := p.Elem().Interface().(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf: %T is not proto.Message or pointer to it", v)
+	}
+	return proto.Unmarshal(data, m)
+}
@@ -0,0 +1,29 @@
+package protobuf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/codec"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestCodec(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity}
+	durations := codec.NewTyped[*durationpb.Duration](cstorage.New(config), Codec)
+
+	if err := durations.Put("a", durationpb.New(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	d, hit, err := durations.Get("a")
+	if err != nil || !hit || d.AsDuration() != time.Minute {
+		t.Errorf("a should be decoded, got %v %v %v", d, hit, err)
+	}
+
+	if _, err := Codec.Marshal("not a message"); err == nil {
+		t.Error("non message should not be marshaled")
+	}
+}
@@ -0,0 +1,61 @@
+package codec
+
+import (
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+// Typed structure is typed view of CStorage, which encodes values of T with Codec on Put and decodes them on Get,
+// so callers don't convert values from and into []byte around every call. Keys put by other code should be of the same format.
+type Typed[T any] struct {
+	cache *cstorage.CStorage
+	codec Codec
+}
+
+// NewTyped function returns Typed of cache, which encodes values with codec(e.g. JSON, Gob, or msgpack and protobuf codecs of subpackages).
+func NewTyped[T any](cache *cstorage.CStorage, codec Codec) *Typed[T] {
+	return &Typed[T]{cache: cache, codec: codec}
+}
+
+// Get function returns value of key. If data of key can't be decoded, it returns error with hit=true.
+func (t *Typed[T]) Get(key string) (value T, hit bool, err error) {
+	data, hit := t.cache.Get(key)
+	if !hit {
+		return value, false, nil
+	}
+	if err := t.codec.Unmarshal(data, &value); err != nil {
+		return value, true, err
+	}
+	return value, true, nil
+}
+
+// Put function encodes value and puts it as data of key, with ttl of CStorage.
+func (t *Typed[T]) Put(key string, value T) error {
+	data, err := t.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = t.cache.PutE(key, data)
+	return err
+}
+
+// PutWithTtl function is same as Put, with ttl of the key.
+func (t *Typed[T]) PutWithTtl(key string, value T, ttl time.Duration) error {
+	data, err := t.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = t.cache.PutWithTtlE(key, data, ttl)
+	return err
+}
+
+// Delete function deletes key.
+func (t *Typed[T]) Delete(key string) bool {
+	return t.cache.Delete(key)
+}
+
+// CStorage function returns underlying CStorage.
+func (t *Typed[T]) CStorage() *cstorage.CStorage {
+	return t.cache
+}
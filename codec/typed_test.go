@@ -0,0 +1,34 @@
+package codec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+func TestTyped(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := cstorage.New(config)
+
+	for _, c := range []Codec{JSON, Gob} {
+		users := NewTyped[user](cache, c)
+		if err := users.Put("alice", user{Name: "alice", Age: 30}); err != nil {
+			t.Fatal(err)
+		}
+		u, hit, err := users.Get("alice")
+		if err != nil || !hit || u.Name != "alice" || u.Age != 30 {
+			t.Errorf("%s: alice should be decoded, got %+v %v %v", c.Name(), u, hit, err)
+		}
+		if _, hit, err := users.Get("bob"); hit || err != nil {
+			t.Errorf("%s: bob should miss, got %v %v", c.Name(), hit, err)
+		}
+	}
+
+	cache.Put("broken", []byte("{"))
+	if _, hit, err := NewTyped[user](cache, JSON).Get("broken"); !hit || err == nil {
+		t.Errorf("undecodable data should be error, got %v %v", hit, err)
+	}
+}
@@ -0,0 +1,74 @@
+package compat
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// Cache is the minimal interface which benchmark harness needs. Any cache can be benchmarked by wrapping it with this interface.
+type Cache interface {
+	Set(key string, value []byte)
+	Get(key string) ([]byte, bool)
+}
+
+// Workload describes access pattern of benchmark.
+// - Keys: number of distinct keys
+// - WriteRatio: ratio of Set among operations, between 0 and 1
+// - ValueSize: size of value in bytes
+type Workload struct {
+	Name       string
+	Keys       int
+	WriteRatio float64
+	ValueSize  int
+}
+
+// Workloads are default workloads used by Bench.
+var Workloads = []Workload{
+	{Name: "read-heavy", Keys: 10000, WriteRatio: 0.1, ValueSize: 128},
+	{Name: "mixed", Keys: 10000, WriteRatio: 0.5, ValueSize: 128},
+	{Name: "write-heavy", Keys: 10000, WriteRatio: 0.9, ValueSize: 128},
+}
+
+// Bench function runs every Workloads against cache made by newCache as sub-benchmarks, and reports hit ratio.
+// Keys are chosen by zipfian distribution, to be similar with real world cache traffic.
+func Bench(b *testing.B, newCache func() Cache) {
+	for _, w := range Workloads {
+		w := w
+		b.Run(w.Name, func(b *testing.B) {
+			RunWorkload(b, newCache(), w)
+		})
+	}
+}
+
+// RunWorkload function runs single workload against cache.
+func RunWorkload(b *testing.B, cache Cache, w Workload) {
+	keys := make([]string, w.Keys)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	value := make([]byte, w.ValueSize)
+
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.1, 1, uint64(w.Keys-1))
+
+	var hits, gets int
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := keys[zipf.Uint64()]
+		if r.Float64() < w.WriteRatio {
+			cache.Set(key, value)
+			continue
+		}
+		gets++
+		if _, hit := cache.Get(key); hit {
+			hits++
+		} else {
+			cache.Set(key, value)
+		}
+	}
+	if gets > 0 {
+		b.ReportMetric(float64(hits)/float64(gets), "hit-ratio")
+	}
+}
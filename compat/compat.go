@@ -0,0 +1,374 @@
+// Package compat provides adapters which expose CStorage through the method sets of other popular Go caches
+// (golang-lru, ristretto, bigcache) and sync.Map, so code written against them can be pointed at CStorage with minimal changes.
+// It also provides a small benchmark harness, so the same workload can be run against CStorage and other caches.
+package compat
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+// value type markers. CStorage only holds []byte, so string values are stored with marker to be returned as string again.
+const (
+	markBytes  byte = 0
+	markString byte = 1
+)
+
+// toKey converts interface{} key to string key of CStorage.
+func toKey(key interface{}) string {
+	switch k := key.(type) {
+	case string:
+		return k
+	case []byte:
+		return string(k)
+	case int:
+		return strconv.Itoa(k)
+	case int64:
+		return strconv.FormatInt(k, 10)
+	case uint64:
+		return strconv.FormatUint(k, 10)
+	case fmt.Stringer:
+		return k.String()
+	default:
+		return fmt.Sprint(k)
+	}
+}
+
+// encode converts interface{} value to bytes with type marker. ok is false if type of value is not supported.
+func encode(value interface{}) (data []byte, ok bool) {
+	switch v := value.(type) {
+	case []byte:
+		data = make([]byte, len(v)+1)
+		data[0] = markBytes
+		copy(data[1:], v)
+		return data, true
+	case string:
+		data = make([]byte, len(v)+1)
+		data[0] = markString
+		copy(data[1:], v)
+		return data, true
+	default:
+		return nil, false
+	}
+}
+
+// decode is reverse of encode.
+func decode(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	if data[0] == markString {
+		return string(data[1:])
+	}
+	return data[1:]
+}
+
+// LRUCache is method set of hashicorp/golang-lru Cache which LRU adapter implements.
+// Code which depends on this interface instead of *lru.Cache can be switched to CStorage without further changes.
+type LRUCache interface {
+	Add(key, value interface{}) (evicted bool)
+	Get(key interface{}) (value interface{}, ok bool)
+	Contains(key interface{}) bool
+	Peek(key interface{}) (value interface{}, ok bool)
+	ContainsOrAdd(key, value interface{}) (ok, evicted bool)
+	PeekOrAdd(key, value interface{}) (previous interface{}, ok, evicted bool)
+	Remove(key interface{}) (present bool)
+	Purge()
+	Len() int
+}
+
+// RistrettoCache is method set of dgraph-io/ristretto Cache which Ristretto adapter implements.
+type RistrettoCache interface {
+	Set(key, value interface{}, cost int64) bool
+	SetWithTTL(key, value interface{}, cost int64, ttl time.Duration) bool
+	Get(key interface{}) (interface{}, bool)
+	GetTTL(key interface{}) (time.Duration, bool)
+	Del(key interface{})
+	Clear()
+	Wait()
+	Close()
+}
+
+// SyncMapCache is method set of sync.Map which SyncMap adapter implements.
+type SyncMapCache interface {
+	Load(key interface{}) (value interface{}, ok bool)
+	Store(key, value interface{})
+	LoadOrStore(key, value interface{}) (actual interface{}, loaded bool)
+	LoadAndDelete(key interface{}) (value interface{}, loaded bool)
+	Delete(key interface{})
+	Range(f func(key, value interface{}) bool)
+}
+
+var (
+	_ LRUCache       = (*LRU)(nil)
+	_ RistrettoCache = (*Ristretto)(nil)
+	_ SyncMapCache   = (*SyncMap)(nil)
+	_ SyncMapCache   = (*sync.Map)(nil)
+)
+
+// LRU is adapter with method set of hashicorp/golang-lru Cache.
+// Keys are converted to string, and only []byte and string values are supported. Other values are not stored.
+type LRU struct {
+	cache *cstorage.CStorage
+}
+
+// NewLRU function returns LRU adapter backed by cache.
+func NewLRU(cache *cstorage.CStorage) *LRU {
+	return &LRU{cache: cache}
+}
+
+// Add adds a value to the cache. Returns true if an eviction occurred.
+func (l *LRU) Add(key, value interface{}) (evicted bool) {
+	data, ok := encode(value)
+	if !ok {
+		return false
+	}
+	full := l.cache.Size() >= l.cache.Cap()
+	hit := l.cache.Put(toKey(key), data)
+	return !hit && full
+}
+
+// Get looks up a key's value from the cache.
+func (l *LRU) Get(key interface{}) (value interface{}, ok bool) {
+	data, hit := l.cache.Get(toKey(key))
+	if !hit {
+		return nil, false
+	}
+	return decode(data), true
+}
+
+// Contains checks if a key is in the cache.
+func (l *LRU) Contains(key interface{}) bool {
+	_, hit := l.cache.Peek(toKey(key))
+	return hit
+}
+
+// Peek returns the key value without updating the recentness of the key.
+func (l *LRU) Peek(key interface{}) (value interface{}, ok bool) {
+	data, hit := l.cache.Peek(toKey(key))
+	if !hit {
+		return nil, false
+	}
+	return decode(data), true
+}
+
+// ContainsOrAdd checks if a key is in the cache without updating the recentness, and if not, adds the value.
+// Note that check and add is not done atomically.
+func (l *LRU) ContainsOrAdd(key, value interface{}) (ok, evicted bool) {
+	if l.Contains(key) {
+		return true, false
+	}
+	return false, l.Add(key, value)
+}
+
+// PeekOrAdd checks if a key is in the cache without updating the recentness, and if not, adds the value.
+// Note that check and add is not done atomically.
+func (l *LRU) PeekOrAdd(key, value interface{}) (previous interface{}, ok, evicted bool) {
+	if previous, ok = l.Peek(key); ok {
+		return previous, true, false
+	}
+	return nil, false, l.Add(key, value)
+}
+
+// Remove removes the provided key from the cache. Returns true if key was present.
+func (l *LRU) Remove(key interface{}) (present bool) {
+	return l.cache.Delete(toKey(key))
+}
+
+// Purge is used to completely clear the cache.
+func (l *LRU) Purge() {
+	l.cache.Clear()
+}
+
+// Len returns the number of items in the cache.
+func (l *LRU) Len() int {
+	return int(l.cache.Size())
+}
+
+// Ristretto is adapter with method set of dgraph-io/ristretto Cache.
+// Cost is ignored since CStorage capacity is counted by entries. Set returns false if value type is not supported.
+type Ristretto struct {
+	cache *cstorage.CStorage
+}
+
+// NewRistretto function returns Ristretto adapter backed by cache.
+func NewRistretto(cache *cstorage.CStorage) *Ristretto {
+	return &Ristretto{cache: cache}
+}
+
+// Set adds a value to the cache. Returns false if value was dropped.
+func (r *Ristretto) Set(key, value interface{}, cost int64) bool {
+	data, ok := encode(value)
+	if !ok {
+		return false
+	}
+	r.cache.Put(toKey(key), data)
+	return true
+}
+
+// SetWithTTL is same as Set, but the key will be expired after ttl. If ttl is zero, Ttl of CStorageConfig is used.
+func (r *Ristretto) SetWithTTL(key, value interface{}, cost int64, ttl time.Duration) bool {
+	data, ok := encode(value)
+	if !ok {
+		return false
+	}
+	if ttl == 0 {
+		r.cache.Put(toKey(key), data)
+		return true
+	}
+	r.cache.PutWithTtl(toKey(key), data, ttl)
+	return true
+}
+
+// GetTTL returns remaining time to live of the key.
+func (r *Ristretto) GetTTL(key interface{}) (time.Duration, bool) {
+	return r.cache.Ttl(toKey(key))
+}
+
+// Get returns the value and true if key is found.
+func (r *Ristretto) Get(key interface{}) (interface{}, bool) {
+	data, hit := r.cache.Get(toKey(key))
+	if !hit {
+		return nil, false
+	}
+	return decode(data), true
+}
+
+// Del deletes the key from the cache.
+func (r *Ristretto) Del(key interface{}) {
+	r.cache.Delete(toKey(key))
+}
+
+// Clear empties the cache.
+func (r *Ristretto) Clear() {
+	r.cache.Clear()
+}
+
+// Wait is no-op since CStorage applies writes synchronously.
+func (r *Ristretto) Wait() {}
+
+// Close is no-op since cache is owned by caller, who should call Close of CStorage.
+func (r *Ristretto) Close() {}
+
+// ErrEntryNotFound is returned by BigCache.Get when key is not found, same as bigcache.ErrEntryNotFound.
+var ErrEntryNotFound = errors.New("Entry not found")
+
+// BigCache is adapter with method set of allegro/bigcache BigCache.
+type BigCache struct {
+	cache *cstorage.CStorage
+}
+
+// NewBigCache function returns BigCache adapter backed by cache.
+func NewBigCache(cache *cstorage.CStorage) *BigCache {
+	return &BigCache{cache: cache}
+}
+
+// Set saves entry under the key.
+func (c *BigCache) Set(key string, entry []byte) error {
+	c.cache.Put(key, entry)
+	return nil
+}
+
+// Get reads entry for the key. It returns ErrEntryNotFound when no entry exists for the given key.
+func (c *BigCache) Get(key string) ([]byte, error) {
+	data, hit := c.cache.Get(key)
+	if !hit {
+		return nil, ErrEntryNotFound
+	}
+	return data, nil
+}
+
+// Delete removes the key. It returns ErrEntryNotFound when no entry exists for the given key.
+func (c *BigCache) Delete(key string) error {
+	if !c.cache.Delete(key) {
+		return ErrEntryNotFound
+	}
+	return nil
+}
+
+// Reset empties the cache.
+func (c *BigCache) Reset() error {
+	c.cache.Clear()
+	return nil
+}
+
+// Len computes number of entries in cache.
+func (c *BigCache) Len() int {
+	return int(c.cache.Size())
+}
+
+// SyncMap is adapter with method set of sync.Map, so existing sync.Map can get capacity and ttl by replacing its type.
+// Keys are converted to string, and only []byte and string values are supported. Other values are not stored.
+// Unlike sync.Map, keys can be evicted or expired, so Load can miss a key which was stored.
+type SyncMap struct {
+	cache *cstorage.CStorage
+}
+
+// NewSyncMap function returns SyncMap adapter backed by cache.
+func NewSyncMap(cache *cstorage.CStorage) *SyncMap {
+	return &SyncMap{cache: cache}
+}
+
+// Load returns the value stored in the map for a key, or nil if no value is present.
+func (m *SyncMap) Load(key interface{}) (value interface{}, ok bool) {
+	data, hit := m.cache.Get(toKey(key))
+	if !hit {
+		return nil, false
+	}
+	return decode(data), true
+}
+
+// Store sets the value for a key.
+func (m *SyncMap) Store(key, value interface{}) {
+	if data, ok := encode(value); ok {
+		m.cache.Put(toKey(key), data)
+	}
+}
+
+// LoadOrStore returns the existing value for the key if present. Otherwise, it stores and returns the given value.
+// The loaded result is true if the value was loaded, false if stored.
+func (m *SyncMap) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	data, ok := encode(value)
+	if !ok {
+		return value, false
+	}
+	k := toKey(key)
+	if existing, hit := m.cache.Get(k); hit {
+		return decode(existing), true
+	}
+	if m.cache.PutIfAbsent(k, data) {
+		return value, false
+	}
+	// key is put by other goroutine in between, or put is refused(e.g. by admission filter)
+	if existing, hit := m.cache.Get(k); hit {
+		return decode(existing), true
+	}
+	return value, false
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any. The loaded result reports whether the key was present.
+func (m *SyncMap) LoadAndDelete(key interface{}) (value interface{}, loaded bool) {
+	data, hit := m.cache.GetAndDelete(toKey(key))
+	if !hit {
+		return nil, false
+	}
+	return decode(data), true
+}
+
+// Delete deletes the value for a key.
+func (m *SyncMap) Delete(key interface{}) {
+	m.cache.Delete(toKey(key))
+}
+
+// Range calls f sequentially for each key and value present in the map. If f returns false, range stops the iteration.
+// Keys are passed as string, since they are converted to string when stored. Keys are called in eviction order.
+func (m *SyncMap) Range(f func(key, value interface{}) bool) {
+	m.cache.IterateLRU(func(key string, data []byte, expiresAt time.Time) bool {
+		return f(key, decode(data))
+	})
+}
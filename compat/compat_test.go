@@ -0,0 +1,139 @@
+package compat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+func newStorage(capacity int64) *cstorage.CStorage {
+	return cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: capacity})
+}
+
+func TestLRU(t *testing.T) {
+	l := NewLRU(newStorage(2))
+
+	l.Add(1, "one")
+	l.Add("2", []byte("two"))
+	if l.Add(3, "three") != true {
+		t.Error("adding third key should evict")
+	}
+
+	v, ok := l.Get(3)
+	if !ok || v.(string) != "three" {
+		t.Errorf("expected string three, got %v", v)
+	}
+	v, ok = l.Get("2")
+	if !ok || string(v.([]byte)) != "two" {
+		t.Errorf("expected bytes two, got %v", v)
+	}
+	if l.Add(4, 4) {
+		t.Error("unsupported value should not be stored")
+	}
+	if l.Contains(4) {
+		t.Error("unsupported value should not be stored")
+	}
+	if ok, _ := l.ContainsOrAdd("2", "new"); !ok {
+		t.Error("key 2 should be contained")
+	}
+	if prev, ok, _ := l.PeekOrAdd(5, "five"); ok || prev != nil {
+		t.Error("key 5 should be added")
+	}
+	if v, ok := l.Peek(5); !ok || v.(string) != "five" {
+		t.Errorf("expected five, got %v", v)
+	}
+	if !l.Remove("2") || l.Len() != 1 {
+		t.Error("remove should delete the key")
+	}
+	l.Purge()
+	if l.Len() != 0 {
+		t.Error("purge should clear the cache")
+	}
+}
+
+func TestRistretto(t *testing.T) {
+	r := NewRistretto(newStorage(10))
+
+	if !r.Set("key1", "value", 1) {
+		t.Error("string value should be accepted")
+	}
+	r.Wait()
+	if v, ok := r.Get("key1"); !ok || v.(string) != "value" {
+		t.Errorf("expected value, got %v", v)
+	}
+	r.SetWithTTL("key2", []byte("value"), 1, time.Minute)
+	if ttl, ok := r.GetTTL("key2"); !ok || ttl > time.Minute {
+		t.Errorf("ttl of key2 should be within a minute, got %v", ttl)
+	}
+	r.Del("key1")
+	if _, ok := r.Get("key1"); ok {
+		t.Error("key1 should be deleted")
+	}
+}
+
+func TestBigCache(t *testing.T) {
+	c := NewBigCache(newStorage(10))
+
+	c.Set("key1", []byte("value"))
+	if v, err := c.Get("key1"); err != nil || string(v) != "value" {
+		t.Errorf("expected value, got %s, %v", v, err)
+	}
+	if err := c.Delete("key2"); err != ErrEntryNotFound {
+		t.Error("deleting missing key should return ErrEntryNotFound")
+	}
+	c.Reset()
+	if _, err := c.Get("key1"); err != ErrEntryNotFound {
+		t.Error("reset should clear the cache")
+	}
+}
+
+type bigCacheHarness struct{ *BigCache }
+
+func (h bigCacheHarness) Set(key string, value []byte) { h.BigCache.Set(key, value) }
+func (h bigCacheHarness) Get(key string) ([]byte, bool) {
+	v, err := h.BigCache.Get(key)
+	return v, err == nil
+}
+
+func BenchmarkCStorage(b *testing.B) {
+	Bench(b, func() Cache {
+		return bigCacheHarness{NewBigCache(newStorage(1000))}
+	})
+}
+
+func TestSyncMap(t *testing.T) {
+	m := NewSyncMap(newStorage(2))
+
+	m.Store(1, "one")
+	m.Store("2", []byte("two"))
+	if v, ok := m.Load(1); !ok || v.(string) != "one" {
+		t.Errorf("expected one, got %v", v)
+	}
+	if actual, loaded := m.LoadOrStore("2", "other"); !loaded || string(actual.([]byte)) != "two" {
+		t.Errorf("existing value should be loaded, got %v", actual)
+	}
+	if actual, loaded := m.LoadOrStore(3, "three"); loaded || actual.(string) != "three" {
+		t.Errorf("new value should be stored, got %v", actual)
+	}
+	if _, ok := m.Load(1); ok {
+		t.Error("least recently used key should be evicted by capacity")
+	}
+
+	count := 0
+	m.Range(func(key, value interface{}) bool {
+		count++
+		return true
+	})
+	if count != 2 {
+		t.Errorf("range should call 2 keys, got %d", count)
+	}
+
+	if v, loaded := m.LoadAndDelete(3); !loaded || v.(string) != "three" {
+		t.Errorf("expected three, got %v", v)
+	}
+	m.Delete("2")
+	if _, ok := m.Load("2"); ok {
+		t.Error("key 2 should be deleted")
+	}
+}
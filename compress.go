@@ -0,0 +1,82 @@
+package cstorage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+// Compressor interface compresses data which is larger than CStorageConfig.CompressThreshold, see CStorageConfig.Compressor.
+// Decompress is given data returned by Compress, and it should not fail for them.
+// Implementations should be safe for concurrent use, since data may be decompressed under read lock.
+type Compressor interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// Gzip is Compressor with compress/gzip at default level. Other algorithms such as snappy or zstd can be used by implementing Compressor.
+var Gzip Compressor = &gzipCompressor{}
+
+type gzipCompressor struct {
+	writers sync.Pool
+}
+
+func (g *gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, ok := g.writers.Get().(*gzip.Writer)
+	if ok {
+		w.Reset(&buf)
+	} else {
+		w = gzip.NewWriter(&buf)
+	}
+	defer g.writers.Put(w)
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g *gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// defaultCompressThreshold is CStorageConfig.CompressThreshold if it is not set.
+const defaultCompressThreshold = 1024
+
+// compress returns data compressed by Compressor if data is larger than CStorageConfig.CompressThreshold and compression makes it smaller.
+// raw is length of data before compression, 0 if data is not compressed.
+func (s *CStorage) compress(data []byte) (compressed []byte, raw int) {
+	c := s.config.Compressor
+	if c == nil {
+		return data, 0
+	}
+	threshold := s.config.CompressThreshold
+	if threshold == 0 {
+		threshold = defaultCompressThreshold
+	}
+	if len(data) <= threshold {
+		return data, 0
+	}
+	compressed, err := c.Compress(data)
+	if err != nil || len(compressed) >= len(data) {
+		return data, 0
+	}
+	return compressed, len(data)
+}
+
+// saving returns bytes saved by compression of data of node. Overhead of encryption is not counted.
+func (s *CStorage) saving(n *node) int64 {
+	if n.raw == 0 {
+		return 0
+	}
+	return int64(n.raw - (len(s.stored(n)) - s.sealer.overhead()))
+}
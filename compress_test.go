@@ -0,0 +1,59 @@
+package cstorage
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCompress(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Compressor: Gzip, CompressThreshold: 100, Checksum: ChecksumAlways}
+	cache := New(config)
+
+	large := bytes.Repeat([]byte(`{"name":"value"}`), 100)
+	cache.Put("large", large)
+	cache.Put("small", []byte("small"))
+
+	if data, hit := cache.Get("large"); !hit || !bytes.Equal(data, large) {
+		t.Errorf("expected large data back, got %d bytes, %v", len(data), hit)
+	}
+	if data, _ := cache.Get("small"); string(data) != "small" {
+		t.Errorf("expected small, got %q", data)
+	}
+
+	st := cache.Stats()
+	if st.CompressionSaved <= 0 || st.CompressionSaved >= int64(len(large)) {
+		t.Errorf("unexpected bytes saved %d", st.CompressionSaved)
+	}
+	if usage := cache.MemoryUsage(); usage >= int64(len(large)) {
+		t.Errorf("memory usage should count compressed data, got %d", usage)
+	}
+
+	// functions which read data see it decompressed
+	cache.Append("large", []byte("!"))
+	if data, _ := cache.GetMulti([]string{"large"})["large"]; !bytes.Equal(data, append(large, '!')) {
+		t.Errorf("expected appended data, got %d bytes", len(data))
+	}
+	var buf bytes.Buffer
+	cache.WriteSnapshot(&buf)
+	restored := New(CStorageConfig{Ttl: ttl, Capacity: capacity})
+	restored.ReadSnapshot(&buf)
+	if data, _ := restored.Get("large"); !bytes.Equal(data, append(large, '!')) {
+		t.Errorf("snapshot should have decompressed data, got %d bytes", len(data))
+	}
+
+	cache.Delete("large")
+	if saved := cache.Stats().CompressionSaved; saved != 0 {
+		t.Errorf("expected no bytes saved after delete, got %d", saved)
+	}
+}
+
+func TestCompressThreshold(t *testing.T) {
+	config := CStorageConfig{Ttl: time.Hour, Capacity: 10, CompressThreshold: -1}
+	if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package cstorage
+
+// PutIfAbsent function puts data only if key is not there(or expired). It returns added=true if data is put.
+// Check and put are done under same lock, so it is safe from race between Get and Put.
+func (s *CStorage) PutIfAbsent(key string, data []byte) (added bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.get(key); ok {
+		return false
+	}
+
+	n, _ := s.put(key, data, s.config.Ttl, s.config.Sliding)
+	return n != nil
+}
+
+// Replace function puts data only if key is already there and not expired. It returns replaced=true if data is put.
+// Check and put are done under same lock, so it is safe from race between Get and Put.
+// Data is put with lifetime and sliding of the key, so ttl given by PutWithTtl or PutSliding is kept.
+func (s *CStorage) Replace(key string, data []byte) (replaced bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, ok := s.get(key)
+	if !ok {
+		return false
+	}
+
+	n, _ = s.put(key, data, n.lifetime, n.sliding)
+	return n != nil
+}
+
+// GetAndDelete function returns data of key and deletes it under same lock, so only one caller can get the data.
+// It is for one-shot items such as CSRF tokens.
+func (s *CStorage) GetAndDelete(key string) (data []byte, hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, ok := s.get(key)
+	if !ok {
+		return nil, false
+	}
+
+	data = s.value(n)
+	s.delete(key)
+	return data, true
+}
+
+// GetAndSet function puts new data and returns old data of key under same lock. hadOld is false if key was not there(or expired).
+// If new is not put(e.g. longer than CStorageConfig.MaxValueBytes), nothing is swapped and hadOld is false. See GetAndSetE for the reason.
+// If key is there, new is put with lifetime and sliding of the key, same as CompareAndSwap. Otherwise ttl of CStorageConfig is used.
+func (s *CStorage) GetAndSet(key string, new []byte) (old []byte, hadOld bool) {
+	old, hadOld, _ = s.GetAndSetE(key, new)
+	return old, hadOld
+}
+
+// GetAndSetE function is same as GetAndSet, but it returns error if new is not put, same as PutE.
+func (s *CStorage) GetAndSetE(key string, new []byte) (old []byte, hadOld bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil, false, ErrClosed
+	}
+	if err := s.checkValue(key, new); err != nil {
+		s.stats.Oversized++
+		return nil, false, err
+	}
+	lifetime, sliding := s.config.Ttl, s.config.Sliding
+	if n, ok := s.get(key); ok {
+		old, hadOld = s.value(n), true
+		lifetime, sliding = n.lifetime, n.sliding
+	}
+
+	if n, _ := s.put(key, new, lifetime, sliding); n == nil {
+		return nil, false, ErrRejected
+	}
+	return old, hadOld, nil
+}
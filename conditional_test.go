@@ -0,0 +1,88 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+func TestConditionalPut(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	if cache.Replace("key1", []byte("1")) {
+		t.Error("key1 is not there, it should not be replaced")
+	}
+	if !cache.PutIfAbsent("key1", []byte("1")) {
+		t.Error("key1 is not there, it should be added")
+	}
+	if cache.PutIfAbsent("key1", []byte("2")) {
+		t.Error("key1 is already there, it should not be added")
+	}
+	if !cache.Replace("key1", []byte("3")) {
+		t.Error("key1 is there, it should be replaced")
+	}
+
+	data, _ := cache.Get("key1")
+	if string(data) != "3" {
+		t.Errorf("data should be 3, got %s", data)
+	}
+}
+
+func TestConditionalPutKeepsTtl(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock}
+	cache := New(config)
+
+	cache.PutWithTtl("short", []byte("1"), time.Minute)
+	cache.PutSliding("sliding", []byte("1"))
+	if !cache.Replace("short", []byte("2")) {
+		t.Fatal("short should be replaced")
+	}
+	if _, hadOld := cache.GetAndSet("sliding", []byte("2")); !hadOld {
+		t.Fatal("sliding should be swapped")
+	}
+	cache.GetAndSet("new", []byte("1"))
+
+	if remaining, _ := cache.Ttl("short"); remaining != time.Minute {
+		t.Errorf("replaced key should keep its ttl, got %v", remaining)
+	}
+	if remaining, _ := cache.Ttl("new"); remaining != ttl {
+		t.Errorf("new key should have ttl of config, got %v", remaining)
+	}
+	clock.Advance(30 * time.Minute)
+	cache.Get("sliding")
+	if remaining, _ := cache.Ttl("sliding"); remaining != ttl {
+		t.Errorf("swapped key should stay sliding, got %v", remaining)
+	}
+}
+
+func TestGetAndDelete(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.Put("csrf", []byte("token"))
+	if data, hit := cache.GetAndDelete("csrf"); !hit || string(data) != "token" {
+		t.Errorf("expected token, got %s", data)
+	}
+	if _, hit := cache.GetAndDelete("csrf"); hit {
+		t.Error("token should be consumed only once")
+	}
+
+	if _, hadOld := cache.GetAndSet("key", []byte("1")); hadOld {
+		t.Error("key was not there")
+	}
+	if old, hadOld := cache.GetAndSet("key", []byte("2")); !hadOld || string(old) != "1" {
+		t.Errorf("expected old data 1, got %s", old)
+	}
+	if data, _ := cache.Get("key"); string(data) != "2" {
+		t.Errorf("data should be 2, got %s", data)
+	}
+}
@@ -0,0 +1,64 @@
+package cstorage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidConfig is returned by CStorageConfig.Validate. Actual error wraps it with the reason, so use errors.Is to check.
+var ErrInvalidConfig = errors.New("cstorage: invalid config")
+
+// Validate function checks CStorageConfig before it is used by New(), so misconfiguration can be caught early(e.g. before deploy).
+// - Capacity should be positive, otherwise every Put will evict the key right away
+// - Ttl should be positive, otherwise every key is expired as soon as it is put
+// - TtlJitter should be in [0, 1), otherwise ttl can be zero or negative
+func (c CStorageConfig) Validate() error {
+	if c.Capacity <= 0 {
+		return fmt.Errorf("%w: capacity should be positive, got %d", ErrInvalidConfig, c.Capacity)
+	}
+	if c.Ttl <= 0 {
+		return fmt.Errorf("%w: ttl should be positive, got %v", ErrInvalidConfig, c.Ttl)
+	}
+	if c.TtlJitter < 0 || c.TtlJitter >= 1 {
+		return fmt.Errorf("%w: ttl jitter should be in [0, 1), got %v", ErrInvalidConfig, c.TtlJitter)
+	}
+	if c.MaxValueBytes < 0 {
+		return fmt.Errorf("%w: max value bytes should not be negative, got %d", ErrInvalidConfig, c.MaxValueBytes)
+	}
+	if c.ArenaSlabBytes < 0 || c.ArenaSlabBytes > 1<<31 {
+		return fmt.Errorf("%w: arena slab bytes should be in [0, 2GB], got %d", ErrInvalidConfig, c.ArenaSlabBytes)
+	}
+	if c.ArenaCompact < 0 || c.ArenaCompact >= 1 {
+		return fmt.Errorf("%w: arena compact should be in [0, 1), got %v", ErrInvalidConfig, c.ArenaCompact)
+	}
+	if c.EventBuffer < 0 {
+		return fmt.Errorf("%w: event buffer should not be negative, got %d", ErrInvalidConfig, c.EventBuffer)
+	}
+	if c.LogInterval < 0 {
+		return fmt.Errorf("%w: log interval should not be negative, got %v", ErrInvalidConfig, c.LogInterval)
+	}
+	if c.SnapshotInterval < 0 || c.SnapshotRetain < 0 {
+		return fmt.Errorf("%w: snapshot interval and retain should not be negative, got %v and %d", ErrInvalidConfig, c.SnapshotInterval, c.SnapshotRetain)
+	}
+	if c.SnapshotInterval > 0 && c.SnapshotDir == "" {
+		return fmt.Errorf("%w: snapshot dir should be set with snapshot interval", ErrInvalidConfig)
+	}
+	if c.AOFSync < AOFSyncEverySecond || c.AOFSync > AOFSyncNever {
+		return fmt.Errorf("%w: unknown aof sync %d", ErrInvalidConfig, c.AOFSync)
+	}
+	if c.AOFRewriteSize < 0 {
+		return fmt.Errorf("%w: aof rewrite size should not be negative, got %d", ErrInvalidConfig, c.AOFRewriteSize)
+	}
+	if c.LeaseTtl < 0 {
+		return fmt.Errorf("%w: lease ttl should not be negative, got %v", ErrInvalidConfig, c.LeaseTtl)
+	}
+	if c.CompressThreshold < 0 {
+		return fmt.Errorf("%w: compress threshold should not be negative, got %d", ErrInvalidConfig, c.CompressThreshold)
+	}
+	if c.Encryption != nil {
+		if _, key := c.Encryption.Current(); len(key) != 16 && len(key) != 24 && len(key) != 32 {
+			return fmt.Errorf("%w: encryption key should be 16, 24 or 32 bytes, got %d", ErrInvalidConfig, len(key))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package cstorage
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+func TestValidate(t *testing.T) {
+	config := CStorageConfig{Ttl: time.Hour, Capacity: 10}
+	if err := config.Validate(); err != nil {
+		t.Errorf("config should be valid, got %v", err)
+	}
+
+	config.Capacity = 0
+	if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("zero capacity should be invalid, got %v", err)
+	}
+
+	config.Capacity = 10
+	config.Ttl = -time.Second
+	if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("negative ttl should be invalid, got %v", err)
+	}
+
+	config.Ttl = time.Hour
+	config.TtlJitter = 1
+	if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("jitter of 100%% should be invalid, got %v", err)
+	}
+
+	config.TtlJitter = 0
+	config.LeaseTtl = -time.Second
+	if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("negative lease ttl should be invalid, got %v", err)
+	}
+
+	config.LeaseTtl = 0
+	config.SnapshotInterval = time.Minute
+	if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("snapshot interval without dir should be invalid, got %v", err)
+	}
+
+	config.SnapshotInterval = 0
+	config.AOFSync = AOFSyncNever + 1
+	if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("unknown aof sync should be invalid, got %v", err)
+	}
+}
+
+func TestTtlJitter(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 100
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, TtlJitter: 0.1, Seed: 1, Clock: clock}
+	cache := New(config)
+
+	distinct := make(map[time.Duration]struct{})
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		cache.Put(key, []byte("v"))
+		remaining, _ := cache.Ttl(key)
+		if remaining < 54*time.Minute || remaining > 66*time.Minute {
+			t.Errorf("ttl should be within 10%% of an hour, got %v", remaining)
+		}
+		distinct[remaining] = struct{}{}
+	}
+	if len(distinct) < 90 {
+		t.Errorf("ttl should be spread, got %d distinct ttl of 100", len(distinct))
+	}
+}
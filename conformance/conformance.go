@@ -0,0 +1,291 @@
+// Package conformance is property-based test suite of the contracts of cache, such as LRU ordering, ttl and size accounting.
+// It runs against any implementation wrapped by Cache, so implementations outside this module(e.g. tiered.Backend or Overflow built on other store)
+// can prove they behave like CStorage. Storage wraps CStorage itself, and the suite is run against every Policy in this package's tests.
+//
+// Properties are checked by random operations against simple model, with fixed seed so failures are reproducible.
+// Failure reports the seed and the operation which broke the property.
+package conformance
+
+import (
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+// Cache interface is operations checked by the suite. Implementation must be safe for concurrent use.
+// - Put: puts data of key with ttl. ttl is always positive.
+// - Delete: deletes key and returns whether it was there
+// - Len: number of keys, including expired ones which are not removed yet
+type Cache interface {
+	Get(key string) (data []byte, hit bool)
+	Put(key string, data []byte, ttl time.Duration)
+	Delete(key string) (hit bool)
+	Len() int
+}
+
+// Contract structure tells how to make Cache and which contracts it promises. Properties of contracts which are not promised are skipped.
+// - New: returns empty Cache which holds up to capacity keys, and whose time is given by clock. capacity is always positive.
+// - LRU: when full, the least recently used key is evicted. Both Get hit and Put are use of key.
+// - Bounded: number of keys never exceeds capacity, and key just put is there.
+// - Clocked: ttl is counted by clock given to New. Without it, ttl properties are skipped, since the suite doesn't wait for real time.
+// - Seed: seed of random operations, 1 if not set
+// - Ops: number of random operations of each property, 1000 if not set
+type Contract struct {
+	New     func(capacity int64, clock *testutil.Clock) Cache
+	LRU     bool
+	Bounded bool
+	Clocked bool
+	Seed    int64
+	Ops     int
+}
+
+// keys is size of key space of random operations. It is small, so keys are overwritten and deleted often.
+const keys = 32
+
+// ttl is ttl of keys when property is not about ttl. It is long enough not to expire during the test, even by real clock.
+const ttl = time.Hour
+
+// Run function runs every property promised by contract as subtests of t.
+func Run(t *testing.T, contract Contract) {
+	if contract.Seed == 0 {
+		contract.Seed = 1
+	}
+	if contract.Ops == 0 {
+		contract.Ops = 1000
+	}
+
+	t.Run("Linearizable", func(t *testing.T) { testLinearizable(t, contract) })
+	t.Run("Size", func(t *testing.T) { testSize(t, contract) })
+	t.Run("Concurrent", func(t *testing.T) { testConcurrent(t, contract) })
+	if contract.Bounded {
+		t.Run("Bounded", func(t *testing.T) { testBounded(t, contract) })
+	}
+	if contract.LRU {
+		t.Run("LRU", func(t *testing.T) { testLRU(t, contract) })
+	}
+	if contract.Clocked {
+		t.Run("TTL", func(t *testing.T) { testTTL(t, contract) })
+	}
+}
+
+// key returns i-th key of key space.
+func key(i int) string {
+	return "key" + strconv.Itoa(i)
+}
+
+// newCache makes Cache of contract with clock at current time.
+func newCache(contract Contract, capacity int64) (Cache, *testutil.Clock) {
+	clock := testutil.NewClock(time.Now())
+	return contract.New(capacity, clock), clock
+}
+
+// testLinearizable checks results of single-key operations, one at a time, match map. Capacity is large enough not to evict.
+func testLinearizable(t *testing.T, contract Contract) {
+	cache, _ := newCache(contract, keys*2)
+	model := make(map[string]string)
+	r := rand.New(rand.NewSource(contract.Seed))
+
+	for op := 0; op < contract.Ops; op++ {
+		k := key(r.Intn(keys))
+		want, present := model[k]
+		switch r.Intn(3) {
+		case 0:
+			v := strconv.Itoa(op)
+			cache.Put(k, []byte(v), ttl)
+			model[k] = v
+		case 1:
+			if hit := cache.Delete(k); hit != present {
+				t.Fatalf("seed %d op %d: Delete(%s) returned %v, expected %v", contract.Seed, op, k, hit, present)
+			}
+			delete(model, k)
+		default:
+			if data, hit := cache.Get(k); hit != present || string(data) != want {
+				t.Fatalf("seed %d op %d: Get(%s) returned %q, %v, expected %q, %v", contract.Seed, op, k, data, hit, want, present)
+			}
+		}
+	}
+}
+
+// testSize checks Len counts every key once, whether it is put, overwritten or deleted.
+func testSize(t *testing.T, contract Contract) {
+	cache, _ := newCache(contract, keys*2)
+	model := make(map[string]bool)
+	r := rand.New(rand.NewSource(contract.Seed))
+
+	for op := 0; op < contract.Ops; op++ {
+		k := key(r.Intn(keys))
+		if r.Intn(3) == 0 {
+			cache.Delete(k)
+			delete(model, k)
+		} else {
+			cache.Put(k, []byte(k), ttl)
+			model[k] = true
+		}
+		if cache.Len() != len(model) {
+			t.Fatalf("seed %d op %d: Len returned %d, expected %d", contract.Seed, op, cache.Len(), len(model))
+		}
+	}
+}
+
+// testBounded checks capacity is never exceeded, and key just put is there.
+func testBounded(t *testing.T, contract Contract) {
+	const capacity = keys / 4
+	cache, _ := newCache(contract, capacity)
+	r := rand.New(rand.NewSource(contract.Seed))
+
+	for op := 0; op < contract.Ops; op++ {
+		k := key(r.Intn(keys))
+		if r.Intn(2) == 0 {
+			cache.Put(k, []byte(k), ttl)
+			if data, hit := cache.Get(k); !hit || string(data) != k {
+				t.Fatalf("seed %d op %d: %s is not there after Put", contract.Seed, op, k)
+			}
+		} else {
+			cache.Get(k)
+		}
+		if cache.Len() > capacity {
+			t.Fatalf("seed %d op %d: Len returned %d, more than capacity %d", contract.Seed, op, cache.Len(), capacity)
+		}
+	}
+}
+
+// testLRU checks keys which are there after random Gets and Puts are the most recently used ones.
+// Keys are compared only at the end of each round, since checking them with Get would change the order.
+func testLRU(t *testing.T, contract Contract) {
+	const capacity = keys / 4
+	r := rand.New(rand.NewSource(contract.Seed))
+
+	for round := 0; round < contract.Ops/100+1; round++ {
+		cache, _ := newCache(contract, capacity)
+		// model is keys from the least recently used
+		var model []string
+		use := func(k string) {
+			for i, m := range model {
+				if m == k {
+					model = append(model[:i], model[i+1:]...)
+					break
+				}
+			}
+			model = append(model, k)
+			if len(model) > capacity {
+				model = model[1:]
+			}
+		}
+		for op := 0; op < 100; op++ {
+			k := key(r.Intn(keys))
+			if r.Intn(2) == 0 {
+				cache.Put(k, []byte(k), ttl)
+				use(k)
+			} else if _, hit := cache.Get(k); hit {
+				use(k)
+			}
+		}
+
+		for _, k := range model {
+			if _, hit := cache.Get(k); !hit {
+				t.Fatalf("seed %d round %d: %s should be there, recently used keys are %v", contract.Seed, round, k, model)
+			}
+		}
+		if cache.Len() != len(model) {
+			t.Fatalf("seed %d round %d: Len returned %d, expected %d", contract.Seed, round, cache.Len(), len(model))
+		}
+	}
+}
+
+// testTTL checks key is there until its ttl passes, and is never seen again after that until it is put again.
+// Exact moment of expiration is not checked, since implementations differ whether key expires at or after it.
+func testTTL(t *testing.T, contract Contract) {
+	cache, clock := newCache(contract, keys*2)
+	r := rand.New(rand.NewSource(contract.Seed))
+	expiresAt := make(map[string]time.Time)
+
+	for op := 0; op < contract.Ops; op++ {
+		k := key(r.Intn(keys))
+		switch r.Intn(3) {
+		case 0:
+			lifetime := time.Duration(r.Intn(100)+1) * time.Second
+			cache.Put(k, []byte(k), lifetime)
+			expiresAt[k] = clock.Now().Add(lifetime)
+		case 1:
+			clock.Advance(time.Duration(r.Intn(10)) * time.Second)
+		default:
+			at, ok := expiresAt[k]
+			now := clock.Now()
+			_, hit := cache.Get(k)
+			if ok && now.Before(at) && !hit {
+				t.Fatalf("seed %d op %d: %s is not there %v before it expires", contract.Seed, op, k, at.Sub(now))
+			}
+			if (!ok || now.After(at)) && hit {
+				t.Fatalf("seed %d op %d: %s is there after it expired", contract.Seed, op, k)
+			}
+			if !hit {
+				delete(expiresAt, k)
+			}
+		}
+	}
+}
+
+// testConcurrent checks linearizability of single key under concurrent use: readers never see data which was not written, or older data than they have seen.
+// Each key is written by single writer with increasing numbers, so newer data is larger.
+func testConcurrent(t *testing.T, contract Contract) {
+	cache, _ := newCache(contract, keys*2)
+	const writers = keys / 4
+	const readers = 4
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(k string) {
+			defer wg.Done()
+			for i := 1; i <= contract.Ops; i++ {
+				cache.Put(k, []byte(strconv.Itoa(i)), ttl)
+			}
+		}(key(w))
+	}
+
+	errs := make(chan string, readers)
+	for rd := 0; rd < readers; rd++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			seen := make([]int, writers)
+			for op := 0; op < contract.Ops; op++ {
+				w := r.Intn(writers)
+				data, hit := cache.Get(key(w))
+				if !hit {
+					if seen[w] > 0 {
+						errs <- key(w) + " disappeared after " + strconv.Itoa(seen[w]) + " was seen"
+						return
+					}
+					continue
+				}
+				n, err := strconv.Atoi(string(data))
+				if err != nil || n < 1 || n > contract.Ops {
+					errs <- key(w) + " has data which was never written: " + strconv.Quote(string(data))
+					return
+				}
+				if n < seen[w] {
+					errs <- key(w) + " went back to " + strconv.Itoa(n) + " after " + strconv.Itoa(seen[w]) + " was seen"
+					return
+				}
+				seen[w] = n
+			}
+		}(contract.Seed + int64(rd))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("seed %d: %s", contract.Seed, err)
+	}
+
+	for w := 0; w < writers; w++ {
+		if data, hit := cache.Get(key(w)); !hit || string(data) != strconv.Itoa(contract.Ops) {
+			t.Errorf("seed %d: %s should have the last data %d, got %q, %v", contract.Seed, key(w), contract.Ops, data, hit)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package conformance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+func TestStorage(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	policies := map[string]cstorage.Policy{
+		"LRU":     cstorage.PolicyLRU,
+		"SLRU":    cstorage.PolicySLRU,
+		"ARC":     cstorage.PolicyARC,
+		"SIEVE":   cstorage.PolicySIEVE,
+		"Sampled": cstorage.PolicySampled,
+	}
+	for name, policy := range policies {
+		t.Run(name, func(t *testing.T) {
+			Run(t, Contract{
+				New:     Storage(cstorage.CStorageConfig{Ttl: ttl, Policy: policy, Seed: 1}),
+				LRU:     policy == cstorage.PolicyLRU,
+				Bounded: true,
+				Clocked: true,
+			})
+		})
+	}
+
+	t.Run("Sliding", func(t *testing.T) {
+		// sliding renews ttl on Get, so ttl property doesn't hold
+		Run(t, Contract{
+			New:     Storage(cstorage.CStorageConfig{Ttl: ttl, Sliding: true}),
+			LRU:     true,
+			Bounded: true,
+		})
+	})
+
+	t.Run("BufferedAccess", func(t *testing.T) {
+		// accesses are applied late, so eviction order is not exact LRU
+		Run(t, Contract{
+			New:     Storage(cstorage.CStorageConfig{Ttl: ttl, BufferedAccess: true}),
+			Bounded: true,
+			Clocked: true,
+		})
+	})
+}
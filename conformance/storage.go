@@ -0,0 +1,38 @@
+package conformance
+
+import (
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+// Storage function returns Contract.New which makes CStorage with config, with Capacity and Clock given by the suite.
+func Storage(config cstorage.CStorageConfig) func(capacity int64, clock *testutil.Clock) Cache {
+	return func(capacity int64, clock *testutil.Clock) Cache {
+		config.Capacity = capacity
+		config.Clock = clock
+		return storage{cstorage.New(config)}
+	}
+}
+
+// storage adapts CStorage to Cache.
+type storage struct {
+	s *cstorage.CStorage
+}
+
+func (s storage) Get(key string) ([]byte, bool) {
+	return s.s.Get(key)
+}
+
+func (s storage) Put(key string, data []byte, ttl time.Duration) {
+	s.s.PutWithTtl(key, data, ttl)
+}
+
+func (s storage) Delete(key string) bool {
+	return s.s.Delete(key)
+}
+
+func (s storage) Len() int {
+	return int(s.s.Size())
+}
@@ -0,0 +1,36 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCopy(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+
+	for _, policy := range []Policy{PolicyLRU, PolicySIEVE} {
+		config := CStorageConfig{Ttl: ttl, Capacity: capacity, Policy: policy}
+		cache := New(config)
+
+		buf := []byte("data")
+		cache.Put("key", buf)
+		buf[0] = 'X'
+		data, _ := cache.Get("key")
+		if string(data) != "data" {
+			t.Errorf("policy %d: modifying data given to Put shouldn't change the cache, got %q", policy, data)
+		}
+		data[0] = 'Y'
+		if data, _ := cache.Get("key"); string(data) != "data" {
+			t.Errorf("policy %d: modifying data returned by Get shouldn't change the cache, got %q", policy, data)
+		}
+	}
+
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, ZeroCopy: true}
+	cache := New(config)
+	buf := []byte("data")
+	cache.Put("key", buf)
+	if data, _ := cache.Get("key"); &data[0] != &buf[0] {
+		t.Error("data shouldn't be copied with ZeroCopy")
+	}
+}
@@ -0,0 +1,68 @@
+package cstorage
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// ErrNotNumber is returned by Increment and Decrement when data of key is not decimal integer.
+var ErrNotNumber = errors.New("cstorage: data is not a decimal integer")
+
+// ErrOverflow is returned by Increment and Decrement when result doesn't fit in int64.
+var ErrOverflow = errors.New("cstorage: counter overflow")
+
+// Increment function adds delta to integer value of key and returns the result, atomically with respect to other operations.
+// Value is stored as decimal string, so it can be read by Get and strconv.ParseInt. Like memcached, ttl of the key is kept.
+// If key is missing, it is put with value of delta and ttl of CStorageConfig.
+// Unlike memcached, value is signed and can be negative. If the result is not put, error of it is returned, same as PutE.
+func (s *CStorage) Increment(key string, delta int64) (value int64, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.increment(key, delta)
+}
+
+// Decrement function is same as Increment, but it subtracts delta.
+func (s *CStorage) Decrement(key string, delta int64) (value int64, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if delta == math.MinInt64 {
+		return 0, ErrOverflow
+	}
+	return s.increment(key, -delta)
+}
+
+// increment is internal function of Increment. Caller should hold the mutex.
+func (s *CStorage) increment(key string, delta int64) (int64, error) {
+	if s.closed {
+		return 0, ErrClosed
+	}
+	n, ok := s.get(key)
+	if !ok {
+		data := []byte(strconv.FormatInt(delta, 10))
+		if n, _ := s.put(key, data, s.config.Ttl, s.config.Sliding); n == nil {
+			return 0, s.rejection(key, data)
+		}
+		return delta, nil
+	}
+
+	value, err := strconv.ParseInt(string(s.value(n)), 10, 64)
+	if err != nil {
+		return 0, ErrNotNumber
+	}
+	if (delta > 0 && value > math.MaxInt64-delta) || (delta < 0 && value < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+	value += delta
+
+	ttl, lifetime, sliding := n.ttl, n.lifetime, n.sliding
+	data := []byte(strconv.FormatInt(value, 10))
+	if n, _ = s.put(key, data, lifetime, sliding); n == nil {
+		return 0, s.rejection(key, data)
+	}
+	s.reschedule(n, ttl)
+	s.logPut(n)
+	return value, nil
+}
@@ -0,0 +1,60 @@
+package cstorage
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIncrement(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	if v, err := cache.Increment("hits", 5); v != 5 || err != nil {
+		t.Errorf("missing key should be created with delta, got %d, %v", v, err)
+	}
+	if v, err := cache.Decrement("hits", 7); v != -2 || err != nil {
+		t.Errorf("expected -2, got %d, %v", v, err)
+	}
+	if data, _ := cache.Get("hits"); string(data) != "-2" {
+		t.Errorf("value should be stored as decimal, got %s", data)
+	}
+
+	cache.PutWithTtl("rate", []byte("0"), time.Minute)
+	_, before, _ := cache.GetWithExpiration("rate")
+	cache.Increment("rate", 1)
+	if _, after, _ := cache.GetWithExpiration("rate"); !after.Equal(before) {
+		t.Errorf("increment should keep ttl, got %v and %v", before, after)
+	}
+
+	cache.Put("text", []byte("abc"))
+	if _, err := cache.Increment("text", 1); err != ErrNotNumber {
+		t.Errorf("expected ErrNotNumber, got %v", err)
+	}
+	cache.Put("max", []byte(strconv.FormatInt(math.MaxInt64, 10)))
+	if _, err := cache.Increment("max", 1); err != ErrOverflow {
+		t.Errorf("expected ErrOverflow, got %v", err)
+	}
+	if _, err := cache.Decrement("max", math.MinInt64); err != ErrOverflow {
+		t.Errorf("expected ErrOverflow, got %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cache.Increment("concurrent", 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if data, _ := cache.Get("concurrent"); string(data) != "800" {
+		t.Errorf("concurrent increments should not be lost, got %s", data)
+	}
+}
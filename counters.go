@@ -0,0 +1,123 @@
+package cstorage
+
+import "sync/atomic"
+
+// Counter is per-key counter which can be disabled by CStorageConfig.DisableCounters, to save memory when there are many small keys.
+// Counters are kept in side tables instead of node, so disabled counter takes no memory.
+type Counter int
+
+const (
+	// CounterHits counts Get hits of each key since it is put. Patterns returns nil without it.
+	CounterHits Counter = 1 << iota
+	// CounterVersion is version of each key which is changed whenever it is put. Without it, version is always 0,
+	// and CompareAndSwapVersion and CompareAndDeleteVersion always fail.
+	CounterVersion
+)
+
+// counters is side tables of per-key counters indexed by node.id. Table of disabled counter is nil.
+// ids of removed nodes are reused, so tables don't grow more than the largest size of CStorage.
+type counters struct {
+	hits     []int64
+	versions []uint64
+	free     []uint32
+	enabled  Counter
+}
+
+// newCounters makes counters for counters which are not disabled.
+func newCounters(disabled Counter) counters {
+	return counters{enabled: (CounterHits | CounterVersion) &^ disabled}
+}
+
+// on returns true if counter c is enabled.
+func (c *counters) on(counter Counter) bool {
+	return c.enabled&counter != 0
+}
+
+// track gives id to node and counters of it start from zero.
+func (c *counters) track(n *node) {
+	if c.enabled == 0 {
+		return
+	}
+	if last := len(c.free) - 1; last >= 0 {
+		n.id = c.free[last]
+		c.free = c.free[:last]
+		return
+	}
+
+	if c.on(CounterHits) {
+		n.id = uint32(len(c.hits))
+		c.hits = append(c.hits, 0)
+	}
+	if c.on(CounterVersion) {
+		n.id = uint32(len(c.versions))
+		c.versions = append(c.versions, 0)
+	}
+}
+
+// untrack resets counters of node and frees its id.
+func (c *counters) untrack(n *node) {
+	if c.enabled == 0 {
+		return
+	}
+	if c.on(CounterHits) {
+		c.hits[n.id] = 0
+	}
+	if c.on(CounterVersion) {
+		c.versions[n.id] = 0
+	}
+	c.free = append(c.free, n.id)
+}
+
+// reset forgets every nodes.
+func (c *counters) reset() {
+	*c = counters{enabled: c.enabled}
+}
+
+// hit counts Get hit of node. It is done atomically, since Get of PolicySIEVE calls it under read lock.
+func (c *counters) hit(n *node) {
+	if c.on(CounterHits) {
+		atomic.AddInt64(&c.hits[n.id], 1)
+	}
+}
+
+// hitsOf returns hits of node.
+func (c *counters) hitsOf(n *node) int64 {
+	if !c.on(CounterHits) {
+		return 0
+	}
+	return atomic.LoadInt64(&c.hits[n.id])
+}
+
+// resetHits resets hits of node, when data is put again.
+func (c *counters) resetHits(n *node) {
+	if c.on(CounterHits) {
+		atomic.StoreInt64(&c.hits[n.id], 0)
+	}
+}
+
+// versionOf returns version of node.
+func (c *counters) versionOf(n *node) uint64 {
+	if !c.on(CounterVersion) {
+		return 0
+	}
+	return c.versions[n.id]
+}
+
+// setVersion sets version of node.
+func (c *counters) setVersion(n *node, version uint64) {
+	if c.on(CounterVersion) {
+		c.versions[n.id] = version
+	}
+}
+
+// size returns bytes of counters per key.
+func (c *counters) size() int64 {
+	var size int64
+	if c.on(CounterHits) {
+		size += 8
+	}
+	if c.on(CounterVersion) {
+		size += 8
+	}
+	return size
+}
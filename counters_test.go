@@ -0,0 +1,53 @@
+package cstorage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDisableCounters(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, DisableCounters: CounterHits | CounterVersion}
+	cache := New(config)
+
+	cache.Put("key", []byte("v"))
+	cache.Get("key")
+	if _, version, hit := cache.GetWithVersion("key"); !hit || version != 0 {
+		t.Errorf("version should be 0 when it is disabled, got %d", version)
+	}
+	if _, swapped := cache.CompareAndSwapVersion("key", 0, []byte("new")); swapped {
+		t.Errorf("compare and swap of version should fail when version is disabled")
+	}
+	if patterns := cache.Patterns(); patterns != nil {
+		t.Errorf("patterns should be nil when hits are disabled, got %v", patterns)
+	}
+	if cache.counters.hits != nil || cache.counters.versions != nil {
+		t.Errorf("disabled counters should not take memory")
+	}
+	if usage := cache.MemoryUsage(); usage != int64(len("key")+len("v"))+nodeOverhead {
+		t.Errorf("memory of disabled counters should not be counted, got %d", usage)
+	}
+}
+
+func TestCountersReuse(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, DisableCounters: CounterVersion}
+	cache := New(config)
+
+	for i := 0; i < 30; i++ {
+		cache.Put(strconv.Itoa(i), []byte("v"))
+	}
+	cache.Get("29")
+	if len(cache.counters.hits) != 10 || cache.counters.versions != nil {
+		t.Errorf("ids of evicted keys should be reused, got %d hits and %d versions", len(cache.counters.hits), len(cache.counters.versions))
+	}
+	if hits := cache.counters.hitsOf(cache.table["29"]); hits != 1 {
+		t.Errorf("hits of 29 should be 1, got %d", hits)
+	}
+	if hits := cache.counters.hitsOf(cache.table["28"]); hits != 0 {
+		t.Errorf("hits of 28 should start from 0 with reused id, got %d", hits)
+	}
+}
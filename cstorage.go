@@ -1,8 +1,37 @@
 // CStorage package is module for provide key - value cache storage
 // Outsiders can use following; Get, Put, Delete, Clear, which are self explanatory
+//
+// This package is the core(hash table + LRU + TTL) and it only depends on standard library.
+// Heavier features live in opt-in subpackages, so users who only want the embedded cache don't pay for them in binary size.
+// - compat: adapters for other cache libraries and sync.Map, and benchmark harness
+// - prometheus: prometheus.Collector of metrics, in separate module since it depends on client_golang
+// - otel: OpenTelemetry instrumentation of cache calls
+// - expvar: metrics published under expvar
+// - codec: value codecs and format migration, and typed view of CStorage; msgpack and protobuf codecs are separate modules
+// - httpapi: REST API as http.Handler
+// - httpcache: http.RoundTripper caching responses of outbound requests, and middleware caching responses of handlers
+// - sqlcache: cache of database/sql query results with invalidation by table
+// - grpcapi: gRPC server and generated client, in separate module since it depends on gRPC
+// - tiered: two-tier cache with remote L2 such as Redis
+// - store: read-through and write-through or write-behind binding to backing store such as database
+// - overflow: disk overflow for evicted keys
+// - readonly: memory-mapped read-only snapshots
+// - shm: experimental cache shared by processes on a host through shared memory
+// - benchmarks: standard benchmark workloads for CStorage and other caches
+// - simulate: replay of access traces to compare hit ratio of configurations
+// - conformance: property-based test suite of cache contracts
+// - replicated: raft replication of writes with linearizable reads, in separate module since it depends on raft
+// - cluster: invalidation broadcast between instances over Redis pub/sub or NATS
+// - client: client sharding keys across remote servers by consistent hashing
+// - peer: fill of misses from peers which own keys, loading each key once across the fleet
+// - lww: eventually consistent replication which resolves conflicts by last writer wins
+// - signing: signing of messages between nodes
+// - testutil: helpers for testing such as manual clock
+// - cmd/cstorage-cli, cmd/cstorage-server, cmd/cstorage-simulate: command line tool, HTTP server and trace simulator
 package cstorage
 
 import (
+	"math/rand"
 	"sync"
 	"time"
 )
@@ -10,41 +39,283 @@ import (
 // CStorage structure is struct for holding data structure and misc of cache storage.
 // CStorage uses hash table, and doubly linked list for eviction policy.
 // - Hash Table: since CStorage is key-value store, hash table should be good choice since it has O(logN) to insert and search.
-// - Double Linked List: length of data would be limited, and eviction will be happen in LRU manner(Least Recently Used). To implement this, I will use double linked list here.
+// - Double Linked List: length of data would be limited, and eviction will be happen in LRU manner(Least Recently Used) by default. To implement this, I will use double linked list here. Other policies can be chosen by CStorageConfig.Policy.
+//
+// Eviction is deterministic. Every key has distinct position in the list, so if several keys are touched at the same time
+// (e.g. by PutMulti), the one which comes first is treated as less recently used and evicted first.
+// Randomized behaviors, if any, draw from rand which is seeded by CStorageConfig.Seed.
 type CStorage struct {
-	table  map[string]*node
-	head   *node
-	tail   *node
-	size   int64
-	mutex  *sync.Mutex
-	config CStorageConfig
+	table   map[string]*node
+	policy  policy
+	size    int64
+	mutex   *sync.RWMutex
+	config  CStorageConfig
+	stats   Stats
+	version uint64
+	rand    *rand.Rand
+	lfu     *tinyLFU
+	pinned  list
+	// every node of table, and ghost nodes of eviction policy
+	nodes *nodes
+	// nodes removed by evict, which are given back to nodes by reclaim
+	retired []*node
+	weight  int64
+	// total weight of pinned keys, which is included in weight
+	pinnedWeight int64
+	namespaces   map[string]*Namespace
+	clock        hlc
+	tags         map[string]map[*node]struct{}
+	cardinality  *cardinality
+	index        *trie
+	// total length of keys and data, see MemoryUsage. data is counted as stored, after compression
+	bytes int64
+	// bytes saved by compression, see CStorageConfig.Compressor
+	saved int64
+	// encrypts data if CStorageConfig.Encryption is set
+	sealer *sealer
+	// records accesses of Get under read lock if CStorageConfig.BufferedAccess is set
+	buffer *accessBuffer
+	// logs events if CStorageConfig.Logger is set
+	logs *logs
+	// receivers of events, see Subscribe and Watch
+	subscribers []*subscription
+	watchers    map[string][]*subscription
+	// holds data if CStorageConfig.ArenaSlabBytes is set
+	arena *arena
+	// number of reads for sampling of checksum verification, accessed atomically
+	reads    int64
+	expiry   expiry
+	counters counters
+	closed   bool
+	// keys being refreshed in background, with id of each refresh
+	refreshing map[string]uint64
+	refreshes  uint64
+	// outstanding leases handed out by GetWithLease, and the last lease token
+	leases   map[string]*lease
+	leaseSeq uint64
+	// append-only log, if it is opened by Open
+	aof *aof
+	// done is closed by Close to stop internal goroutines, which are counted by workers
+	done    chan struct{}
+	workers sync.WaitGroup
 }
 
 // CStorageConfig structure should be provided when outside code calls New() function. It will set properties of storage such as ttl or capacity
+// - TtlJitter: if set, ttl of every put is changed randomly within the ratio(e.g. 0.1 for ±10%), so keys put at once(e.g. on warm up) don't expire at once. It should be less than 1.
+// - Sliding: if true, every successful Get renews ttl of the key, so key stays alive as long as it is read. Otherwise ttl is counted from Put.
+// - Seed: seed of random source for randomized behaviors. Same seed gives same result for same operations, which is useful for tests. If 0, seed is chosen by current time.
+// - SchemaVersion: version of value schema which is stamped on every Put. Keys with older version are treated as miss on Get, unless Upgrader upgrades it.
+// - TinyLFU: if true, TinyLFU admission filter is placed in front of LRU. When storage is full, new key is put only if it is estimated to be accessed more often than the key to be evicted, so scan traffic doesn't wipe out hot keys.
+// - Policy: eviction policy, LRU by default. See Policy.
+// - ProtectedRatio: for PolicySLRU, ratio of capacity given to protected segment. 0.8 if not set.
+// - Samples: for PolicySampled, number of keys sampled to choose victim. 5 if not set.
+// - Overflow: optional store where keys evicted by capacity are spilled to, instead of being discarded. See Overflow.
+// - PinnedInCapacity: if true, pinned keys count toward Capacity. Otherwise they are kept on top of Capacity. See Pin.
+// - EvictPinned: if true, oldest pinned key is evicted when nothing else can be evicted. Otherwise new key is not put. See Pin.
+// - Weigher: optional function which returns weight of key, so Capacity is total weight(e.g. bytes) instead of number of keys. Weight is 1 if not set, and it must not be negative. SLRU, ARC and TinyLFU still size their internal structures by Capacity as if it is number of keys.
+// - HLC: if true, every put is stamped with timestamp of hybrid logical clock, which is returned in Info. See PutWithTimestamp.
+// - Namespaces: configuration of each Namespace by name. See Namespace.
+// - CardinalityWindow: if set, distinct keys ever written are counted with HyperLogLog, for CStorage and for each Namespace, and their growth is checked every window.
+// - CardinalityGrowth: ratio of growth of distinct keys during a window, which is reported to OnCardinalityGrowth. 0.5 if not set.
+// - OnCardinalityGrowth: optional function called when distinct keys grow more than CardinalityGrowth during a window while there are more distinct keys than capacity. It usually means keys are generated without bound, e.g. keys containing timestamp. namespace is empty for CStorage itself. It is called while holding the lock, so it must not call functions of CStorage.
+// - PrefixIndex: if true, keys are indexed by prefix, so DeletePrefix and DeleteMatch don't scan every keys. It takes memory for each byte of keys.
+// - Checksum: integrity verification of data on Get, off by default. See Checksum.
+// - ChecksumSample: for ChecksumSampled, data is verified on one of every ChecksumSample Gets. 100 if not set.
+// - SnapshotPath: if set, Close writes snapshot of CStorage to the path, which can be read by ReadSnapshot. See WriteSnapshot.
+// - CleanupInterval: if set, RemoveExpired is called every interval in background until Close is called.
+// - Clock: source of current time for ttl, real clock if not set. It is for testing ttl without waiting, see testutil.Clock.
+// - DisableCounters: per-key counters which are not kept, to save memory when there are many small keys. See Counter. Time of last access is kept only by PolicySampled, which needs it.
+// - Refresh: optional function which loads data of key again. If it is set, key which is hit by Get after RefreshAhead of its ttl has passed is loaded by it in background, and put again with the same ttl, so hot keys don't expire. Sliding keys are not refreshed. Error keeps current data.
+// - RefreshAhead: ratio of ttl after which key is refreshed by Refresh. 0.8 if not set.
+// - ZeroCopy: if true, data is not copied by put and Get family functions, so caller must not modify data after it is put, nor data returned by Get. Otherwise data is copied, which costs allocation but modification by caller can't corrupt the cache.
+// - MaxValueBytes: if set, data longer than it is not put, so a buggy caller can't blow memory with a huge value. Existing data of the key is kept. See PutE.
+// - ArenaSlabBytes: if set, data is copied into slabs of this size(e.g. 1MB) and nodes refer it by offset, so GC marks few large slabs instead of millions of small values. Data larger than quarter of slab is allocated by itself.
+// - ArenaCompact: ratio of garbage in slabs, left by replaced or removed data, above which data is moved into new slabs on next put. 0.5 if not set.
+// - Compressor: optional Compressor(e.g. Gzip) which compresses data larger than CompressThreshold on put, and decompresses it whenever data is returned. Data is kept as is if it doesn't get smaller. Capacity, Weigher and Overflow see data before compression, while MemoryUsage counts it after compression.
+// - CompressThreshold: data longer than it in bytes is compressed by Compressor. 1024 if not set.
+// - Encryption: optional KeyProvider(e.g. NewKeys) whose key encrypts data by AES-GCM when it is put, after compression. Data is decrypted whenever it is returned, and snapshot has data encrypted as well, so the same keys are needed to read it. Data encrypted by retired key is treated as miss.
+// - BufferedAccess: if true, Get hit is done under read lock, and it is recorded in a lossy buffer which is applied to eviction policy in batches on background, so concurrent Gets don't contend for the lock. Eviction order is not exact then, since accesses are applied late and some are dropped when the buffer is full. Otherwise every Get moves the key at once. It has no effect with TinyLFU or PolicySIEVE.
+// - Logger: optional Logger(e.g. *slog.Logger) of events such as evictions, expiry sweeps and snapshots. See Logger.
+// - LogInterval: minimum interval between log events of the same kind(e.g. evictions), so hot paths don't flood logs. Events in between are counted and reported by the next one. 1s if not set.
+// - EventBuffer: size of buffer of each channel of Subscribe and Watch, beyond which events are dropped. 1024 if not set.
+// - EventValues: if true, Event of Subscribe and Watch has data of the key, which costs a copy for each event unless ZeroCopy is set.
+// - Upgrader: optional function which converts data of older schema into current one. If it returns ok=false, key is treated as miss. It is called while holding the lock, so it must not call functions of CStorage.
+// - SnapshotSkipCorrupt: if true, ReadSnapshot skips records which don't match their checksum, counting them in Stats.CorruptRecords, instead of failing with ErrSnapshotCorrupt.
+// - SnapshotDir: if set, snapshots are written to the directory every SnapshotInterval and on Close, keeping SnapshotRetain latest ones. The latest one can be read by LoadLatestSnapshot on start.
+// - SnapshotInterval: interval of snapshots to SnapshotDir. If 0, snapshot is written only on Close.
+// - SnapshotRetain: number of snapshots kept in SnapshotDir, older ones are removed. 3 if not set.
+// - AOFPath: if set, Open replays append-only log of writes at the path on start, and appends every write to it, so a crash loses writes of AOFSync window at most. New doesn't open it, since replay can fail. See Open.
+// - AOFSync: when append-only log is synced to disk, every second by default. See AOFSync.
+// - AOFRewriteSize: size of append-only log beyond which it is rewritten into a record of each key, when it also doubled since the last rewrite. 64MB if not set.
+// - LeaseTtl: how long a lease handed out by GetWithLease is valid, after which other caller gets a new lease of the key. 10s if not set.
 type CStorageConfig struct {
-	Ttl      time.Duration
-	Capacity int64
+	Ttl                 time.Duration
+	TtlJitter           float64
+	Capacity            int64
+	Sliding             bool
+	Seed                int64
+	SchemaVersion       uint32
+	Upgrader            func(key string, data []byte, from uint32) (upgraded []byte, ok bool)
+	Overflow            Overflow
+	TinyLFU             bool
+	Policy              Policy
+	ProtectedRatio      float64
+	Samples             int
+	PinnedInCapacity    bool
+	EvictPinned         bool
+	Weigher             func(key string, data []byte) int64
+	Namespaces          map[string]NamespaceConfig
+	HLC                 bool
+	CardinalityWindow   time.Duration
+	CardinalityGrowth   float64
+	OnCardinalityGrowth func(namespace string, cardinality uint64, growth float64)
+	PrefixIndex         bool
+	Checksum            Checksum
+	ChecksumSample      int
+	SnapshotPath        string
+	Clock               Clock
+	CleanupInterval     time.Duration
+	DisableCounters     Counter
+	Refresh             func(key string) (data []byte, err error)
+	RefreshAhead        float64
+	MaxValueBytes       int
+	ZeroCopy            bool
+	ArenaSlabBytes      int
+	ArenaCompact        float64
+	Compressor          Compressor
+	CompressThreshold   int
+	Encryption          KeyProvider
+	BufferedAccess      bool
+	Logger              Logger
+	LogInterval         time.Duration
+	EventBuffer         int
+	EventValues         bool
+	LeaseTtl            time.Duration
+	SnapshotSkipCorrupt bool
+	SnapshotDir         string
+	SnapshotInterval    time.Duration
+	SnapshotRetain      int
+	AOFPath             string
+	AOFSync             AOFSync
+	AOFRewriteSize      int64
+}
+
+// Clock is source of current time. See CStorageConfig.Clock.
+type Clock interface {
+	Now() time.Time
 }
 
 // New function is initializer of CStorage. It takes CStorageConfig as parameter, which acts as configuration, and returns the pointer to CStorage.
 func New(config CStorageConfig) *CStorage {
-	return &CStorage{
-		table:  make(map[string]*node),
-		head:   nil,
-		tail:   nil,
-		size:   0,
-		mutex:  &sync.Mutex{},
-		config: config,
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	ns := &nodes{}
+	s := &CStorage{
+		table:    make(map[string]*node),
+		nodes:    ns,
+		policy:   newPolicy(config, ns),
+		pinned:   newList(ns),
+		size:     0,
+		mutex:    &sync.RWMutex{},
+		config:   config,
+		rand:     rand.New(rand.NewSource(seed)),
+		counters: newCounters(config.DisableCounters),
+	}
+
+	if config.TinyLFU {
+		var seeds [4]uint64
+		for i := range seeds {
+			seeds[i] = s.rand.Uint64()
+		}
+		s.lfu = newTinyLFU(config.Capacity, seeds)
+	}
+
+	if config.ArenaSlabBytes > 0 {
+		s.arena = newArena(config.ArenaSlabBytes)
+	}
+
+	if config.Encryption != nil {
+		s.sealer = newSealer(config.Encryption)
+	}
+
+	if config.BufferedAccess && config.Policy != PolicySIEVE && !config.TinyLFU {
+		s.buffer = newAccessBuffer()
+		s.spawn(s.drain)
+	}
+
+	if config.Logger != nil {
+		s.logs = newLogs(config.Logger, config.LogInterval)
+	}
+
+	if config.CardinalityWindow > 0 {
+		s.cardinality = &cardinality{}
+	}
+
+	if config.PrefixIndex {
+		s.index = &trie{}
+	}
+
+	if config.CleanupInterval > 0 {
+		s.spawn(func(done <-chan struct{}) {
+			s.cleanup(config.CleanupInterval, done)
+		})
+	}
+
+	if config.SnapshotDir != "" && config.SnapshotInterval > 0 {
+		s.spawn(func(done <-chan struct{}) {
+			s.scheduleSnapshots(config.SnapshotInterval, done)
+		})
 	}
+
+	return s
 }
 
-// node is internal structure of cache storage. It has key which is key in hashmap, data, ttl which is time to live, prev which is pointer to previous node in linked list, next which is vise versa.
+// node is internal structure of cache storage. It has key which is key in hashmap, data, ttl which is time to live, prev which is index of previous node in linked list, next which is vise versa.
+// self is index of the node itself, see nodes.
+// sliding and lifetime is for sliding expiration, if sliding is true, ttl will be renewed with lifetime on every Get.
+// id is index of the node in side tables of per-key counters, such as hits and version, see Counter.
+// meta is user metadata attached by PutWithMeta, and flags are opaque flags attached by PutWithFlags, they are replaced whenever data is put. schema is value schema version of data.
+// segment is used by eviction policy which has several lists, to tell which list the node is in. visited is used by PolicySIEVE, accessed atomically.
+// pos is used by PolicySampled, for index of the node.
+// pinned is true if the node is in pinned list of CStorage instead of eviction policy. priority is eviction priority of the node.
+// weight is given by CStorageConfig.Weigher when data is put. ns is Namespace the node belongs to, if any.
+// stamp is hybrid logical clock timestamp of data, zero unless CStorageConfig.HLC is set or data is put by PutWithTimestamp.
+// tags are given by PutTagged, and they are replaced whenever data is put.
+// sum is checksum of data if CStorageConfig.Checksum is set. slot is index of the node in expiry heap.
+// raw is length of data before compression, 0 if data is not compressed, see CStorageConfig.Compressor. ref is location of data in arena, data is nil if it is there.
+// Fields are ordered by size, so small ones are packed without padding.
+// once is true if the node is deleted on first Get hit, see PutOnce. hash is hash of key, which is kept so key is not hashed again(e.g. when it is compared by TinyLFU).
 type node struct {
-	key  string
-	data []byte
-	ttl  time.Time
-	prev *node
-	next *node
+	key      string
+	data     []byte
+	ttl      time.Time
+	lifetime time.Duration
+	meta     map[string]string
+	raw      int
+	pos      int
+	weight   int64
+	ns       *Namespace
+	stamp    Timestamp
+	tags     []string
+	slot     int
+	hash     uint64
+	priority Priority
+	ref      arenaRef
+	id       uint32
+	flags    uint32
+	schema   uint32
+	sum      uint32
+	visited  int32
+	self     int32
+	prev     int32
+	next     int32
+	segment  uint8
+	sliding  bool
+	pinned   bool
+	once     bool
 }
 
 // Get function is to get data with key in cache storage. Since CStorage is key-value store, data can be found by key.
@@ -52,23 +323,142 @@ type node struct {
 // - Search hashmap
 // - If there is no data with key, it will return empty data with hit=false
 // - If ttl is expired, it will delete record and return hit=false
+// - If key is sliding, it will renew ttl of the key
 // - If none of above, it will move the node by eviction policy, and return data with hit=true
+// With PolicySIEVE or CStorageConfig.BufferedAccess, Get doesn't move the node at once, so it is done under read lock when it is possible.
 func (s *CStorage) Get(key string) (data []byte, hit bool) {
+	if data, hit, ok := s.getShared(key); ok {
+		return data, hit
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, ok := s.get(key)
+	if !ok {
+		return nil, false
+	}
+
+	return s.value(n), true
+}
+
+// GetWithExpiration function is same as Get, but it also returns the time when the key will be expired.
+// It is useful when caller should tell downstream how long the data is valid. (e.g. Cache-Control header)
+func (s *CStorage) GetWithExpiration(key string) (data []byte, expiresAt time.Time, hit bool) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	n, ok := s.get(key)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+
+	return s.value(n), n.ttl, true
+}
+
+// Ttl function returns remaining time to live of the key. It returns hit=false if key is not there or expired.
+// Unlike Get, Ttl doesn't renew sliding key, since it is just a peek.
+func (s *CStorage) Ttl(key string) (ttl time.Duration, hit bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	n, ok := s.table[key]
+	if !ok {
+		return 0, false
+	}
+
+	remaining := n.ttl.Sub(s.now())
+	if remaining < 0 {
+		return 0, false
+	}
+
+	return remaining, true
+}
+
+// get is internal search function which deletes expired key and renews sliding key. Caller should hold the mutex.
+func (s *CStorage) get(key string) (*node, bool) {
+	return s.getHashed(key, s.hash(key))
+}
+
+// getHashed is same as get, with hash of key which is given by caller.
+func (s *CStorage) getHashed(key string, h uint64) (*node, bool) {
+	if s.closed {
+		return nil, false
+	}
+	if s.lfu != nil {
+		s.lfu.record(h)
+	}
+
 	n, ok := s.table[key]
 	if !ok {
+		if n, ok = s.recover(key); !ok {
+			s.stats.Misses++
+			return nil, false
+		}
+	}
+
+	now := s.now()
+	if n.ttl.Before(now) {
+		s.evict(n, false)
+		s.stats.Misses++
+		s.stats.Expired++
+		return nil, false
+	}
+
+	if n.schema < s.config.SchemaVersion && !s.upgrade(n) {
+		s.evict(n, false)
+		s.stats.Misses++
+		s.stats.Stale++
+		return nil, false
+	}
+
+	if !s.intact(n) {
+		s.evict(n, false)
+		s.stats.Misses++
+		s.stats.Corrupted++
+		return nil, false
+	}
+
+	if s.sealer != nil && !s.sealer.known(s.stored(n)) {
+		s.evict(n, false)
+		s.stats.Misses++
+		s.stats.Stale++
 		return nil, false
 	}
 
-	if n.ttl.Before(time.Now()) {
-		s.evict(n)
-		s.size--
+	s.stats.Hits++
+	s.counters.hit(n)
+
+	if n.once {
+		s.evict(n, false)
+		return n, true
+	}
+
+	if n.sliding {
+		s.reschedule(n, now.Add(n.lifetime))
+	} else if s.refreshDue(n, now) {
+		s.refresh(n)
+	}
+
+	if !n.pinned {
+		s.policy.access(n)
+	}
+
+	return n, true
+}
+
+// Peek function is same as Get, but it doesn't move the node by eviction policy nor renew ttl of sliding key.
+// It is useful for inspecting the cache without affecting it.
+func (s *CStorage) Peek(key string) (data []byte, hit bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	n, ok := s.table[key]
+	if !ok || n.ttl.Before(s.now()) {
 		return nil, false
 	}
 
-	return n.data, true
+	return s.value(n), true
 }
 
 // Put function is to upsert data with key in cache storage. It will return hit=true if it is update or hit=false if the key didn't existed before.
@@ -76,39 +466,227 @@ func (s *CStorage) Get(key string) (data []byte, hit bool) {
 // - Search hashmap with provided key
 // - If key is there, just update data, renew ttl, move node according to eviction policy, return hit=true
 // - If key is not there, next will happen
-// - Check storage size is full, if full, remove one node in accordance to eviction policy
+// - Check storage size is full, if full, remove nodes in accordance to eviction policy until there is room for the key
 // - Push key-data to hashmap, place it with eviction policy, return hit=false
 // *Note that hit is just key hits. Not the operation is successful or not.
 func (s *CStorage) Put(key string, data []byte) (hit bool) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	n, ok := s.table[key]
+	_, hit = s.put(key, data, s.config.Ttl, s.config.Sliding)
+	return hit
+}
 
-	ttl := time.Now().Add(s.config.Ttl)
+// PutWithTtl function is same as Put, but ttl of the key is given by caller instead of CStorageConfig.Ttl.
+func (s *CStorage) PutWithTtl(key string, data []byte, ttl time.Duration) (hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, hit = s.put(key, data, ttl, s.config.Sliding)
+	return hit
+}
+
+// PutSliding function is same as Put, but key will have sliding expiration regardless of CStorageConfig.Sliding.
+// It is for the case when only some keys(e.g. sessions) should stay alive while it is being read.
+func (s *CStorage) PutSliding(key string, data []byte) (hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, hit = s.put(key, data, s.config.Ttl, true)
+	return hit
+}
+
+// put is internal upsert function with lifetime of the key. Caller should hold the mutex.
+// It returns the node of key, which is nil if key is not put because admission filter refused it, every key is pinned, it is heavier than capacity, or CStorage is closed.
+func (s *CStorage) put(key string, data []byte, lifetime time.Duration, sliding bool) (n *node, hit bool) {
+	return s.putHashed(key, s.hash(key), data, lifetime, sliding)
+}
+
+// putHashed is same as put, with hash of key which is given by caller.
+func (s *CStorage) putHashed(key string, h uint64, data []byte, lifetime time.Duration, sliding bool) (n *node, hit bool) {
+	return s.putMeta(key, h, data, lifetime, sliding, nil)
+}
+
+// putMeta is same as putHashed, and it attaches meta to the node before EventPut is published, so subscribers see it.
+// meta is kept as it is, so caller should copy it.
+func (s *CStorage) putMeta(key string, h uint64, data []byte, lifetime time.Duration, sliding bool, meta map[string]string) (n *node, hit bool) {
+	if s.closed {
+		return nil, false
+	}
+	s.reclaim()
+	s.compact()
+	if s.checkValue(key, data) != nil {
+		s.stats.Oversized++
+		return nil, false
+	}
+	lifetime = s.jitter(lifetime)
+	s.cancelRefresh(key)
+	s.releaseLease(key)
+	n, ok := s.table[key]
+	ttl := s.now().Add(lifetime)
+	weight := s.weigh(key, data)
+	s.count(s.cardinality, "", h, s.config.Capacity)
+	stored, raw, err := s.encode(data)
+	if err != nil {
+		s.stats.Rejected++
+		return nil, false
+	}
 
 	if ok {
-		s.table[key].data = data
-		s.table[key].ttl = ttl
-		s.setHead(n)
-		return true
+		if weight > s.config.Capacity && (!n.pinned || s.config.PinnedInCapacity) {
+			s.evict(n, true)
+			s.stats.Evicted++
+			return nil, true
+		}
+
+		s.weight += weight - n.weight
+		if n.pinned {
+			s.pinnedWeight += weight - n.weight
+		}
+		if n.ns != nil {
+			n.ns.weight += weight - n.weight
+		}
+		n.weight = weight
+		s.store(n, stored, raw)
+		s.reschedule(n, ttl)
+		n.lifetime = lifetime
+		n.sliding = sliding
+		n.meta = meta
+		n.flags = 0
+		s.counters.resetHits(n)
+		n.once = false
+		s.untag(n)
+		n.schema = s.config.SchemaVersion
+		s.prioritize(n, PriorityNormal)
+		s.version++
+		s.counters.setVersion(n, s.version)
+		s.stamp(n)
+		if !n.pinned {
+			s.policy.access(n)
+		}
+		s.makeRoom(0, n)
+		s.publish(EventPut, n)
+		s.logPut(n)
+		return n, true
+	}
+
+	if weight > s.config.Capacity {
+		s.stats.Rejected++
+		return nil, false
+	}
+
+	s.policy.adapt(key)
+
+	if s.lfu != nil {
+		s.lfu.record(h)
+		if victim := s.policy.victim(); s.full(weight) && victim != nil && !s.lfu.admit(h, victim.hash) {
+			s.stats.Rejected++
+			return nil, false
+		}
+	}
+
+	if !s.makeRoom(weight, nil) {
+		s.stats.Rejected++
+		return nil, false
+	}
+
+	newNode := s.insert(key, h, stored, raw, lifetime, sliding, weight)
+	newNode.meta = meta
+	s.policy.add(newNode)
+	s.publish(EventPut, newNode)
+	s.logPut(newNode)
+
+	return newNode, false
+}
+
+// makeRoom evicts keys until there is room for weight. keep is node which should not be evicted, if any.
+// Already expired keys are removed first, from the earliest expired one, and then keys are evicted by eviction policy.
+// It returns false if there is no key it can evict. Caller should hold the mutex.
+func (s *CStorage) makeRoom(weight int64, keep *node) bool {
+	now := s.now()
+	for s.full(weight) {
+		if n := s.expired(now, keep); n != nil {
+			s.evict(n, false)
+			s.stats.Expired++
+			continue
+		}
+
+		victim := s.policy.victim()
+		if victim == nil || victim == keep {
+			if !s.config.EvictPinned || s.pinned.tail == 0 || s.pinned.last() == keep {
+				return false
+			}
+			victim = s.pinned.last()
+		}
+		s.spill(victim)
+		s.evict(victim, true)
+		s.stats.Evicted++
 	}
+	return true
+}
 
-	for s.size >= s.config.Capacity {
-		s.evict(s.tail)
-		s.size--
+// full returns true if key of weight can't be put without eviction. Pinned keys are not counted unless CStorageConfig.PinnedInCapacity is set.
+func (s *CStorage) full(weight int64) bool {
+	used := s.weight
+	if !s.config.PinnedInCapacity {
+		used -= s.pinnedWeight
 	}
+	return used+weight > s.config.Capacity
+}
 
-	newNode := &node{
-		key:  key,
-		data: data,
-		ttl:  ttl,
+// jitter changes lifetime randomly by CStorageConfig.TtlJitter. Caller should hold the mutex, since rand is not safe for concurrent use.
+func (s *CStorage) jitter(lifetime time.Duration) time.Duration {
+	if s.config.TtlJitter <= 0 {
+		return lifetime
 	}
+	return lifetime + time.Duration((s.rand.Float64()*2-1)*s.config.TtlJitter*float64(lifetime))
+}
+
+// weigh returns weight of key by CStorageConfig.Weigher.
+func (s *CStorage) weigh(key string, data []byte) int64 {
+	if s.config.Weigher == nil {
+		return 1
+	}
+	if w := s.config.Weigher(key, data); w > 0 {
+		return w
+	}
+	return 0
+}
+
+// insert creates node of new key of hash h and puts it into hash table. stored and raw are data encoded by encode.
+// Caller should place the node in eviction policy or pinned list.
+func (s *CStorage) insert(key string, h uint64, stored []byte, raw int, lifetime time.Duration, sliding bool, weight int64) *node {
+	if s.config.Overflow != nil {
+		s.config.Overflow.Remove(key)
+	}
+
+	newNode := s.nodes.alloc()
+	*newNode = node{
+		self:     newNode.self,
+		key:      key,
+		ttl:      s.now().Add(lifetime),
+		lifetime: lifetime,
+		sliding:  sliding,
+		schema:   s.config.SchemaVersion,
+		priority: PriorityNormal,
+		weight:   weight,
+		hash:     h,
+	}
+	s.counters.track(newNode)
+	s.version++
+	s.counters.setVersion(newNode, s.version)
+	s.stamp(newNode)
+	s.store(newNode, stored, raw)
+	s.schedule(newNode)
 	s.table[key] = newNode
-	s.setHead(newNode)
+	if s.index != nil {
+		s.index.add(newNode)
+	}
 	s.size++
+	s.weight += weight
+	s.bytes += int64(len(key))
 
-	return false
+	return newNode
 }
 
 // Delete function is to manually deletes key-value from CStorage.
@@ -116,13 +694,19 @@ func (s *CStorage) Delete(key string) (hit bool) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	return s.delete(key)
+}
+
+// delete is internal delete function. Caller should hold the mutex.
+func (s *CStorage) delete(key string) (hit bool) {
+	s.reclaim()
+	s.releaseLease(key)
 	node, ok := s.table[key]
 	if !ok {
-		return false
+		return s.config.Overflow != nil && s.config.Overflow.Remove(key)
 	}
 
-	s.evict(node)
-	s.size--
+	s.evict(node, false)
 
 	return true
 }
@@ -132,10 +716,50 @@ func (s *CStorage) Clear() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	for s.head != nil {
-		s.evict(s.tail)
+	s.reset()
+	if s.config.Overflow != nil {
+		s.config.Overflow.Clear()
+	}
+	s.publish(EventClear, nil)
+	s.logClear()
+}
+
+// reset removes every key. Caller should hold the mutex.
+func (s *CStorage) reset() {
+	s.table = make(map[string]*node)
+	s.policy.reset()
+	s.pinned.clear()
+	s.expiry = nil
+	s.counters.reset()
+	s.refreshing = nil
+	s.releaseLeases(func(string) bool { return true })
+	s.tags = nil
+	if s.index != nil {
+		s.index = &trie{}
 	}
 	s.size = 0
+	s.weight = 0
+	s.pinnedWeight = 0
+	s.bytes = 0
+	s.saved = 0
+	if s.arena != nil {
+		s.arena = newArena(s.arena.slabSize)
+	}
+	// after policy is reset, which may release its ghost nodes
+	s.nodes.reset()
+	s.retired = nil
+	for _, ns := range s.namespaces {
+		ns.size = 0
+		ns.weight = 0
+	}
+}
+
+// now returns current time by CStorageConfig.Clock.
+func (s *CStorage) now() time.Time {
+	if s.config.Clock != nil {
+		return s.config.Clock.Now()
+	}
+	return time.Now()
 }
 
 // Size function will return current size of CStorage
@@ -144,103 +768,98 @@ func (s *CStorage) Size() (size int64) {
 	return s.size
 }
 
-// Cap function will return maximum size(capacity) of CStorage.
+// Weight function will return total weight of keys in CStorage. It is same as Size if CStorageConfig.Weigher is not set.
+func (s *CStorage) Weight() (weight int64) {
+	return s.weight
+}
+
+// Cap function will return maximum size(capacity) of CStorage. It is maximum total weight if CStorageConfig.Weigher is set.
 func (s *CStorage) Cap() (capacity int64) {
 	return s.config.Capacity
 }
 
-// RemoveExpired function will traverse CStorage and will remove all expired key.
-// Since current version of CStorage uses passive method for ttl, it is possible for CStorage to hold already expired key.
-// This function should be called in regular basis to avoid memory efficiency
+// RemoveExpired function will remove all expired key under single lock.
+// Expired keys are taken from expiry heap from the earliest expired one, so it takes O(logN) for each expired key instead of scanning every key.
+// Expired leases of GetWithLease are removed as well, since leases of keys which are never filled are not removed otherwise.
+// Since CStorage removes expired key passively on Get, it is possible for CStorage to hold already expired key.
+// This function should be called in regular basis to avoid memory efficiency, or set CStorageConfig.CleanupInterval to call it in background.
 func (s *CStorage) RemoveExpired() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.reclaim()
 	var count int64 = 0
-	for key, value := range s.table {
-		if value.ttl.Before(time.Now()) {
-			s.Delete(key)
-			count++
-		}
+	now := s.now()
+	for n := s.expired(now, nil); n != nil; n = s.expired(now, nil) {
+		s.evict(n, false)
+		count++
 	}
+	s.stats.Expired += count
+	s.logSwept(count)
+	s.removeExpiredLeases(now)
 	return count
 }
 
-// setHead function is move node to head of linked list
-func (s *CStorage) setHead(n *node) {
-	if n == nil {
-		return
-	}
-
-	if s.head == nil {
-		s.head = n
-		s.tail = n
-		return
-	}
-
-	if s.head == n {
-		return
-	}
-
-	if s.tail == n {
-		s.tail = s.tail.prev
-		s.tail.next = nil
-		n.prev = nil
-		s.head.prev = n
-		n.next = s.head
-		s.head = n
-		return
+// cleanup calls RemoveExpired every interval until done is closed.
+func (s *CStorage) cleanup(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.RemoveExpired()
+		case <-done:
+			return
+		}
 	}
+}
 
-	if n.prev != nil {
-		n.prev.next = n.next
+// evict is to evict node from eviction policy and hash map, and to update size of CStorage.
+// evicted is true if it is removed by capacity, not by expiration or deletion.
+func (s *CStorage) evict(n *node, evicted bool) {
+	if len(s.subscribers) > 0 || len(s.watchers) > 0 {
+		s.publish(s.removal(n, evicted), n)
 	}
-
-	if n.next != nil {
-		n.next.prev = n.prev
+	s.logRemoved(n)
+	if n.pinned {
+		s.pinned.remove(n)
+		s.pinnedWeight -= n.weight
+	} else {
+		s.policy.remove(n, evicted)
 	}
-
-	n.prev = nil
-	s.head.prev = n
-	n.next = s.head
-	s.head = n
-}
-
-// evict is to evict node from linked list and hash map
-func (s *CStorage) evict(n *node) {
-	if s.head == s.tail && s.head == n {
-		s.head = nil
-		s.tail = nil
-		n.prev = nil
-		n.next = nil
-		delete(s.table, n.key)
-		return
+	s.unschedule(n)
+	s.cancelRefresh(n.key)
+	s.counters.untrack(n)
+	delete(s.table, n.key)
+	if s.index != nil {
+		s.index.remove(n.key)
 	}
-
-	if s.head == n {
-		s.head = s.head.next
-		s.head.prev = nil
-		n.prev = nil
-		n.next = nil
-		delete(s.table, n.key)
-		return
+	s.untag(n)
+	s.size--
+	s.weight -= n.weight
+	s.bytes -= int64(len(n.key) + len(s.stored(n)))
+	if n.ref.slab != 0 {
+		s.arena.free(n.ref)
 	}
-
-	if s.tail == n {
-		s.tail = s.tail.prev
-		s.tail.next = nil
-		n.prev = nil
-		n.next = nil
-		delete(s.table, n.key)
-		return
+	s.saved -= s.saving(n)
+	if n.ns != nil {
+		n.ns.detach(n, evicted)
 	}
-
-	if n.prev != nil {
-		n.prev.next = n.next
+	s.retired = append(s.retired, n)
+	if evicted {
+		s.logEvicted(n)
 	}
+}
 
-	if n.next != nil {
-		n.next.prev = n.prev
+// each calls fn with every node in eviction order, and then with pinned nodes from the oldest one.
+func (s *CStorage) each(fn func(n *node) bool) {
+	ok := true
+	s.policy.each(func(n *node) bool {
+		ok = fn(n)
+		return ok
+	})
+	if ok {
+		s.pinned.each(fn)
 	}
-
-	n.prev = nil
-	n.next = nil
-	delete(s.table, n.key)
 }
@@ -3,12 +3,14 @@ package cstorage
 import (
 	"testing"
 	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
 )
 
 func TestLRUEvictPolicy(t *testing.T) {
 	ttl := time.Duration(time.Hour * 24)
 	var capacity int64 = 10
-	config := CStorageConfig{ttl, capacity}
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
 	cache := New(config)
 
 	cache.Put("key1", []byte("1jqoweijgn3120nvc0qjew0j"))
@@ -68,7 +70,7 @@ func TestLRUEvictPolicy(t *testing.T) {
 func TestDeletion(t *testing.T) {
 	ttl := time.Duration(time.Hour * 24)
 	var capacity int64 = 10
-	config := CStorageConfig{ttl, capacity}
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
 	cache := New(config)
 
 	cache.Put("key1", []byte("1jqoweijgn3120nvc0qjew0j"))
@@ -107,7 +109,8 @@ func TestDeletion(t *testing.T) {
 func TestTTL(t *testing.T) {
 	ttl := time.Duration(time.Second * 1)
 	var capacity int64 = 10
-	config := CStorageConfig{ttl, capacity}
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock}
 	cache := New(config)
 
 	cache.Put("key1", []byte("1jqoweijgn3120nvc0qjew0j"))
@@ -119,7 +122,7 @@ func TestTTL(t *testing.T) {
 	cache.Put("key7", []byte("7jqoweijgn3120nvc0qjew0j"))
 	cache.Put("key8", []byte("8jqoweijgn3120nvc0qjew0j"))
 
-	time.Sleep(time.Second * 2)
+	clock.Advance(time.Second * 2)
 
 	_, hit := cache.Get("key1")
 	if hit {
@@ -137,3 +140,87 @@ func TestTTL(t *testing.T) {
 		t.Error("after removeExpired, it will clear all")
 	}
 }
+
+func TestSlidingTTL(t *testing.T) {
+	ttl := time.Duration(time.Second * 1)
+	var capacity int64 = 10
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock}
+	cache := New(config)
+
+	cache.PutSliding("session", []byte("1jqoweijgn3120nvc0qjew0j"))
+	cache.Put("absolute", []byte("2jqoweijgn3120nvc0qjew0j"))
+
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Millisecond * 600)
+		if _, hit := cache.Get("session"); !hit {
+			t.Error("sliding key should be renewed by Get")
+		}
+	}
+
+	if _, hit := cache.Get("absolute"); hit {
+		t.Error("absolute key should be expired")
+	}
+
+	config.Sliding = true
+	cache = New(config)
+	cache.Put("key1", []byte("1jqoweijgn3120nvc0qjew0j"))
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Millisecond * 600)
+		if _, hit := cache.Get("key1"); !hit {
+			t.Error("key should be renewed when config is sliding")
+		}
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	before := time.Now()
+	cache.Put("key1", []byte("1jqoweijgn3120nvc0qjew0j"))
+
+	_, expiresAt, hit := cache.GetWithExpiration("key1")
+	if !hit {
+		t.Fatal("key1 should exist")
+	}
+	if expiresAt.Before(before.Add(ttl)) || expiresAt.After(time.Now().Add(ttl)) {
+		t.Errorf("expiresAt should be an hour later, got %v", expiresAt)
+	}
+
+	remaining, hit := cache.Ttl("key1")
+	if !hit || remaining <= 0 || remaining > ttl {
+		t.Errorf("remaining ttl is wrong, got %v", remaining)
+	}
+
+	if _, hit := cache.Ttl("key2"); hit {
+		t.Error("never put key2, it said hit key2")
+	}
+}
+
+func TestDeterministicEviction(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 4
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Seed: 1}
+
+	for i := 0; i < 10; i++ {
+		cache := New(config)
+		cache.PutMulti([]Entry{
+			{Key: "key1", Data: []byte("1")},
+			{Key: "key2", Data: []byte("2")},
+			{Key: "key3", Data: []byte("3")},
+			{Key: "key4", Data: []byte("4")},
+		})
+		cache.PutMulti([]Entry{
+			{Key: "key5", Data: []byte("5")},
+			{Key: "key6", Data: []byte("6")},
+		})
+
+		got := cache.GetMulti([]string{"key1", "key2", "key3", "key4"})
+		if len(got) != 2 || got["key3"] == nil || got["key4"] == nil {
+			t.Fatalf("keys put first should be evicted first, got %v", got)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package cstorage
+
+// encode converts data into the form it is stored in node; compressed by CStorageConfig.Compressor and then encrypted by CStorageConfig.Encryption, if they are set.
+// Data is copied unless CStorageConfig.ZeroCopy is set, so caller can modify data after it is put.
+// raw is length of data before compression, 0 if data is not compressed. Error is returned only if data can't be encrypted.
+func (s *CStorage) encode(data []byte) (stored []byte, raw int, err error) {
+	stored, raw = s.compress(data)
+	if s.sealer != nil {
+		if stored, err = s.sealer.seal(stored); err != nil {
+			return nil, 0, err
+		}
+	}
+	if raw == 0 && s.sealer == nil && !s.config.ZeroCopy {
+		stored = copyBuffer(stored)
+	}
+	return stored, raw, nil
+}
+
+// store sets data of node which is encoded by encode, into arena if it is used. Buffer of former data is reused if it is owned. Checksum is computed over stored data.
+// Caller should hold the mutex.
+func (s *CStorage) store(n *node, stored []byte, raw int) {
+	s.bytes += int64(len(stored) - len(s.stored(n)))
+	s.saved -= s.saving(n)
+	if n.ref.slab != 0 {
+		s.arena.free(n.ref)
+	} else if n.data != nil && s.owned(n) {
+		putBuffer(n.data)
+	}
+
+	n.data, n.ref = stored, arenaRef{}
+	n.raw = raw
+	if s.arena != nil {
+		if r, ok := s.arena.alloc(stored); ok {
+			// stored is copied into arena, so its buffer can be reused unless it is given by caller
+			if s.owned(n) {
+				putBuffer(stored)
+			}
+			n.data, n.ref = nil, r
+		}
+	}
+	s.saved += s.saving(n)
+	s.seal(n)
+}
+
+// value returns data of node as it was put, decrypted and decompressed. Data is copied unless CStorageConfig.ZeroCopy is set,
+// so caller can modify returned data without corrupting the cache. Since data is verified by checksum and key of encryption is checked
+// before it is decoded on Get, decoding only fails if Compressor or KeyProvider is broken, and nil is returned then.
+// Caller should hold the mutex, read lock is enough.
+func (s *CStorage) value(n *node) []byte {
+	data := s.stored(n)
+	if s.sealer != nil {
+		var err error
+		if data, err = s.sealer.open(data); err != nil {
+			return nil
+		}
+	}
+	if n.raw == 0 {
+		if s.sealer == nil && !s.config.ZeroCopy {
+			return clone(data)
+		}
+		return data
+	}
+	data, err := s.config.Compressor.Decompress(data)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// clone returns copy of data, nil stays nil.
+func clone(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+	return append(make([]byte, 0, len(data)), data...)
+}
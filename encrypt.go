@@ -0,0 +1,161 @@
+package cstorage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+// ErrDecrypt is returned when encrypted data can't be decrypted, e.g. snapshot is encrypted by key which is unknown or retired, or it is modified.
+var ErrDecrypt = errors.New("cstorage: data can't be decrypted")
+
+// KeyProvider interface gives keys of AES-GCM encryption of data, see CStorageConfig.Encryption. Keys should be 16, 24 or 32 bytes long,
+// for AES-128, AES-192 or AES-256. Implementations should be safe for concurrent use.
+// - Current: key which data is encrypted with when it is put, and its id which is stored with encrypted data. Key of an id should never change.
+// - Key: key of id, which decrypts data encrypted before rotation. ok is false if the key is retired, then data encrypted by it is treated as miss.
+type KeyProvider interface {
+	Current() (id uint32, key []byte)
+	Key(id uint32) (key []byte, ok bool)
+}
+
+// Keys structure is KeyProvider which holds keys in memory. Keys can be rotated while CStorage is used.
+type Keys struct {
+	mutex   sync.RWMutex
+	current uint32
+	keys    map[uint32][]byte
+}
+
+// NewKeys function returns Keys with key of id as current key.
+func NewKeys(id uint32, key []byte) *Keys {
+	return &Keys{current: id, keys: map[uint32][]byte{id: key}}
+}
+
+// Current function returns current key and its id.
+func (k *Keys) Current() (id uint32, key []byte) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	return k.current, k.keys[k.current]
+}
+
+// Key function returns key of id, if it is not retired.
+func (k *Keys) Key(id uint32) (key []byte, ok bool) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	key, ok = k.keys[id]
+	return key, ok
+}
+
+// Rotate function makes key of id current, so data put after it is encrypted by the key. Former keys still decrypt data encrypted by them until they are retired.
+func (k *Keys) Rotate(id uint32, key []byte) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.current = id
+	k.keys[id] = key
+}
+
+// Retire function forgets key of id, so data encrypted by it is not readable anymore. Current key can't be retired.
+func (k *Keys) Retire(id uint32) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if id != k.current {
+		delete(k.keys, id)
+	}
+}
+
+// Encrypted data is id of key(4 bytes, big endian), nonce and sealed data, whose tag authenticates the id as well.
+const (
+	nonceSize = 12
+	tagSize   = 16
+)
+
+// sealer encrypts and decrypts data with keys of KeyProvider. AEAD of each key is made once and cached.
+type sealer struct {
+	provider KeyProvider
+	mutex    sync.Mutex
+	aeads    map[uint32]cipher.AEAD
+}
+
+func newSealer(provider KeyProvider) *sealer {
+	return &sealer{provider: provider, aeads: make(map[uint32]cipher.AEAD)}
+}
+
+// aead returns AEAD of key of id.
+func (s *sealer) aead(id uint32, key []byte) (cipher.AEAD, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if a, ok := s.aeads[id]; ok {
+		return a, nil
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	a, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	s.aeads[id] = a
+	return a, nil
+}
+
+// seal encrypts data with current key.
+func (s *sealer) seal(data []byte) ([]byte, error) {
+	id, key := s.provider.Current()
+	a, err := s.aead(id, key)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 4+nonceSize, 4+nonceSize+len(data)+tagSize)
+	binary.BigEndian.PutUint32(out, id)
+	if _, err := rand.Read(out[4:]); err != nil {
+		return nil, err
+	}
+	return a.Seal(out, out[4:], data, out[:4]), nil
+}
+
+// open decrypts data encrypted by seal.
+func (s *sealer) open(data []byte) ([]byte, error) {
+	if len(data) < s.overhead() {
+		return nil, ErrDecrypt
+	}
+	id := binary.BigEndian.Uint32(data)
+	key, ok := s.provider.Key(id)
+	if !ok {
+		return nil, ErrDecrypt
+	}
+	a, err := s.aead(id, key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := a.Open(nil, data[4:4+nonceSize], data[4+nonceSize:], data[:4])
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+// known returns false if data is encrypted by key which is retired. Caller should check it before data is returned by Get.
+func (s *sealer) known(data []byte) bool {
+	if len(data) < s.overhead() {
+		return false
+	}
+	_, ok := s.provider.Key(binary.BigEndian.Uint32(data))
+	return ok
+}
+
+// overhead returns bytes added to data by encryption, 0 if sealer is nil.
+func (s *sealer) overhead() int {
+	if s == nil {
+		return 0
+	}
+	return 4 + nonceSize + tagSize
+}
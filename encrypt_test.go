@@ -0,0 +1,70 @@
+package cstorage
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEncryption(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	keys := NewKeys(1, bytes.Repeat([]byte{1}, 32))
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Encryption: keys, Compressor: Gzip, CompressThreshold: 10}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	cache := New(config)
+
+	secret := bytes.Repeat([]byte("secret "), 10)
+	cache.Put("key1", secret)
+	if data, hit := cache.Get("key1"); !hit || !bytes.Equal(data, secret) {
+		t.Errorf("expected secret back, got %q, %v", data, hit)
+	}
+	cache.mutex.RLock()
+	stored := cache.table["key1"].data
+	cache.mutex.RUnlock()
+	if bytes.Contains(stored, []byte("secret")) {
+		t.Error("data should be encrypted in memory")
+	}
+
+	var buf bytes.Buffer
+	cache.WriteSnapshot(&buf)
+	if bytes.Contains(buf.Bytes(), []byte("secret")) {
+		t.Error("data should be encrypted in snapshot")
+	}
+	plain := New(CStorageConfig{Ttl: ttl, Capacity: capacity})
+	if _, err := plain.ReadSnapshot(bytes.NewReader(buf.Bytes())); err != ErrDecrypt {
+		t.Errorf("expected ErrDecrypt without keys, got %v", err)
+	}
+	restored := New(config)
+	if _, err := restored.ReadSnapshot(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := restored.Get("key1"); !bytes.Equal(data, secret) {
+		t.Errorf("expected secret from snapshot, got %q", data)
+	}
+
+	// data encrypted by former key is readable until the key is retired
+	keys.Rotate(2, bytes.Repeat([]byte{2}, 16))
+	cache.Put("key2", []byte("2"))
+	if data, _ := cache.Get("key1"); !bytes.Equal(data, secret) {
+		t.Errorf("expected secret after rotation, got %q", data)
+	}
+	keys.Retire(1)
+	if _, hit := cache.Get("key1"); hit {
+		t.Error("key1 shouldn't be hit after its key is retired")
+	}
+	if data, _ := cache.Get("key2"); string(data) != "2" {
+		t.Errorf("expected 2, got %q", data)
+	}
+	if st := cache.Stats(); st.Stale != 1 {
+		t.Errorf("expected 1 stale key, got %d", st.Stale)
+	}
+
+	invalid := CStorageConfig{Ttl: ttl, Capacity: capacity, Encryption: NewKeys(1, []byte("short"))}
+	if err := invalid.Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
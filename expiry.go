@@ -0,0 +1,62 @@
+package cstorage
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expiry is min-heap of nodes ordered by ttl, so expired keys are found without scanning every key.
+// Every node in hash table is in the heap, and slot of node is its index in the heap.
+type expiry []*node
+
+func (e expiry) Len() int { return len(e) }
+
+func (e expiry) Less(i, j int) bool { return e[i].ttl.Before(e[j].ttl) }
+
+func (e expiry) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+	e[i].slot = i
+	e[j].slot = j
+}
+
+func (e *expiry) Push(x interface{}) {
+	n := x.(*node)
+	n.slot = len(*e)
+	*e = append(*e, n)
+}
+
+func (e *expiry) Pop() interface{} {
+	old := *e
+	n := old[len(old)-1]
+	old[len(old)-1] = nil
+	*e = old[:len(old)-1]
+	return n
+}
+
+// schedule puts node into expiry heap. Caller should hold the mutex.
+func (s *CStorage) schedule(n *node) {
+	heap.Push(&s.expiry, n)
+}
+
+// reschedule changes ttl of node and moves it in expiry heap. Caller should hold the mutex.
+func (s *CStorage) reschedule(n *node, ttl time.Time) {
+	n.ttl = ttl
+	heap.Fix(&s.expiry, n.slot)
+}
+
+// unschedule takes node out of expiry heap. Caller should hold the mutex.
+func (s *CStorage) unschedule(n *node) {
+	heap.Remove(&s.expiry, n.slot)
+}
+
+// expired returns the node which is expired earliest before now, or nil if there is none. keep is node which should not be returned.
+func (s *CStorage) expired(now time.Time, keep *node) *node {
+	if len(s.expiry) == 0 {
+		return nil
+	}
+	n := s.expiry[0]
+	if n == keep || !n.ttl.Before(now) {
+		return nil
+	}
+	return n
+}
@@ -0,0 +1,117 @@
+package cstorage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+func TestEvictExpiredFirst(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 3
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.Put("0", []byte("0"))
+	cache.PutWithTtl("short", []byte("short"), time.Millisecond)
+	cache.Put("1", []byte("1"))
+	cache.Get("short")
+	time.Sleep(2 * time.Millisecond)
+
+	cache.Put("2", []byte("2"))
+
+	if _, hit := cache.Get("0"); !hit {
+		t.Errorf("least recently used key should survive while expired key can be removed")
+	}
+	if cache.Size() != 3 {
+		t.Errorf("size should be 3, got %d", cache.Size())
+	}
+	stats := cache.Stats()
+	if stats.Expired != 1 || stats.Evicted != 0 {
+		t.Errorf("expired key should be counted as expired, got expired %d, evicted %d", stats.Expired, stats.Evicted)
+	}
+
+	cache.Put("3", []byte("3"))
+	if _, hit := cache.Peek("1"); hit {
+		t.Errorf("least recently used key should be evicted when nothing is expired")
+	}
+}
+
+func TestExpiryHeap(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	for i := 0; i < 10; i++ {
+		cache.PutWithTtl(strconv.Itoa(i), []byte("v"), time.Duration(i+1)*time.Minute)
+	}
+	cache.Put("0", []byte("v"))
+	cache.Delete("5")
+	cache.Increment("n", 1)
+
+	if len(cache.expiry) != int(cache.Size()) {
+		t.Fatalf("every key should be in expiry heap, got %d for size %d", len(cache.expiry), cache.Size())
+	}
+	for i, n := range cache.expiry {
+		if n.slot != i {
+			t.Errorf("slot of %s should be %d, got %d", n.key, i, n.slot)
+		}
+		if i > 0 && n.ttl.Before(cache.expiry[(i-1)/2].ttl) {
+			t.Errorf("%s expires before its parent", n.key)
+		}
+	}
+	if cache.expiry[0].key != "1" {
+		t.Errorf("1 should expire first, got %s", cache.expiry[0].key)
+	}
+}
+
+func TestRemoveExpired(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock}
+	cache := New(config)
+
+	for i := 0; i < 10; i++ {
+		cache.PutWithTtl(strconv.Itoa(i), []byte("v"), time.Duration(i+1)*time.Minute)
+	}
+	clock.Advance(5*time.Minute + time.Second)
+
+	if removed := cache.RemoveExpired(); removed != 5 {
+		t.Errorf("5 keys should be removed, got %d", removed)
+	}
+	for i := 0; i < 10; i++ {
+		if _, hit := cache.Peek(strconv.Itoa(i)); hit != (i >= 5) {
+			t.Errorf("only keys after 5 minutes should be left, %d is hit=%v", i, hit)
+		}
+	}
+	if stats := cache.Stats(); stats.Expired != 5 {
+		t.Errorf("5 keys should be counted as expired, got %d", stats.Expired)
+	}
+}
+
+func TestCleanupInterval(t *testing.T) {
+	ttl := time.Duration(time.Millisecond)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, CleanupInterval: time.Millisecond}
+	cache := New(config)
+	defer cache.Close()
+
+	cache.Put("key", []byte("v"))
+	deadline := time.Now().Add(time.Second)
+	for {
+		cache.mutex.RLock()
+		size := cache.size
+		cache.mutex.RUnlock()
+		if size == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expired key should be removed in background")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
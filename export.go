@@ -0,0 +1,156 @@
+package cstorage
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+// Exported is an entry written by ExportJSON and ExportGob. Value is plain data even if CStorageConfig.Encryption is set,
+// so exported contents can be read by CStorage of other environment, unlike snapshot. Value is base64 string in JSON. Meta is metadata given by PutWithMeta.
+// Lifetime is ttl the key is put with, which sliding key is extended by on access if Sliding is set, and which refresh-ahead is scheduled by.
+type Exported struct {
+	Key       string            `json:"key"`
+	Value     []byte            `json:"value"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	Flags     uint32            `json:"flags,omitempty"`
+	Meta      map[string]string `json:"meta,omitempty"`
+	Lifetime  time.Duration     `json:"lifetime,omitempty"`
+	Sliding   bool              `json:"sliding,omitempty"`
+}
+
+// ExportJSON function writes every key of CStorage to w as JSON array of Exported, one entry in a line, for debugging and test fixtures.
+// Keys are written in eviction order, and copied under the lock, and written after the lock is released.
+func (s *CStorage) ExportJSON(w io.Writer) (count int, err error) {
+	items, err := s.exportItems()
+	if err != nil {
+		return 0, err
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("[")
+	for _, it := range items {
+		if count > 0 {
+			bw.WriteString(",")
+		}
+		bw.WriteString("\n")
+		b, err := json.Marshal(Exported{Key: it.key, Value: it.data, ExpiresAt: it.expiresAt, Flags: it.flags, Meta: it.meta,
+			Lifetime: it.lifetime, Sliding: it.sliding})
+		if err != nil {
+			return count, err
+		}
+		bw.Write(b)
+		count++
+	}
+	bw.WriteString("\n]\n")
+	return count, bw.Flush()
+}
+
+// ImportJSON function puts keys of JSON array of Exported from r, which is written by ExportJSON or by hand, with their remaining ttl.
+// Expired keys are skipped. If expires_at is omitted, key is put with ttl of CStorageConfig.
+func (s *CStorage) ImportJSON(r io.Reader) (count int, err error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	if t, err := dec.Token(); err != nil {
+		return 0, err
+	} else if t != json.Delim('[') {
+		return 0, errors.New("cstorage: exported JSON should be array")
+	}
+	for dec.More() {
+		var e Exported
+		if err := dec.Decode(&e); err != nil {
+			return count, err
+		}
+		put, err := s.importEntry(e)
+		if err != nil {
+			return count, err
+		}
+		if put {
+			count++
+		}
+	}
+	_, err = dec.Token()
+	return count, err
+}
+
+// ExportGob function is same as ExportJSON, but entries are written as gob stream of Exported, which is smaller and faster to read
+// for migrating contents between environments.
+func (s *CStorage) ExportGob(w io.Writer) (count int, err error) {
+	items, err := s.exportItems()
+	if err != nil {
+		return 0, err
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := gob.NewEncoder(bw)
+	for _, it := range items {
+		if err := enc.Encode(Exported{Key: it.key, Value: it.data, ExpiresAt: it.expiresAt, Flags: it.flags, Meta: it.meta,
+			Lifetime: it.lifetime, Sliding: it.sliding}); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, bw.Flush()
+}
+
+// ImportGob function puts keys written by ExportGob from r with their remaining ttl. Expired keys are skipped.
+func (s *CStorage) ImportGob(r io.Reader) (count int, err error) {
+	dec := gob.NewDecoder(bufio.NewReader(r))
+	for {
+		var e Exported
+		if err := dec.Decode(&e); err != nil {
+			if err == io.EOF {
+				return count, nil
+			}
+			return count, err
+		}
+		put, err := s.importEntry(e)
+		if err != nil {
+			return count, err
+		}
+		if put {
+			count++
+		}
+	}
+}
+
+// exportItems copies every key under the lock.
+func (s *CStorage) exportItems() ([]item, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.closed {
+		return nil, ErrClosed
+	}
+	return s.items(), nil
+}
+
+// importEntry puts e with its remaining ttl, and returns false if it is expired or rejected.
+func (s *CStorage) importEntry(e Exported) (put bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return false, ErrClosed
+	}
+	ttl := s.config.Ttl
+	if !e.ExpiresAt.IsZero() {
+		ttl = e.ExpiresAt.Sub(s.now())
+	}
+	if ttl <= 0 {
+		return false, nil
+	}
+	n := s.putRemaining(e.Key, e.Value, ttl, e.Lifetime, e.Sliding, e.Meta)
+	if n == nil {
+		return false, nil
+	}
+	if e.Flags != 0 {
+		n.flags = e.Flags
+	}
+	if e.Lifetime > 0 || e.Flags != 0 {
+		s.logPut(n)
+	}
+	return true, nil
+}
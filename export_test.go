@@ -0,0 +1,144 @@
+package cstorage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+func TestExportJSON(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock}
+	s := New(config)
+	s.Put("a", []byte("1"))
+	s.PutWithTtl("b", []byte{0, 0xff}, time.Minute)
+	s.PutWithTtl("c", []byte("3"), time.Second)
+
+	var b bytes.Buffer
+	if count, err := s.ExportJSON(&b); err != nil || count != 3 {
+		t.Fatalf("expected 3 keys exported, got %d %v", count, err)
+	}
+	if !strings.Contains(b.String(), `"key":"b","value":"AP8="`) {
+		t.Errorf("value should be base64, got %s", b.String())
+	}
+
+	clock.Advance(2 * time.Second)
+	restored := New(CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock})
+	if count, err := restored.ImportJSON(&b); err != nil || count != 2 {
+		t.Fatalf("expected 2 keys imported, got %d %v", count, err)
+	}
+	if data, hit := restored.Get("b"); !hit || !bytes.Equal(data, []byte{0, 0xff}) {
+		t.Errorf("b should be imported, got %v %v", data, hit)
+	}
+	if remaining, _ := restored.Ttl("b"); remaining != time.Minute-2*time.Second {
+		t.Errorf("b should keep its expiration, got %v", remaining)
+	}
+	if _, hit := restored.Get("c"); hit {
+		t.Error("expired c should be skipped")
+	}
+
+	// fixture written by hand, without expiration
+	fixture := `[{"key": "user:1", "value": "YWxpY2U="}]`
+	if count, err := restored.ImportJSON(strings.NewReader(fixture)); err != nil || count != 1 {
+		t.Fatalf("fixture should be imported, got %d %v", count, err)
+	}
+	if remaining, _ := restored.Ttl("user:1"); remaining != ttl {
+		t.Errorf("key without expiration should have ttl of config, got %v", remaining)
+	}
+	if _, err := restored.ImportJSON(strings.NewReader(`{"key": "a"}`)); err == nil {
+		t.Error("object should not be imported")
+	}
+}
+
+func TestExportGob(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Encryption: NewKeys(1, bytes.Repeat([]byte{1}, 32))}
+	s := New(config)
+	s.Put("a", []byte("1"))
+	s.Put("b", []byte("2"))
+
+	var b bytes.Buffer
+	if count, err := s.ExportGob(&b); err != nil || count != 2 {
+		t.Fatalf("expected 2 keys exported, got %d %v", count, err)
+	}
+	// exported data is plain, so CStorage without the key can read it
+	restored := New(CStorageConfig{Ttl: ttl, Capacity: capacity})
+	if count, err := restored.ImportGob(&b); err != nil || count != 2 {
+		t.Fatalf("expected 2 keys imported, got %d %v", count, err)
+	}
+	if data, _ := restored.Get("b"); string(data) != "2" {
+		t.Errorf("b should be imported, got %q", data)
+	}
+}
+
+func TestExportMeta(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	s := New(config)
+	s.PutWithMeta("a", []byte("1"), map[string]string{"origin": "billing", "trace": "t1"})
+	s.Put("b", []byte("2"))
+
+	var j, g bytes.Buffer
+	if _, err := s.ExportJSON(&j); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ExportGob(&g); err != nil {
+		t.Fatal(err)
+	}
+	imports := map[string]func(*CStorage) (int, error){
+		"json": func(restored *CStorage) (int, error) { return restored.ImportJSON(&j) },
+		"gob":  func(restored *CStorage) (int, error) { return restored.ImportGob(&g) },
+	}
+	for name, imp := range imports {
+		restored := New(config)
+		if count, err := imp(restored); err != nil || count != 2 {
+			t.Fatalf("%s: expected 2 keys imported, got %d %v", name, count, err)
+		}
+		if info, _ := restored.GetWithInfo("a"); info.Meta["origin"] != "billing" || info.Meta["trace"] != "t1" || len(info.Meta) != 2 {
+			t.Errorf("%s: a should be imported with metadata, got %v", name, info.Meta)
+		}
+		if info, _ := restored.GetWithInfo("b"); info.Meta != nil {
+			t.Errorf("%s: b should be imported without metadata, got %v", name, info.Meta)
+		}
+	}
+}
+
+func TestExportSliding(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock}
+	s := New(config)
+	s.PutSliding("a", []byte("1"))
+	s.Put("b", []byte("2"))
+	clock.Advance(10 * time.Minute)
+
+	var b bytes.Buffer
+	if _, err := s.ExportJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	config.Sliding = true
+	restored := New(config)
+	if count, err := restored.ImportJSON(&b); err != nil || count != 2 {
+		t.Fatalf("expected 2 keys imported, got %d %v", count, err)
+	}
+	if remaining, _ := restored.Ttl("a"); remaining != 50*time.Minute {
+		t.Errorf("a should keep its expiration, got %v", remaining)
+	}
+	clock.Advance(5 * time.Minute)
+	restored.Get("a")
+	restored.Get("b")
+	if remaining, _ := restored.Ttl("a"); remaining != ttl {
+		t.Errorf("sliding a should be renewed with its lifetime, got %v", remaining)
+	}
+	if remaining, _ := restored.Ttl("b"); remaining != 45*time.Minute {
+		t.Errorf("b should not be sliding regardless of config, got %v", remaining)
+	}
+}
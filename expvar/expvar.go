@@ -0,0 +1,36 @@
+// Package expvar publishes metrics of CStorage under expvar, so services already exposing /debug/vars get cache visibility with one line:
+//
+//	expvar.PublishExpvar("cache", cache)
+//
+// It lives outside of the core package, since importing expvar registers /debug/vars on http.DefaultServeMux and links net/http.
+package expvar
+
+import (
+	"expvar"
+
+	"github.com/cocm1324/cstorage"
+)
+
+// PublishExpvar function is to publish metrics of cache as expvar variable of name. Metrics are read from Stats whenever the variable is read,
+// and written as JSON object with size, capacity, hits, misses, hit_ratio, evicted, expired, rejected and memory_usage.
+// Like expvar.Publish, it panics if name is already published.
+func PublishExpvar(name string, cache *cstorage.CStorage) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return metrics(cache.Stats())
+	}))
+}
+
+// metrics converts st into fields of published variable.
+func metrics(st cstorage.Stats) map[string]interface{} {
+	return map[string]interface{}{
+		"size":         st.Size,
+		"capacity":     st.Capacity,
+		"hits":         st.Hits,
+		"misses":       st.Misses,
+		"hit_ratio":    st.HitRatio(),
+		"evicted":      st.Evicted,
+		"expired":      st.Expired,
+		"rejected":     st.Rejected,
+		"memory_usage": st.MemoryUsage,
+	}
+}
@@ -0,0 +1,48 @@
+package expvar
+
+import (
+	"encoding/json"
+	"expvar"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+// runs makes names of published vars unique across runs of -count, since expvar can't unpublish them.
+var runs int32
+
+func TestPublishExpvar(t *testing.T) {
+	name := t.Name() + "_" + strconv.Itoa(int(atomic.AddInt32(&runs, 1)))
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 2
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := cstorage.New(config)
+
+	PublishExpvar(name, cache)
+	cache.Put("a", []byte("1"))
+	cache.Put("b", []byte("2"))
+	cache.Put("c", []byte("3"))
+	cache.Get("c")
+	cache.Get("a")
+
+	var got map[string]float64
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]float64{"size": 2, "capacity": 2, "hits": 1, "misses": 1, "hit_ratio": 0.5, "evicted": 1}
+	for field, value := range expected {
+		if got[field] != value {
+			t.Errorf("expected %s %v, got %v", field, value, got[field])
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("publishing the same name twice should panic")
+		}
+	}()
+	PublishExpvar(name, cache)
+}
@@ -0,0 +1,28 @@
+package cstorage
+
+// PutWithFlags function is same as Put, but it attaches opaque flags to the key, like flags of memcached.
+// CStorage doesn't interpret flags. They are for clients to record how data is encoded, such as content type or compression codec,
+// without wrapping data in their own envelope. Flags are replaced by every put, so Put without flags resets them to 0.
+func (s *CStorage) PutWithFlags(key string, data []byte, flags uint32) (hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, hit := s.put(key, data, s.config.Ttl, s.config.Sliding)
+	if n != nil && flags != 0 {
+		n.flags = flags
+		s.logPut(n)
+	}
+	return hit
+}
+
+// GetWithFlags function is same as Get, but it also returns flags given by PutWithFlags.
+func (s *CStorage) GetWithFlags(key string) (data []byte, flags uint32, hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, ok := s.get(key)
+	if !ok {
+		return nil, 0, false
+	}
+	return s.value(n), n.flags, true
+}
@@ -0,0 +1,47 @@
+package cstorage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestFlags(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.PutWithFlags("key1", []byte("1"), 42)
+	if data, flags, hit := cache.GetWithFlags("key1"); !hit || string(data) != "1" || flags != 42 {
+		t.Errorf("expected 1 with flags 42, got %q, %d, %v", data, flags, hit)
+	}
+	if info, _ := cache.GetWithInfo("key1"); info.Flags != 42 {
+		t.Errorf("expected flags 42 in info, got %d", info.Flags)
+	}
+
+	cache.Put("key1", []byte("2"))
+	if _, flags, _ := cache.GetWithFlags("key1"); flags != 0 {
+		t.Errorf("put should reset flags, got %d", flags)
+	}
+	if _, _, hit := cache.GetWithFlags("key2"); hit {
+		t.Error("key2 shouldn't be hit")
+	}
+
+	cache.PutMulti([]Entry{{Key: "key2", Data: []byte("2"), Flags: 7}})
+	if _, flags, _ := cache.GetWithFlags("key2"); flags != 7 {
+		t.Errorf("PutMulti should attach flags, got %d", flags)
+	}
+
+	var buf bytes.Buffer
+	if _, err := cache.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(config)
+	if _, err := restored.ReadSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, flags, _ := restored.GetWithFlags("key2"); flags != 7 {
+		t.Errorf("snapshot should keep flags, got %d", flags)
+	}
+}
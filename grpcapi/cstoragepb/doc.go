@@ -0,0 +1,5 @@
+// Package cstoragepb is protocol of cstorage.v1 gRPC API defined in cstorage.proto, with its generated messages, client and server interfaces.
+// Clients in other languages can be generated from cstorage.proto as well.
+package cstoragepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative cstorage.proto
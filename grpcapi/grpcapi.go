@@ -0,0 +1,187 @@
+// Package grpcapi provides gRPC server of cstorage.v1 API(see cstoragepb/cstorage.proto) wrapping CStorage,
+// so services in other languages can share a cache node with typed messages and streaming invalidation.
+// Go clients use cstoragepb.NewCStorageClient.
+//
+// It is a separate module, since gRPC and protobuf are heavy dependencies which users of the core module shouldn't pay for.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/grpcapi/cstoragepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server structure is cstoragepb.CStorageServer of single CStorage.
+type Server struct {
+	cstoragepb.UnimplementedCStorageServer
+	cache *cstorage.CStorage
+}
+
+var _ cstoragepb.CStorageServer = (*Server)(nil)
+
+// NewServer function returns Server of cache.
+func NewServer(cache *cstorage.CStorage) *Server {
+	return &Server{cache: cache}
+}
+
+// Register function registers Server of cache to s, e.g. *grpc.Server.
+func Register(s grpc.ServiceRegistrar, cache *cstorage.CStorage) *Server {
+	srv := NewServer(cache)
+	cstoragepb.RegisterCStorageServer(s, srv)
+	return srv
+}
+
+// Get returns data and expiration of key.
+func (s *Server) Get(ctx context.Context, req *cstoragepb.GetRequest) (*cstoragepb.GetResponse, error) {
+	data, expiresAt, hit := s.cache.GetWithExpiration(req.GetKey())
+	if !hit {
+		return &cstoragepb.GetResponse{}, nil
+	}
+	return &cstoragepb.GetResponse{Hit: true, Data: data, ExpiresAt: timestamppb.New(expiresAt)}, nil
+}
+
+// Put puts data of key, with ttl of CStorageConfig if ttl is not set or not positive.
+func (s *Server) Put(ctx context.Context, req *cstoragepb.PutRequest) (*cstoragepb.PutResponse, error) {
+	var hit bool
+	var err error
+	if ttl := req.GetTtl().AsDuration(); req.GetTtl() != nil && ttl > 0 {
+		hit, err = s.cache.PutWithTtlE(req.GetKey(), req.GetData(), ttl)
+	} else {
+		hit, err = s.cache.PutE(req.GetKey(), req.GetData())
+	}
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	return &cstoragepb.PutResponse{Updated: hit}, nil
+}
+
+// Delete deletes key.
+func (s *Server) Delete(ctx context.Context, req *cstoragepb.DeleteRequest) (*cstoragepb.DeleteResponse, error) {
+	return &cstoragepb.DeleteResponse{Deleted: s.cache.Delete(req.GetKey())}, nil
+}
+
+// Batch runs GetMulti, PutMulti and DeleteMulti.
+func (s *Server) Batch(ctx context.Context, req *cstoragepb.BatchRequest) (*cstoragepb.BatchResponse, error) {
+	resp := &cstoragepb.BatchResponse{}
+	if gets := req.GetGets(); len(gets) > 0 {
+		keys := make([]string, len(gets))
+		for i, g := range gets {
+			keys[i] = g.GetKey()
+		}
+		hits := s.cache.GetMulti(keys)
+		resp.Gets = make([]*cstoragepb.GetResponse, len(keys))
+		for i, key := range keys {
+			data, hit := hits[key]
+			resp.Gets[i] = &cstoragepb.GetResponse{Hit: hit, Data: data}
+		}
+	}
+	if puts := req.GetPuts(); len(puts) > 0 {
+		entries := make([]cstorage.Entry, len(puts))
+		for i, p := range puts {
+			entries[i] = cstorage.Entry{Key: p.GetKey(), Data: p.GetData()}
+			if ttl := p.GetTtl().AsDuration(); p.GetTtl() != nil && ttl > 0 {
+				entries[i].Ttl = ttl
+			}
+		}
+		hits := s.cache.PutMulti(entries)
+		resp.Puts = make([]*cstoragepb.PutResponse, len(entries))
+		for i, e := range entries {
+			resp.Puts[i] = &cstoragepb.PutResponse{Updated: hits[e.Key]}
+		}
+	}
+	if deletes := req.GetDeletes(); len(deletes) > 0 {
+		keys := make([]string, len(deletes))
+		for i, d := range deletes {
+			keys[i] = d.GetKey()
+		}
+		hits := s.cache.DeleteMulti(keys)
+		resp.Deletes = make([]*cstoragepb.DeleteResponse, len(keys))
+		for i, key := range keys {
+			resp.Deletes[i] = &cstoragepb.DeleteResponse{Deleted: hits[key]}
+		}
+	}
+	return resp, nil
+}
+
+// Stats returns counters of CStorage.
+func (s *Server) Stats(ctx context.Context, req *cstoragepb.StatsRequest) (*cstoragepb.StatsResponse, error) {
+	st := s.cache.Stats()
+	return &cstoragepb.StatsResponse{
+		Hits:          st.Hits,
+		Misses:        st.Misses,
+		Evicted:       st.Evicted,
+		Expired:       st.Expired,
+		Rejected:      st.Rejected,
+		Size:          st.Size,
+		Capacity:      st.Capacity,
+		MemoryUsage:   st.MemoryUsage,
+		DroppedEvents: st.DroppedEvents,
+		HitRatio:      st.HitRatio(),
+	}, nil
+}
+
+// Watch streams events of CStorage. Single key is watched by Watch of CStorage, and several keys are filtered from Subscribe.
+// Events dropped by slow stream are counted in Stats.DroppedEvents, same as any subscriber. It ends with UNAVAILABLE when CStorage is closed.
+func (s *Server) Watch(req *cstoragepb.WatchRequest, stream cstoragepb.CStorage_WatchServer) error {
+	var events <-chan cstorage.Event
+	var cancel func()
+	keys := req.GetKeys()
+	if len(keys) == 1 {
+		events, cancel = s.cache.Watch(keys[0])
+	} else {
+		events, cancel = s.cache.Subscribe()
+	}
+	defer cancel()
+	var filter map[string]bool
+	if len(keys) > 1 {
+		filter = make(map[string]bool, len(keys))
+		for _, key := range keys {
+			filter[key] = true
+		}
+	}
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case e, ok := <-events:
+			if !ok {
+				return status.Error(codes.Unavailable, cstorage.ErrClosed.Error())
+			}
+			if filter != nil && e.Type != cstorage.EventClear && !filter[e.Key] {
+				continue
+			}
+			if err := stream.Send(event(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// event converts e to message.
+func event(e cstorage.Event) *cstoragepb.Event {
+	return &cstoragepb.Event{
+		Type: cstoragepb.EventType(e.Type + 1),
+		Key:  e.Key,
+		Data: e.Data,
+		Time: timestamppb.New(e.Time),
+	}
+}
+
+// statusOf converts error of PutE to status.
+func statusOf(err error) error {
+	switch {
+	case errors.Is(err, cstorage.ErrValueTooLarge), errors.Is(err, cstorage.ErrRejected):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, cstorage.ErrClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
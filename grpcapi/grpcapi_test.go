@@ -0,0 +1,144 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/grpcapi/cstoragepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func newClient(t *testing.T, config cstorage.CStorageConfig, opts ...grpc.DialOption) (*cstorage.CStorage, cstoragepb.CStorageClient) {
+	cache := cstorage.New(config)
+	l := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	Register(s, cache)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+
+	opts = append(opts,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient("passthrough:///bufconn", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return cache, cstoragepb.NewCStorageClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity, MaxValueBytes: 8}
+	cache, client := newClient(t, config)
+
+	if resp, err := client.Get(ctx, &cstoragepb.GetRequest{Key: "a"}); err != nil || resp.Hit {
+		t.Errorf("a should miss, got %v %v", resp, err)
+	}
+	if resp, err := client.Put(ctx, &cstoragepb.PutRequest{Key: "a", Data: []byte("1"), Ttl: durationpb.New(time.Minute)}); err != nil || resp.Updated {
+		t.Errorf("a should be inserted, got %v %v", resp, err)
+	}
+	resp, err := client.Get(ctx, &cstoragepb.GetRequest{Key: "a"})
+	if err != nil || !resp.Hit || string(resp.Data) != "1" {
+		t.Fatalf("a should hit, got %v %v", resp, err)
+	}
+	if left := time.Until(resp.ExpiresAt.AsTime()); left > time.Minute || left < 50*time.Second {
+		t.Errorf("a should expire in a minute, got %v", left)
+	}
+	if _, err := client.Put(ctx, &cstoragepb.PutRequest{Key: "b", Data: []byte("too large value")}); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected RESOURCE_EXHAUSTED, got %v", err)
+	}
+	if resp, err := client.Delete(ctx, &cstoragepb.DeleteRequest{Key: "a"}); err != nil || !resp.Deleted {
+		t.Errorf("a should be deleted, got %v %v", resp, err)
+	}
+
+	cache.Put("x", []byte("1"))
+	batch, err := client.Batch(ctx, &cstoragepb.BatchRequest{
+		Gets:    []*cstoragepb.GetRequest{{Key: "x"}, {Key: "missing"}},
+		Puts:    []*cstoragepb.PutRequest{{Key: "x", Data: []byte("2")}, {Key: "y", Data: []byte("3")}},
+		Deletes: []*cstoragepb.DeleteRequest{{Key: "y"}, {Key: "missing"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !batch.Gets[0].Hit || string(batch.Gets[0].Data) != "1" || batch.Gets[1].Hit {
+		t.Errorf("unexpected gets %v", batch.Gets)
+	}
+	if !batch.Puts[0].Updated || batch.Puts[1].Updated {
+		t.Errorf("unexpected puts %v", batch.Puts)
+	}
+	if !batch.Deletes[0].Deleted || batch.Deletes[1].Deleted {
+		t.Errorf("unexpected deletes %v", batch.Deletes)
+	}
+
+	stats, err := client.Stats(ctx, &cstoragepb.StatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Size != 1 || stats.Capacity != capacity || stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("unexpected stats %v", stats)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity, EventValues: true}
+	cache, client := newClient(t, config)
+
+	for _, keys := range [][]string{{"a"}, {"a", "b"}, nil} {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err := client.Watch(ctx, &cstoragepb.WatchRequest{Keys: keys})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// server subscribes after it receives the request, which is not confirmed to the client
+		time.Sleep(20 * time.Millisecond)
+
+		cache.Put("c", []byte("0"))
+		cache.Put("a", []byte("1"))
+		cache.Delete("a")
+		cache.Clear()
+
+		var got []string
+		for len(got) < 3 {
+			e, err := stream.Recv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.Key == "c" {
+				if keys != nil {
+					t.Errorf("keys %v should not receive event of c", keys)
+				}
+				continue
+			}
+			got = append(got, e.Type.String()+" "+e.Key+" "+string(e.Data))
+		}
+		expected := "EVENT_TYPE_PUT a 1,EVENT_TYPE_DELETE a 1,EVENT_TYPE_CLEAR  "
+		if s := strings.Join(got, ","); s != expected {
+			t.Errorf("keys %v: expected %q, got %q", keys, expected, s)
+		}
+		cancel()
+	}
+
+	stream, err := client.Watch(context.Background(), &cstoragepb.WatchRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	cache.Close()
+	if _, err := stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected UNAVAILABLE after Close, got %v", err)
+	}
+}
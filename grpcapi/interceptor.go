@@ -0,0 +1,76 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// CacheOptions structure is configuration of UnaryClientInterceptor.
+// - Ttls: ttl of responses of each full method name(e.g. "/cstorage.v1.CStorage/Get"). Methods which are not listed are not cached
+// - Ttl: ttl of responses of methods which are not in Ttls. If 0, only methods in Ttls are cached
+type CacheOptions struct {
+	Ttls map[string]time.Duration
+	Ttl  time.Duration
+}
+
+// UnaryClientInterceptor function returns client interceptor which caches successful responses of unary RPCs in cache, keyed by method
+// and hash of request, so read-heavy RPCs are shielded by passing grpc.WithUnaryInterceptor to the connection, without changing call sites.
+// Requests are hashed by deterministic marshaling, and metadata is not part of the key, so methods whose responses depend on caller
+// (e.g. by auth token) should not be cached. Calls with SkipCache are sent to server, and their responses are cached.
+func UnaryClientInterceptor(cache *cstorage.CStorage, options CacheOptions) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ttl, ok := options.Ttls[method]
+		if !ok {
+			ttl = options.Ttl
+		}
+		in, isReq := req.(proto.Message)
+		out, isReply := reply.(proto.Message)
+		if ttl <= 0 || !isReq || !isReply {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		b, err := proto.MarshalOptions{Deterministic: true}.Marshal(in)
+		if err != nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		sum := sha256.Sum256(b)
+		key := method + "#" + hex.EncodeToString(sum[:])
+
+		if !skipped(opts) {
+			if data, hit := cache.Get(key); hit && proto.Unmarshal(data, out) == nil {
+				return nil
+			}
+		}
+		if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+			return err
+		}
+		if data, err := proto.Marshal(out); err == nil {
+			cache.PutWithTtl(key, data, ttl)
+		}
+		return nil
+	}
+}
+
+// SkipCache function returns grpc.CallOption which makes UnaryClientInterceptor send the call to server instead of using cached response,
+// refreshing the cache with its response.
+func SkipCache() grpc.CallOption {
+	return skipCache{}
+}
+
+type skipCache struct {
+	grpc.EmptyCallOption
+}
+
+func skipped(opts []grpc.CallOption) bool {
+	for _, o := range opts {
+		if _, ok := o.(skipCache); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package grpcapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/grpcapi/cstoragepb"
+	"google.golang.org/grpc"
+)
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	ctx := context.Background()
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity}
+	responses := cstorage.New(config)
+	interceptor := UnaryClientInterceptor(responses, CacheOptions{Ttls: map[string]time.Duration{cstoragepb.CStorage_Get_FullMethodName: time.Minute}})
+	server, client := newClient(t, config, grpc.WithUnaryInterceptor(interceptor))
+
+	server.Put("a", []byte("1"))
+	if resp, err := client.Get(ctx, &cstoragepb.GetRequest{Key: "a"}); err != nil || string(resp.Data) != "1" {
+		t.Fatalf("a should hit, got %v %v", resp, err)
+	}
+	server.Put("a", []byte("2"))
+	if resp, _ := client.Get(ctx, &cstoragepb.GetRequest{Key: "a"}); string(resp.Data) != "1" {
+		t.Errorf("cached response should be returned, got %q", resp.Data)
+	}
+	if resp, _ := client.Get(ctx, &cstoragepb.GetRequest{Key: "b"}); resp.Hit {
+		t.Error("different request should not share response")
+	}
+	if resp, _ := client.Get(ctx, &cstoragepb.GetRequest{Key: "a"}, SkipCache()); string(resp.Data) != "2" {
+		t.Errorf("SkipCache should call server, got %q", resp.Data)
+	}
+	if resp, _ := client.Get(ctx, &cstoragepb.GetRequest{Key: "a"}); string(resp.Data) != "2" {
+		t.Errorf("response of SkipCache should be cached, got %q", resp.Data)
+	}
+
+	before := responses.Stats().Size
+	client.Stats(ctx, &cstoragepb.StatsRequest{})
+	if responses.Stats().Size != before {
+		t.Error("method which is not listed should not be cached")
+	}
+}
@@ -0,0 +1,30 @@
+package cstorage
+
+// KeyHash function returns hash of key which CStorage uses internally, for TinyLFU admission filter and cardinality.
+// Caller which has long keys can compute it once(e.g. when key is made) and give it to batch functions, such as Entry.Hash of PutMulti
+// and GetMultiHashed, so CStorage doesn't hash the key again. Wrong hash doesn't break correctness, but makes admission and cardinality inaccurate.
+func KeyHash(key string) uint64 {
+	return hash64(key)
+}
+
+// hash returns hash of key, or 0 if neither TinyLFU nor cardinality is used, so key is not hashed for nothing.
+func (s *CStorage) hash(key string) uint64 {
+	if s.lfu == nil && s.cardinality == nil {
+		return 0
+	}
+	return hash64(key)
+}
+
+// hash64 is FNV-1a of key followed by finalizer of MurmurHash3, so every bits are well mixed.
+func hash64(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,48 @@
+package cstorage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyHash(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, TinyLFU: true}
+	cache := New(config)
+
+	long := strings.Repeat("k", 1024)
+	cache.Put(long, []byte("v"))
+	if n := cache.table[long]; n.hash != KeyHash(long) {
+		t.Errorf("hash of key should be kept in node, got %d", n.hash)
+	}
+
+	hits := cache.PutMulti([]Entry{
+		{Key: "a", Data: []byte("1"), Hash: KeyHash("a")},
+		{Key: "b", Data: []byte("2")},
+	})
+	if hits["a"] || hits["b"] {
+		t.Errorf("keys should be inserted, got %v", hits)
+	}
+	if cache.table["a"].hash != KeyHash("a") || cache.table["b"].hash != KeyHash("b") {
+		t.Errorf("hash should be given by entry or computed")
+	}
+
+	got := cache.GetMultiHashed([]string{"a", "b", "c"}, []uint64{KeyHash("a"), KeyHash("b"), KeyHash("c")})
+	if len(got) != 2 || string(got["a"]) != "1" || string(got["b"]) != "2" {
+		t.Errorf("expected a and b, got %v", got)
+	}
+}
+
+func TestKeyHashUnused(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.Put("key", []byte("v"))
+	if n := cache.table["key"]; n.hash != 0 {
+		t.Errorf("key should not be hashed when nothing uses it, got %d", n.hash)
+	}
+}
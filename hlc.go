@@ -0,0 +1,123 @@
+package cstorage
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamp structure is hybrid logical clock timestamp. Wall is physical time in unix nano, and Logical orders
+// events which have the same Wall. It stays close to physical time, but it never goes backward even if clocks of nodes are skewed,
+// since clock of a node is moved forward by timestamps it receives from others.
+type Timestamp struct {
+	Wall    int64
+	Logical uint32
+}
+
+// Before function returns true if t happened before u.
+func (t Timestamp) Before(u Timestamp) bool {
+	return t.Wall < u.Wall || (t.Wall == u.Wall && t.Logical < u.Logical)
+}
+
+// IsZero function returns true if t is zero Timestamp, which is given to keys put without CStorageConfig.HLC.
+func (t Timestamp) IsZero() bool {
+	return t == Timestamp{}
+}
+
+// String function formats t as "wall.logical", which is parsed by ParseTimestamp. It is for sending timestamp in text, such as HTTP header.
+func (t Timestamp) String() string {
+	return strconv.FormatInt(t.Wall, 10) + "." + strconv.FormatUint(uint64(t.Logical), 10)
+}
+
+// ParseTimestamp function parses Timestamp formatted by Timestamp.String.
+func ParseTimestamp(s string) (Timestamp, error) {
+	wall, logical, ok := strings.Cut(s, ".")
+	if !ok {
+		return Timestamp{}, errors.New("cstorage: invalid timestamp " + strconv.Quote(s))
+	}
+	w, err := strconv.ParseInt(wall, 10, 64)
+	if err != nil {
+		return Timestamp{}, errors.New("cstorage: invalid timestamp " + strconv.Quote(s))
+	}
+	l, err := strconv.ParseUint(logical, 10, 32)
+	if err != nil {
+		return Timestamp{}, errors.New("cstorage: invalid timestamp " + strconv.Quote(s))
+	}
+	return Timestamp{Wall: w, Logical: uint32(l)}, nil
+}
+
+// hlc is hybrid logical clock. Caller should hold the mutex of CStorage.
+type hlc struct {
+	last Timestamp
+}
+
+// now returns timestamp of local event.
+func (c *hlc) now() Timestamp {
+	wall := time.Now().UnixNano()
+	if wall > c.last.Wall {
+		c.last = Timestamp{Wall: wall}
+	} else {
+		c.last.Logical++
+	}
+	return c.last
+}
+
+// update moves clock forward by timestamp received from other node.
+func (c *hlc) update(remote Timestamp) {
+	wall := time.Now().UnixNano()
+	switch {
+	case wall > c.last.Wall && wall > remote.Wall:
+		c.last = Timestamp{Wall: wall}
+	case remote.Wall > c.last.Wall:
+		c.last = Timestamp{Wall: remote.Wall, Logical: remote.Logical + 1}
+	case c.last.Wall > remote.Wall:
+		c.last.Logical++
+	default:
+		if remote.Logical > c.last.Logical {
+			c.last.Logical = remote.Logical
+		}
+		c.last.Logical++
+	}
+}
+
+// Now function returns new timestamp from hybrid logical clock of CStorage, which is later than every timestamp
+// the CStorage has given or received. It is for stamping writes which are sent to other nodes.
+func (s *CStorage) Now() Timestamp {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.clock.now()
+}
+
+// PutWithTimestamp function puts data only if ts is later than timestamp of current data of key(last writer wins).
+// It is for applying writes from other nodes; clock of CStorage is moved forward by ts, so later local writes are stamped later than ts.
+// If timestamps are equal, data which is greater by bytes.Compare wins, so every node ends up with the same data regardless of order of writes.
+// It returns applied=true if data is put.
+func (s *CStorage) PutWithTimestamp(key string, data []byte, ts Timestamp) (applied bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.clock.update(ts)
+
+	if n, ok := s.table[key]; ok && !n.ttl.Before(s.now()) {
+		if ts.Before(n.stamp) || (ts == n.stamp && bytes.Compare(data, s.value(n)) <= 0) {
+			return false
+		}
+	}
+
+	n, _ := s.put(key, data, s.config.Ttl, s.config.Sliding)
+	if n == nil {
+		return false
+	}
+	n.stamp = ts
+	return true
+}
+
+// stamp stamps node with current timestamp if CStorageConfig.HLC is set. Caller should hold the mutex.
+func (s *CStorage) stamp(n *node) {
+	if s.config.HLC {
+		n.stamp = s.clock.now()
+	}
+}
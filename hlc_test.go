@@ -0,0 +1,60 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHLC(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, HLC: true}
+	cache := New(config)
+
+	cache.Put("key", []byte("1"))
+	first, _ := cache.GetWithInfo("key")
+	cache.Put("key", []byte("2"))
+	second, _ := cache.GetWithInfo("key")
+	if first.Timestamp.IsZero() || !first.Timestamp.Before(second.Timestamp) {
+		t.Errorf("timestamps should increase, got %+v and %+v", first.Timestamp, second.Timestamp)
+	}
+
+	// remote clock is far ahead of local clock
+	remote := Timestamp{Wall: time.Now().Add(time.Hour).UnixNano()}
+	if !cache.PutWithTimestamp("key", []byte("remote"), remote) {
+		t.Error("later write should be applied")
+	}
+	if cache.PutWithTimestamp("key", []byte("old"), second.Timestamp) {
+		t.Error("earlier write shouldn't be applied")
+	}
+	if now := cache.Now(); !remote.Before(now) {
+		t.Errorf("clock should be moved forward by remote timestamp, got %+v", now)
+	}
+
+	cache.Put("key", []byte("local"))
+	info, _ := cache.GetWithInfo("key")
+	if string(info.Data) != "local" || !remote.Before(info.Timestamp) {
+		t.Errorf("local write after remote one should be stamped later, got %+v", info.Timestamp)
+	}
+
+	tie := Timestamp{Wall: info.Timestamp.Wall + 1}
+	cache.PutWithTimestamp("tie", []byte("b"), tie)
+	if cache.PutWithTimestamp("tie", []byte("a"), tie) {
+		t.Error("smaller data shouldn't win tie")
+	}
+	if !cache.PutWithTimestamp("tie", []byte("c"), tie) {
+		t.Error("greater data should win tie")
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	ts := Timestamp{Wall: time.Now().UnixNano(), Logical: 3}
+	if parsed, err := ParseTimestamp(ts.String()); err != nil || parsed != ts {
+		t.Errorf("timestamp should be parsed back, got %+v %v", parsed, err)
+	}
+	for _, s := range []string{"", "1", "a.1", "1.a", "1.4294967296"} {
+		if _, err := ParseTimestamp(s); err == nil {
+			t.Errorf("%q should be invalid", s)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrUnauthenticated is returned by Authenticator when request has no valid credential.
+var ErrUnauthenticated = errors.New("httpapi: unauthenticated")
+
+// Authenticator interface verifies credential of request and returns principal, which is name of the caller(e.g. service name).
+// It is plugged into Auth, so the server can be wired into existing auth systems by implementing it.
+// Built-in ones are Tokens, ClientCert and HMAC, and they can be combined by Authenticators.
+type Authenticator interface {
+	Authenticate(r *http.Request) (principal string, err error)
+}
+
+// Auth structure is middleware which serves only authenticated requests. Others get 401.
+// Principal of the request can be read by PrincipalFrom in the next handler.
+type Auth struct {
+	next          http.Handler
+	authenticator Authenticator
+}
+
+// NewAuth function wraps next with Auth. Each listener of a server can have its own Authenticator.
+func NewAuth(next http.Handler, authenticator Authenticator) *Auth {
+	return &Auth{next: next, authenticator: authenticator}
+}
+
+// principalKey is context key of principal.
+type principalKey struct{}
+
+// ServeHTTP authenticates and serves the request.
+func (a *Auth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	principal, err := a.authenticator.Authenticate(r)
+	if err != nil {
+		http.Error(w, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+	a.next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+}
+
+// PrincipalFrom function returns principal of request authenticated by Auth.
+func PrincipalFrom(ctx context.Context) (principal string, ok bool) {
+	principal, ok = ctx.Value(principalKey{}).(string)
+	return principal, ok
+}
+
+// Authenticators is Authenticator which tries each one in order, and succeeds with the first one which succeeds.
+type Authenticators []Authenticator
+
+// Authenticate function returns principal of the first Authenticator which accepts the request.
+func (as Authenticators) Authenticate(r *http.Request) (string, error) {
+	for _, a := range as {
+		if principal, err := a.Authenticate(r); err == nil {
+			return principal, nil
+		}
+	}
+	return "", ErrUnauthenticated
+}
+
+// Tokens is Authenticator of bearer tokens, which maps token to principal. Token is given by "Authorization: Bearer <token>" header.
+type Tokens map[string]string
+
+// Authenticate function looks up bearer token of the request. Tokens are compared in constant time.
+func (ts Tokens) Authenticate(r *http.Request) (string, error) {
+	token, ok := authorization(r, "Bearer")
+	if !ok {
+		return "", ErrUnauthenticated
+	}
+
+	principal, found := "", false
+	for t, p := range ts {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			principal, found = p, true
+		}
+	}
+	if !found {
+		return "", ErrUnauthenticated
+	}
+	return principal, nil
+}
+
+// ClientCert is Authenticator of mutual TLS. Principal is common name of client certificate which is verified by the TLS server,
+// so http.Server should have TLSConfig with ClientAuth and ClientCAs. If Allowed is not empty, only common names in it are accepted.
+type ClientCert struct {
+	Allowed []string
+}
+
+// Authenticate function returns common name of verified client certificate.
+func (c ClientCert) Authenticate(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", ErrUnauthenticated
+	}
+
+	name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if len(c.Allowed) == 0 {
+		return name, nil
+	}
+	for _, allowed := range c.Allowed {
+		if name == allowed {
+			return name, nil
+		}
+	}
+	return "", ErrUnauthenticated
+}
+
+// HMAC is Authenticator of signed requests. Keys maps key id to secret, and key id is the principal.
+// If Lookup is set, it is used instead of Keys, so keys can be rotated while serving(e.g. by signing.Keyring).
+// Request is signed by SignRequest, and signature is valid only for MaxSkew(5 minutes if zero) from Date header, to limit replay.
+// Body is read to be hashed only after key id and Date are accepted, and at most MaxBody(32MiB if zero) bytes of it are read.
+type HMAC struct {
+	Keys    map[string][]byte
+	Lookup  func(keyID string) (key []byte, ok bool)
+	MaxSkew time.Duration
+	MaxBody int64
+}
+
+// hmacScheme is scheme of Authorization header of signed request.
+const hmacScheme = "CSTORAGE-HMAC-SHA256"
+
+// Authenticate function verifies signature of the request. Body is read to be verified and restored for next handler.
+func (h HMAC) Authenticate(r *http.Request) (string, error) {
+	cred, ok := authorization(r, hmacScheme)
+	if !ok {
+		return "", ErrUnauthenticated
+	}
+	keyID, sig, ok := strings.Cut(cred, ":")
+	if !ok {
+		return "", ErrUnauthenticated
+	}
+	var key []byte
+	if h.Lookup != nil {
+		key, ok = h.Lookup(keyID)
+	} else {
+		key, ok = h.Keys[keyID]
+	}
+	if !ok {
+		return "", ErrUnauthenticated
+	}
+
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return "", ErrUnauthenticated
+	}
+	skew := h.MaxSkew
+	if skew == 0 {
+		skew = 5 * time.Minute
+	}
+	if d := time.Since(date); d > skew || d < -skew {
+		return "", ErrUnauthenticated
+	}
+
+	got, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return "", ErrUnauthenticated
+	}
+	max := h.MaxBody
+	if max == 0 {
+		max = 32 << 20
+	}
+	expected, err := signature(r, key, max)
+	if err != nil || !hmac.Equal(got, expected) {
+		return "", ErrUnauthenticated
+	}
+	return keyID, nil
+}
+
+// SignRequest function signs request for HMAC with key of keyID. It sets Date header if it is missing.
+// Body is read to be signed and restored, so it should be called after body is set.
+func SignRequest(r *http.Request, keyID string, key []byte) error {
+	if r.Header.Get("Date") == "" {
+		r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	sig, err := signature(r, key, -1)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Authorization", hmacScheme+" "+keyID+":"+base64.StdEncoding.EncodeToString(sig))
+	return nil
+}
+
+// signature is HMAC-SHA256 of method, path with query, Date header and SHA-256 of body, separated by newline.
+// Body longer than max is an error, and it is not limited if max is negative.
+func signature(r *http.Request, key []byte, max int64) ([]byte, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		reader := r.Body
+		if max >= 0 {
+			reader = http.MaxBytesReader(nil, r.Body, max)
+		}
+		if body, err = io.ReadAll(reader); err != nil {
+			return nil, err
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	sum := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, key)
+	io.WriteString(mac, r.Method+"\n"+r.URL.RequestURI()+"\n"+r.Header.Get("Date")+"\n"+hex.EncodeToString(sum[:]))
+	return mac.Sum(nil), nil
+}
+
+// authorization returns credential of Authorization header with scheme.
+func authorization(r *http.Request, scheme string) (string, bool) {
+	h := r.Header.Get("Authorization")
+	if len(h) <= len(scheme)+1 || !strings.EqualFold(h[:len(scheme)], scheme) || h[len(scheme)] != ' ' {
+		return "", false
+	}
+	return h[len(scheme)+1:], true
+}
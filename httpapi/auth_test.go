@@ -0,0 +1,99 @@
+package httpapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuth(t *testing.T) {
+	var principal string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = PrincipalFrom(r.Context())
+	})
+	hmacKeys := HMAC{Keys: map[string][]byte{"replica": []byte("secret")}}
+	h := NewAuth(next, Authenticators{Tokens{"t0ken": "dashboard"}, hmacKeys, ClientCert{Allowed: []string{"worker"}}})
+
+	serve := func(r *http.Request) int {
+		principal = ""
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	r := httptest.NewRequest("GET", "/keys/a", nil)
+	if code := serve(r); code != http.StatusUnauthorized {
+		t.Errorf("request without credential should be 401, got %d", code)
+	}
+	r.Header.Set("Authorization", "Bearer wrong")
+	if code := serve(r); code != http.StatusUnauthorized {
+		t.Errorf("wrong token should be 401, got %d", code)
+	}
+	r.Header.Set("Authorization", "Bearer t0ken")
+	if code := serve(r); code != http.StatusOK || principal != "dashboard" {
+		t.Errorf("token should be accepted as dashboard, got %d %q", code, principal)
+	}
+
+	r = httptest.NewRequest("PUT", "/keys/a?ttl=1m", strings.NewReader("data"))
+	SignRequest(r, "replica", []byte("secret"))
+	if code := serve(r); code != http.StatusOK || principal != "replica" {
+		t.Errorf("signed request should be accepted as replica, got %d %q", code, principal)
+	}
+
+	r = httptest.NewRequest("PUT", "/keys/a", strings.NewReader("data"))
+	SignRequest(r, "replica", []byte("secret"))
+	r.Body = http.NoBody
+	if code := serve(r); code != http.StatusUnauthorized {
+		t.Errorf("tampered request should be 401, got %d", code)
+	}
+
+	r = httptest.NewRequest("GET", "/keys/a", nil)
+	r.Header.Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	SignRequest(r, "replica", []byte("secret"))
+	if code := serve(r); code != http.StatusUnauthorized {
+		t.Errorf("stale request should be 401, got %d", code)
+	}
+
+	r = httptest.NewRequest("GET", "/keys/a", nil)
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "worker"}}}}}
+	if code := serve(r); code != http.StatusOK || principal != "worker" {
+		t.Errorf("client certificate should be accepted as worker, got %d %q", code, principal)
+	}
+	r.TLS.VerifiedChains[0][0].Subject.CommonName = "intruder"
+	if code := serve(r); code != http.StatusUnauthorized {
+		t.Errorf("client certificate which is not allowed should be 401, got %d", code)
+	}
+}
+
+func TestHMACBody(t *testing.T) {
+	h := HMAC{Keys: map[string][]byte{"replica": []byte("secret")}, MaxBody: 16}
+
+	body := &countingReader{}
+	r := httptest.NewRequest("PUT", "/keys/a", body)
+	r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	r.Header.Set("Authorization", hmacScheme+" unknown:c2ln")
+	if _, err := h.Authenticate(r); err != ErrUnauthenticated || body.read != 0 {
+		t.Errorf("unknown key should be refused before body is read, got %v and %d bytes read", err, body.read)
+	}
+	r.Header.Set("Authorization", hmacScheme+" replica:c2ln")
+	r.Header.Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	if _, err := h.Authenticate(r); err != ErrUnauthenticated || body.read != 0 {
+		t.Errorf("stale request should be refused before body is read, got %v and %d bytes read", err, body.read)
+	}
+
+	r = httptest.NewRequest("PUT", "/keys/a", strings.NewReader(strings.Repeat("x", 17)))
+	SignRequest(r, "replica", []byte("secret"))
+	if _, err := h.Authenticate(r); err != ErrUnauthenticated {
+		t.Errorf("body longer than MaxBody should be refused, got %v", err)
+	}
+	r = httptest.NewRequest("PUT", "/keys/a", strings.NewReader(strings.Repeat("x", 16)))
+	SignRequest(r, "replica", []byte("secret"))
+	if principal, err := h.Authenticate(r); err != nil || principal != "replica" {
+		t.Errorf("body within MaxBody should be accepted, got %q %v", principal, err)
+	}
+}
@@ -0,0 +1,248 @@
+// Package httpapi provides http.Handler which exposes CStorage as REST API, so the cache can be used from curl or dashboards.
+//
+// Endpoints:
+//
+//	GET    /keys/{key}              returns data of key as body, 404 if missing. Timestamp of data is in X-Cstorage-Timestamp header
+//	                                if it has one(see cstorage.CStorageConfig.HLC)
+//	PUT    /keys/{key}?ttl=10m      puts body as data of key, ttl is optional. 413 if body is longer than MaxValueBytes,
+//	                                507 if key is rejected, 503 if cache is closed. With X-Cstorage-Timestamp header, data is put by
+//	                                cstorage.PutWithTimestamp without ttl, and 412 if it is not applied(e.g. data of key is newer)
+//	DELETE /keys/{key}              deletes key, 404 if missing
+//	GET    /keys?after=&limit=      lists keys in lexical order, after is the last key of previous page
+//	DELETE /keys                    clears every keys
+//	GET    /stats                   returns cstorage.Stats as JSON
+//	GET    /stats/patterns          returns cstorage.Patterns as JSON, wasted key patterns first
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+// DefaultLimit is page size of key listing when limit is not given, and MaxLimit is its upper bound.
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// TimestampHeader is header of hybrid logical clock timestamp of data, formatted by cstorage.Timestamp.String.
+const TimestampHeader = "X-Cstorage-Timestamp"
+
+// Handler structure serves REST API of single CStorage.
+type Handler struct {
+	cache *cstorage.CStorage
+}
+
+// NewHandler function returns Handler of cache.
+func NewHandler(cache *cstorage.CStorage) *Handler {
+	return &Handler{cache: cache}
+}
+
+// KeyList is response body of key listing. Next is the value for after parameter of next page, empty if it is the last page.
+type KeyList struct {
+	Keys []string `json:"keys"`
+	Next string   `json:"next,omitempty"`
+}
+
+// StatsResponse is response body of /stats.
+type StatsResponse struct {
+	cstorage.Stats
+	HitRatio float64
+}
+
+// ServeHTTP routes request to each endpoint.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+	switch {
+	case path == "/stats":
+		h.stats(w, r)
+	case path == "/stats/patterns":
+		h.patterns(w, r)
+	case path == "/keys" || path == "/keys/":
+		h.keys(w, r)
+	case strings.HasPrefix(path, "/keys/"):
+		key, err := url.PathUnescape(strings.TrimPrefix(path, "/keys/"))
+		if err != nil {
+			http.Error(w, "invalid key", http.StatusBadRequest)
+			return
+		}
+		h.key(w, r, key)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) key(w http.ResponseWriter, r *http.Request, key string) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		info, hit := h.cache.GetWithInfo(key)
+		if !hit {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+		if !info.Timestamp.IsZero() {
+			w.Header().Set(TimestampHeader, info.Timestamp.String())
+		}
+		w.Write(info.Data)
+	case http.MethodPut:
+		// body is read one byte beyond the limit, so too large body is told from error of reading without reading whole of it
+		max := h.cache.MaxValueBytes()
+		if max > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, int64(max)+1)
+		}
+		data, err := io.ReadAll(r.Body)
+		if max > 0 && len(data) > max {
+			http.Error(w, cstorage.ErrValueTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if stamp := r.Header.Get(TimestampHeader); stamp != "" {
+			h.putStamped(w, r, key, data, stamp)
+			return
+		}
+		var hit bool
+		if ttl := r.URL.Query().Get("ttl"); ttl != "" {
+			d, perr := time.ParseDuration(ttl)
+			if perr != nil || d <= 0 {
+				http.Error(w, "invalid ttl", http.StatusBadRequest)
+				return
+			}
+			hit, err = h.cache.PutWithTtlE(key, data, d)
+		} else {
+			hit, err = h.cache.PutE(key, data)
+		}
+		switch {
+		case errors.Is(err, cstorage.ErrValueTooLarge):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		case errors.Is(err, cstorage.ErrRejected):
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+			return
+		case errors.Is(err, cstorage.ErrClosed):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if hit {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+	case http.MethodDelete:
+		if !h.cache.Delete(key) {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, "GET, HEAD, PUT, DELETE")
+	}
+}
+
+// putStamped puts data of key by PutWithTimestamp, which is for copying data between nodes without making it look newer.
+func (h *Handler) putStamped(w http.ResponseWriter, r *http.Request, key string, data []byte, stamp string) {
+	ts, err := cstorage.ParseTimestamp(stamp)
+	if err != nil {
+		http.Error(w, "invalid timestamp", http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("ttl") != "" {
+		http.Error(w, "ttl is not supported with timestamp", http.StatusBadRequest)
+		return
+	}
+	if !h.cache.PutWithTimestamp(key, data, ts) {
+		http.Error(w, "not applied", http.StatusPreconditionFailed)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) keys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		limit := DefaultLimit
+		if l := q.Get("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n <= 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		if limit > MaxLimit {
+			limit = MaxLimit
+		}
+		writeJSON(w, h.list(q.Get("after"), limit))
+	case http.MethodDelete:
+		h.cache.Clear()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, "GET, DELETE")
+	}
+}
+
+// list returns at most limit keys which are greater than after in lexical order.
+func (h *Handler) list(after string, limit int) KeyList {
+	var keys []string
+	h.cache.IterateKeys(func(key string) bool {
+		if key > after {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	sort.Strings(keys)
+
+	list := KeyList{Keys: keys}
+	if len(keys) > limit {
+		list.Keys = keys[:limit]
+		list.Next = keys[limit-1]
+	}
+	if list.Keys == nil {
+		list.Keys = []string{}
+	}
+	return list
+}
+
+func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, "GET")
+		return
+	}
+	st := h.cache.Stats()
+	writeJSON(w, StatsResponse{Stats: st, HitRatio: st.HitRatio()})
+}
+
+func (h *Handler) patterns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, "GET")
+		return
+	}
+	writeJSON(w, h.cache.Patterns())
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,149 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+func do(t *testing.T, h http.Handler, method, target, body string) *http.Response {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec.Result()
+}
+
+func TestHandler(t *testing.T) {
+	cache := cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 10, MaxValueBytes: 10})
+	h := NewHandler(cache)
+
+	if res := do(t, h, "PUT", "/keys/user%2F1", "one"); res.StatusCode != http.StatusCreated {
+		t.Errorf("first put should be 201, got %d", res.StatusCode)
+	}
+	if res := do(t, h, "PUT", "/keys/large", "more than ten bytes"); res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("too large put should be 413, got %d", res.StatusCode)
+	}
+	if res := do(t, h, "PUT", "/keys/user%2F1?ttl=1m", "uno"); res.StatusCode != http.StatusNoContent {
+		t.Errorf("second put should be 204, got %d", res.StatusCode)
+	}
+	do(t, h, "PUT", "/keys/a", "a")
+	do(t, h, "PUT", "/keys/b", "b")
+
+	res := do(t, h, "GET", "/keys/user%2F1", "")
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK || string(body) != "uno" {
+		t.Errorf("expected uno, got %d %s", res.StatusCode, body)
+	}
+
+	var list KeyList
+	json.NewDecoder(do(t, h, "GET", "/keys?limit=2", "").Body).Decode(&list)
+	if len(list.Keys) != 2 || list.Keys[0] != "a" || list.Next != "b" {
+		t.Errorf("unexpected first page %+v", list)
+	}
+	list = KeyList{}
+	json.NewDecoder(do(t, h, "GET", "/keys?limit=2&after=b", "").Body).Decode(&list)
+	if len(list.Keys) != 1 || list.Keys[0] != "user/1" || list.Next != "" {
+		t.Errorf("unexpected second page %+v", list)
+	}
+
+	if res := do(t, h, "DELETE", "/keys/a", ""); res.StatusCode != http.StatusNoContent {
+		t.Errorf("delete should be 204, got %d", res.StatusCode)
+	}
+	if res := do(t, h, "GET", "/keys/a", ""); res.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted key should be 404, got %d", res.StatusCode)
+	}
+
+	var st StatsResponse
+	json.NewDecoder(do(t, h, "GET", "/stats", "").Body).Decode(&st)
+	if st.Size != 2 || st.Hits != 1 || st.Misses != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+
+	var patterns []cstorage.Pattern
+	json.NewDecoder(do(t, h, "GET", "/stats/patterns", "").Body).Decode(&patterns)
+	if len(patterns) != 2 || patterns[1].Pattern != "user/{n}" || patterns[1].Hits != 1 {
+		t.Errorf("unexpected patterns %+v", patterns)
+	}
+
+	do(t, h, "DELETE", "/keys", "")
+	if cache.Size() != 0 {
+		t.Error("delete /keys should clear the cache")
+	}
+}
+
+// countingReader is endless body which counts bytes read from it.
+type countingReader struct {
+	read int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.read += len(p)
+	return len(p), nil
+}
+
+func TestHandlerPutErrors(t *testing.T) {
+	cache := cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 10, MaxValueBytes: 100, Weigher: func(key string, data []byte) int64 {
+		return int64(len(data))
+	}})
+	h := NewHandler(cache)
+
+	body := &countingReader{}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("PUT", "/keys/huge", body))
+	if rec.Code != http.StatusRequestEntityTooLarge || body.read > 1<<20 {
+		t.Errorf("huge body should be 413 without reading it, got %d after %d bytes", rec.Code, body.read)
+	}
+	if res := do(t, h, "PUT", "/keys/heavy", "heavier than capacity"); res.StatusCode != http.StatusInsufficientStorage {
+		t.Errorf("rejected put should be 507, got %d", res.StatusCode)
+	}
+	cache.Close()
+	if res := do(t, h, "PUT", "/keys/a", "a"); res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("put to closed cache should be 503, got %d", res.StatusCode)
+	}
+}
+
+func TestHandlerTimestamp(t *testing.T) {
+	cache := cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 10, HLC: true})
+	h := NewHandler(cache)
+
+	do(t, h, "PUT", "/keys/a", "1")
+	res := do(t, h, "GET", "/keys/a", "")
+	stamp, err := cstorage.ParseTimestamp(res.Header.Get(TimestampHeader))
+	if err != nil || stamp.IsZero() {
+		t.Fatalf("timestamp of data should be returned, got %q", res.Header.Get(TimestampHeader))
+	}
+
+	put := func(target, body string, ts cstorage.Timestamp) int {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", target, strings.NewReader(body))
+		r.Header.Set(TimestampHeader, ts.String())
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+	older := cstorage.Timestamp{Wall: stamp.Wall - 1}
+	if code := put("/keys/a", "old", older); code != http.StatusPreconditionFailed {
+		t.Errorf("older data should be 412, got %d", code)
+	}
+	newer := cstorage.Timestamp{Wall: stamp.Wall + 1}
+	if code := put("/keys/a", "new", newer); code != http.StatusNoContent {
+		t.Errorf("newer data should be 204, got %d", code)
+	}
+	if info, _ := cache.GetWithInfo("a"); string(info.Data) != "new" || info.Timestamp != newer {
+		t.Errorf("newer data should be put with its timestamp, got %q %+v", info.Data, info.Timestamp)
+	}
+	if code := put("/keys/a?ttl=1m", "new", newer); code != http.StatusBadRequest {
+		t.Errorf("ttl with timestamp should be 400, got %d", code)
+	}
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/keys/a", strings.NewReader("x"))
+	r.Header.Set(TimestampHeader, "yesterday")
+	if h.ServeHTTP(rec, r); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid timestamp should be 400, got %d", rec.Code)
+	}
+}
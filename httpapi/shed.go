@@ -0,0 +1,80 @@
+package httpapi
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// Priority of request for load shedding. Lower priority is shed first.
+type Priority int
+
+const (
+	// PriorityLow is for bulk operations such as key listing and clear.
+	PriorityLow Priority = iota
+	// PriorityNormal is for writes.
+	PriorityNormal
+	// PriorityHigh is for reads, which are never shed.
+	PriorityHigh
+)
+
+// DefaultPriority function classifies request of Handler; reads are high, writes are normal, and the others are low.
+func DefaultPriority(r *http.Request) Priority {
+	path := r.URL.EscapedPath()
+	if !strings.HasPrefix(path, "/keys/") || path == "/keys/" {
+		if path == "/stats" {
+			return PriorityHigh
+		}
+		return PriorityLow
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return PriorityHigh
+	}
+	return PriorityNormal
+}
+
+// Shedder structure is middleware which tracks requests in flight and fast-fails lower priority requests when there are too many,
+// so the server stays responsive for reads during overload instead of slowing down every requests.
+// - When in-flight requests exceed Threshold, PriorityLow requests are shed
+// - When they exceed twice of Threshold, PriorityNormal requests are shed too
+// Shed request gets 503 with Retry-After header.
+type Shedder struct {
+	next      http.Handler
+	threshold int64
+	priority  func(*http.Request) Priority
+	inFlight  int64
+	shed      int64
+}
+
+// NewShedder function wraps next with Shedder. If priority is nil, DefaultPriority is used.
+func NewShedder(next http.Handler, threshold int, priority func(*http.Request) Priority) *Shedder {
+	if priority == nil {
+		priority = DefaultPriority
+	}
+	return &Shedder{next: next, threshold: int64(threshold), priority: priority}
+}
+
+// ServeHTTP serves or sheds the request.
+func (s *Shedder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	depth := atomic.AddInt64(&s.inFlight, 1)
+	defer atomic.AddInt64(&s.inFlight, -1)
+
+	p := s.priority(r)
+	if (p <= PriorityLow && depth > s.threshold) || (p <= PriorityNormal && depth > 2*s.threshold) {
+		atomic.AddInt64(&s.shed, 1)
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "server overloaded", http.StatusServiceUnavailable)
+		return
+	}
+	s.next.ServeHTTP(w, r)
+}
+
+// InFlight returns number of requests currently in flight.
+func (s *Shedder) InFlight() int64 {
+	return atomic.LoadInt64(&s.inFlight)
+}
+
+// Shed returns number of requests shed so far.
+func (s *Shedder) Shed() int64 {
+	return atomic.LoadInt64(&s.shed)
+}
@@ -0,0 +1,54 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestShedder(t *testing.T) {
+	block := make(chan struct{})
+	var started sync.WaitGroup
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Block") != "" {
+			started.Done()
+			<-block
+		}
+	})
+	s := NewShedder(slow, 2, nil)
+
+	var done sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		started.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			r := httptest.NewRequest("GET", "/keys/a", nil)
+			r.Header.Set("X-Block", "1")
+			s.ServeHTTP(httptest.NewRecorder(), r)
+		}()
+	}
+	started.Wait()
+
+	status := func(method, target string) int {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec.Code
+	}
+	if code := status("GET", "/keys"); code != http.StatusServiceUnavailable {
+		t.Errorf("listing should be shed, got %d", code)
+	}
+	if code := status("PUT", "/keys/a"); code != http.StatusOK {
+		t.Errorf("write should not be shed yet, got %d", code)
+	}
+	if code := status("GET", "/keys/a"); code != http.StatusOK {
+		t.Errorf("read should never be shed, got %d", code)
+	}
+	if s.Shed() != 1 || s.InFlight() != 2 {
+		t.Errorf("unexpected counters shed=%d inFlight=%d", s.Shed(), s.InFlight())
+	}
+
+	close(block)
+	done.Wait()
+}
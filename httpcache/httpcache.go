@@ -0,0 +1,309 @@
+// Package httpcache provides Transport, http.RoundTripper which caches responses of GET requests in CStorage following HTTP caching(RFC 9111)
+// as a private cache. Fresh responses are returned from CStorage without request. Stale responses with ETag or Last-Modified are revalidated
+// by conditional request, and reused if the server responds 304 Not Modified, so outbound API calls become cacheable by wrapping http.Client.
+// On the server side, Middleware caches responses rendered by handlers.
+package httpcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+// XFromCache is header set to "1" on responses which are returned from cache, including ones revalidated by 304.
+const XFromCache = "X-From-Cache"
+
+// Options structure is configuration of Transport.
+// - Transport: http.RoundTripper which sends requests, http.DefaultTransport if nil
+// - StaleTtl: how long responses with ETag or Last-Modified are kept after they become stale, for revalidation. 1h if 0
+type Options struct {
+	Transport http.RoundTripper
+	StaleTtl  time.Duration
+}
+
+// Transport structure is http.RoundTripper caching responses in CStorage. Keys of CStorage are URLs of requests.
+type Transport struct {
+	cache   *cstorage.CStorage
+	options Options
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// New function returns Transport which caches responses in cache.
+func New(cache *cstorage.CStorage, options Options) *Transport {
+	if options.Transport == nil {
+		options.Transport = http.DefaultTransport
+	}
+	if options.StaleTtl <= 0 {
+		options.StaleTtl = time.Hour
+	}
+	return &Transport{cache: cache, options: options}
+}
+
+// Client function returns http.Client which sends requests through t.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// entry is cached response, which is encoded by gob in CStorage.
+type entry struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Vary has values of request headers which are named by Vary of response
+	Vary    http.Header
+	Expires time.Time
+}
+
+// RoundTrip function returns cached response of GET request if it is fresh, or sends request otherwise, revalidating stale response
+// if it has validators. Requests with Range, with conditional headers of caller, or with Cache-Control: no-store bypass the cache,
+// and Cache-Control: no-cache forces revalidation. Successful requests of unsafe methods(e.g. POST) invalidate cached response of the URL.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.URL.String()
+	if req.Method != http.MethodGet {
+		resp, err := t.options.Transport.RoundTrip(req)
+		if err == nil && !safe(req.Method) && resp.StatusCode < 400 {
+			t.cache.Delete(key)
+		}
+		return resp, err
+	}
+
+	directives := cacheControl(req.Header)
+	if _, ok := directives["no-store"]; ok || req.Header.Get("Range") != "" || conditional(req.Header) {
+		return t.options.Transport.RoundTrip(req)
+	}
+
+	e, cached := t.load(key, req)
+	out := req
+	if cached {
+		if _, ok := directives["no-cache"]; !ok && time.Now().Before(e.Expires) {
+			return e.response(req), nil
+		}
+		etag, modified := e.Header.Get("ETag"), e.Header.Get("Last-Modified")
+		if etag != "" || modified != "" {
+			out = req.Clone(req.Context())
+			if etag != "" {
+				out.Header.Set("If-None-Match", etag)
+			}
+			if modified != "" {
+				out.Header.Set("If-Modified-Since", modified)
+			}
+		}
+	}
+
+	resp, err := t.options.Transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	received := time.Now()
+	if cached && out != req && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		for name, values := range resp.Header {
+			if name != "Content-Length" && name != "Transfer-Encoding" {
+				e.Header[name] = values
+			}
+		}
+		e.Expires = expires(e.Header, received)
+		t.store(key, e)
+		return e.response(req), nil
+	}
+	return t.storeResponse(key, req, resp, received)
+}
+
+// storeResponse stores resp if it is cacheable, and returns resp whose body can be read again.
+func (t *Transport) storeResponse(key string, req *http.Request, resp *http.Response, received time.Time) (*http.Response, error) {
+	if !cacheable(resp) {
+		if resp.StatusCode < 500 {
+			t.cache.Delete(key)
+		}
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	e := &entry{StatusCode: resp.StatusCode, Header: resp.Header.Clone(), Body: body, Expires: expires(resp.Header, received)}
+	for _, name := range varied(resp.Header) {
+		if e.Vary == nil {
+			e.Vary = make(http.Header)
+		}
+		e.Vary[name] = req.Header.Values(name)
+	}
+	t.store(key, e)
+	return resp, nil
+}
+
+// load returns cached entry of key, if its Vary matches req.
+func (t *Transport) load(key string, req *http.Request) (*entry, bool) {
+	data, hit := t.cache.Get(key)
+	if !hit {
+		return nil, false
+	}
+	var e entry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&e); err != nil {
+		return nil, false
+	}
+	for name, values := range e.Vary {
+		if strings.Join(values, ",") != strings.Join(req.Header.Values(name), ",") {
+			return nil, false
+		}
+	}
+	return &e, true
+}
+
+// store puts e until it expires, or StaleTtl after it if it can be revalidated.
+func (t *Transport) store(key string, e *entry) {
+	ttl := time.Until(e.Expires)
+	if e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != "" {
+		if ttl < 0 {
+			ttl = 0
+		}
+		ttl += t.options.StaleTtl
+	}
+	if ttl <= 0 {
+		t.cache.Delete(key)
+		return
+	}
+
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(e); err != nil {
+		return
+	}
+	t.cache.PutWithTtl(key, b.Bytes(), ttl)
+}
+
+// response returns new response of req from e.
+func (e *entry) response(req *http.Request) *http.Response {
+	header := e.Header.Clone()
+	header.Set(XFromCache, "1")
+	if date, err := http.ParseTime(header.Get("Date")); err == nil {
+		if age := time.Since(date); age > 0 {
+			header.Set("Age", strconv.Itoa(int(age.Seconds())))
+		}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// cacheable reports whether resp can be stored; it has a status which is cacheable by default, doesn't forbid storing,
+// and is fresh for a while or has validators.
+func cacheable(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusMultipleChoices, http.StatusMovedPermanently,
+		http.StatusNotFound, http.StatusGone:
+	default:
+		return false
+	}
+	if _, ok := cacheControl(resp.Header)["no-store"]; ok {
+		return false
+	}
+	for _, name := range varied(resp.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+	if resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "" {
+		return true
+	}
+	return expires(resp.Header, time.Now()).After(time.Now())
+}
+
+// expires returns the time when response with header, which is received at received, becomes stale.
+// Freshness is given by max-age, or by Expires relative to Date. Without them, or with no-cache, response is stale at once.
+func expires(header http.Header, received time.Time) time.Time {
+	directives := cacheControl(header)
+	if _, ok := directives["no-cache"]; ok {
+		return received
+	}
+
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		date = received
+	}
+	var lifetime time.Duration
+	if v, ok := directives["max-age"]; ok {
+		seconds, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return received
+		}
+		lifetime = time.Duration(seconds) * time.Second
+	} else if v := header.Get("Expires"); v != "" {
+		at, err := http.ParseTime(v)
+		if err != nil {
+			return received
+		}
+		lifetime = at.Sub(date)
+	}
+
+	// age is how old response is when it is received, by Age of upstream caches and by Date
+	var age time.Duration
+	if seconds, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil {
+		age = time.Duration(seconds) * time.Second
+	}
+	if d := received.Sub(date); d > 0 {
+		age += d
+	}
+	return received.Add(lifetime - age)
+}
+
+// cacheControl returns directives of Cache-Control in header, by lower case names.
+func cacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+// varied returns canonical names of request headers listed in Vary of response header.
+func varied(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// conditional reports whether request header has conditions, which caller wants to be sent as they are.
+func conditional(header http.Header) bool {
+	return header.Get("If-None-Match") != "" || header.Get("If-Modified-Since") != "" ||
+		header.Get("If-Match") != "" || header.Get("If-Unmodified-Since") != ""
+}
+
+// safe reports whether method doesn't change resource.
+func safe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+func newClient() *http.Client {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity}
+	return New(cstorage.New(config), Options{}).Client()
+}
+
+// get returns body of response, and whether it is from cache.
+func get(t *testing.T, client *http.Client, url string, header http.Header) (string, bool) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body), resp.Header.Get(XFromCache) == "1"
+}
+
+func TestMaxAge(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/expired":
+			w.Header().Set("Expires", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+		case "/private":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		io.WriteString(w, strings.Repeat("x", int(n)))
+	}))
+	defer srv.Close()
+	client := newClient()
+
+	first, _ := get(t, client, srv.URL+"/fresh", nil)
+	if body, cached := get(t, client, srv.URL+"/fresh", nil); !cached || body != first {
+		t.Errorf("fresh response should be from cache, got %q %v", body, cached)
+	}
+	if _, cached := get(t, client, srv.URL+"/fresh", http.Header{"Cache-Control": {"no-cache"}}); cached {
+		t.Error("no-cache request should go to server, since response has no validators")
+	}
+	for _, path := range []string{"/expired", "/private"} {
+		get(t, client, srv.URL+path, nil)
+		if _, cached := get(t, client, srv.URL+path, nil); cached {
+			t.Errorf("%s should not be from cache", path)
+		}
+	}
+	if requests != 6 {
+		t.Errorf("expected 6 requests, got %d", requests)
+	}
+}
+
+func TestRevalidate(t *testing.T) {
+	var requests, notModified int32
+	var version atomic.Value
+	version.Store("v1")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		v := version.Load().(string)
+		etag := `"` + v + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-None-Match") == etag {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		io.WriteString(w, v)
+	}))
+	defer srv.Close()
+	client := newClient()
+
+	get(t, client, srv.URL, nil)
+	if body, cached := get(t, client, srv.URL, nil); !cached || body != "v1" {
+		t.Errorf("304 should return cached body, got %q %v", body, cached)
+	}
+	version.Store("v2")
+	if body, cached := get(t, client, srv.URL, nil); cached || body != "v2" {
+		t.Errorf("changed response should be from server, got %q %v", body, cached)
+	}
+	if requests != 3 || notModified != 1 {
+		t.Errorf("expected 3 requests and 1 revalidation, got %d %d", requests, notModified)
+	}
+}
+
+func TestVary(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		io.WriteString(w, r.Header.Get("Accept-Language"))
+	}))
+	defer srv.Close()
+	client := newClient()
+
+	en := http.Header{"Accept-Language": {"en"}}
+	get(t, client, srv.URL, en)
+	if body, cached := get(t, client, srv.URL, http.Header{"Accept-Language": {"ko"}}); cached || body != "ko" {
+		t.Errorf("different Accept-Language should miss, got %q %v", body, cached)
+	}
+	if body, cached := get(t, client, srv.URL, http.Header{"Accept-Language": {"ko"}}); !cached || body != "ko" {
+		t.Errorf("same Accept-Language should hit, got %q %v", body, cached)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, r.Method)
+	}))
+	defer srv.Close()
+	client := newClient()
+
+	get(t, client, srv.URL, nil)
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, cached := get(t, client, srv.URL, nil); cached {
+		t.Error("POST should invalidate cached response")
+	}
+}
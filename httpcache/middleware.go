@@ -0,0 +1,218 @@
+package httpcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+// Middleware structure caches responses rendered by handlers on the server side, so following requests of the same key are served from CStorage
+// without calling the handler. Only responses of GET with status 200 are cached, unless they have Cache-Control: no-store or private, or Set-Cookie.
+// Responses to requests with Authorization are cached only if they have Cache-Control: public, s-maxage or must-revalidate, since key
+// doesn't have identity of the user(RFC 9111 section 3.5).
+type Middleware struct {
+	cache *cstorage.CStorage
+}
+
+// NewMiddleware function returns Middleware which stores responses in cache.
+func NewMiddleware(cache *cstorage.CStorage) *Middleware {
+	return &Middleware{cache: cache}
+}
+
+// URLKey function is key function of Middleware which keys responses by host and request URI.
+func URLKey(r *http.Request) string {
+	return r.Host + r.URL.RequestURI()
+}
+
+// Cache function returns handler which serves cached response of the key which keyFn returns, or calls h and caches its response for ttl.
+// If keyFn returns empty string, request is not cached. If keyFn is nil, URLKey is used. If response has Vary, each variant of the listed
+// request headers is cached separately under the key.
+func (m *Middleware) Cache(h http.Handler, keyFn func(*http.Request) string, ttl time.Duration) http.Handler {
+	if keyFn == nil {
+		keyFn = URLKey
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			h.ServeHTTP(w, r)
+			return
+		}
+		key := keyFn(r)
+		if key == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if e, ok := m.load(key, r); ok {
+			header := w.Header()
+			for name, values := range e.Header {
+				header[name] = values
+			}
+			header.Set(XFromCache, "1")
+			w.WriteHeader(e.StatusCode)
+			w.Write(e.Body)
+			return
+		}
+
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		if !rec.storable(r) {
+			return
+		}
+		e := &entry{StatusCode: rec.status, Header: rec.header, Body: rec.body.Bytes()}
+		m.store(key, r, e, ttl)
+	})
+}
+
+// Invalidate function deletes cached responses of key, including every variant of it.
+func (m *Middleware) Invalidate(key string) {
+	m.cache.Delete(key)
+	m.cache.DeletePrefix(key + "\x00")
+}
+
+// InvalidatePrefix function deletes cached responses of every keys which start with prefix(e.g. host and path of a collection),
+// and returns number of deleted entries.
+func (m *Middleware) InvalidatePrefix(prefix string) int {
+	return m.cache.DeletePrefix(prefix)
+}
+
+// load returns cached response of key for r. Key of response with Vary has entry which only lists the header names,
+// and the response is under variant key of them.
+func (m *Middleware) load(key string, r *http.Request) (*entry, bool) {
+	e, ok := m.decode(key)
+	if !ok || len(e.Vary) == 0 {
+		return e, ok
+	}
+	return m.decode(variant(key, e.Vary, r))
+}
+
+// store puts e under key, or under variant key of r if e has Vary.
+func (m *Middleware) store(key string, r *http.Request, e *entry, ttl time.Duration) {
+	names := varied(e.Header)
+	if len(names) == 0 {
+		m.encode(key, e, ttl)
+		return
+	}
+	vary := make(http.Header)
+	for _, name := range names {
+		if name == "*" {
+			return
+		}
+		vary[name] = nil
+	}
+	m.encode(key, &entry{Vary: vary}, ttl)
+	m.encode(variant(key, vary, r), e, ttl)
+}
+
+func (m *Middleware) decode(key string) (*entry, bool) {
+	data, hit := m.cache.Get(key)
+	if !hit {
+		return nil, false
+	}
+	var e entry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&e); err != nil {
+		return nil, false
+	}
+	return &e, true
+}
+
+func (m *Middleware) encode(key string, e *entry, ttl time.Duration) {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(e); err != nil {
+		return
+	}
+	m.cache.PutWithTtl(key, b.Bytes(), ttl)
+}
+
+// variant returns key of response for values of vary headers in r. Variant keys start with key and NUL, so Invalidate deletes them by prefix.
+func variant(key string, vary http.Header, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(key)
+	b.WriteByte(0)
+	for _, name := range sortedNames(vary) {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// sortedNames returns names of header in order, so variant key doesn't depend on map order.
+func sortedNames(header http.Header) []string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// recorder is http.ResponseWriter which passes response to client and records it for cache.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	r.status = status
+	r.header = r.ResponseWriter.Header().Clone()
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush sends recorded response so far to client, so streaming handlers work under Middleware.
+func (r *recorder) Flush() {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped http.ResponseWriter for http.ResponseController.
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// storable reports whether recorded response to req can be cached.
+func (r *recorder) storable(req *http.Request) bool {
+	if !r.wroteHeader {
+		r.header = r.ResponseWriter.Header().Clone()
+	}
+	if r.status != http.StatusOK || r.header.Get("Set-Cookie") != "" {
+		return false
+	}
+	directives := cacheControl(r.header)
+	_, noStore := directives["no-store"]
+	_, private := directives["private"]
+	if noStore || private {
+		return false
+	}
+	if req.Header.Get("Authorization") != "" {
+		_, public := directives["public"]
+		_, sMaxAge := directives["s-maxage"]
+		_, mustRevalidate := directives["must-revalidate"]
+		return public || sMaxAge || mustRevalidate
+	}
+	return true
+}
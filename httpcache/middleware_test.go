@@ -0,0 +1,128 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+func newMiddleware() *Middleware {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity}
+	return NewMiddleware(cstorage.New(config))
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls int32
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "text/plain")
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/private":
+			w.Header().Set("Cache-Control", "private")
+			io.WriteString(w, "mine")
+		default:
+			io.WriteString(w, "page "+r.URL.Path)
+		}
+	})
+	m := newMiddleware()
+	srv := httptest.NewServer(m.Cache(h, func(r *http.Request) string { return r.URL.Path }, time.Minute))
+	defer srv.Close()
+	client := srv.Client()
+
+	get(t, client, srv.URL+"/a", nil)
+	if body, cached := get(t, client, srv.URL+"/a", nil); !cached || body != "page /a" {
+		t.Errorf("/a should be from cache, got %q %v", body, cached)
+	}
+	for _, path := range []string{"/missing", "/private"} {
+		get(t, client, srv.URL+path, nil)
+		if _, cached := get(t, client, srv.URL+path, nil); cached {
+			t.Errorf("%s should not be cached", path)
+		}
+	}
+
+	m.Invalidate("/a")
+	if _, cached := get(t, client, srv.URL+"/a", nil); cached {
+		t.Error("/a should be invalidated")
+	}
+	if calls != 6 {
+		t.Errorf("expected 6 calls of handler, got %d", calls)
+	}
+}
+
+func TestMiddlewareVary(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Accept-Language")
+		io.WriteString(w, r.Header.Get("Accept-Language"))
+	})
+	m := newMiddleware()
+	srv := httptest.NewServer(m.Cache(h, nil, time.Minute))
+	defer srv.Close()
+	client := srv.Client()
+
+	en, ko := http.Header{"Accept-Language": {"en"}}, http.Header{"Accept-Language": {"ko"}}
+	get(t, client, srv.URL, en)
+	get(t, client, srv.URL, ko)
+	if body, cached := get(t, client, srv.URL, en); !cached || body != "en" {
+		t.Errorf("en should be from cache, got %q %v", body, cached)
+	}
+	if body, cached := get(t, client, srv.URL, ko); !cached || body != "ko" {
+		t.Errorf("ko should be from cache, got %q %v", body, cached)
+	}
+
+	if count := m.InvalidatePrefix(srv.Listener.Addr().String()); count != 3 {
+		t.Errorf("key and its 2 variants should be invalidated, got %d", count)
+	}
+	if _, cached := get(t, client, srv.URL, ko); cached {
+		t.Error("ko should be invalidated")
+	}
+}
+
+func TestMiddlewareAuthorization(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/public" {
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		}
+		io.WriteString(w, "hello "+r.Header.Get("Authorization"))
+	})
+	m := newMiddleware()
+	srv := httptest.NewServer(m.Cache(h, nil, time.Minute))
+	defer srv.Close()
+	client := srv.Client()
+
+	get(t, client, srv.URL+"/me", http.Header{"Authorization": {"alice"}})
+	if body, cached := get(t, client, srv.URL+"/me", http.Header{"Authorization": {"bob"}}); cached || body != "hello bob" {
+		t.Errorf("response to authorized request should not be shared, got %q %v", body, cached)
+	}
+	get(t, client, srv.URL+"/public", http.Header{"Authorization": {"alice"}})
+	if _, cached := get(t, client, srv.URL+"/public", http.Header{"Authorization": {"bob"}}); !cached {
+		t.Error("public response should be cached")
+	}
+}
+
+func TestMiddlewareFlush(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "first ")
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Error("recorder should be http.Flusher")
+			return
+		}
+		f.Flush()
+		io.WriteString(w, "second")
+	})
+	m := newMiddleware()
+	rec := httptest.NewRecorder()
+	m.Cache(h, nil, time.Minute).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	if !rec.Flushed || rec.Body.String() != "first second" {
+		t.Errorf("flush should be passed to client, got %v %q", rec.Flushed, rec.Body.String())
+	}
+}
@@ -0,0 +1,159 @@
+// Package daemon holds configuration shared by command line tools of CStorage.
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/httpapi"
+)
+
+// Config structure is configuration file of the daemon, written in JSON.
+// - Ttl: default ttl of keys, written as Go duration string(e.g. "10m")
+// - TtlJitter, Capacity, Sliding, MaxValueBytes: same as cstorage.CStorageConfig
+// - Listen: address to listen(e.g. ":7070")
+// - DataDir: directory for persistence files, it should be writable
+// - MemoryBudget, EntryBytes: if both are set, Capacity * EntryBytes should fit in MemoryBudget
+// - ShedThreshold: in-flight requests above which the server sheds lower priority requests, 0 disables shedding
+// - AuthTokens: bearer tokens mapped to principal, see httpapi.Tokens
+// - AuthHMACKeys: secrets of signed requests by key id, see httpapi.HMAC
+// If neither AuthTokens nor AuthHMACKeys is set, requests are not authenticated.
+type Config struct {
+	Ttl           string            `json:"ttl"`
+	TtlJitter     float64           `json:"ttl_jitter"`
+	Capacity      int64             `json:"capacity"`
+	Sliding       bool              `json:"sliding"`
+	MaxValueBytes int               `json:"max_value_bytes"`
+	Listen        string            `json:"listen"`
+	DataDir       string            `json:"data_dir"`
+	MemoryBudget  int64             `json:"memory_budget"`
+	EntryBytes    int64             `json:"entry_bytes"`
+	ShedThreshold int               `json:"shed_threshold"`
+	AuthTokens    map[string]string `json:"auth_tokens"`
+	AuthHMACKeys  map[string]string `json:"auth_hmac_keys"`
+}
+
+// Load function reads Config from JSON file at path.
+func Load(path string) (Config, error) {
+	var c Config
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Storage function converts Config into cstorage.CStorageConfig.
+func (c Config) Storage() (cstorage.CStorageConfig, error) {
+	ttl, err := time.ParseDuration(c.Ttl)
+	if err != nil {
+		return cstorage.CStorageConfig{}, fmt.Errorf("%w: ttl %q: %v", cstorage.ErrInvalidConfig, c.Ttl, err)
+	}
+	return cstorage.CStorageConfig{Ttl: ttl, TtlJitter: c.TtlJitter, Capacity: c.Capacity, Sliding: c.Sliding, MaxValueBytes: c.MaxValueBytes}, nil
+}
+
+// Authenticator function returns Authenticator of AuthTokens and AuthHMACKeys, or nil if neither is set.
+func (c Config) Authenticator() httpapi.Authenticator {
+	var as httpapi.Authenticators
+	if len(c.AuthTokens) > 0 {
+		as = append(as, httpapi.Tokens(c.AuthTokens))
+	}
+	if len(c.AuthHMACKeys) > 0 {
+		keys := make(map[string][]byte, len(c.AuthHMACKeys))
+		for id, secret := range c.AuthHMACKeys {
+			keys[id] = []byte(secret)
+		}
+		as = append(as, httpapi.HMAC{Keys: keys})
+	}
+	if len(as) == 0 {
+		return nil
+	}
+	return as
+}
+
+// Validate function checks Config without touching environment. Every problems are joined into single error.
+func (c Config) Validate() error {
+	var errs []error
+
+	sc, err := c.Storage()
+	if err != nil {
+		errs = append(errs, err)
+	} else if err := sc.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+			errs = append(errs, fmt.Errorf("listen %q: %v", c.Listen, err))
+		}
+	}
+	if c.ShedThreshold < 0 {
+		errs = append(errs, errors.New("shed_threshold should not be negative"))
+	}
+	for token := range c.AuthTokens {
+		if token == "" {
+			errs = append(errs, errors.New("auth_tokens should not have empty token"))
+		}
+	}
+	for id, secret := range c.AuthHMACKeys {
+		if len(secret) < 16 {
+			errs = append(errs, fmt.Errorf("auth_hmac_keys %q: secret should be at least 16 bytes", id))
+		}
+	}
+	if c.MemoryBudget < 0 || c.EntryBytes < 0 {
+		errs = append(errs, errors.New("memory_budget and entry_bytes should not be negative"))
+	}
+	if c.MemoryBudget > 0 && c.EntryBytes > 0 && c.Capacity > c.MemoryBudget/c.EntryBytes {
+		errs = append(errs, fmt.Errorf("capacity %d * entry_bytes %d exceeds memory_budget %d", c.Capacity, c.EntryBytes, c.MemoryBudget))
+	}
+	return join(errs)
+}
+
+// DryRun function validates Config and checks environment as the daemon would at start, without serving.
+// It binds and releases Listen address, and checks DataDir is writable.
+func (c Config) DryRun() error {
+	errs := []error{}
+	if err := c.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Listen != "" {
+		l, err := net.Listen("tcp", c.Listen)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("listen %q: %v", c.Listen, err))
+		} else {
+			l.Close()
+		}
+	}
+
+	if c.DataDir != "" {
+		f, err := os.CreateTemp(c.DataDir, ".dry-run-*")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("data_dir %q is not writable: %v", c.DataDir, err))
+		} else {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}
+	return join(errs)
+}
+
+// join returns single error which has every message of errs. It returns nil if errs is empty.
+func join(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	msg := errs[0].Error()
+	for _, err := range errs[1:] {
+		msg += "; " + err.Error()
+	}
+	return errors.New(msg)
+}
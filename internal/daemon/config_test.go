@@ -0,0 +1,70 @@
+package daemon
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cocm1324/cstorage/httpapi"
+)
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	os.WriteFile(path, []byte(`{"ttl": "10m", "ttl_jitter": 0.1, "capacity": 100, "max_value_bytes": 1024, "listen": "127.0.0.1:0", "data_dir": "`+dir+`"}`), 0o644)
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DryRun(); err != nil {
+		t.Errorf("config should pass dry run, got %v", err)
+	}
+	if cc, _ := c.Storage(); cc.TtlJitter != 0.1 || cc.MaxValueBytes != 1024 {
+		t.Errorf("ttl jitter and max value bytes should be passed to CStorageConfig, got %v, %d", cc.TtlJitter, cc.MaxValueBytes)
+	}
+
+	c.Ttl = "ten minutes"
+	c.MemoryBudget = 1000
+	c.EntryBytes = 100
+	c.DataDir = filepath.Join(dir, "missing")
+	err = c.DryRun()
+	if err == nil {
+		t.Fatal("config should fail dry run")
+	}
+	for _, want := range []string{"ttl", "memory_budget", "data_dir"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %s, got %v", want, err)
+		}
+	}
+}
+
+func TestMemoryBudget(t *testing.T) {
+	c := Config{Ttl: "10m", Capacity: 10, MemoryBudget: 1000, EntryBytes: 100}
+	if err := c.Validate(); err != nil {
+		t.Errorf("capacity which fits memory_budget should pass, got %v", err)
+	}
+	// capacity * entry_bytes overflows int64 to negative
+	c.Capacity = math.MaxInt64/100 + 2
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "memory_budget") {
+		t.Errorf("huge capacity should exceed memory_budget, got %v", err)
+	}
+}
+
+func TestAuthenticator(t *testing.T) {
+	c := Config{Ttl: "10m", Capacity: 100}
+	if c.Authenticator() != nil {
+		t.Error("authenticator should be nil without credentials")
+	}
+
+	c.AuthTokens = map[string]string{"t0ken": "dashboard"}
+	c.AuthHMACKeys = map[string]string{"replica": "short"}
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "replica") {
+		t.Errorf("short secret should fail validation, got %v", err)
+	}
+	if as, ok := c.Authenticator().(httpapi.Authenticators); !ok || len(as) != 2 {
+		t.Errorf("expected token and hmac authenticators, got %#v", c.Authenticator())
+	}
+}
@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cocm1324/cstorage"
+)
+
+// Environment variables which are set for new process on warm restart.
+// - EnvListenFD: file descriptor of inherited listening socket
+// - EnvHandoff: path of handoff snapshot which has contents of the cache
+const (
+	EnvListenFD = "CSTORAGE_LISTEN_FD"
+	EnvHandoff  = "CSTORAGE_HANDOFF"
+)
+
+// handoffFile is name of handoff snapshot in DataDir.
+const handoffFile = "handoff.gob"
+
+// HandoffPath function returns path of handoff snapshot, which is in DataDir, or temporary directory if DataDir is not set.
+func (c Config) HandoffPath() string {
+	dir := c.DataDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, handoffFile)
+}
+
+// Listen function returns listener inherited from previous process if EnvListenFD is set, otherwise it listens addr.
+func Listen(addr string) (net.Listener, error) {
+	v := os.Getenv(EnvListenFD)
+	if v == "" {
+		return net.Listen("tcp", addr)
+	}
+
+	fd, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s %q: %v", EnvListenFD, v, err)
+	}
+	f := os.NewFile(uintptr(fd), "listener")
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// ListenerFile function returns duplicated file of listener, which keeps the socket open after listener is closed,
+// so connections are queued by the kernel until new process accepts them.
+func ListenerFile(l net.Listener) (*os.File, error) {
+	fl, ok := l.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, errors.New("listener doesn't have file")
+	}
+	return fl.File()
+}
+
+// WriteHandoff function writes every keys of cache to path in eviction order, so recency is kept when it is read back.
+func WriteHandoff(path string, cache *cstorage.CStorage) (count int, err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if count, err = cache.WriteSnapshot(f); err != nil {
+		return 0, err
+	}
+	return count, f.Sync()
+}
+
+// ReadHandoff function puts keys in handoff snapshot at path into cache, with their remaining ttl. Expired keys are skipped.
+func ReadHandoff(path string, cache *cstorage.CStorage) (count int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return cache.ReadSnapshot(f)
+}
+
+// Exec function starts new process of the same binary with the same arguments, which inherits listener and reads handoff snapshot.
+func Exec(listener *os.File, handoff string) (*os.Process, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = []*os.File{listener}
+	// ExtraFiles start from file descriptor 3
+	cmd.Env = append(os.Environ(), EnvListenFD+"=3", EnvHandoff+"="+handoff)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd.Process, nil
+}
@@ -0,0 +1,69 @@
+package daemon
+
+import (
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+func TestHandoff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), handoffFile)
+	cache := cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 3})
+	cache.Put("a", []byte("1"))
+	cache.Put("b", []byte("2"))
+	cache.PutWithTtl("expired", []byte("3"), time.Millisecond)
+	cache.Get("a")
+	time.Sleep(time.Millisecond * 5)
+
+	if count, err := WriteHandoff(path, cache); count != 3 || err != nil {
+		t.Fatalf("3 keys should be written, got %d, %v", count, err)
+	}
+
+	restored := cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 3})
+	if count, err := ReadHandoff(path, restored); count != 2 || err != nil {
+		t.Fatalf("2 keys should be read, got %d, %v", count, err)
+	}
+	if data, _ := restored.Get("b"); string(data) != "2" {
+		t.Errorf("expected 2, got %s", data)
+	}
+
+	// a is restored after b since it was more recently used, but b is read above, so a is least recently used now
+	restored.Put("c", []byte{})
+	restored.Put("d", []byte{})
+	if _, hit := restored.Peek("a"); hit {
+		t.Error("recency should be kept by handoff")
+	}
+}
+
+func TestListenInherited(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ListenerFile(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	t.Setenv(EnvListenFD, strconv.Itoa(int(f.Fd())))
+	inherited, err := Listen("ignored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inherited.Close()
+	if inherited.Addr().String() != addr {
+		t.Errorf("listener should be inherited, got %s", inherited.Addr())
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("socket should stay open after original listener is closed: %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,268 @@
+// Package resp is minimal client of Redis serialization protocol(RESP2), used by Redis adapters of CStorage
+// so they don't need dependency on Redis client library.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Error is error reply of the server.
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// ErrClosed is returned when Client is already closed.
+var ErrClosed = errors.New("resp: client closed")
+
+// Conn is single connection to the server.
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// Dial function connects to the server at addr.
+func Dial(ctx context.Context, addr string) (*Conn, error) {
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(c), nil
+}
+
+// NewConn function wraps established connection.
+func NewConn(c net.Conn) *Conn {
+	return &Conn{conn: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// SetDeadline sets deadline of reads and writes of the connection, zero for no deadline.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+// Send writes command without waiting reply. Flush should be called to actually send it.
+func (c *Conn) Send(args ...string) error {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return nil
+}
+
+// Flush sends buffered commands.
+func (c *Conn) Flush() error {
+	return c.w.Flush()
+}
+
+// WriteReply writes v as reply, it is for implementing server side. v should be one of types which Receive returns, or int.
+func (c *Conn) WriteReply(v interface{}) error {
+	switch v := v.(type) {
+	case string:
+		c.w.WriteString("+" + v + "\r\n")
+	case Error:
+		c.w.WriteString("-" + string(v) + "\r\n")
+	case int:
+		c.w.WriteString(":" + strconv.Itoa(v) + "\r\n")
+	case int64:
+		c.w.WriteString(":" + strconv.FormatInt(v, 10) + "\r\n")
+	case []byte:
+		if v == nil {
+			c.w.WriteString("$-1\r\n")
+			break
+		}
+		c.w.WriteString("$" + strconv.Itoa(len(v)) + "\r\n")
+		c.w.Write(v)
+		c.w.WriteString("\r\n")
+	case []interface{}:
+		c.w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, e := range v {
+			if err := c.WriteReply(e); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("resp: unsupported reply type %T", v)
+	}
+	return nil
+}
+
+// Receive reads single reply. Reply is one of string(simple string), Error, int64, []byte(bulk string, nil if null) or []interface{}.
+func (c *Conn) Receive() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("resp: malformed line %q", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return []byte(nil), err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return []interface{}(nil), err
+		}
+		arr := make([]interface{}, n)
+		for i := range arr {
+			if arr[i], err = c.Receive(); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	}
+	return nil, fmt.Errorf("resp: unknown reply type %q", line[0])
+}
+
+// Do sends command and waits its reply. Error reply is returned as error.
+func (c *Conn) Do(ctx context.Context, args ...string) (interface{}, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+	} else {
+		c.conn.SetDeadline(time.Time{})
+	}
+	c.Send(args...)
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	v, err := c.Receive()
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := v.(Error); ok {
+		return nil, e
+	}
+	return v, nil
+}
+
+// Client is pool of connections to single server.
+type Client struct {
+	addr   string
+	mutex  sync.Mutex
+	idle   []*Conn
+	max    int
+	closed bool
+}
+
+// NewClient function returns Client which keeps at most maxIdle idle connections to addr.
+func NewClient(addr string, maxIdle int) *Client {
+	if maxIdle <= 0 {
+		maxIdle = 4
+	}
+	return &Client{addr: addr, max: maxIdle}
+}
+
+// Do runs command on pooled connection.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v, err := conn.Do(ctx, args...)
+	if _, isReply := err.(Error); err != nil && !isReply {
+		conn.Close()
+		return nil, err
+	}
+	c.put(conn)
+	return v, err
+}
+
+// Pipeline sends every commands at once and then reads their replies, which saves round trips.
+// Error replies are returned in replies as Error, not as err.
+func (c *Client) Pipeline(ctx context.Context, cmds [][]string) (replies []interface{}, err error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.conn.SetDeadline(deadline)
+	} else {
+		conn.conn.SetDeadline(time.Time{})
+	}
+	for _, cmd := range cmds {
+		conn.Send(cmd...)
+	}
+	if err := conn.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	replies = make([]interface{}, len(cmds))
+	for i := range cmds {
+		if replies[i], err = conn.Receive(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	c.put(conn)
+	return replies, nil
+}
+
+// Close closes idle connections and makes further Do fail.
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.closed = true
+	for _, conn := range c.idle {
+		conn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+func (c *Client) get(ctx context.Context) (*Conn, error) {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(c.idle); n > 0 {
+		conn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mutex.Unlock()
+		return conn, nil
+	}
+	c.mutex.Unlock()
+	return Dial(ctx, c.addr)
+}
+
+func (c *Client) put(conn *Conn) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed || len(c.idle) >= c.max {
+		conn.Close()
+		return
+	}
+	c.idle = append(c.idle, conn)
+}
@@ -0,0 +1,158 @@
+// Package resptest provides in-memory server which understands small subset of Redis commands,
+// for testing Redis adapters without Redis.
+package resptest
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cocm1324/cstorage/internal/resp"
+)
+
+// Server understands GET, SET(with PX), DEL, PEXPIRE, PUBLISH, SUBSCRIBE and PING.
+type Server struct {
+	Addr     string
+	listener net.Listener
+	mutex    sync.Mutex
+	data     map[string][]byte
+	expire   map[string]time.Time
+	subs     map[string][]*resp.Conn
+	commands []string
+}
+
+// NewServer function starts Server on random local port.
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Addr:     l.Addr().String(),
+		listener: l,
+		data:     make(map[string][]byte),
+		expire:   make(map[string]time.Time),
+		subs:     make(map[string][]*resp.Conn),
+	}
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+// Commands returns every commands received so far.
+func (s *Server) Commands() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// Expiration returns expiration time of key, zero if it doesn't expire.
+func (s *Server) Expiration(key string) time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.expire[key]
+}
+
+func (s *Server) serve() {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(resp.NewConn(c))
+	}
+}
+
+func (s *Server) handle(c *resp.Conn) {
+	defer c.Close()
+	for {
+		v, err := c.Receive()
+		if err != nil {
+			return
+		}
+		arr, _ := v.([]interface{})
+		if len(arr) == 0 {
+			return
+		}
+		args := make([]string, len(arr))
+		for i, a := range arr {
+			b, _ := a.([]byte)
+			args[i] = string(b)
+		}
+		s.mutex.Lock()
+		s.commands = append(s.commands, strings.Join(args, " "))
+		if reply := s.reply(c, args); reply != nil {
+			c.WriteReply(reply)
+		}
+		c.Flush()
+		s.mutex.Unlock()
+	}
+}
+
+// reply runs command and returns its reply. Caller should hold the mutex.
+func (s *Server) reply(c *resp.Conn, args []string) interface{} {
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		v, ok := s.data[args[1]]
+		if exp, has := s.expire[args[1]]; has && exp.Before(time.Now()) {
+			ok = false
+		}
+		if !ok {
+			return []byte(nil)
+		}
+		return v
+	case "SET":
+		// like Redis, PX should be positive
+		ms := 0
+		if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+			if ms, _ = strconv.Atoi(args[4]); ms <= 0 {
+				return resp.Error("ERR invalid expire time in 'set' command")
+			}
+		}
+		s.data[args[1]] = []byte(args[2])
+		delete(s.expire, args[1])
+		if ms > 0 {
+			s.expire[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+		return "OK"
+	case "DEL":
+		n := 0
+		for _, k := range args[1:] {
+			if _, ok := s.data[k]; ok {
+				n++
+			}
+			delete(s.data, k)
+			delete(s.expire, k)
+		}
+		return n
+	case "PEXPIRE":
+		if _, ok := s.data[args[1]]; !ok {
+			return 0
+		}
+		ms, _ := strconv.Atoi(args[2])
+		s.expire[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return 1
+	case "PUBLISH":
+		subs := s.subs[args[1]]
+		for _, sub := range subs {
+			sub.WriteReply([]interface{}{[]byte("message"), []byte(args[1]), []byte(args[2])})
+			sub.Flush()
+		}
+		return len(subs)
+	case "SUBSCRIBE":
+		for i, ch := range args[1:] {
+			s.subs[ch] = append(s.subs[ch], c)
+			c.WriteReply([]interface{}{[]byte("subscribe"), []byte(ch), i + 1})
+		}
+		return nil
+	case "PING":
+		return "PONG"
+	}
+	return resp.Error("ERR unknown command")
+}
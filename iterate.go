@@ -0,0 +1,83 @@
+package cstorage
+
+import (
+	"sort"
+	"time"
+)
+
+// IterateFunc is callback for iterating functions. Returning false stops the iteration.
+type IterateFunc func(key string, data []byte, expiresAt time.Time) bool
+
+// item is snapshot of node used for iteration.
+type item struct {
+	key       string
+	data      []byte
+	expiresAt time.Time
+	flags     uint32
+	meta      map[string]string
+	lifetime  time.Duration
+	sliding   bool
+}
+
+// IterateLRU function calls fn for every key from least recently used to most recently used, which is the order of eviction.
+// If other Policy than LRU is used, keys are called in the order the policy would evict them. Pinned keys are called last.
+// Keys are copied under the lock before calling fn, so fn can call other functions of CStorage without deadlock.
+// Iteration doesn't affect eviction order or ttl.
+func (s *CStorage) IterateLRU(fn IterateFunc) {
+	for _, it := range s.snapshot() {
+		if !fn(it.key, it.data, it.expiresAt) {
+			return
+		}
+	}
+}
+
+// IterateKeys function calls fn for every key in the same order as IterateLRU, but only keys are copied under the lock.
+// It is for listing keys of large cache, since data is not copied, decrypted or decompressed.
+func (s *CStorage) IterateKeys(fn func(key string) bool) {
+	s.mutex.RLock()
+	keys := make([]string, 0, len(s.table))
+	s.each(func(n *node) bool {
+		keys = append(keys, n.key)
+		return true
+	})
+	s.mutex.RUnlock()
+
+	for _, key := range keys {
+		if !fn(key) {
+			return
+		}
+	}
+}
+
+// IterateByExpiry function calls fn for every key from the one which expires first.
+// Keys with same expiration are called in eviction order.
+func (s *CStorage) IterateByExpiry(fn IterateFunc) {
+	items := s.snapshot()
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].expiresAt.Before(items[j].expiresAt)
+	})
+	for _, it := range items {
+		if !fn(it.key, it.data, it.expiresAt) {
+			return
+		}
+	}
+}
+
+// snapshot copies keys in eviction order(tail to head). Pinned keys come last.
+func (s *CStorage) snapshot() []item {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.items()
+}
+
+// items is snapshot without locking. Caller should hold the mutex.
+func (s *CStorage) items() []item {
+	items := make([]item, 0, len(s.table))
+	s.each(func(n *node) bool {
+		items = append(items, item{key: n.key, data: s.value(n), expiresAt: n.ttl, flags: n.flags, meta: copyMeta(n.meta),
+			lifetime: n.lifetime, sliding: n.sliding})
+		return true
+	})
+	return items
+}
@@ -0,0 +1,45 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIterate(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.PutWithTtl("key1", []byte("1"), time.Hour*3)
+	cache.PutWithTtl("key2", []byte("2"), time.Hour*1)
+	cache.PutWithTtl("key3", []byte("3"), time.Hour*2)
+	cache.Get("key1")
+
+	var keys []string
+	cache.IterateLRU(func(key string, data []byte, expiresAt time.Time) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if len(keys) != 3 || keys[0] != "key2" || keys[1] != "key3" || keys[2] != "key1" {
+		t.Errorf("expected key2, key3, key1, got %v", keys)
+	}
+
+	keys = nil
+	cache.IterateKeys(func(key string) bool {
+		keys = append(keys, key)
+		return len(keys) < 2
+	})
+	if len(keys) != 2 || keys[0] != "key2" || keys[1] != "key3" {
+		t.Errorf("keys should be in eviction order, got %v", keys)
+	}
+
+	keys = nil
+	cache.IterateByExpiry(func(key string, data []byte, expiresAt time.Time) bool {
+		keys = append(keys, key)
+		return len(keys) < 2
+	})
+	if len(keys) != 2 || keys[0] != "key2" || keys[1] != "key3" {
+		t.Errorf("expected key2, key3, got %v", keys)
+	}
+}
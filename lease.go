@@ -0,0 +1,130 @@
+package cstorage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrLeaseInvalid is returned by PutWithLease when the lease is not the current lease of the key; it has expired, or it is invalidated
+// by a write or delete of the key after it was handed out, so the data is likely stale.
+var ErrLeaseInvalid = errors.New("cstorage: lease is invalid")
+
+// Lease is token handed out by GetWithLease on miss, which entitles its holder to fill the key by PutWithLease. Zero is not a lease.
+type Lease uint64
+
+// lease is outstanding lease of a key. done is closed when the lease is resolved, so waiters of GetWithLeaseWait wake up.
+type lease struct {
+	token   Lease
+	expires time.Time
+	done    chan struct{}
+}
+
+// GetWithLease function is same as Get, but on miss it hands out a lease of the key, in the manner of memcached leases for read-aside caching.
+// Only the holder should load data and put it by PutWithLease, which fails if the key is written or deleted meanwhile, so a stale fill
+// racing with invalidation is not put. While a lease is outstanding, other misses get zero lease(hot miss); they should wait and retry,
+// see GetWithLeaseWait, instead of loading the same data. Lease expires after CStorageConfig.LeaseTtl, so a holder which crashed doesn't block the key.
+func (s *CStorage) GetWithLease(key string) (data []byte, lease Lease, hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, lease, hit, _ = s.getWithLease(key)
+	return data, lease, hit
+}
+
+// GetWithLeaseWait function is same as GetWithLease, but on hot miss it waits until the outstanding lease is resolved by PutWithLease,
+// by a write or delete of the key, or by expiry, and tries again. It returns error of ctx if ctx is done while waiting.
+func (s *CStorage) GetWithLeaseWait(ctx context.Context, key string) (data []byte, lease Lease, hit bool, err error) {
+	for {
+		s.mutex.Lock()
+		data, lease, hit, l := s.getWithLease(key)
+		var wait time.Duration
+		if l != nil {
+			wait = l.expires.Sub(s.now())
+		}
+		s.mutex.Unlock()
+		if l == nil {
+			return data, lease, hit, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-l.done:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, 0, false, ctx.Err()
+		}
+		timer.Stop()
+	}
+}
+
+// getWithLease returns data of key, or new lease on miss. On hot miss, it returns outstanding lease of other caller. Caller should hold the mutex.
+func (s *CStorage) getWithLease(key string) (data []byte, token Lease, hit bool, outstanding *lease) {
+	if n, ok := s.get(key); ok {
+		return s.value(n), 0, true, nil
+	}
+	if l, ok := s.leases[key]; ok {
+		if s.now().Before(l.expires) {
+			return nil, 0, false, l
+		}
+		s.releaseLease(key)
+	}
+
+	ttl := s.config.LeaseTtl
+	if ttl == 0 {
+		ttl = 10 * time.Second
+	}
+	if s.leases == nil {
+		s.leases = make(map[string]*lease)
+	}
+	s.leaseSeq++
+	l := &lease{token: Lease(s.leaseSeq), expires: s.now().Add(ttl), done: make(chan struct{})}
+	s.leases[key] = l
+	return nil, l.token, false, nil
+}
+
+// PutWithLease function puts data of key if lease is the outstanding lease of the key, handed out by GetWithLease.
+// Otherwise it returns ErrLeaseInvalid, and data is not put. The lease is used up by the put either way.
+// It returns ErrRejected if key is not put for the same reasons as PutE.
+func (s *CStorage) PutWithLease(key string, data []byte, lease Lease) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	l, ok := s.leases[key]
+	if !ok || l.token != lease || !s.now().Before(l.expires) {
+		return ErrLeaseInvalid
+	}
+	if n, _ := s.put(key, data, s.config.Ttl, s.config.Sliding); n == nil {
+		s.releaseLease(key)
+		return ErrRejected
+	}
+	return nil
+}
+
+// releaseLease resolves outstanding lease of key, if any. It is called by every put and delete of the key, so a lease handed out before
+// them can't be used to put. Caller should hold the mutex.
+func (s *CStorage) releaseLease(key string) {
+	if l, ok := s.leases[key]; ok {
+		close(l.done)
+		delete(s.leases, key)
+	}
+}
+
+// removeExpiredLeases resolves leases which expired by now. Caller should hold the mutex.
+func (s *CStorage) removeExpiredLeases(now time.Time) {
+	for key, l := range s.leases {
+		if !now.Before(l.expires) {
+			s.releaseLease(key)
+		}
+	}
+}
+
+// releaseLeases resolves outstanding leases of keys for which fn returns true. Caller should hold the mutex.
+func (s *CStorage) releaseLeases(fn func(key string) bool) {
+	for key := range s.leases {
+		if fn(key) {
+			s.releaseLease(key)
+		}
+	}
+}
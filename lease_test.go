@@ -0,0 +1,142 @@
+package cstorage
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+func TestLease(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock, LeaseTtl: time.Second}
+	s := New(config)
+
+	_, lease, hit := s.GetWithLease("a")
+	if hit || lease == 0 {
+		t.Fatalf("miss should hand out lease, got %v %v", lease, hit)
+	}
+	if _, other, _ := s.GetWithLease("a"); other != 0 {
+		t.Errorf("second miss should be hot miss, got %v", other)
+	}
+	if err := s.PutWithLease("a", []byte("1"), lease+1); err != ErrLeaseInvalid {
+		t.Errorf("wrong lease should be invalid, got %v", err)
+	}
+	if err := s.PutWithLease("a", []byte("1"), lease); err != nil {
+		t.Fatal(err)
+	}
+	if data, _, hit := s.GetWithLease("a"); !hit || string(data) != "1" {
+		t.Errorf("a should hit, got %q %v", data, hit)
+	}
+	if err := s.PutWithLease("a", []byte("2"), lease); err != ErrLeaseInvalid {
+		t.Errorf("lease should be used up, got %v", err)
+	}
+
+	// delete after miss invalidates the lease, so the fill loaded before it is not put
+	s.Delete("a")
+	_, lease, _ = s.GetWithLease("a")
+	s.Delete("a")
+	if err := s.PutWithLease("a", []byte("stale"), lease); err != ErrLeaseInvalid {
+		t.Errorf("lease should be invalidated by delete, got %v", err)
+	}
+	if _, hit := s.Get("a"); hit {
+		t.Error("stale fill should not be put")
+	}
+
+	_, lease, _ = s.GetWithLease("b")
+	s.Put("b", []byte("fresh"))
+	if err := s.PutWithLease("b", []byte("stale"), lease); err != ErrLeaseInvalid {
+		t.Errorf("lease should be invalidated by put, got %v", err)
+	}
+	if data, _ := s.Get("b"); string(data) != "fresh" {
+		t.Errorf("b should keep the fresh data, got %q", data)
+	}
+
+	_, lease, _ = s.GetWithLease("user:1")
+	s.DeletePrefix("user:")
+	if err := s.PutWithLease("user:1", []byte("stale"), lease); err != ErrLeaseInvalid {
+		t.Errorf("lease should be invalidated by DeletePrefix, got %v", err)
+	}
+}
+
+func TestLeaseExpire(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock, LeaseTtl: time.Second}
+	s := New(config)
+
+	_, lease, _ := s.GetWithLease("a")
+	clock.Advance(time.Second)
+	if err := s.PutWithLease("a", []byte("1"), lease); err != ErrLeaseInvalid {
+		t.Errorf("expired lease should be invalid, got %v", err)
+	}
+	_, next, _ := s.GetWithLease("a")
+	if next == 0 || next == lease {
+		t.Errorf("miss after expiry should hand out new lease, got %v", next)
+	}
+}
+
+func TestLeaseSweep(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock, LeaseTtl: time.Second}
+	s := New(config)
+
+	// misses of keys which backend doesn't have are never filled
+	for i := 0; i < 1000; i++ {
+		s.GetWithLease("missing:" + strconv.Itoa(i))
+	}
+	s.RemoveExpired()
+	if len(s.leases) != 1000 {
+		t.Errorf("outstanding leases should be kept, got %d", len(s.leases))
+	}
+	_, lease, _ := s.GetWithLease("live")
+	clock.Advance(time.Second)
+	s.GetWithLease("fresh")
+	s.RemoveExpired()
+	if _, ok := s.leases["fresh"]; len(s.leases) != 1 || !ok {
+		t.Errorf("expired leases should be removed by sweep, got %d leases", len(s.leases))
+	}
+	if err := s.PutWithLease("live", []byte("1"), lease); err != ErrLeaseInvalid {
+		t.Errorf("swept lease should be invalid, got %v", err)
+	}
+}
+
+func TestGetWithLeaseWait(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	s := New(config)
+
+	_, lease, _ := s.GetWithLease("a")
+	result := make(chan string)
+	go func() {
+		data, _, hit, err := s.GetWithLeaseWait(context.Background(), "a")
+		if err != nil || !hit {
+			result <- "<miss>"
+			return
+		}
+		result <- string(data)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if err := s.PutWithLease("a", []byte("1"), lease); err != nil {
+		t.Fatal(err)
+	}
+	if data := <-result; data != "1" {
+		t.Errorf("waiter should get data put by lease holder, got %q", data)
+	}
+
+	s.GetWithLease("b")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, _, err := s.GetWithLeaseWait(ctx, "b"); err != context.DeadlineExceeded {
+		t.Errorf("wait should end with ctx, got %v", err)
+	}
+}
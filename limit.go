@@ -0,0 +1,78 @@
+package cstorage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrValueTooLarge is returned by PutE when data is longer than CStorageConfig.MaxValueBytes. Actual error is *ValueTooLargeError, which matches it by errors.Is.
+var ErrValueTooLarge = errors.New("cstorage: value too large")
+
+// ErrRejected is returned by PutE when key is not put for other reasons than size; admission filter refused it, every key is pinned, or it is heavier than capacity.
+var ErrRejected = errors.New("cstorage: key is rejected")
+
+// ValueTooLargeError structure is error of data longer than CStorageConfig.MaxValueBytes. Size and Max are in bytes.
+type ValueTooLargeError struct {
+	Key  string
+	Size int
+	Max  int
+}
+
+func (e *ValueTooLargeError) Error() string {
+	return fmt.Sprintf("cstorage: value of %q is %d bytes, larger than %d", e.Key, e.Size, e.Max)
+}
+
+// Is returns true for ErrValueTooLarge.
+func (e *ValueTooLargeError) Is(target error) bool {
+	return target == ErrValueTooLarge
+}
+
+// PutE function is same as Put, but it returns error if key is not put. Put silently drops such key, which is counted in Stats.
+// - *ValueTooLargeError: data is longer than CStorageConfig.MaxValueBytes
+// - ErrRejected: admission filter refused it, every key is pinned, or it is heavier than capacity
+// - ErrClosed: CStorage is closed
+func (s *CStorage) PutE(key string, data []byte) (hit bool, err error) {
+	return s.PutWithTtlE(key, data, s.config.Ttl)
+}
+
+// PutWithTtlE function is same as PutE, but ttl of the key is given by caller.
+func (s *CStorage) PutWithTtlE(key string, data []byte, ttl time.Duration) (hit bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return false, ErrClosed
+	}
+	if err := s.checkValue(key, data); err != nil {
+		s.stats.Oversized++
+		return false, err
+	}
+	n, hit := s.put(key, data, ttl, s.config.Sliding)
+	if n == nil {
+		return hit, ErrRejected
+	}
+	return hit, nil
+}
+
+// MaxValueBytes function returns CStorageConfig.MaxValueBytes, which is 0 if length of data is not limited.
+// Servers can limit request body by it before reading whole of it.
+func (s *CStorage) MaxValueBytes() int {
+	return s.config.MaxValueBytes
+}
+
+// rejection returns error of data which put didn't put; *ValueTooLargeError if it is too long, otherwise ErrRejected.
+func (s *CStorage) rejection(key string, data []byte) error {
+	if err := s.checkValue(key, data); err != nil {
+		return err
+	}
+	return ErrRejected
+}
+
+// checkValue returns *ValueTooLargeError if data is longer than CStorageConfig.MaxValueBytes. Length is checked before compression.
+func (s *CStorage) checkValue(key string, data []byte) error {
+	if max := s.config.MaxValueBytes; max > 0 && len(data) > max {
+		return &ValueTooLargeError{Key: key, Size: len(data), Max: max}
+	}
+	return nil
+}
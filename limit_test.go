@@ -0,0 +1,93 @@
+package cstorage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMaxValueBytes(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, MaxValueBytes: 4}
+	cache := New(config)
+
+	if _, err := cache.PutE("key1", []byte("1234")); err != nil {
+		t.Fatal(err)
+	}
+	_, err := cache.PutE("key1", []byte("12345"))
+	var tooLarge *ValueTooLargeError
+	if !errors.Is(err, ErrValueTooLarge) || !errors.As(err, &tooLarge) || tooLarge.Key != "key1" || tooLarge.Size != 5 || tooLarge.Max != 4 {
+		t.Errorf("expected ValueTooLargeError, got %v", err)
+	}
+	if data, _ := cache.Get("key1"); string(data) != "1234" {
+		t.Errorf("existing data should be kept, got %q", data)
+	}
+
+	if cache.Put("key2", []byte("12345")) {
+		t.Error("key2 shouldn't be hit")
+	}
+	if _, hit := cache.Get("key2"); hit {
+		t.Error("too large key2 shouldn't be put")
+	}
+	if _, err := cache.PutPinned("key3", []byte("12345")); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("expected ErrValueTooLarge from PutPinned, got %v", err)
+	}
+	if st := cache.Stats(); st.Oversized != 3 || st.Rejected != 0 {
+		t.Errorf("expected 3 oversized puts, got %d, rejected %d", st.Oversized, st.Rejected)
+	}
+
+	cache.Close()
+	if _, err := cache.PutE("key1", nil); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+
+	if err := (CStorageConfig{Ttl: ttl, Capacity: capacity, MaxValueBytes: -1}).Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestMaxValueBytesOperations(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, MaxValueBytes: 2}
+	cache := New(config)
+
+	cache.Put("key1", []byte("ab"))
+	if cache.CompareAndSwap("key1", []byte("ab"), []byte("abc")) {
+		t.Error("too large data shouldn't be swapped")
+	}
+	_, version, _ := cache.GetWithVersion("key1")
+	if _, swapped := cache.CompareAndSwapVersion("key1", version, []byte("abc")); swapped {
+		t.Error("too large data shouldn't be swapped by version")
+	}
+	if cache.Replace("key1", []byte("abc")) {
+		t.Error("too large data shouldn't be replaced")
+	}
+	if old, hadOld := cache.GetAndSet("key1", []byte("abc")); hadOld || old != nil {
+		t.Errorf("too large data shouldn't be set, got %q %v", old, hadOld)
+	}
+	if _, _, err := cache.GetAndSetE("key1", []byte("abc")); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("expected ErrValueTooLarge from GetAndSetE, got %v", err)
+	}
+	if cache.Append("key1", []byte("c")) || cache.Prepend("key1", []byte("c")) {
+		t.Error("too large joined data shouldn't be put")
+	}
+	if data, _ := cache.Get("key1"); string(data) != "ab" {
+		t.Errorf("existing data should be kept, got %q", data)
+	}
+
+	cache.Put("n", []byte("99"))
+	if _, err := cache.Increment("n", 1); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("expected ErrValueTooLarge from Increment, got %v", err)
+	}
+	if data, _ := cache.Get("n"); string(data) != "99" {
+		t.Errorf("counter should be kept, got %q", data)
+	}
+	if _, err := cache.Increment("m", 100); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("expected ErrValueTooLarge from Increment of missing key, got %v", err)
+	}
+	if _, hit := cache.Get("m"); hit {
+		t.Error("too large counter shouldn't be put")
+	}
+}
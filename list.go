@@ -0,0 +1,90 @@
+package cstorage
+
+// list is doubly linked list of nodes used by eviction policies. head is most recently used side, tail is the other side.
+// Links are index of nodes in nodes instead of pointers, see nodes.
+// A node can be in only one list at once, since prev and next are fields of node.
+type list struct {
+	nodes *nodes
+	head  int32
+	tail  int32
+	len   int64
+}
+
+// newList makes empty list of nodes in ns.
+func newList(ns *nodes) list {
+	return list{nodes: ns}
+}
+
+// clear forgets every nodes in the list, without unlinking them.
+func (l *list) clear() {
+	*l = list{nodes: l.nodes}
+}
+
+// last function returns node at tail, nil if list is empty
+func (l *list) last() *node {
+	return l.nodes.at(l.tail)
+}
+
+// prev function returns node next to n toward head, nil if n is head
+func (l *list) prev(n *node) *node {
+	return l.nodes.at(n.prev)
+}
+
+// pushHead function is to put node which is not in any list at head of list
+func (l *list) pushHead(n *node) {
+	n.prev = 0
+	n.next = l.head
+	if l.head != 0 {
+		l.nodes.at(l.head).prev = n.self
+	}
+	l.head = n.self
+	if l.tail == 0 {
+		l.tail = n.self
+	}
+	l.len++
+}
+
+// setHead function is move node in the list to head of list
+func (l *list) setHead(n *node) {
+	if l.head == n.self {
+		return
+	}
+
+	l.remove(n)
+	l.pushHead(n)
+}
+
+// remove function is to take node out of list
+func (l *list) remove(n *node) {
+	if l.head == n.self {
+		l.head = n.next
+	}
+
+	if l.tail == n.self {
+		l.tail = n.prev
+	}
+
+	if n.prev != 0 {
+		l.nodes.at(n.prev).next = n.next
+	}
+
+	if n.next != 0 {
+		l.nodes.at(n.next).prev = n.prev
+	}
+
+	n.prev = 0
+	n.next = 0
+	l.len--
+}
+
+// each function calls fn from tail to head until fn returns false. fn can remove the node it is called with.
+func (l *list) each(fn func(n *node) bool) bool {
+	for n := l.last(); n != nil; {
+		prev := l.prev(n)
+		if !fn(n) {
+			return false
+		}
+		n = prev
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package cstorage
+
+import (
+	"sync"
+	"time"
+)
+
+// Logger interface is structured logger of events of CStorage, see CStorageConfig.Logger. *slog.Logger of log/slog satisfies it.
+// args are alternating keys and values, as in log/slog. It might be called while holding the lock, so it must not call functions of CStorage.
+// Events are
+// - Debug "cstorage: key evicted": key is evicted by capacity
+// - Info "cstorage: capacity pressure": keys are evicted by capacity, with number of evictions since previous event
+// - Debug "cstorage: expired keys removed": RemoveExpired removed expired keys, including one by CStorageConfig.CleanupInterval
+// - Info "cstorage: snapshot written", "cstorage: snapshot read": snapshot is written or read, Warn with error if it failed
+// - Info "cstorage: log replayed", "cstorage: log rewritten": append-only log is replayed by Open or rewritten, Warn with error if it failed
+// - Warn "cstorage: log write failed": write to append-only log failed. It is logged once until Close, which returns the error
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+}
+
+// logEvent is kind of log event whose rate is limited by CStorageConfig.LogInterval.
+type logEvent int
+
+const (
+	logEviction logEvent = iota
+	logPressure
+	logSweep
+	logEvents
+)
+
+// logs limits rate of each logEvent. Events within interval from the last logged one are suppressed and counted.
+// It has its own mutex, since events are logged under read lock as well as write lock.
+type logs struct {
+	logger     Logger
+	interval   time.Duration
+	mutex      sync.Mutex
+	last       [logEvents]time.Time
+	suppressed [logEvents]int64
+}
+
+func newLogs(logger Logger, interval time.Duration) *logs {
+	if interval == 0 {
+		interval = time.Second
+	}
+	return &logs{logger: logger, interval: interval}
+}
+
+// allow reports whether event e at now can be logged. If so, suppressed is number of events suppressed since the last logged one.
+func (l *logs) allow(e logEvent, now time.Time) (suppressed int64, ok bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if !l.last[e].IsZero() && now.Sub(l.last[e]) < l.interval {
+		l.suppressed[e]++
+		return 0, false
+	}
+	suppressed = l.suppressed[e]
+	l.last[e], l.suppressed[e] = now, 0
+	return suppressed, true
+}
+
+// logEvicted logs eviction of n by capacity.
+func (s *CStorage) logEvicted(n *node) {
+	if s.logs == nil {
+		return
+	}
+	now := s.now()
+	if suppressed, ok := s.logs.allow(logEviction, now); ok {
+		s.logs.logger.Debug("cstorage: key evicted", "key", n.key, "suppressed", suppressed)
+	}
+	if suppressed, ok := s.logs.allow(logPressure, now); ok {
+		s.logs.logger.Info("cstorage: capacity pressure", "evicted", suppressed+1, "size", s.size, "capacity", s.config.Capacity)
+	}
+}
+
+// logSwept logs count expired keys removed by RemoveExpired.
+func (s *CStorage) logSwept(count int64) {
+	if s.logs == nil || count == 0 {
+		return
+	}
+	if suppressed, ok := s.logs.allow(logSweep, s.now()); ok {
+		s.logs.logger.Debug("cstorage: expired keys removed", "count", count, "size", s.size, "suppressed", suppressed)
+	}
+}
+
+// logSnapshot logs snapshot of count keys, which is written if written is true, and read otherwise. It is not rate limited.
+func (s *CStorage) logSnapshot(written bool, count int, elapsed time.Duration, err error) {
+	if s.logs == nil {
+		return
+	}
+	msg := "cstorage: snapshot read"
+	if written {
+		msg = "cstorage: snapshot written"
+	}
+	if err != nil {
+		s.logs.logger.Warn(msg, "keys", count, "elapsed", elapsed, "error", err)
+		return
+	}
+	s.logs.logger.Info(msg, "keys", count, "elapsed", elapsed)
+}
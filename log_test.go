@@ -0,0 +1,113 @@
+package cstorage
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+type entry struct {
+	level string
+	msg   string
+	args  map[string]interface{}
+}
+
+type recordingLogger struct {
+	mutex   sync.Mutex
+	entries []entry
+}
+
+func (l *recordingLogger) log(level, msg string, args []interface{}) {
+	e := entry{level: level, msg: msg, args: make(map[string]interface{})}
+	for i := 0; i+1 < len(args); i += 2 {
+		e.args[fmt.Sprint(args[i])] = args[i+1]
+	}
+	l.mutex.Lock()
+	l.entries = append(l.entries, e)
+	l.mutex.Unlock()
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) { l.log("debug", msg, args) }
+func (l *recordingLogger) Info(msg string, args ...interface{})  { l.log("info", msg, args) }
+func (l *recordingLogger) Warn(msg string, args ...interface{})  { l.log("warn", msg, args) }
+
+func (l *recordingLogger) find(msg string) []entry {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	var found []entry
+	for _, e := range l.entries {
+		if e.msg == msg {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func TestLoggerEvictions(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	clock := testutil.NewClock(time.Now())
+	logger := &recordingLogger{}
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock, Logger: logger, LogInterval: time.Minute}
+	cache := New(config)
+
+	for i := 0; i < 15; i++ {
+		cache.Put(strconv.Itoa(i), []byte("v"))
+	}
+	evicted := logger.find("cstorage: key evicted")
+	pressure := logger.find("cstorage: capacity pressure")
+	if len(evicted) != 1 || len(pressure) != 1 {
+		t.Fatalf("evictions within interval should be logged once, got %d and %d", len(evicted), len(pressure))
+	}
+	if evicted[0].level != "debug" || evicted[0].args["key"] != "0" || pressure[0].level != "info" || pressure[0].args["evicted"] != int64(1) {
+		t.Errorf("unexpected events %+v, %+v", evicted[0], pressure[0])
+	}
+
+	clock.Advance(time.Minute)
+	cache.Put("15", []byte("v"))
+	pressure = logger.find("cstorage: capacity pressure")
+	if len(pressure) != 2 || pressure[1].args["evicted"] != int64(5) {
+		t.Errorf("suppressed evictions should be reported by the next event, got %+v", pressure)
+	}
+	if evicted = logger.find("cstorage: key evicted"); len(evicted) != 2 || evicted[1].args["suppressed"] != int64(4) {
+		t.Errorf("expected 4 suppressed evictions, got %+v", evicted)
+	}
+}
+
+func TestLoggerSweepAndSnapshot(t *testing.T) {
+	ttl := time.Duration(time.Minute)
+	var capacity int64 = 10
+	clock := testutil.NewClock(time.Now())
+	logger := &recordingLogger{}
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock, Logger: logger}
+	cache := New(config)
+
+	cache.Put("a", []byte("1"))
+	cache.Put("b", []byte("2"))
+	cache.RemoveExpired()
+	if swept := logger.find("cstorage: expired keys removed"); len(swept) != 0 {
+		t.Errorf("sweep which removed nothing should not be logged, got %+v", swept)
+	}
+
+	var buf bytes.Buffer
+	cache.WriteSnapshot(&buf)
+	if written := logger.find("cstorage: snapshot written"); len(written) != 1 || written[0].level != "info" || written[0].args["keys"] != 2 {
+		t.Errorf("unexpected snapshot events %+v", written)
+	}
+
+	clock.Advance(2 * time.Minute)
+	cache.RemoveExpired()
+	if swept := logger.find("cstorage: expired keys removed"); len(swept) != 1 || swept[0].args["count"] != int64(2) {
+		t.Errorf("unexpected sweep events %+v", swept)
+	}
+
+	cache.ReadSnapshot(bytes.NewReader([]byte("broken")))
+	if read := logger.find("cstorage: snapshot read"); len(read) != 1 || read[0].level != "warn" || read[0].args["error"] == nil {
+		t.Errorf("failed read should be logged with error, got %+v", read)
+	}
+}
@@ -0,0 +1,93 @@
+package lww
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cocm1324/cstorage/httpapi"
+	"github.com/cocm1324/cstorage/signing"
+)
+
+// HTTPTransport structure is Transport which POSTs deltas as JSON to {peer}/deltas, where Handler of the peer is served.
+type HTTPTransport struct {
+	// Self is address of this node, which is sent so the peer doesn't send deltas back to it.
+	Self   string
+	Client *http.Client
+	// Keyring signs requests if it is set, for peers whose Options.Keyring accepts its signing key.
+	Keyring *signing.Keyring
+}
+
+var _ Transport = (*HTTPTransport)(nil)
+
+// Send posts deltas to peer, which is base URL of the peer.
+func (t *HTTPTransport) Send(ctx context.Context, peer string, deltas []Delta) error {
+	b, err := json.Marshal(deltas)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(peer, "/")+"/deltas", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.Self != "" {
+		req.Header.Set("X-Cstorage-Peer", t.Self)
+	}
+	if t.Keyring != nil {
+		if err := t.Keyring.SignRequest(req); err != nil {
+			return err
+		}
+	}
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("lww: %s responded %s: %s", peer, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Handler function returns http.Handler which applies deltas sent by HTTPTransport of peers to n.
+// If Options.Keyring of n is set, requests which are not signed by its accepted keys are rejected with 401.
+func Handler(n *Node) http.Handler {
+	var auth httpapi.Authenticator
+	if n.options.Keyring != nil {
+		auth = n.options.Keyring.Authenticator(n.options.MaxSkew)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/deltas" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if auth != nil {
+			if _, err := auth.Authenticate(r); err != nil {
+				http.Error(w, "unauthenticated", http.StatusUnauthorized)
+				return
+			}
+		}
+		var deltas []Delta
+		if err := json.NewDecoder(r.Body).Decode(&deltas); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		n.Apply(r.Header.Get("X-Cstorage-Peer"), deltas)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
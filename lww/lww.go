@@ -0,0 +1,260 @@
+// Package lww provides Node, which replicates writes between CStorages asynchronously, resolving conflicts by last writer wins.
+// Every write is stamped by hybrid logical clock of CStorage(see cstorage.Timestamp), and nodes gossip deltas of writes to their peers
+// every Interval. It is eventually consistent: a write is seen by other nodes after an interval or so, and concurrent writes of a key
+// end up with the one of the latest timestamp on every node. It suits multi-region read-mostly caches, where a second of staleness is fine.
+// Deltas sent over HTTP should be signed by signing.Keyring(see Options.Keyring) unless the network is trusted.
+package lww
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/signing"
+)
+
+// Delta structure is a write of key. Deleted is true for deletion, which is kept as tombstone so older puts arriving later don't revive the key.
+type Delta struct {
+	Key     string             `json:"key"`
+	Data    []byte             `json:"data,omitempty"`
+	Deleted bool               `json:"deleted,omitempty"`
+	Stamp   cstorage.Timestamp `json:"stamp"`
+}
+
+// Transport interface sends deltas to a peer, which passes them to Apply of its Node. Implementations should be safe for concurrent use.
+type Transport interface {
+	Send(ctx context.Context, peer string, deltas []Delta) error
+}
+
+// Options structure is configuration of Node.
+// - Peers: addresses of other nodes, in the form Transport understands(e.g. base URL for HTTPTransport)
+// - Transport: how deltas are sent
+// - Interval: interval of gossip, 200ms if 0
+// - BatchSize: maximum deltas in a message, 1000 if 0
+// - TombstoneTtl: how long deleted keys are remembered, 10m if 0. Puts delayed longer than it can revive deleted key
+// - OnError: optional function called with error of sending to peer. Deltas are sent again on next interval
+// - Keyring: optional signing.Keyring, and Handler rejects deltas which are not signed by its accepted keys(see HTTPTransport.Keyring)
+// - MaxSkew: how far Date of signed request can be from now, 5m if 0. See httpapi.HMAC
+type Options struct {
+	Peers        []string
+	Transport    Transport
+	Interval     time.Duration
+	BatchSize    int
+	TombstoneTtl time.Duration
+	OnError      func(peer string, err error)
+	Keyring      *signing.Keyring
+	MaxSkew      time.Duration
+}
+
+// Node structure replicates writes of CStorage. Writes should go through Node, since writes to CStorage directly are not replicated.
+type Node struct {
+	cache   *cstorage.CStorage
+	options Options
+
+	mutex      sync.Mutex
+	tombstones map[string]cstorage.Timestamp
+	// pending has deltas to be sent to each peer, latest one for each key
+	pending map[string]map[string]Delta
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New function returns Node of cache, and starts gossip in background until Close is called.
+func New(cache *cstorage.CStorage, options Options) *Node {
+	if options.Interval <= 0 {
+		options.Interval = 200 * time.Millisecond
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = 1000
+	}
+	if options.TombstoneTtl <= 0 {
+		options.TombstoneTtl = 10 * time.Minute
+	}
+	n := &Node{
+		cache:      cache,
+		options:    options,
+		tombstones: make(map[string]cstorage.Timestamp),
+		pending:    make(map[string]map[string]Delta),
+		done:       make(chan struct{}),
+	}
+	for _, p := range options.Peers {
+		n.pending[p] = make(map[string]Delta)
+	}
+	n.wg.Add(1)
+	go n.loop()
+	return n
+}
+
+// CStorage function returns local CStorage, which should only be read.
+func (n *Node) CStorage() *cstorage.CStorage {
+	return n.cache
+}
+
+// Get function returns data of key from local CStorage.
+func (n *Node) Get(key string) ([]byte, bool) {
+	return n.cache.Get(key)
+}
+
+// Put function puts data of key locally, and sends it to peers on next gossip.
+func (n *Node) Put(key string, data []byte) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	d := Delta{Key: key, Data: data, Stamp: n.stamp(key)}
+	delete(n.tombstones, key)
+	n.cache.PutWithTimestamp(key, data, d.Stamp)
+	n.enqueue(d, "")
+}
+
+// Delete function deletes key locally, and sends it to peers on next gossip.
+func (n *Node) Delete(key string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	d := Delta{Key: key, Deleted: true, Stamp: n.stamp(key)}
+	n.tombstones[key] = d.Stamp
+	n.cache.Delete(key)
+	n.enqueue(d, "")
+}
+
+// stamp returns timestamp of local write of key, which is later than tombstone of key even if the tombstone came from a node whose clock is ahead.
+func (n *Node) stamp(key string) cstorage.Timestamp {
+	ts := n.cache.Now()
+	if t, ok := n.tombstones[key]; ok && !t.Before(ts) {
+		ts = cstorage.Timestamp{Wall: t.Wall, Logical: t.Logical + 1}
+	}
+	return ts
+}
+
+// Apply function applies deltas received from peer from. Deltas which win are sent to other peers, so writes spread
+// even if nodes are not fully connected. It returns number of applied deltas.
+func (n *Node) Apply(from string, deltas []Delta) (applied int) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for _, d := range deltas {
+		if n.apply(d) {
+			n.enqueue(d, from)
+			applied++
+		}
+	}
+	return applied
+}
+
+// apply applies d if it is later than current data or tombstone of its key.
+func (n *Node) apply(d Delta) bool {
+	if t, ok := n.tombstones[d.Key]; ok && !t.Before(d.Stamp) {
+		return false
+	}
+	if !d.Deleted {
+		if !n.cache.PutWithTimestamp(d.Key, d.Data, d.Stamp) {
+			return false
+		}
+		delete(n.tombstones, d.Key)
+		return true
+	}
+	if info, hit := n.cache.GetWithInfo(d.Key); hit && d.Stamp.Before(info.Timestamp) {
+		return false
+	}
+	n.cache.Delete(d.Key)
+	n.tombstones[d.Key] = d.Stamp
+	return true
+}
+
+// enqueue queues d to every peer except from. Caller should hold the mutex.
+func (n *Node) enqueue(d Delta, from string) {
+	for p, deltas := range n.pending {
+		if p != from {
+			deltas[d.Key] = d
+		}
+	}
+}
+
+// Close function stops gossip after sending pending deltas once more.
+func (n *Node) Close() error {
+	close(n.done)
+	n.wg.Wait()
+	return nil
+}
+
+func (n *Node) loop() {
+	defer n.wg.Done()
+	ticker := time.NewTicker(n.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n.gossip()
+			n.expireTombstones()
+		case <-n.done:
+			n.gossip()
+			return
+		}
+	}
+}
+
+// gossip sends pending deltas to each peer. Deltas of failed peer are kept, unless newer deltas of same keys are queued meanwhile.
+func (n *Node) gossip() {
+	n.mutex.Lock()
+	batches := make(map[string][]Delta)
+	for p, deltas := range n.pending {
+		if len(deltas) == 0 {
+			continue
+		}
+		batch := make([]Delta, 0, len(deltas))
+		for _, d := range deltas {
+			batch = append(batch, d)
+		}
+		batches[p] = batch
+		n.pending[p] = make(map[string]Delta)
+	}
+	n.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	for p, batch := range batches {
+		wg.Add(1)
+		go func(p string, batch []Delta) {
+			defer wg.Done()
+			for i := 0; i < len(batch); i += n.options.BatchSize {
+				end := i + n.options.BatchSize
+				if end > len(batch) {
+					end = len(batch)
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), n.options.Interval*10)
+				err := n.options.Transport.Send(ctx, p, batch[i:end])
+				cancel()
+				if err != nil {
+					n.requeue(p, batch[i:])
+					if n.options.OnError != nil {
+						n.options.OnError(p, err)
+					}
+					return
+				}
+			}
+		}(p, batch)
+	}
+	wg.Wait()
+}
+
+// requeue queues deltas to peer p again, unless later delta of the key is queued.
+func (n *Node) requeue(p string, deltas []Delta) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	pending := n.pending[p]
+	for _, d := range deltas {
+		if q, ok := pending[d.Key]; !ok || q.Stamp.Before(d.Stamp) {
+			pending[d.Key] = d
+		}
+	}
+}
+
+// expireTombstones forgets tombstones older than TombstoneTtl.
+func (n *Node) expireTombstones() {
+	limit := time.Now().Add(-n.options.TombstoneTtl).UnixNano()
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for key, t := range n.tombstones {
+		if t.Wall < limit {
+			delete(n.tombstones, key)
+		}
+	}
+}
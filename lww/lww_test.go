@@ -0,0 +1,175 @@
+package lww
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/signing"
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+func newCache() *cstorage.CStorage {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 100
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity}
+	return cstorage.New(config)
+}
+
+// memory is in-memory Transport of nodes by name, which can be broken.
+type memory struct {
+	mutex  sync.Mutex
+	nodes  map[string]*Node
+	broken bool
+}
+
+func (m *memory) Send(ctx context.Context, peer string, deltas []Delta) error {
+	m.mutex.Lock()
+	n, broken := m.nodes[peer], m.broken
+	m.mutex.Unlock()
+	if broken {
+		return errors.New("broken")
+	}
+	// sender is not known, so deltas come back to it, where they lose against themselves
+	n.Apply("", deltas)
+	return nil
+}
+
+func (m *memory) add(name string, n *Node) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.nodes[name] = n
+}
+
+func (m *memory) setBroken(broken bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.broken = broken
+}
+
+// eventually waits until cond is true.
+
+func get(n *Node, key string) string {
+	data, hit := n.cache.Peek(key)
+	if !hit {
+		return "<miss>"
+	}
+	return string(data)
+}
+
+func TestNode(t *testing.T) {
+	m := &memory{nodes: make(map[string]*Node)}
+	// a - b - c, a and c know only b
+	topology := map[string][]string{"a": {"b"}, "b": {"a", "c"}, "c": {"b"}}
+	nodes := make(map[string]*Node)
+	for name, peers := range topology {
+		n := New(newCache(), Options{Peers: peers, Transport: m, Interval: 5 * time.Millisecond})
+		defer n.Close()
+		nodes[name] = n
+		m.add(name, n)
+	}
+
+	nodes["a"].Put("k", []byte("1"))
+	testutil.Eventually(t, func() bool { return get(nodes["c"], "k") == "1" }, "put should spread through b")
+
+	// concurrent writes converge to the later one
+	nodes["c"].Put("k", []byte("2"))
+	nodes["a"].Put("k", []byte("3"))
+	testutil.Eventually(t, func() bool {
+		return get(nodes["a"], "k") == "3" && get(nodes["b"], "k") == "3" && get(nodes["c"], "k") == "3"
+	}, "later write should win on every node")
+
+	nodes["b"].Delete("k")
+	testutil.Eventually(t, func() bool {
+		return get(nodes["a"], "k") == "<miss>" && get(nodes["c"], "k") == "<miss>"
+	}, "delete should spread")
+}
+
+func TestTombstone(t *testing.T) {
+	n := New(newCache(), Options{})
+	defer n.Close()
+
+	old := n.cache.Now()
+	n.Put("k", []byte("1"))
+	n.Delete("k")
+	if applied := n.Apply("", []Delta{{Key: "k", Data: []byte("old"), Stamp: old}}); applied != 0 {
+		t.Error("put older than delete should not revive key")
+	}
+	if _, hit := n.Get("k"); hit {
+		t.Error("k should stay deleted")
+	}
+
+	// tombstone from a node whose clock is ahead doesn't hide later local put
+	ahead := cstorage.Timestamp{Wall: time.Now().Add(time.Hour).UnixNano()}
+	n.Apply("", []Delta{{Key: "k", Deleted: true, Stamp: ahead}})
+	n.Put("k", []byte("2"))
+	if data, _ := n.Get("k"); string(data) != "2" {
+		t.Errorf("local put after remote delete should win, got %q", data)
+	}
+	if applied := n.Apply("", []Delta{{Key: "k", Deleted: true, Stamp: ahead}}); applied != 0 {
+		t.Error("repeated delete should not be applied")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	m := &memory{nodes: make(map[string]*Node)}
+	errs := make(chan error, 100)
+	a := New(newCache(), Options{Peers: []string{"b"}, Transport: m, Interval: 5 * time.Millisecond, OnError: func(peer string, err error) { errs <- err }})
+	defer a.Close()
+	b := New(newCache(), Options{Transport: m})
+	defer b.Close()
+	m.add("b", b)
+
+	m.setBroken(true)
+	for i := 0; i < 10; i++ {
+		a.Put(fmt.Sprint(i), []byte("1"))
+	}
+	<-errs
+	m.setBroken(false)
+	testutil.Eventually(t, func() bool { return b.cache.Stats().Size == 10 }, "deltas should be sent again after failure")
+}
+
+func TestHTTPTransport(t *testing.T) {
+	b := New(newCache(), Options{})
+	defer b.Close()
+	srv := httptest.NewServer(Handler(b))
+	defer srv.Close()
+
+	a := New(newCache(), Options{Peers: []string{srv.URL}, Transport: &HTTPTransport{Self: "a"}, Interval: 5 * time.Millisecond})
+	a.Put("k", []byte("1"))
+	a.Close()
+	if data, hit := b.Get("k"); !hit || string(data) != "1" {
+		t.Errorf("k should be sent by Close, got %q %v", data, hit)
+	}
+}
+
+func TestHTTPTransportSigned(t *testing.T) {
+	keyring := signing.NewKeyring(signing.Key{ID: "k1", Secret: []byte("secret")})
+	b := New(newCache(), Options{Keyring: keyring})
+	defer b.Close()
+	srv := httptest.NewServer(Handler(b))
+	defer srv.Close()
+
+	signed := &HTTPTransport{Self: "a", Keyring: keyring}
+	if err := signed.Send(context.Background(), srv.URL, []Delta{{Key: "k", Data: []byte("1"), Stamp: cstorage.Timestamp{Wall: 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	if data, hit := b.Get("k"); !hit || string(data) != "1" {
+		t.Errorf("signed deltas should be applied, got %q %v", data, hit)
+	}
+
+	other := signing.NewKeyring(signing.Key{ID: "k1", Secret: []byte("other")})
+	for _, transport := range []*HTTPTransport{{Self: "a"}, {Self: "a", Keyring: other}} {
+		if err := transport.Send(context.Background(), srv.URL, []Delta{{Key: "x", Data: []byte("1"), Stamp: cstorage.Timestamp{Wall: 1}}}); err == nil {
+			t.Error("deltas which are not signed by accepted key should be rejected")
+		}
+	}
+	if _, hit := b.Get("x"); hit {
+		t.Error("rejected deltas should not be applied")
+	}
+}
@@ -0,0 +1,44 @@
+package cstorage
+
+import "unsafe"
+
+// nodeOverhead is estimated bytes of a key other than its key, data and per-key counters; node itself, and hash table entry which has
+// string header, pointer and a byte of hash, divided by load factor of Go map.
+const nodeOverhead = int64(unsafe.Sizeof(node{})) + 32
+
+// MemoryUsage function returns estimated bytes held by keys in CStorage; keys, data and per-key overhead of internal structures.
+// Metadata, tags and structures which don't grow with keys(e.g. TinyLFU sketch) are not counted.
+// It is estimation, so it is useful for tuning Capacity against memory limit of container, rather than exact accounting.
+func (s *CStorage) MemoryUsage() int64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.memoryUsage()
+}
+
+// memoryUsage is internal function of MemoryUsage. Caller should hold the mutex.
+func (s *CStorage) memoryUsage() int64 {
+	return s.bytes + s.size*(nodeOverhead+s.counters.size())
+}
+
+// Remaining function returns room left in CStorage. entries is how much more can be put before eviction starts,
+// which is number of keys, or total weight if CStorageConfig.Weigher is set. Pinned keys are counted only if CStorageConfig.PinnedInCapacity is set.
+// bytes is estimated memory those entries would take, assuming they are as large as keys in CStorage on average. It is 0 if CStorage is empty.
+func (s *CStorage) Remaining() (entries int64, bytes int64) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	used := s.weight
+	if !s.config.PinnedInCapacity {
+		used -= s.pinnedWeight
+	}
+	entries = s.config.Capacity - used
+	if entries < 0 {
+		entries = 0
+	}
+
+	if s.weight > 0 {
+		bytes = entries * s.memoryUsage() / s.weight
+	}
+	return entries, bytes
+}
@@ -0,0 +1,41 @@
+package cstorage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMemoryUsage(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	if cache.MemoryUsage() != 0 {
+		t.Errorf("empty cache should use 0 bytes, got %d", cache.MemoryUsage())
+	}
+	if entries, bytes := cache.Remaining(); entries != capacity || bytes != 0 {
+		t.Errorf("expected %d entries and 0 bytes, got %d and %d", capacity, entries, bytes)
+	}
+
+	for i := 0; i < 4; i++ {
+		cache.Put("key"+strconv.Itoa(i), make([]byte, 96))
+	}
+	perKey := int64(4+96) + nodeOverhead + 16 // hits and version
+	if usage := cache.MemoryUsage(); usage != 4*perKey {
+		t.Errorf("expected %d bytes, got %d", 4*perKey, usage)
+	}
+	if entries, bytes := cache.Remaining(); entries != 6 || bytes != 6*perKey {
+		t.Errorf("expected 6 entries and %d bytes, got %d and %d", 6*perKey, entries, bytes)
+	}
+
+	cache.Put("key0", make([]byte, 46))
+	cache.Delete("key1")
+	if usage := cache.MemoryUsage(); usage != 3*perKey-50 {
+		t.Errorf("expected %d bytes, got %d", 3*perKey-50, usage)
+	}
+	if cache.Stats().MemoryUsage != cache.MemoryUsage() {
+		t.Error("stats should have memory usage")
+	}
+}
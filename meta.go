@@ -0,0 +1,66 @@
+package cstorage
+
+import "time"
+
+// Info structure is everything CStorage knows about a key, returned by GetWithInfo.
+// Timestamp is hybrid logical clock timestamp of data, see CStorageConfig.HLC. Tags are given by PutTagged. Flags are given by PutWithFlags.
+type Info struct {
+	Data      []byte
+	ExpiresAt time.Time
+	Version   uint64
+	Meta      map[string]string
+	Flags     uint32
+	Schema    uint32
+	Timestamp Timestamp
+	Tags      []string
+}
+
+// PutWithMeta function is same as Put, but it attaches small user metadata to the key.
+// It is for instrumentation which should travel with data, such as origin service, trace id or schema version.
+// Metadata is copied, so caller can reuse meta after the call.
+func (s *CStorage) PutWithMeta(key string, data []byte, meta map[string]string) (hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, hit = s.putMeta(key, s.hash(key), data, s.config.Ttl, s.config.Sliding, copyMeta(meta))
+	return hit
+}
+
+// GetWithInfo function is same as Get, but it returns Info which has expiration, version and metadata of the key.
+func (s *CStorage) GetWithInfo(key string) (info Info, hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, ok := s.get(key)
+	if !ok {
+		return Info{}, false
+	}
+
+	return s.info(n), true
+}
+
+// info makes Info of node. Caller should hold the mutex.
+func (s *CStorage) info(n *node) Info {
+	return Info{
+		Data:      s.value(n),
+		ExpiresAt: n.ttl,
+		Version:   s.counters.versionOf(n),
+		Meta:      copyMeta(n.meta),
+		Flags:     n.flags,
+		Schema:    n.schema,
+		Timestamp: n.stamp,
+		Tags:      append([]string(nil), n.tags...),
+	}
+}
+
+// copyMeta copies metadata map, nil stays nil.
+func copyMeta(meta map[string]string) map[string]string {
+	if meta == nil {
+		return nil
+	}
+	c := make(map[string]string, len(meta))
+	for k, v := range meta {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,37 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMeta(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	meta := map[string]string{"origin": "user-service", "trace": "abc"}
+	cache.PutWithMeta("key1", []byte("1"), meta)
+	meta["origin"] = "changed"
+
+	info, hit := cache.GetWithInfo("key1")
+	if !hit || string(info.Data) != "1" || info.Meta["origin"] != "user-service" || info.Meta["trace"] != "abc" {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if info.Version == 0 || info.ExpiresAt.IsZero() {
+		t.Errorf("info should have version and expiration, got %+v", info)
+	}
+
+	cache.Put("key1", []byte("2"))
+	info, _ = cache.GetWithInfo("key1")
+	if info.Meta != nil {
+		t.Errorf("put should replace metadata, got %v", info.Meta)
+	}
+
+	cache.PutMulti([]Entry{{Key: "key2", Data: []byte("2"), Meta: map[string]string{"schema": "v2"}}})
+	info, _ = cache.GetWithInfo("key2")
+	if info.Meta["schema"] != "v2" {
+		t.Errorf("PutMulti should attach metadata, got %v", info.Meta)
+	}
+}
@@ -0,0 +1,214 @@
+package cstorage
+
+import "time"
+
+// nsSeparator separates name of Namespace and key. Since it is not printable, keys of Namespace don't collide with keys put directly.
+const nsSeparator = "\x00"
+
+// NamespaceConfig structure is configuration of Namespace, given by CStorageConfig.Namespaces.
+// - Ttl: ttl of keys put to the namespace. Ttl of CStorageConfig is used if zero.
+// - Capacity: share of capacity the namespace can use, in the same unit as Capacity of CStorageConfig. If the namespace is full, its own key is evicted for new key. No limit other than storage capacity if zero.
+type NamespaceConfig struct {
+	Ttl      time.Duration
+	Capacity int64
+}
+
+// Namespace structure is a view of CStorage which has its own ttl, capacity share and stats, so several kinds of objects
+// can be cached in one CStorage instead of separate instances, sharing memory and eviction policy.
+// Keys of Namespace are stored in CStorage as name + "\x00" + key, so they are also seen by functions of CStorage such as IterateLRU.
+type Namespace struct {
+	s           *CStorage
+	name        string
+	prefix      string
+	config      NamespaceConfig
+	size        int64
+	weight      int64
+	stats       Stats
+	cardinality *cardinality
+}
+
+// Namespace function returns Namespace of name, which is made on first call. Its configuration is taken from CStorageConfig.Namespaces.
+func (s *CStorage) Namespace(name string) *Namespace {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if ns, ok := s.namespaces[name]; ok {
+		return ns
+	}
+
+	ns := &Namespace{s: s, name: name, prefix: name + nsSeparator, config: s.config.Namespaces[name]}
+	if ns.config.Ttl == 0 {
+		ns.config.Ttl = s.config.Ttl
+	}
+	if s.config.CardinalityWindow > 0 {
+		ns.cardinality = &cardinality{}
+	}
+	if s.namespaces == nil {
+		s.namespaces = make(map[string]*Namespace)
+	}
+	s.namespaces[name] = ns
+	return ns
+}
+
+// Name function returns name of Namespace.
+func (ns *Namespace) Name() string {
+	return ns.name
+}
+
+// Get function is same as Get of CStorage, for key in Namespace.
+func (ns *Namespace) Get(key string) (data []byte, hit bool) {
+	s := ns.s
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, ok := s.get(ns.prefix + key)
+	if !ok {
+		ns.stats.Misses++
+		return nil, false
+	}
+	if n.ns == nil {
+		// recovered from Overflow
+		ns.attach(n)
+	}
+	ns.stats.Hits++
+	return s.value(n), true
+}
+
+// Put function is same as Put of CStorage, but key is put with ttl of Namespace.
+// If Namespace has capacity share and it is full, keys of Namespace are evicted first.
+func (ns *Namespace) Put(key string, data []byte) (hit bool) {
+	ns.s.mutex.Lock()
+	defer ns.s.mutex.Unlock()
+
+	return ns.put(key, data, ns.config.Ttl)
+}
+
+// PutWithTtl function is same as PutWithTtl of CStorage, for key in Namespace.
+func (ns *Namespace) PutWithTtl(key string, data []byte, ttl time.Duration) (hit bool) {
+	ns.s.mutex.Lock()
+	defer ns.s.mutex.Unlock()
+
+	return ns.put(key, data, ttl)
+}
+
+// put is internal function of Put and PutWithTtl. Caller should hold the mutex.
+func (ns *Namespace) put(key string, data []byte, ttl time.Duration) (hit bool) {
+	s := ns.s
+	capacity := ns.config.Capacity
+	if capacity == 0 {
+		capacity = s.config.Capacity
+	}
+	s.count(ns.cardinality, ns.name, s.hash(key), capacity)
+
+	key = ns.prefix + key
+	if _, ok := s.table[key]; !ok && ns.config.Capacity > 0 {
+		weight := s.weigh(key, data)
+		if weight > ns.config.Capacity {
+			ns.stats.Rejected++
+			s.stats.Rejected++
+			return false
+		}
+		for ns.weight+weight > ns.config.Capacity {
+			victim := ns.victim()
+			if victim == nil {
+				break
+			}
+			s.spill(victim)
+			s.evict(victim, true)
+			s.stats.Evicted++
+		}
+	}
+
+	n, hit := s.put(key, data, ttl, s.config.Sliding)
+	if n == nil {
+		ns.stats.Rejected++
+		return hit
+	}
+	if n.ns == nil {
+		ns.attach(n)
+	}
+	return hit
+}
+
+// Delete function is same as Delete of CStorage, for key in Namespace.
+func (ns *Namespace) Delete(key string) (hit bool) {
+	s := ns.s
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.delete(ns.prefix + key)
+}
+
+// Clear function deletes every keys of Namespace. Keys of other namespaces are not affected.
+func (ns *Namespace) Clear() {
+	s := ns.s
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var keys []*node
+	s.each(func(n *node) bool {
+		if n.ns == ns {
+			keys = append(keys, n)
+		}
+		return true
+	})
+	for _, n := range keys {
+		s.evict(n, false)
+	}
+}
+
+// Size function returns number of keys in Namespace.
+func (ns *Namespace) Size() int64 {
+	s := ns.s
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return ns.size
+}
+
+// Stats function returns counters of Namespace. Hits and Misses are of Get of Namespace, and Evicted includes keys evicted
+// by capacity of either Namespace or CStorage. Capacity is capacity share of Namespace.
+func (ns *Namespace) Stats() Stats {
+	s := ns.s
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	st := ns.stats
+	st.Size = ns.size
+	st.Weight = ns.weight
+	st.Capacity = ns.config.Capacity
+	if ns.cardinality != nil {
+		st.Cardinality = uint64(ns.cardinality.estimate())
+	}
+	return st
+}
+
+// attach makes node belong to Namespace. Caller should hold the mutex.
+func (ns *Namespace) attach(n *node) {
+	n.ns = ns
+	ns.size++
+	ns.weight += n.weight
+}
+
+// detach is called when node of Namespace is removed from CStorage. Caller should hold the mutex.
+func (ns *Namespace) detach(n *node, evicted bool) {
+	ns.size--
+	ns.weight -= n.weight
+	if evicted {
+		ns.stats.Evicted++
+	}
+}
+
+// victim returns key of Namespace which comes first in eviction order, or nil if there is none.
+// It scans keys in eviction order, so it takes longer when Namespace has small portion of keys.
+func (ns *Namespace) victim() *node {
+	var victim *node
+	ns.s.policy.each(func(n *node) bool {
+		if n.ns == ns {
+			victim = n
+			return false
+		}
+		return true
+	})
+	return victim
+}
@@ -0,0 +1,62 @@
+package cstorage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNamespace(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Namespaces: map[string]NamespaceConfig{
+		"sessions": {Ttl: time.Millisecond, Capacity: 3},
+	}}
+	cache := New(config)
+
+	sessions := cache.Namespace("sessions")
+	users := cache.Namespace("users")
+	if cache.Namespace("sessions") != sessions {
+		t.Error("Namespace should return same view for same name")
+	}
+
+	users.Put("key", []byte("user"))
+	sessions.Put("key", []byte("session"))
+	cache.Put("key", []byte("plain"))
+	if data, _ := users.Get("key"); string(data) != "user" {
+		t.Errorf("expected user, got %s", data)
+	}
+	if data, _ := cache.Get("key"); string(data) != "plain" {
+		t.Errorf("expected plain, got %s", data)
+	}
+
+	time.Sleep(time.Millisecond * 10)
+	if _, hit := sessions.Get("key"); hit {
+		t.Error("key of sessions should expire by ttl of namespace")
+	}
+	if _, hit := users.Get("key"); !hit {
+		t.Error("key of users should use ttl of storage")
+	}
+
+	for i := 0; i < 5; i++ {
+		sessions.PutWithTtl(strconv.Itoa(i), []byte{}, ttl)
+	}
+	if sessions.Size() != 3 {
+		t.Errorf("sessions should be limited to its capacity share, got %d", sessions.Size())
+	}
+	if _, hit := users.Get("key"); !hit {
+		t.Error("key of other namespace shouldn't be evicted by full namespace")
+	}
+	st := sessions.Stats()
+	if st.Hits != 0 || st.Misses != 1 || st.Evicted != 2 || st.Size != 3 || st.Capacity != 3 {
+		t.Errorf("unexpected stats of sessions %+v", st)
+	}
+
+	sessions.Clear()
+	if sessions.Size() != 0 || cache.Size() != 2 {
+		t.Errorf("only keys of sessions should be cleared, got %d and %d", sessions.Size(), cache.Size())
+	}
+	if !users.Delete("key") || users.Size() != 0 {
+		t.Error("key of users should be deleted")
+	}
+}
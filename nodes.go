@@ -0,0 +1,64 @@
+package cstorage
+
+import "math/bits"
+
+// nodes holds nodes of CStorage in chunks of contiguous memory, so nodes are close to each other and links of list are int32 index
+// instead of pointers, which GC doesn't scan. Chunk k has firstChunk<<k nodes, so small CStorage doesn't take much memory.
+// Chunks never move once allocated, so *node stays valid. Index is 1-based, so zero value of link means none.
+// Released nodes are reused by later alloc; only nodes which are not referred anymore should be released, see CStorage.reclaim.
+type nodes struct {
+	chunks [][]node
+	free   []int32
+	// number of indexes handed out, including released ones
+	used int32
+}
+
+// firstChunk is number of nodes in the first chunk.
+const firstChunk = 16
+
+// alloc returns zeroed node, which knows its index as self.
+func (ns *nodes) alloc() *node {
+	var i int32
+	if k := len(ns.free); k > 0 {
+		i = ns.free[k-1]
+		ns.free = ns.free[:k-1]
+	} else {
+		ns.used++
+		i = ns.used
+		if chunk, _ := locate(i); chunk == len(ns.chunks) {
+			ns.chunks = append(ns.chunks, make([]node, firstChunk<<chunk))
+		}
+	}
+
+	n := ns.at(i)
+	*n = node{self: i}
+	return n
+}
+
+// at returns node of index i, nil if i is 0.
+func (ns *nodes) at(i int32) *node {
+	if i == 0 {
+		return nil
+	}
+	chunk, off := locate(i)
+	return &ns.chunks[chunk][off]
+}
+
+// release zeroes node and gives its index back for reuse.
+func (ns *nodes) release(n *node) {
+	i := n.self
+	*n = node{}
+	ns.free = append(ns.free, i)
+}
+
+// reset forgets every nodes. Chunks are left to GC, so nodes still referred by caller stay valid.
+func (ns *nodes) reset() {
+	*ns = nodes{}
+}
+
+// locate returns chunk of index i, and offset in the chunk.
+func locate(i int32) (chunk int, off int) {
+	p := int(i - 1)
+	chunk = bits.Len(uint(p/firstChunk+1)) - 1
+	return chunk, p - firstChunk*(1<<chunk-1)
+}
@@ -0,0 +1,92 @@
+package cstorage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNodes(t *testing.T) {
+	ns := &nodes{}
+	seen := make(map[*node]bool)
+	for i := int32(1); i <= 1000; i++ {
+		n := ns.alloc()
+		if n.self != i || ns.at(i) != n || seen[n] {
+			t.Fatalf("node %d is not at its index", i)
+		}
+		seen[n] = true
+	}
+	if len(ns.chunks) != 6 {
+		t.Errorf("expected 6 chunks for 1000 nodes, got %d", len(ns.chunks))
+	}
+
+	n := ns.at(500)
+	ns.release(n)
+	if reused := ns.alloc(); reused != n || reused.self != 500 {
+		t.Errorf("released node should be reused, got %d", reused.self)
+	}
+	if ns.at(0) != nil {
+		t.Error("index 0 should be nil")
+	}
+}
+
+func TestNodesReuse(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+
+	for _, policy := range []Policy{PolicyLRU, PolicySLRU, PolicyARC, PolicySIEVE} {
+		config := CStorageConfig{Ttl: ttl, Capacity: capacity, Policy: policy}
+		cache := New(config)
+		for i := 0; i < 1000; i++ {
+			cache.Put(strconv.Itoa(i), []byte(strconv.Itoa(i)))
+			if i%7 == 0 {
+				cache.Delete(strconv.Itoa(i - 1))
+			}
+			if i%3 == 0 {
+				cache.Get(strconv.Itoa(i - 2))
+			}
+		}
+		// nodes of removed keys are reused, so nodes don't grow with churn
+		if used := int64(cache.nodes.used); used > 4*capacity {
+			t.Errorf("policy %d: expected nodes to be reused, %d are used", policy, used)
+		}
+		for i := 990; i < 1000; i++ {
+			if data, hit := cache.Get(strconv.Itoa(i)); hit && string(data) != strconv.Itoa(i) {
+				t.Errorf("policy %d: %d has wrong data %q", policy, i, data)
+			}
+		}
+		if cache.Size() > capacity {
+			t.Errorf("policy %d: size %d exceeds capacity", policy, cache.Size())
+		}
+	}
+}
+
+func BenchmarkPut(b *testing.B) {
+	cache := New(CStorageConfig{Ttl: time.Hour, Capacity: 100000})
+	keys := make([]string, 200000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	data := []byte("data")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Put(keys[i%len(keys)], data)
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	cache := New(CStorageConfig{Ttl: time.Hour, Capacity: 100000})
+	keys := make([]string, 100000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		cache.Put(keys[i], []byte("data"))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Get(keys[(i*7919)%len(keys)])
+	}
+}
@@ -0,0 +1,17 @@
+package cstorage
+
+import "time"
+
+// PutOnce function is same as PutWithTtl, but key is deleted on its first Get hit, so data can be read only once(e.g. one-time token).
+// Get and delete are done under same lock, so only one of concurrent Gets gets the data. Peek doesn't delete the key.
+// If key is put again by other Put family functions, it is no longer deleted on Get.
+func (s *CStorage) PutOnce(key string, data []byte, ttl time.Duration) (hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, hit := s.put(key, data, ttl, false)
+	if n != nil {
+		n.once = true
+	}
+	return hit
+}
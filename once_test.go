@@ -0,0 +1,62 @@
+package cstorage
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPutOnce(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.PutOnce("token", []byte("secret"), time.Minute)
+	if _, hit := cache.Peek("token"); !hit {
+		t.Errorf("peek should not delete the key")
+	}
+	if data, hit := cache.Get("token"); !hit || string(data) != "secret" {
+		t.Errorf("first get should hit, got %s", data)
+	}
+	if _, hit := cache.Get("token"); hit {
+		t.Errorf("second get should miss")
+	}
+	if cache.Size() != 0 {
+		t.Errorf("key should be deleted, got size %d", cache.Size())
+	}
+
+	cache.PutOnce("token", []byte("secret"), time.Minute)
+	cache.Put("token", []byte("reusable"))
+	cache.Get("token")
+	if _, hit := cache.Get("token"); !hit {
+		t.Errorf("key put again by Put should not be deleted on get")
+	}
+}
+
+func TestPutOnceConcurrent(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Policy: PolicySIEVE}
+	cache := New(config)
+
+	cache.PutOnce("token", []byte("secret"), time.Minute)
+
+	var hits int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, hit := cache.Get("token"); hit {
+				atomic.AddInt32(&hits, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if hits != 1 {
+		t.Errorf("only one get should hit, got %d", hits)
+	}
+}
@@ -0,0 +1,180 @@
+// Package otel instruments CStorage for OpenTelemetry, so cache behavior shows up in distributed traces next to the calls it shields.
+// Cache wraps CStorage with context-aware Get, Put, Delete and Load, which start a span for each call and record its latency.
+//
+// It doesn't depend on OpenTelemetry module. Tracer, Span and Meter are the small part of its API which is used here,
+// so adapters of go.opentelemetry.io/otel/trace.Tracer and metric.Float64Histogram take a few lines, e.g.
+//
+//	type tracer struct{ trace.Tracer }
+//
+//	func (t tracer) Start(ctx context.Context, name string) (context.Context, otel.Span) {
+//		ctx, s := t.Tracer.Start(ctx, name)
+//		return ctx, span{s}
+//	}
+//
+// where span converts Attribute into attribute.KeyValue in SetAttributes.
+package otel
+
+import (
+	"context"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+// Attribute is key and value of attribute of span or measurement. Value is bool, int64, float64 or string.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Names of attributes set by Cache, following semantic conventions of OpenTelemetry where there is one.
+const (
+	AttributeOperation = "cache.operation"
+	AttributeHit       = "cache.hit"
+	AttributeKey       = "cache.key"
+	AttributeSize      = "cache.item.size"
+)
+
+// Tracer interface starts spans, like trace.Tracer of OpenTelemetry.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span interface is span started by Tracer, like trace.Span of OpenTelemetry.
+type Span interface {
+	SetAttributes(attributes ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Meter interface records latency of operations, e.g. to histogram of OpenTelemetry named "cache.operation.duration".
+// Attributes are the same as span of the operation, except key.
+type Meter interface {
+	Record(ctx context.Context, latency time.Duration, attributes ...Attribute)
+}
+
+// Options structure is configuration of Cache.
+// - Tracer: optional Tracer which starts span of every operation
+// - Meter: optional Meter which records latency of every operation
+// - Prefix: prefix of span names(e.g. "cstorage.get"), "cstorage" if empty
+// - RecordKeys: if true, key is set as attribute of spans. It is off by default since keys might have personal data, and they make traces larger.
+type Options struct {
+	Tracer     Tracer
+	Meter      Meter
+	Prefix     string
+	RecordKeys bool
+}
+
+// Cache structure is CStorage instrumented by Options. Calls made directly to CStorage are not instrumented.
+type Cache struct {
+	cache   *cstorage.CStorage
+	options Options
+}
+
+// New function returns Cache which instruments cache.
+func New(cache *cstorage.CStorage, options Options) *Cache {
+	if options.Prefix == "" {
+		options.Prefix = "cstorage"
+	}
+	return &Cache{cache: cache, options: options}
+}
+
+// CStorage function returns wrapped CStorage.
+func (c *Cache) CStorage() *cstorage.CStorage {
+	return c.cache
+}
+
+// Get calls Get of CStorage in span "get", with cache.hit attribute.
+func (c *Cache) Get(ctx context.Context, key string) (data []byte, hit bool) {
+	o := c.start(ctx, "get", key)
+	data, hit = c.cache.Get(key)
+	o.end(nil, Attribute{AttributeHit, hit}, Attribute{AttributeSize, int64(len(data))})
+	return data, hit
+}
+
+// Put calls Put of CStorage in span "put".
+func (c *Cache) Put(ctx context.Context, key string, data []byte) (hit bool) {
+	o := c.start(ctx, "put", key)
+	hit = c.cache.Put(key, data)
+	o.end(nil, Attribute{AttributeSize, int64(len(data))})
+	return hit
+}
+
+// PutWithTtl calls PutWithTtl of CStorage in span "put".
+func (c *Cache) PutWithTtl(ctx context.Context, key string, data []byte, ttl time.Duration) (hit bool) {
+	o := c.start(ctx, "put", key)
+	hit = c.cache.PutWithTtl(key, data, ttl)
+	o.end(nil, Attribute{AttributeSize, int64(len(data))})
+	return hit
+}
+
+// Delete calls Delete of CStorage in span "delete", with cache.hit attribute which tells whether key was there.
+func (c *Cache) Delete(ctx context.Context, key string) (hit bool) {
+	o := c.start(ctx, "delete", key)
+	hit = c.cache.Delete(key)
+	o.end(nil, Attribute{AttributeHit, hit})
+	return hit
+}
+
+// Load function is to get data of key, loading it by loader and putting it on miss, in span "load".
+// loader is called with context of the span, so its own spans(e.g. of database query) are children of it. Error of loader is returned as is, and nothing is put.
+func (c *Cache) Load(ctx context.Context, key string, loader func(ctx context.Context, key string) ([]byte, error)) (data []byte, err error) {
+	o := c.start(ctx, "load", key)
+	data, hit := c.cache.Get(key)
+	if !hit {
+		data, err = loader(o.ctx, key)
+		if err == nil {
+			c.cache.Put(key, data)
+		}
+	}
+	o.end(err, Attribute{AttributeHit, hit}, Attribute{AttributeSize, int64(len(data))})
+	return data, err
+}
+
+// Refresh function wraps fn, which is to be CStorageConfig.Refresh, so every background refresh is done in span "refresh".
+// Since refresh is not part of any request, the span is root of its own trace.
+func Refresh(options Options, fn func(key string) ([]byte, error)) func(key string) ([]byte, error) {
+	c := New(nil, options)
+	return func(key string) ([]byte, error) {
+		o := c.start(context.Background(), "refresh", key)
+		data, err := fn(key)
+		o.end(err, Attribute{AttributeSize, int64(len(data))})
+		return data, err
+	}
+}
+
+// operation is instrumented call in progress.
+type operation struct {
+	c     *Cache
+	ctx   context.Context
+	name  string
+	span  Span
+	start time.Time
+}
+
+func (c *Cache) start(ctx context.Context, name, key string) operation {
+	o := operation{c: c, ctx: ctx, name: name, start: time.Now()}
+	if c.options.Tracer != nil {
+		o.ctx, o.span = c.options.Tracer.Start(ctx, c.options.Prefix+"."+name)
+		if c.options.RecordKeys {
+			o.span.SetAttributes(Attribute{AttributeKey, key})
+		}
+	}
+	return o
+}
+
+// end ends span of operation with attributes and err, if any, and records latency.
+func (o operation) end(err error, attributes ...Attribute) {
+	latency := time.Since(o.start)
+	attributes = append(attributes, Attribute{AttributeOperation, o.name})
+	if o.span != nil {
+		o.span.SetAttributes(attributes...)
+		if err != nil {
+			o.span.RecordError(err)
+		}
+		o.span.End()
+	}
+	if o.c.options.Meter != nil {
+		o.c.options.Meter.Record(o.ctx, latency, attributes...)
+	}
+}
@@ -0,0 +1,147 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+type parentKey struct{}
+
+type recordedSpan struct {
+	name       string
+	parent     string
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+func (s *recordedSpan) SetAttributes(attributes ...Attribute) {
+	for _, a := range attributes {
+		s.attributes[a.Key] = a.Value
+	}
+}
+
+func (s *recordedSpan) RecordError(err error) { s.err = err }
+
+func (s *recordedSpan) End() { s.ended = true }
+
+type recorder struct {
+	mutex     sync.Mutex
+	spans     []*recordedSpan
+	latencies []map[string]interface{}
+}
+
+func (r *recorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(parentKey{}).(string)
+	s := &recordedSpan{name: name, parent: parent, attributes: make(map[string]interface{})}
+	r.mutex.Lock()
+	r.spans = append(r.spans, s)
+	r.mutex.Unlock()
+	return context.WithValue(ctx, parentKey{}, name), s
+}
+
+func (r *recorder) Record(ctx context.Context, latency time.Duration, attributes ...Attribute) {
+	m := map[string]interface{}{}
+	for _, a := range attributes {
+		m[a.Key] = a.Value
+	}
+	r.mutex.Lock()
+	r.latencies = append(r.latencies, m)
+	r.mutex.Unlock()
+}
+
+func TestCache(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity}
+	r := &recorder{}
+	c := New(cstorage.New(config), Options{Tracer: r, Meter: r})
+	ctx := context.Background()
+
+	c.Put(ctx, "a", []byte("1"))
+	c.Get(ctx, "a")
+	c.Get(ctx, "b")
+	c.Delete(ctx, "a")
+
+	expected := []struct {
+		name string
+		hit  interface{}
+	}{{"cstorage.put", nil}, {"cstorage.get", true}, {"cstorage.get", false}, {"cstorage.delete", true}}
+	if len(r.spans) != len(expected) || len(r.latencies) != len(expected) {
+		t.Fatalf("expected %d spans and latencies, got %d and %d", len(expected), len(r.spans), len(r.latencies))
+	}
+	for i, e := range expected {
+		s := r.spans[i]
+		if s.name != e.name || s.attributes[AttributeHit] != e.hit || !s.ended {
+			t.Errorf("span %d: expected %s with hit %v, got %+v", i, e.name, e.hit, s)
+		}
+		if _, ok := s.attributes[AttributeKey]; ok {
+			t.Errorf("span %d: key should not be recorded by default", i)
+		}
+		if r.latencies[i][AttributeHit] != e.hit {
+			t.Errorf("latency %d: expected hit %v, got %v", i, e.hit, r.latencies[i])
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity}
+	r := &recorder{}
+	c := New(cstorage.New(config), Options{Tracer: r, Prefix: "users", RecordKeys: true})
+	ctx := context.Background()
+
+	loads := 0
+	loader := func(ctx context.Context, key string) ([]byte, error) {
+		loads++
+		// loader's own span is child of load span
+		_, s := r.Start(ctx, "db.query")
+		s.End()
+		return []byte("loaded"), nil
+	}
+	for i := 0; i < 2; i++ {
+		if data, err := c.Load(ctx, "a", loader); err != nil || string(data) != "loaded" {
+			t.Fatalf("expected loaded data, got %q, %v", data, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("expected single load, got %d", loads)
+	}
+	if len(r.spans) != 3 || r.spans[1].name != "db.query" || r.spans[1].parent != "users.load" {
+		t.Fatalf("expected db.query span under users.load, got %+v", r.spans)
+	}
+	if r.spans[0].attributes[AttributeHit] != false || r.spans[2].attributes[AttributeHit] != true || r.spans[0].attributes[AttributeKey] != "a" {
+		t.Errorf("unexpected attributes %v and %v", r.spans[0].attributes, r.spans[2].attributes)
+	}
+
+	failure := errors.New("db is down")
+	if _, err := c.Load(ctx, "b", func(context.Context, string) ([]byte, error) { return nil, failure }); err != failure {
+		t.Errorf("expected error of loader, got %v", err)
+	}
+	if s := r.spans[len(r.spans)-1]; s.err != failure {
+		t.Errorf("error should be recorded in span, got %v", s.err)
+	}
+	if _, hit := c.CStorage().Get("b"); hit {
+		t.Error("failed load should not be put")
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	r := &recorder{}
+	refresh := Refresh(Options{Tracer: r}, func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+
+	if data, err := refresh("a"); err != nil || string(data) != "a" {
+		t.Fatalf("expected data of fn, got %q, %v", data, err)
+	}
+	if len(r.spans) != 1 || r.spans[0].name != "cstorage.refresh" || r.spans[0].parent != "" {
+		t.Errorf("expected root span of refresh, got %+v", r.spans)
+	}
+}
@@ -0,0 +1,52 @@
+package cstorage
+
+import "time"
+
+// Overflow interface is secondary store for keys evicted by capacity, such as disk(see overflow subpackage).
+// When key is evicted by capacity it is spilled to Overflow, and when Get misses in memory Overflow is looked up and key found there is moved back to memory.
+// Memory and Overflow never hold the same key at once. Overflow is called while holding the lock of CStorage.
+// - Spill: store key. Overflow may discard it(e.g. when it is full), since it is still a cache.
+// - Load: returns key if it is there. Expired key can be returned, CStorage will discard it.
+// - Remove: removes key and returns whether it was there
+// - Clear: removes every keys
+type Overflow interface {
+	Spill(key string, data []byte, expiresAt time.Time) error
+	Load(key string) (data []byte, expiresAt time.Time, ok bool)
+	Remove(key string) bool
+	Clear()
+}
+
+// spill writes node to Overflow before it is evicted. Caller should hold the mutex.
+func (s *CStorage) spill(n *node) {
+	if s.config.Overflow == nil || n == nil {
+		return
+	}
+	if s.config.Overflow.Spill(n.key, s.value(n), n.ttl) == nil {
+		s.stats.Spilled++
+	}
+}
+
+// recover moves key from Overflow back into memory. Caller should hold the mutex.
+func (s *CStorage) recover(key string) (*node, bool) {
+	if s.config.Overflow == nil {
+		return nil, false
+	}
+
+	data, expiresAt, ok := s.config.Overflow.Load(key)
+	if !ok {
+		return nil, false
+	}
+
+	lifetime := expiresAt.Sub(s.now())
+	if lifetime <= 0 {
+		s.config.Overflow.Remove(key)
+		return nil, false
+	}
+
+	n, _ := s.put(key, data, lifetime, s.config.Sliding)
+	if n == nil {
+		return nil, false
+	}
+	s.stats.Recovered++
+	return n, true
+}
@@ -0,0 +1,218 @@
+// Package overflow provides cstorage.Overflow implementations.
+// Disk spills evicted keys into append-only segment files in a directory, bounded by size.
+package overflow
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+// segments is number of segment files Disk keeps at most. When the store is full, the oldest segment is dropped.
+const segments = 4
+
+// header is size of record header; key length(4), data length(4), expiration in unix nano(8).
+const header = 16
+
+// ErrTooLarge is returned by Spill when single record is larger than a segment.
+var ErrTooLarge = errors.New("overflow: record is larger than segment")
+
+// ErrClosed is returned by Spill after Disk is closed.
+var ErrClosed = errors.New("overflow: disk is closed")
+
+// Disk structure is bounded on-disk store of evicted keys. It is safe for concurrent use.
+// Files in the directory are owned by Disk, existing segment files are removed when it is created,
+// since it is overflow of the cache and not persistence.
+type Disk struct {
+	dir        string
+	segmentMax int64
+
+	mutex    sync.Mutex
+	segments []*segment
+	index    map[string]location
+	closed   bool
+}
+
+var _ cstorage.Overflow = (*Disk)(nil)
+
+// segment is single append-only file.
+type segment struct {
+	id   int64
+	file *os.File
+	size int64
+}
+
+// location is where the record of key is.
+type location struct {
+	segment   *segment
+	offset    int64
+	keyLen    uint32
+	dataLen   uint32
+	expiresAt time.Time
+}
+
+// NewDisk function returns Disk which stores at most maxBytes in dir. dir is created if it doesn't exist.
+func NewDisk(dir string, maxBytes int64) (*Disk, error) {
+	if maxBytes < segments {
+		return nil, fmt.Errorf("overflow: maxBytes should be at least %d", segments)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	old, _ := filepath.Glob(filepath.Join(dir, "overflow-*.seg"))
+	for _, f := range old {
+		os.Remove(f)
+	}
+
+	d := &Disk{
+		dir:        dir,
+		segmentMax: maxBytes / segments,
+		index:      make(map[string]location),
+	}
+	if err := d.rotate(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Spill appends record of key. Previous record of key, if any, becomes garbage.
+func (d *Disk) Spill(key string, data []byte, expiresAt time.Time) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.closed {
+		return ErrClosed
+	}
+	size := int64(header + len(key) + len(data))
+	if size > d.segmentMax {
+		return ErrTooLarge
+	}
+
+	cur := d.segments[len(d.segments)-1]
+	if cur.size+size > d.segmentMax {
+		if err := d.rotate(); err != nil {
+			return err
+		}
+		cur = d.segments[len(d.segments)-1]
+	}
+
+	buf := make([]byte, size)
+	binary.LittleEndian.PutUint32(buf[0:], uint32(len(key)))
+	binary.LittleEndian.PutUint32(buf[4:], uint32(len(data)))
+	binary.LittleEndian.PutUint64(buf[8:], uint64(expiresAt.UnixNano()))
+	copy(buf[header:], key)
+	copy(buf[header+len(key):], data)
+
+	if _, err := cur.file.WriteAt(buf, cur.size); err != nil {
+		return err
+	}
+	d.index[key] = location{
+		segment:   cur,
+		offset:    cur.size,
+		keyLen:    uint32(len(key)),
+		dataLen:   uint32(len(data)),
+		expiresAt: expiresAt,
+	}
+	cur.size += size
+	return nil
+}
+
+// Load reads record of key. It misses after Disk is closed.
+func (d *Disk) Load(key string) ([]byte, time.Time, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	loc, ok := d.index[key]
+	if d.closed || !ok {
+		return nil, time.Time{}, false
+	}
+
+	data := make([]byte, loc.dataLen)
+	if _, err := loc.segment.file.ReadAt(data, loc.offset+header+int64(loc.keyLen)); err != nil {
+		delete(d.index, key)
+		return nil, time.Time{}, false
+	}
+	return data, loc.expiresAt, true
+}
+
+// Remove forgets key. Its bytes are reclaimed when the segment is dropped.
+func (d *Disk) Remove(key string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	_, ok := d.index[key]
+	delete(d.index, key)
+	return ok
+}
+
+// Clear removes every keys and segment files. It does nothing after Disk is closed.
+func (d *Disk) Clear() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.closed {
+		return
+	}
+	for _, seg := range d.segments {
+		seg.file.Close()
+		os.Remove(seg.file.Name())
+	}
+	d.segments = nil
+	d.index = make(map[string]location)
+	d.rotate()
+}
+
+// Len returns number of keys in Disk.
+func (d *Disk) Len() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return len(d.index)
+}
+
+// Close closes and removes every segment files. Spill returns ErrClosed and Load misses after it. Calling it more than once is safe.
+func (d *Disk) Close() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.closed = true
+	for _, seg := range d.segments {
+		seg.file.Close()
+		os.Remove(seg.file.Name())
+	}
+	d.segments = nil
+	d.index = nil
+	return nil
+}
+
+// rotate opens new segment, and drops the oldest one with its keys if there are too many. Caller should hold the mutex.
+func (d *Disk) rotate() error {
+	var id int64
+	if n := len(d.segments); n > 0 {
+		id = d.segments[n-1].id + 1
+	}
+
+	f, err := os.OpenFile(filepath.Join(d.dir, fmt.Sprintf("overflow-%08d.seg", id)), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	d.segments = append(d.segments, &segment{id: id, file: f})
+
+	if len(d.segments) > segments {
+		oldest := d.segments[0]
+		d.segments = d.segments[1:]
+		for key, loc := range d.index {
+			if loc.segment == oldest {
+				delete(d.index, key)
+			}
+		}
+		oldest.file.Close()
+		os.Remove(oldest.file.Name())
+	}
+	return nil
+}
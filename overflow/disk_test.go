@@ -0,0 +1,102 @@
+package overflow
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+func TestDisk(t *testing.T) {
+	disk, err := NewDisk(t.TempDir(), 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer disk.Close()
+
+	cache := cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 2, Overflow: disk})
+	cache.Put("key1", []byte("1"))
+	cache.Put("key2", []byte("2"))
+	cache.Put("key3", []byte("3"))
+
+	if disk.Len() != 1 {
+		t.Errorf("key1 should be spilled, disk has %d", disk.Len())
+	}
+	data, hit := cache.Get("key1")
+	if !hit || string(data) != "1" {
+		t.Errorf("key1 should be read back from disk, got %s", data)
+	}
+	if disk.Len() != 1 {
+		t.Errorf("key2 should be spilled and key1 should be removed from disk, disk has %d", disk.Len())
+	}
+	if st := cache.Stats(); st.Spilled != 2 || st.Recovered != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+
+	if !cache.Delete("key2") {
+		t.Error("deleting spilled key should hit")
+	}
+	if _, hit := cache.Get("key2"); hit {
+		t.Error("key2 should be deleted from disk")
+	}
+}
+
+func TestDiskBound(t *testing.T) {
+	disk, err := NewDisk(t.TempDir(), 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer disk.Close()
+
+	for i := 0; i < 100; i++ {
+		if err := disk.Spill(fmt.Sprintf("key%d", i), make([]byte, 20), time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if disk.Len() >= 100 || disk.Len() == 0 {
+		t.Errorf("old segments should be dropped, disk has %d", disk.Len())
+	}
+	if _, _, ok := disk.Load("key99"); !ok {
+		t.Error("latest key should be kept")
+	}
+	if _, _, ok := disk.Load("key0"); ok {
+		t.Error("oldest key should be dropped")
+	}
+	if err := disk.Spill("big", make([]byte, 200), time.Now()); err != ErrTooLarge {
+		t.Errorf("record larger than segment should be rejected, got %v", err)
+	}
+}
+
+func TestDiskClose(t *testing.T) {
+	dir := t.TempDir()
+	disk, err := NewDisk(dir, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 1, Overflow: disk})
+	cache.Put("key1", []byte("1"))
+	cache.Put("key2", []byte("2"))
+	if err := disk.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := disk.Spill("key3", []byte("3"), time.Now().Add(time.Hour)); err != ErrClosed {
+		t.Errorf("Spill after Close should fail, got %v", err)
+	}
+	if _, _, ok := disk.Load("key1"); ok {
+		t.Error("Load after Close should miss")
+	}
+	if _, hit := cache.Get("key1"); hit {
+		t.Error("spilled key should miss after Close")
+	}
+	cache.Put("key4", []byte("4"))
+	disk.Clear()
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.seg")); len(files) != 0 {
+		t.Errorf("Clear after Close should not create segment, got %v", files)
+	}
+	if err := disk.Close(); err != nil {
+		t.Errorf("second Close should succeed, got %v", err)
+	}
+}
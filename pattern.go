@@ -0,0 +1,162 @@
+package cstorage
+
+import (
+	"sort"
+	"strings"
+)
+
+// Thresholds of wasted pattern. Pattern with at least wastedMinKeys keys is wasted if ratio of keys which were ever hit is below wastedReuse.
+const (
+	wastedMinKeys = 10
+	wastedReuse   = 0.05
+)
+
+// Pattern structure is statistics of keys which have the same structure, returned by Patterns.
+// - Pattern: key structure, where variable segments are replaced by placeholders, e.g. "user:{n}:profile"
+// - Keys: number of keys of the pattern in CStorage
+// - Hits: total hits of the keys since they are put
+// - Unused: number of keys which were never hit since they are put
+// - Wasted: true if the pattern has enough keys and almost none of them were hit, which means they only take space of the cache
+type Pattern struct {
+	Pattern string
+	Keys    int64
+	Hits    int64
+	Unused  int64
+	Wasted  bool
+}
+
+// ReuseRatio function returns ratio of keys which were hit at least once.
+func (p Pattern) ReuseRatio() float64 {
+	if p.Keys == 0 {
+		return 0
+	}
+	return float64(p.Keys-p.Unused) / float64(p.Keys)
+}
+
+// Patterns function analyzes structure of keys in CStorage, and returns statistics of each pattern, wasted ones first and then by number of keys.
+// Keys are split into segments by ':', '/', '.' and '|', and segments which look like numbers, UUIDs or hashes are replaced by
+// {n}, {uuid} and {hex}. Trailing number of segment is replaced as well, e.g. "item42" becomes "item{n}".
+// Since hits are counted from when keys are put, recently put keys can be counted as unused. It returns nil if CounterHits is disabled.
+func (s *CStorage) Patterns() []Pattern {
+	type sample struct {
+		key  string
+		hits int64
+	}
+
+	s.mutex.RLock()
+	if !s.counters.on(CounterHits) {
+		s.mutex.RUnlock()
+		return nil
+	}
+	samples := make([]sample, 0, len(s.table))
+	for key, n := range s.table {
+		samples = append(samples, sample{key: key, hits: s.counters.hitsOf(n)})
+	}
+	s.mutex.RUnlock()
+
+	byPattern := make(map[string]*Pattern)
+	for _, sm := range samples {
+		name := keyPattern(sm.key)
+		p, ok := byPattern[name]
+		if !ok {
+			p = &Pattern{Pattern: name}
+			byPattern[name] = p
+		}
+		p.Keys++
+		p.Hits += sm.hits
+		if sm.hits == 0 {
+			p.Unused++
+		}
+	}
+
+	patterns := make([]Pattern, 0, len(byPattern))
+	for _, p := range byPattern {
+		p.Wasted = p.Keys >= wastedMinKeys && p.ReuseRatio() < wastedReuse
+		patterns = append(patterns, *p)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		a, b := patterns[i], patterns[j]
+		if a.Wasted != b.Wasted {
+			return a.Wasted
+		}
+		if a.Keys != b.Keys {
+			return a.Keys > b.Keys
+		}
+		return a.Pattern < b.Pattern
+	})
+	return patterns
+}
+
+// keyPattern returns structure of key. See Patterns.
+func keyPattern(key string) string {
+	var b strings.Builder
+	start := 0
+	for i := 0; i <= len(key); i++ {
+		if i < len(key) && !strings.ContainsRune(":/.|", rune(key[i])) {
+			continue
+		}
+		b.WriteString(segmentPattern(key[start:i]))
+		if i < len(key) {
+			b.WriteByte(key[i])
+		}
+		start = i + 1
+	}
+	return b.String()
+}
+
+// segmentPattern returns placeholder of segment if it is variable, otherwise segment with trailing number replaced.
+func segmentPattern(seg string) string {
+	if seg == "" {
+		return seg
+	}
+
+	digits, hex := 0, 0
+	for i := 0; i < len(seg); i++ {
+		c := seg[i]
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+			hex++
+		case c >= 'a' && c <= 'f', c >= 'A' && c <= 'F':
+			hex++
+		}
+	}
+
+	switch {
+	case digits == len(seg):
+		return "{n}"
+	case isUUID(seg):
+		return "{uuid}"
+	case hex == len(seg) && len(seg) >= 16 && digits > 0:
+		return "{hex}"
+	}
+
+	i := len(seg)
+	for i > 0 && seg[i-1] >= '0' && seg[i-1] <= '9' {
+		i--
+	}
+	if i < len(seg) {
+		return seg[:i] + "{n}"
+	}
+	return seg
+}
+
+// isUUID returns true if seg is UUID in 8-4-4-4-12 form.
+func isUUID(seg string) bool {
+	if len(seg) != 36 {
+		return false
+	}
+	for i := 0; i < len(seg); i++ {
+		c := seg[i]
+		if i == 8 || i == 13 || i == 18 || i == 23 {
+			if c != '-' {
+				return false
+			}
+			continue
+		}
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
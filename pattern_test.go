@@ -0,0 +1,54 @@
+package cstorage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPatterns(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 100
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	for i := 0; i < 20; i++ {
+		key := "user:" + strconv.Itoa(i) + ":profile"
+		cache.Put(key, []byte{})
+		cache.Get(key)
+	}
+	for i := 0; i < 30; i++ {
+		cache.Put("report:"+strconv.Itoa(1600000000+i), []byte{})
+	}
+	cache.Put("config", []byte{})
+
+	patterns := cache.Patterns()
+	if len(patterns) != 3 {
+		t.Fatalf("expected 3 patterns, got %+v", patterns)
+	}
+	if p := patterns[0]; p.Pattern != "report:{n}" || !p.Wasted || p.Keys != 30 || p.Unused != 30 {
+		t.Errorf("report:{n} should be wasted, got %+v", p)
+	}
+	if p := patterns[1]; p.Pattern != "user:{n}:profile" || p.Wasted || p.Hits != 20 || p.ReuseRatio() != 1 {
+		t.Errorf("user:{n}:profile should be reused, got %+v", p)
+	}
+	if p := patterns[2]; p.Pattern != "config" || p.Wasted {
+		t.Errorf("config has too few keys to be wasted, got %+v", p)
+	}
+}
+
+func TestKeyPattern(t *testing.T) {
+	cases := map[string]string{
+		"user:42":      "user:{n}",
+		"item42/price": "item{n}/price",
+		"session:3f2b1c9e-8a7d-4e6f-9b0a-1c2d3e4f5a6b": "session:{uuid}",
+		"blob:9f86d081884c7d659a2feaa0c55ad015":        "blob:{hex}",
+		"cafe:beef":                                    "cafe:beef",
+		"a..b:":                                        "a..b:",
+	}
+	for key, expected := range cases {
+		if got := keyPattern(key); got != expected {
+			t.Errorf("pattern of %q should be %q, got %q", key, expected, got)
+		}
+	}
+}
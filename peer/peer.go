@@ -0,0 +1,328 @@
+// Package peer provides Group, which fills misses from peer nodes over HTTP before loading, in the manner of groupcache.
+// Each key is owned by one node of consistent hash ring of peers. A miss on other nodes asks the owner, and only the owner calls Loader,
+// so concurrent misses of a key across the fleet are loaded once. Keys keep ttl and eviction of CStorage: the owner keeps loaded data
+// in its cache, and the others may replicate hot keys in small HotCache until ttl which the owner has left.
+package peer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/client"
+)
+
+// DefaultBasePath is path prefix of peer requests, when it is not given.
+const DefaultBasePath = "/_cstorage/"
+
+// ttlHeader carries ttl which the owner has left for the key, so replicas don't outlive it.
+const ttlHeader = "X-Cstorage-Ttl"
+
+// Loader is function which loads data of key on its owner, e.g. from database.
+type Loader func(ctx context.Context, key string) ([]byte, error)
+
+// LoadError structure is error of Loader, either local or on the owner. Group doesn't load again on other node for it.
+type LoadError struct {
+	Key  string
+	Peer string
+	Err  string
+}
+
+func (e *LoadError) Error() string {
+	if e.Peer == "" {
+		return fmt.Sprintf("peer: loading %q: %s", e.Key, e.Err)
+	}
+	return fmt.Sprintf("peer: loading %q on %s: %s", e.Key, e.Peer, e.Err)
+}
+
+// Options structure is configuration of Group.
+// - Self: base URL of this node(e.g. http://10.0.0.1:8080), which should be one of peers given to SetPeers
+// - BasePath: path prefix of peer requests, DefaultBasePath if empty. Group should be served at Path()
+// - Replicas: virtual nodes of each peer on the ring, client.DefaultReplicas if 0
+// - HotCache: cache of keys owned by other peers. If nil, keys of other peers are not replicated, and every Get of them asks the owner
+// - HotFraction: probability that key fetched from the owner is kept in HotCache, 0.1 if 0. Hot keys are fetched often, so they are replicated soon
+// - Client: HTTP client of peer requests, http.DefaultClient if nil
+type Options struct {
+	Self        string
+	BasePath    string
+	Replicas    int
+	HotCache    *cstorage.CStorage
+	HotFraction float64
+	Client      *http.Client
+}
+
+// Stats structure is counters of Group.
+// - Gets: calls of Get
+// - CacheHits, HotHits: Gets served by the cache of owned keys, and by HotCache
+// - PeerLoads, PeerErrors: fetches from owners, and those which failed and were loaded locally instead
+// - Loads: calls of Loader
+// - ServerRequests: requests served for other peers
+type Stats struct {
+	Gets           int64
+	CacheHits      int64
+	HotHits        int64
+	PeerLoads      int64
+	PeerErrors     int64
+	Loads          int64
+	ServerRequests int64
+}
+
+// Group structure is a named set of keys which are filled by Loader on their owners.
+type Group struct {
+	name    string
+	cache   *cstorage.CStorage
+	loader  Loader
+	options Options
+	path    string
+
+	mutex sync.RWMutex
+	ring  *client.Ring
+
+	// fetches and loads are separate, since fetch falls back to load for same key
+	fetches flights
+	loads   flights
+	stats   Stats
+}
+
+// New function returns Group of name, which keeps owned keys in cache and loads them by loader.
+// Until SetPeers is called, the node owns every key.
+func New(name string, cache *cstorage.CStorage, loader Loader, options Options) *Group {
+	if options.BasePath == "" {
+		options.BasePath = DefaultBasePath
+	}
+	if options.HotFraction == 0 {
+		options.HotFraction = 0.1
+	}
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+	options.Self = strings.TrimRight(options.Self, "/")
+	return &Group{
+		name:    name,
+		cache:   cache,
+		loader:  loader,
+		options: options,
+		path:    options.BasePath + url.PathEscape(name) + "/",
+		ring:    client.NewRing(options.Replicas),
+		fetches: flights{calls: make(map[string]*call)},
+		loads:   flights{calls: make(map[string]*call)},
+	}
+}
+
+// Path function returns path where Group should be served by ServeHTTP.
+func (g *Group) Path() string {
+	return g.path
+}
+
+// SetPeers function replaces peers of the ring with base URLs of peers, including Self.
+// Peers should be same on every node, otherwise a key can be loaded by several nodes.
+func (g *Group) SetPeers(peers ...string) {
+	ring := client.NewRing(g.options.Replicas)
+	for _, p := range peers {
+		ring.Add(strings.TrimRight(p, "/"))
+	}
+	g.mutex.Lock()
+	g.ring = ring
+	g.mutex.Unlock()
+}
+
+// Stats function returns copy of counters of Group.
+func (g *Group) Stats() Stats {
+	return Stats{
+		Gets:           atomic.LoadInt64(&g.stats.Gets),
+		CacheHits:      atomic.LoadInt64(&g.stats.CacheHits),
+		HotHits:        atomic.LoadInt64(&g.stats.HotHits),
+		PeerLoads:      atomic.LoadInt64(&g.stats.PeerLoads),
+		PeerErrors:     atomic.LoadInt64(&g.stats.PeerErrors),
+		Loads:          atomic.LoadInt64(&g.stats.Loads),
+		ServerRequests: atomic.LoadInt64(&g.stats.ServerRequests),
+	}
+}
+
+// owner returns base URL of owner of key, or empty if this node owns it.
+func (g *Group) owner(key string) string {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	p, ok := g.ring.Get(key)
+	if !ok || p == g.options.Self {
+		return ""
+	}
+	return p
+}
+
+// Get function returns data of key from the cache or HotCache, or else from its owner, which loads it if needed.
+// If the owner can't be reached, key is loaded locally. Error of Loader is returned as *LoadError.
+func (g *Group) Get(ctx context.Context, key string) ([]byte, error) {
+	atomic.AddInt64(&g.stats.Gets, 1)
+	if data, hit := g.cache.Get(key); hit {
+		atomic.AddInt64(&g.stats.CacheHits, 1)
+		return data, nil
+	}
+	if hot := g.options.HotCache; hot != nil {
+		if data, hit := hot.Get(key); hit {
+			atomic.AddInt64(&g.stats.HotHits, 1)
+			return data, nil
+		}
+	}
+
+	p := g.owner(key)
+	if p == "" {
+		data, _, err := g.load(ctx, key)
+		return data, err
+	}
+	// concurrent misses of key on this node ask the owner once
+	v, err := g.fetches.do(key, func() (flight, error) {
+		data, ttl, err := g.fetch(ctx, p, key)
+		if err == nil {
+			if hot := g.options.HotCache; hot != nil && ttl > 0 && rand.Float64() < g.options.HotFraction {
+				hot.PutWithTtl(key, data, ttl)
+			}
+			return flight{data: data, ttl: ttl}, nil
+		}
+		var le *LoadError
+		if errors.As(err, &le) || ctx.Err() != nil {
+			return flight{}, err
+		}
+		atomic.AddInt64(&g.stats.PeerErrors, 1)
+		data, ttl, err = g.load(ctx, key)
+		return flight{data: data, ttl: ttl}, err
+	})
+	return v.data, err
+}
+
+// load returns data of key from the cache, or loads it once for concurrent callers and puts it into the cache.
+// ttl is ttl left for the data.
+func (g *Group) load(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	v, err := g.loads.do(key, func() (flight, error) {
+		// key may be loaded by caller which was waiting for lock of flights
+		if data, hit := g.cache.Peek(key); hit {
+			ttl, _ := g.cache.Ttl(key)
+			return flight{data: data, ttl: ttl}, nil
+		}
+		atomic.AddInt64(&g.stats.Loads, 1)
+		data, err := g.loader(ctx, key)
+		if err != nil {
+			return flight{}, &LoadError{Key: key, Err: err.Error()}
+		}
+		g.cache.Put(key, data)
+		// ttl is 0 if the cache rejected data, then it is not replicated either
+		ttl, _ := g.cache.Ttl(key)
+		return flight{data: data, ttl: ttl}, nil
+	})
+	return v.data, v.ttl, err
+}
+
+// fetch asks peer p for key.
+func (g *Group) fetch(ctx context.Context, p, key string) ([]byte, time.Duration, error) {
+	atomic.AddInt64(&g.stats.PeerLoads, 1)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p+g.path+url.PathEscape(key), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := g.options.Client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		ttl, err := time.ParseDuration(resp.Header.Get(ttlHeader))
+		if err != nil {
+			return nil, 0, fmt.Errorf("peer: invalid ttl from %s: %w", p, err)
+		}
+		return body, ttl, nil
+	case http.StatusBadGateway:
+		return nil, 0, &LoadError{Key: key, Peer: p, Err: strings.TrimSpace(string(body))}
+	}
+	return nil, 0, fmt.Errorf("peer: %s from %s", resp.Status, p)
+}
+
+// ServeHTTP serves GET {Path()}{key} of other peers. The key is loaded here even if this node doesn't think it owns the key,
+// so requests don't go around while peers disagree on the ring. Error of Loader is responded with 502.
+func (g *Group) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path := r.URL.EscapedPath()
+	if !strings.HasPrefix(path, g.path) {
+		http.NotFound(w, r)
+		return
+	}
+	key, err := url.PathUnescape(strings.TrimPrefix(path, g.path))
+	if err != nil {
+		http.Error(w, "invalid key", http.StatusBadRequest)
+		return
+	}
+	atomic.AddInt64(&g.stats.ServerRequests, 1)
+
+	data, hit := g.cache.Get(key)
+	ttl, _ := g.cache.Ttl(key)
+	if !hit {
+		data, ttl, err = g.load(r.Context(), key)
+	}
+	if err != nil {
+		var le *LoadError
+		if errors.As(err, &le) {
+			http.Error(w, le.Err, http.StatusBadGateway)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set(ttlHeader, ttl.String())
+	w.Write(data)
+}
+
+// flight is result of a call of flights.
+type flight struct {
+	data []byte
+	ttl  time.Duration
+}
+
+type call struct {
+	wg  sync.WaitGroup
+	v   flight
+	err error
+}
+
+// flights runs a function once for concurrent callers of same key.
+type flights struct {
+	mutex sync.Mutex
+	calls map[string]*call
+}
+
+func (f *flights) do(key string, fn func() (flight, error)) (flight, error) {
+	f.mutex.Lock()
+	if c, ok := f.calls[key]; ok {
+		f.mutex.Unlock()
+		c.wg.Wait()
+		return c.v, c.err
+	}
+	c := &call{}
+	c.wg.Add(1)
+	f.calls[key] = c
+	f.mutex.Unlock()
+
+	c.v, c.err = fn()
+	f.mutex.Lock()
+	delete(f.calls, key)
+	f.mutex.Unlock()
+	c.wg.Done()
+	return c.v, c.err
+}
@@ -0,0 +1,168 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+func newCache() *cstorage.CStorage {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity}
+	return cstorage.New(config)
+}
+
+// newFleet starts n nodes of group sharing loader, with HotCache which keeps every fetched key.
+func newFleet(t *testing.T, n int, loader Loader) ([]*Group, []*httptest.Server) {
+	groups := make([]*Group, n)
+	servers := make([]*httptest.Server, n)
+	urls := make([]string, n)
+	for i := range groups {
+		var g *Group
+		servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { g.ServeHTTP(w, r) }))
+		t.Cleanup(servers[i].Close)
+		urls[i] = servers[i].URL
+		g = New("users", newCache(), loader, Options{Self: urls[i], HotCache: newCache(), HotFraction: 1})
+		groups[i] = g
+	}
+	for _, g := range groups {
+		g.SetPeers(urls...)
+	}
+	return groups, servers
+}
+
+func TestGroup(t *testing.T) {
+	var loads int64
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) ([]byte, error) {
+		atomic.AddInt64(&loads, 1)
+		<-release
+		return []byte("data of " + key), nil
+	}
+	groups, _ := newFleet(t, 3, loader)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for _, g := range groups {
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(g *Group) {
+				defer wg.Done()
+				data, err := g.Get(ctx, "user:1")
+				if err != nil || string(data) != "data of user:1" {
+					t.Errorf("unexpected %q %v", data, err)
+				}
+			}(g)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if loads != 1 {
+		t.Errorf("key should be loaded once across the fleet, got %d", loads)
+	}
+
+	owners := 0
+	for _, g := range groups {
+		if _, hit := g.cache.Peek("user:1"); hit {
+			owners++
+			continue
+		}
+		ttl, hit := g.options.HotCache.Ttl("user:1")
+		if !hit || ttl > time.Hour || ttl < 59*time.Minute {
+			t.Errorf("hot key should be replicated with ttl of the owner, got %v %v", ttl, hit)
+		}
+		if _, err := g.Get(ctx, "user:1"); err != nil {
+			t.Fatal(err)
+		}
+		if st := g.Stats(); st.HotHits != 1 || st.PeerLoads != 1 {
+			t.Errorf("unexpected stats %+v", st)
+		}
+	}
+	if owners != 1 {
+		t.Errorf("key should be kept only by its owner, got %d", owners)
+	}
+}
+
+func TestLoadError(t *testing.T) {
+	var loads int64
+	loader := func(ctx context.Context, key string) ([]byte, error) {
+		atomic.AddInt64(&loads, 1)
+		return nil, errors.New("not found")
+	}
+	groups, _ := newFleet(t, 3, loader)
+	for _, g := range groups {
+		_, err := g.Get(context.Background(), "user:1")
+		var le *LoadError
+		if !errors.As(err, &le) || le.Err != "not found" {
+			t.Errorf("expected LoadError, got %v", err)
+		}
+	}
+	if loads != 3 {
+		t.Errorf("error of the owner should not be loaded again locally, got %d loads", loads)
+	}
+}
+
+func TestPeerDown(t *testing.T) {
+	var loads int64
+	loader := func(ctx context.Context, key string) ([]byte, error) {
+		atomic.AddInt64(&loads, 1)
+		return []byte("1"), nil
+	}
+	groups, servers := newFleet(t, 2, loader)
+	// find key owned by the second node and stop it
+	var key string
+	for i := 0; key == ""; i++ {
+		if k := strconv.Itoa(i); groups[0].owner(k) == servers[1].URL {
+			key = k
+			break
+		}
+	}
+	servers[1].Close()
+
+	data, err := groups[0].Get(context.Background(), key)
+	if err != nil || string(data) != "1" {
+		t.Fatalf("key should be loaded locally, got %q %v", data, err)
+	}
+	if st := groups[0].Stats(); st.PeerErrors != 1 || st.Loads != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	if _, hit := groups[0].cache.Peek(key); !hit {
+		t.Error("locally loaded key should be cached")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	g := New("users", newCache(), func(ctx context.Context, key string) ([]byte, error) { return []byte(key), nil }, Options{})
+	srv := httptest.NewServer(g)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + g.Path() + "a%2Fb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get(ttlHeader) == "" {
+		t.Errorf("unexpected response %v %v", resp.Status, resp.Header)
+	}
+	if _, hit := g.cache.Peek("a/b"); !hit {
+		t.Error("escaped key should be loaded")
+	}
+	resp, err = http.Post(srv.URL+g.Path()+"a", "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %v", resp.Status)
+	}
+}
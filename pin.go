@@ -0,0 +1,103 @@
+package cstorage
+
+import "errors"
+
+// ErrFull is returned when key can't be put since every key in CStorage is pinned and CStorageConfig.EvictPinned is not set.
+var ErrFull = errors.New("cstorage: no key to evict, every key is pinned")
+
+// Pin function is to pin existing key, so it is never evicted by capacity. It returns hit=false if there is no such key.
+// Pinned key still expires by ttl and can be deleted. Whether pinned keys count toward capacity is decided by CStorageConfig.PinnedInCapacity.
+// If they count and every key is pinned, Put of new key fails unless CStorageConfig.EvictPinned is set.
+func (s *CStorage) Pin(key string) (hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, ok := s.table[key]
+	if !ok || n.ttl.Before(s.now()) {
+		return false
+	}
+
+	s.pin(n)
+	return true
+}
+
+// Unpin function is to put pinned key back under eviction policy. It returns hit=false if there is no such key.
+// If pinned keys are kept on top of capacity, other keys can be evicted to make room for it.
+func (s *CStorage) Unpin(key string) (hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, ok := s.table[key]
+	if !ok || n.ttl.Before(s.now()) {
+		return false
+	}
+	if !n.pinned {
+		return true
+	}
+
+	s.pinned.remove(n)
+	s.pinnedWeight -= n.weight
+	n.pinned = false
+	s.policy.adapt(key)
+
+	// unpinned key is not counted yet, so there should be room for it
+	s.weight -= n.weight
+	s.makeRoom(n.weight, nil)
+	s.weight += n.weight
+
+	s.policy.add(n)
+	return true
+}
+
+// PutPinned function is same as Put, but key is pinned as well. See Pin.
+// It returns ErrFull if there is no room for the key, *ValueTooLargeError if data is longer than CStorageConfig.MaxValueBytes, or ErrClosed if CStorage is closed.
+func (s *CStorage) PutPinned(key string, data []byte) (hit bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return false, ErrClosed
+	}
+	if err := s.checkValue(key, data); err != nil {
+		s.stats.Oversized++
+		return false, err
+	}
+	if _, ok := s.table[key]; ok {
+		n, _ := s.put(key, data, s.config.Ttl, s.config.Sliding)
+		if n == nil {
+			return true, ErrFull
+		}
+		s.pin(n)
+		return true, nil
+	}
+
+	weight := s.weigh(key, data)
+	if s.config.PinnedInCapacity && (weight > s.config.Capacity || !s.makeRoom(weight, nil)) {
+		s.stats.Rejected++
+		return false, ErrFull
+	}
+
+	stored, raw, err := s.encode(data)
+	if err != nil {
+		return false, err
+	}
+	n := s.insert(key, s.hash(key), stored, raw, s.config.Ttl, s.config.Sliding, weight)
+	n.pinned = true
+	s.pinned.pushHead(n)
+	s.pinnedWeight += weight
+	s.publish(EventPut, n)
+	s.logPut(n)
+	return false, nil
+}
+
+// pin moves node from eviction policy to pinned list. Caller should hold the mutex.
+func (s *CStorage) pin(n *node) {
+	if n.pinned {
+		return
+	}
+
+	s.policy.remove(n, false)
+	n.pinned = true
+	s.pinned.pushHead(n)
+	s.pinnedWeight += n.weight
+}
@@ -0,0 +1,106 @@
+package cstorage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPin(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+
+	for _, policy := range []Policy{PolicyLRU, PolicySLRU, PolicyARC, PolicySIEVE} {
+		config := CStorageConfig{Ttl: ttl, Capacity: capacity, Policy: policy}
+		cache := New(config)
+
+		cache.Put("config", []byte("blob"))
+		if !cache.Pin("config") {
+			t.Fatal("config should be pinned")
+		}
+		if cache.Pin("none") {
+			t.Error("missing key can't be pinned")
+		}
+		for i := 0; i < 100; i++ {
+			cache.Put(strconv.Itoa(i), []byte{})
+		}
+		if _, hit := cache.Get("config"); !hit {
+			t.Errorf("policy %d: pinned key should survive eviction", policy)
+		}
+		if cache.Size() != capacity+1 {
+			t.Errorf("policy %d: pinned key should be kept on top of capacity, got size %d", policy, cache.Size())
+		}
+
+		cache.Unpin("config")
+		if cache.Size() != capacity {
+			t.Errorf("policy %d: unpin should make room for the key, got size %d", policy, cache.Size())
+		}
+		for i := 0; i < 100; i++ {
+			cache.Put("x"+strconv.Itoa(i), []byte{})
+		}
+		if _, hit := cache.Peek("config"); hit {
+			t.Errorf("policy %d: unpinned key should be evicted", policy)
+		}
+	}
+}
+
+func TestPinnedInCapacity(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 2
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, PinnedInCapacity: true}
+	cache := New(config)
+
+	if _, err := cache.PutPinned("a", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	cache.Put("b", []byte("b"))
+	cache.Put("c", []byte("c"))
+	if _, hit := cache.Peek("b"); hit {
+		t.Error("b should be evicted since pinned key takes capacity")
+	}
+
+	if _, err := cache.PutPinned("c", []byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.PutPinned("d", []byte("d")); err != ErrFull {
+		t.Errorf("every key is pinned, expected ErrFull, got %v", err)
+	}
+	cache.Put("d", []byte("d"))
+	if _, hit := cache.Peek("d"); hit {
+		t.Error("d shouldn't be put since every key is pinned")
+	}
+	if st := cache.Stats(); st.Pinned != 2 || st.Rejected != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+
+	config.EvictPinned = true
+	cache = New(config)
+	cache.PutPinned("a", []byte("a"))
+	cache.PutPinned("b", []byte("b"))
+	cache.Put("c", []byte("c"))
+	if _, hit := cache.Peek("a"); hit {
+		t.Error("oldest pinned key should be evicted with EvictPinned")
+	}
+	if _, hit := cache.Peek("c"); !hit {
+		t.Error("c should be put with EvictPinned")
+	}
+}
+
+func TestPinExpiration(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.PutWithTtl("a", []byte("a"), time.Millisecond)
+	cache.Pin("a")
+	cache.PutPinned("b", []byte("b"))
+	time.Sleep(2 * time.Millisecond)
+
+	if removed := cache.RemoveExpired(); removed != 1 {
+		t.Errorf("pinned key should still expire, removed %d", removed)
+	}
+	if !cache.Delete("b") || cache.Size() != 0 || cache.Stats().Pinned != 0 {
+		t.Error("pinned key should be deleted")
+	}
+}
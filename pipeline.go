@@ -0,0 +1,103 @@
+package cstorage
+
+import "time"
+
+// Pipeline structure queues Get, Put and Delete operations and runs them together by Exec, acquiring the lock only once.
+// It is like pipeline of Redis for embedded cache; operations are run in the order they are queued, and no other operation
+// can come in between. Pipeline is not safe for concurrent use, but Pipelines of the same CStorage can be used concurrently.
+type Pipeline struct {
+	s   *CStorage
+	ops []op
+}
+
+// Result structure is result of an operation of Pipeline.
+// - Data: data of Get, nil for other operations
+// - Hit: same as hit returned by Get, Put or Delete
+type Result struct {
+	Data []byte
+	Hit  bool
+}
+
+// opKind is kind of queued operation
+type opKind uint8
+
+const (
+	opGet opKind = iota
+	opPut
+	opDelete
+)
+
+// op is queued operation of Pipeline. ttl is used by opPut, it is Ttl of CStorageConfig if zero.
+type op struct {
+	kind opKind
+	key  string
+	data []byte
+	ttl  time.Duration
+}
+
+// Pipeline function makes an empty Pipeline of CStorage.
+func (s *CStorage) Pipeline() *Pipeline {
+	return &Pipeline{s: s}
+}
+
+// Get function queues Get of key.
+func (p *Pipeline) Get(key string) *Pipeline {
+	p.ops = append(p.ops, op{kind: opGet, key: key})
+	return p
+}
+
+// Put function queues Put of key.
+func (p *Pipeline) Put(key string, data []byte) *Pipeline {
+	p.ops = append(p.ops, op{kind: opPut, key: key, data: data})
+	return p
+}
+
+// PutWithTtl function queues PutWithTtl of key.
+func (p *Pipeline) PutWithTtl(key string, data []byte, ttl time.Duration) *Pipeline {
+	p.ops = append(p.ops, op{kind: opPut, key: key, data: data, ttl: ttl})
+	return p
+}
+
+// Delete function queues Delete of key.
+func (p *Pipeline) Delete(key string) *Pipeline {
+	p.ops = append(p.ops, op{kind: opDelete, key: key})
+	return p
+}
+
+// Len function returns number of queued operations.
+func (p *Pipeline) Len() int {
+	return len(p.ops)
+}
+
+// Exec function runs queued operations under a single lock and returns their results in the order they are queued.
+// Queue is emptied, so Pipeline can be reused.
+func (p *Pipeline) Exec() []Result {
+	ops := p.ops
+	p.ops = nil
+	results := make([]Result, len(ops))
+	if len(ops) == 0 {
+		return results
+	}
+
+	s := p.s
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, o := range ops {
+		switch o.kind {
+		case opGet:
+			if n, ok := s.get(o.key); ok {
+				results[i] = Result{Data: s.value(n), Hit: true}
+			}
+		case opPut:
+			ttl := o.ttl
+			if ttl == 0 {
+				ttl = s.config.Ttl
+			}
+			_, results[i].Hit = s.put(o.key, o.data, ttl, s.config.Sliding)
+		case opDelete:
+			results[i].Hit = s.delete(o.key)
+		}
+	}
+	return results
+}
@@ -0,0 +1,53 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.Put("key1", []byte("1"))
+
+	p := cache.Pipeline().
+		Get("key1").
+		Put("key2", []byte("2")).
+		Get("key2").
+		Delete("key1").
+		Get("key1").
+		PutWithTtl("key3", []byte("3"), time.Millisecond)
+	if p.Len() != 6 {
+		t.Fatalf("6 operations should be queued, got %d", p.Len())
+	}
+
+	results := p.Exec()
+	expected := []Result{
+		{Data: []byte("1"), Hit: true},
+		{Hit: false},
+		{Data: []byte("2"), Hit: true},
+		{Hit: true},
+		{Hit: false},
+		{Hit: false},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+	for i, r := range results {
+		if string(r.Data) != string(expected[i].Data) || r.Hit != expected[i].Hit {
+			t.Errorf("result %d: expected %+v, got %+v", i, expected[i], r)
+		}
+	}
+
+	if p.Len() != 0 || len(p.Exec()) != 0 {
+		t.Error("queue should be emptied by Exec")
+	}
+
+	time.Sleep(time.Millisecond * 10)
+	if _, hit := cache.Get("key3"); hit {
+		t.Error("key3 should be expired")
+	}
+}
@@ -0,0 +1,77 @@
+package cstorage
+
+// Policy is eviction policy of CStorage, which decides the key to be evicted when storage is full.
+type Policy int
+
+const (
+	// PolicyLRU evicts least recently used key. It is the default.
+	PolicyLRU Policy = iota
+	// PolicySLRU is segmented LRU. New keys land in probation segment and are promoted to protected segment on second access.
+	// Keys in probation are evicted first, so keys accessed only once (e.g. by scan) don't push out hot keys.
+	PolicySLRU
+	// PolicyARC is Adaptive Replacement Cache. It keeps keys seen once and keys seen more than once in separate lists,
+	// remembers recently evicted keys in ghost lists, and adapts the size of two lists by ghost hits,
+	// so it follows workload which shifts between recency and frequency.
+	PolicyARC
+	// PolicySIEVE keeps keys in insertion order and only marks key as visited on access, instead of moving it.
+	// On eviction, a hand sweeps from the oldest key, giving visited keys second chance by clearing the mark.
+	// Since reads don't reorder the list, Get runs under read lock and concurrent reads don't block each other.
+	PolicySIEVE
+	// PolicySampled is approximate LRU like Redis. Each key only has time of last access instead of position in a list,
+	// and victim is the least recently accessed one among CStorageConfig.Samples randomly chosen keys.
+	// More samples get closer to exact LRU, at the cost of time of eviction.
+	PolicySampled
+)
+
+// policy is interface of eviction policies. Caller should hold the mutex of CStorage.
+// - adapt: new key is about to be put, it is called before eviction for the key
+// - add: node is newly put
+// - access: node is read or updated
+// - remove: node is removed, evicted is true if it is removed by capacity, false if by expiration or deletion
+// - victim: returns node to be evicted next, nil if empty
+// - each: calls fn in the order of eviction until fn returns false
+// - reset: forgets every nodes
+// - resize: capacity of CStorage is changed
+type policy interface {
+	adapt(key string)
+	add(n *node)
+	access(n *node)
+	remove(n *node, evicted bool)
+	victim() *node
+	each(fn func(n *node) bool)
+	reset()
+	resize(capacity int64)
+}
+
+// newPolicy makes policy by config, for nodes in ns.
+func newPolicy(config CStorageConfig, ns *nodes) policy {
+	switch config.Policy {
+	case PolicySLRU:
+		return newSLRU(config.Capacity, config.ProtectedRatio, ns)
+	case PolicyARC:
+		return newARC(config.Capacity, ns)
+	case PolicySIEVE:
+		return &sieve{list: newList(ns)}
+	case PolicySampled:
+		return newSampled(config)
+	default:
+		return &lru{list: newList(ns)}
+	}
+}
+
+// lru is policy which evicts least recently used node.
+type lru struct {
+	list list
+}
+
+func (p *lru) adapt(key string)             {}
+func (p *lru) add(n *node)                  { p.list.pushHead(n) }
+func (p *lru) access(n *node)               { p.list.setHead(n) }
+func (p *lru) remove(n *node, evicted bool) { p.list.remove(n) }
+func (p *lru) victim() *node                { return p.list.last() }
+func (p *lru) reset()                       { p.list.clear() }
+func (p *lru) resize(capacity int64)        {}
+
+func (p *lru) each(fn func(n *node) bool) {
+	p.list.each(fn)
+}
@@ -0,0 +1,99 @@
+package cstorage
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// Buffers are pooled by size class of power of two, from 1<<minBufferClass to 1<<maxBufferClass bytes. Larger buffers are left to GC.
+const (
+	minBufferClass = 6
+	maxBufferClass = 16
+)
+
+var bufferPools [maxBufferClass + 1]sync.Pool
+
+// getBuffer returns buffer of length size, whose capacity may be larger. It is taken from pool if possible.
+func getBuffer(size int) []byte {
+	class := bufferClass(size)
+	if class > maxBufferClass {
+		return make([]byte, size)
+	}
+	if b, ok := bufferPools[class].Get().([]byte); ok {
+		return b[:size]
+	}
+	return make([]byte, size, 1<<class)
+}
+
+// putBuffer gives buffer back to pool. Buffer must not be used after it.
+func putBuffer(b []byte) {
+	if cap(b) < 1<<minBufferClass {
+		return
+	}
+	// largest class which fits in capacity, so buffer taken from the class is large enough
+	class := bits.Len(uint(cap(b))) - 1
+	if class > maxBufferClass {
+		return
+	}
+	bufferPools[class].Put(b[:0]) //nolint:staticcheck // slice header is small enough
+}
+
+// bufferClass returns size class for size bytes.
+func bufferClass(size int) int {
+	if size <= 1<<minBufferClass {
+		return minBufferClass
+	}
+	return bits.Len(uint(size - 1))
+}
+
+// copyBuffer copies data into buffer from pool, nil stays nil.
+func copyBuffer(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+	b := getBuffer(len(data))
+	copy(b, data)
+	return b
+}
+
+// owned returns true if data of node is not shared with caller, so its buffer can be reused when data is replaced or node is reclaimed.
+// Only data given to put with CStorageConfig.ZeroCopy is shared, since it is stored as is. Data in arena is not a buffer of its own.
+func (s *CStorage) owned(n *node) bool {
+	return n.ref.slab == 0 && (!s.config.ZeroCopy || n.raw != 0 || s.sealer != nil)
+}
+
+// reclaim gives nodes removed by evict back to nodes for reuse, and buffers of their data to pool.
+// Removed node can still be used by caller for a while(e.g. node returned by get after it is evicted as once key), so it is called
+// only at the beginning of functions which modify CStorage, such as put and delete, when nothing holds removed node. Caller should hold the mutex.
+func (s *CStorage) reclaim() {
+	for i, n := range s.retired {
+		if s.owned(n) {
+			putBuffer(n.data)
+		}
+		s.nodes.release(n)
+		s.retired[i] = nil
+	}
+	s.retired = s.retired[:0]
+}
+
+// Borrow function is same as Get, but data is copied into buffer from pool instead of new allocation.
+// Caller should give data back by Release when it is done, and must not use data after it. Data which is not released is just left to GC.
+func (s *CStorage) Borrow(key string) (data []byte, hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, ok := s.get(key)
+	if !ok {
+		return nil, false
+	}
+	if n.raw == 0 && s.sealer == nil {
+		return copyBuffer(s.stored(n)), true
+	}
+	// decoded data is not shared with the cache, so it can be released as well
+	return s.value(n), true
+}
+
+// Release function gives data returned by Borrow back to the pool, so it is reused by later Borrow or put.
+func (s *CStorage) Release(data []byte) {
+	putBuffer(data)
+}
@@ -0,0 +1,105 @@
+package cstorage
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBorrow(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.Put("key1", []byte("1"))
+	data, hit := cache.Borrow("key1")
+	if !hit || string(data) != "1" {
+		t.Errorf("expected 1, got %q, %v", data, hit)
+	}
+	data[0] = 'X'
+	cache.Release(data)
+	if data, _ := cache.Get("key1"); string(data) != "1" {
+		t.Errorf("borrowed data should be a copy, got %q", data)
+	}
+	if _, hit := cache.Borrow("key2"); hit {
+		t.Error("key2 shouldn't be hit")
+	}
+}
+
+func TestRecycle(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+
+	for _, zeroCopy := range []bool{false, true} {
+		config := CStorageConfig{Ttl: ttl, Capacity: capacity, ZeroCopy: zeroCopy}
+		cache := New(config)
+
+		// data given to put with ZeroCopy is shared with caller, so it must not be reused by the pool
+		given := make([][]byte, 100)
+		for i := range given {
+			given[i] = bytes.Repeat([]byte(strconv.Itoa(i)), 100)
+			cache.Put(strconv.Itoa(i), given[i])
+			if i%3 == 0 {
+				cache.Delete(strconv.Itoa(i))
+			}
+		}
+		for i := 90; i < 100; i++ {
+			data, hit := cache.Get(strconv.Itoa(i))
+			if i%3 == 0 {
+				if hit {
+					t.Errorf("%d should be deleted", i)
+				}
+				continue
+			}
+			if !hit || !bytes.Equal(data, bytes.Repeat([]byte(strconv.Itoa(i)), 100)) {
+				t.Errorf("zero copy %v: %d has wrong data", zeroCopy, i)
+			}
+		}
+		for i, data := range given {
+			if !bytes.Equal(data, bytes.Repeat([]byte(strconv.Itoa(i)), 100)) {
+				t.Errorf("zero copy %v: data given to put %d is modified", zeroCopy, i)
+			}
+		}
+	}
+}
+
+// BenchmarkChurn puts new keys into full storage, so every put evicts a key whose node and buffer are reused.
+func BenchmarkChurn(b *testing.B) {
+	cache := New(CStorageConfig{Ttl: time.Hour, Capacity: 1000})
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	data := make([]byte, 256)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Put(keys[i%len(keys)], data)
+	}
+}
+
+func BenchmarkGetCopy(b *testing.B) {
+	cache := New(CStorageConfig{Ttl: time.Hour, Capacity: 1000})
+	cache.Put("key", make([]byte, 256))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Get("key")
+	}
+}
+
+func BenchmarkBorrow(b *testing.B) {
+	cache := New(CStorageConfig{Ttl: time.Hour, Capacity: 1000})
+	cache.Put("key", make([]byte, 256))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data, _ := cache.Borrow("key")
+		cache.Release(data)
+	}
+}
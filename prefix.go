@@ -0,0 +1,170 @@
+package cstorage
+
+import "strings"
+
+// DeletePrefix function deletes every keys which start with prefix, and returns number of deleted keys.
+// It scans every keys, unless CStorageConfig.PrefixIndex is set.
+func (s *CStorage) DeletePrefix(prefix string) (count int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.releaseLeases(func(key string) bool { return strings.HasPrefix(key, prefix) })
+	for _, n := range s.withPrefix(prefix) {
+		s.evict(n, false)
+		count++
+	}
+	return count
+}
+
+// DeleteMatch function deletes every keys which match glob pattern, and returns number of deleted keys.
+// In pattern, '*' matches any sequence of characters including empty one, '?' matches single character, and '\' escapes next character.
+// Unlike path.Match, '/' is not special. With CStorageConfig.PrefixIndex, only keys starting with the part before the first '*' or '?' are scanned.
+func (s *CStorage) DeleteMatch(pattern string) (count int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.releaseLeases(func(key string) bool { return match(pattern, key) })
+	for _, n := range s.withPrefix(literalPrefix(pattern)) {
+		if match(pattern, n.key) {
+			s.evict(n, false)
+			count++
+		}
+	}
+	return count
+}
+
+// withPrefix returns nodes of keys which start with prefix. Caller should hold the mutex.
+func (s *CStorage) withPrefix(prefix string) []*node {
+	var nodes []*node
+	if s.index != nil {
+		s.index.each(prefix, func(n *node) {
+			nodes = append(nodes, n)
+		})
+		return nodes
+	}
+
+	for key, n := range s.table {
+		if strings.HasPrefix(key, prefix) {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// literalPrefix returns part of pattern before the first wildcard, without escapes.
+func literalPrefix(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?':
+			return b.String()
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+		}
+		b.WriteByte(pattern[i])
+	}
+	return b.String()
+}
+
+// match reports whether key matches glob pattern. It works on bytes, so '?' matches single byte.
+func match(pattern, key string) bool {
+	// star is position of the last '*' in pattern, and retry is position in key from which the rest is matched again when '*' takes one more byte
+	star, retry := -1, 0
+	p, k := 0, 0
+	for k < len(key) {
+		if p < len(pattern) {
+			c, width := pattern[p], 1
+			switch c {
+			case '*':
+				star, retry = p, k
+				p++
+				continue
+			case '?':
+				p++
+				k++
+				continue
+			case '\\':
+				if p+1 < len(pattern) {
+					c, width = pattern[p+1], 2
+				}
+			}
+			if c == key[k] {
+				p += width
+				k++
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		retry++
+		p, k = star+1, retry
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// trie is index of keys by prefix, used if CStorageConfig.PrefixIndex is set. Caller should hold the mutex of CStorage.
+type trie struct {
+	node     *node
+	children map[byte]*trie
+}
+
+// add indexes node by its key.
+func (t *trie) add(n *node) {
+	for i := 0; i < len(n.key); i++ {
+		child, ok := t.children[n.key[i]]
+		if !ok {
+			if t.children == nil {
+				t.children = make(map[byte]*trie)
+			}
+			child = &trie{}
+			t.children[n.key[i]] = child
+		}
+		t = child
+	}
+	t.node = n
+}
+
+// remove removes key from index, and prunes branches which have no key.
+func (t *trie) remove(key string) {
+	if len(key) == 0 {
+		t.node = nil
+		return
+	}
+
+	child, ok := t.children[key[0]]
+	if !ok {
+		return
+	}
+	child.remove(key[1:])
+	if child.node == nil && len(child.children) == 0 {
+		delete(t.children, key[0])
+	}
+}
+
+// each calls fn with every nodes whose key starts with prefix.
+func (t *trie) each(prefix string, fn func(n *node)) {
+	for i := 0; i < len(prefix); i++ {
+		child, ok := t.children[prefix[i]]
+		if !ok {
+			return
+		}
+		t = child
+	}
+	t.walk(fn)
+}
+
+// walk calls fn with every nodes under t.
+func (t *trie) walk(fn func(n *node)) {
+	if t.node != nil {
+		fn(t.node)
+	}
+	for _, child := range t.children {
+		child.walk(fn)
+	}
+}
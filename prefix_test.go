@@ -0,0 +1,66 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeletePrefix(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+
+	for _, index := range []bool{false, true} {
+		config := CStorageConfig{Ttl: ttl, Capacity: capacity, PrefixIndex: index}
+		cache := New(config)
+
+		for _, key := range []string{"product:1", "product:12:a", "product:123:a", "product:123:b", "product:124:a", "user:1"} {
+			cache.Put(key, []byte{})
+		}
+
+		if count := cache.DeleteMatch("product:12?:*"); count != 3 {
+			t.Errorf("index %v: 3 keys should match, got %d", index, count)
+		}
+		if count := cache.DeletePrefix("product:"); count != 2 {
+			t.Errorf("index %v: 2 keys should be left with prefix, got %d", index, count)
+		}
+		if count := cache.DeletePrefix("product:"); count != 0 {
+			t.Errorf("index %v: no key should be left with prefix, got %d", index, count)
+		}
+		if _, hit := cache.Get("user:1"); !hit || cache.Size() != 1 {
+			t.Errorf("index %v: user:1 should be left", index)
+		}
+		if index && len(cache.index.children) != 1 {
+			t.Errorf("index of deleted keys should be pruned, got %d branches", len(cache.index.children))
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		key     string
+		match   bool
+	}{
+		{"", "", true},
+		{"*", "", true},
+		{"*", "a/b", true},
+		{"a*", "abc", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abcd", false},
+		{"*b*", "abc", true},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"a*b*c", "axbxbxc", true},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+		{`a\`, `a\`, true},
+	}
+	for _, c := range cases {
+		if got := match(c.pattern, c.key); got != c.match {
+			t.Errorf("match(%q, %q) should be %v", c.pattern, c.key, c.match)
+		}
+	}
+	if p := literalPrefix(`product:\*1*`); p != "product:*1" {
+		t.Errorf("unexpected literal prefix %q", p)
+	}
+}
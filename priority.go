@@ -0,0 +1,111 @@
+package cstorage
+
+// Priority is eviction priority of key. When storage is full, keys of lower priority are evicted first,
+// and eviction policy decides the key among keys of same priority.
+type Priority int
+
+const (
+	// PriorityLow is for keys which are cheap to recompute.
+	PriorityLow Priority = iota
+	// PriorityNormal is given to keys put by Put family functions.
+	PriorityNormal
+	// PriorityHigh is for keys which are expensive to recompute. They are evicted only when there is no other key.
+	PriorityHigh
+)
+
+// PutWithPriority function is same as Put, but key is put with given eviction priority instead of PriorityNormal.
+// Priority is kept until the key is put again, by PutWithPriority or other Put family functions.
+func (s *CStorage) PutWithPriority(key string, data []byte, priority Priority) (hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, hit := s.put(key, data, s.config.Ttl, s.config.Sliding)
+	if n != nil {
+		s.prioritize(n, priority)
+	}
+	return hit
+}
+
+// prioritize moves node to eviction class of priority. Caller should hold the mutex.
+func (s *CStorage) prioritize(n *node, priority Priority) {
+	if priority < PriorityLow || priority > PriorityHigh {
+		priority = PriorityNormal
+	}
+	if n.priority == priority {
+		return
+	}
+
+	c, ok := s.policy.(*classes)
+	if !ok {
+		c = newClasses(s.policy, s.config, s.nodes)
+		s.policy = c
+	}
+
+	if !n.pinned {
+		c.remove(n, false)
+	}
+	n.priority = priority
+	if !n.pinned {
+		c.add(n)
+	}
+}
+
+// classes is policy which has separate eviction policy for each priority, and evicts from lower priority first.
+// CStorage starts with single policy, and replaces it with classes when key of other priority than PriorityNormal is put.
+type classes struct {
+	by [PriorityHigh + 1]policy
+}
+
+// newClasses makes classes, with normal as the policy of PriorityNormal, since every nodes so far are of PriorityNormal.
+func newClasses(normal policy, config CStorageConfig, ns *nodes) *classes {
+	c := &classes{}
+	for i := range c.by {
+		c.by[i] = newPolicy(config, ns)
+	}
+	c.by[PriorityNormal] = normal
+	return c
+}
+
+func (c *classes) adapt(key string) {
+	for _, p := range c.by {
+		p.adapt(key)
+	}
+}
+
+func (c *classes) add(n *node)                  { c.by[n.priority].add(n) }
+func (c *classes) access(n *node)               { c.by[n.priority].access(n) }
+func (c *classes) remove(n *node, evicted bool) { c.by[n.priority].remove(n, evicted) }
+
+func (c *classes) victim() *node {
+	for _, p := range c.by {
+		if n := p.victim(); n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+func (c *classes) each(fn func(n *node) bool) {
+	ok := true
+	for _, p := range c.by {
+		p.each(func(n *node) bool {
+			ok = fn(n)
+			return ok
+		})
+		if !ok {
+			return
+		}
+	}
+}
+
+func (c *classes) reset() {
+	for _, p := range c.by {
+		p.reset()
+	}
+}
+
+func (c *classes) resize(capacity int64) {
+	for _, p := range c.by {
+		p.resize(capacity)
+	}
+}
@@ -0,0 +1,76 @@
+package cstorage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPriority(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+
+	for _, policy := range []Policy{PolicyLRU, PolicySLRU, PolicyARC, PolicySIEVE} {
+		config := CStorageConfig{Ttl: ttl, Capacity: capacity, Policy: policy}
+		cache := New(config)
+
+		cache.Put("normal", []byte{})
+		for i := 0; i < 3; i++ {
+			cache.PutWithPriority("high"+strconv.Itoa(i), []byte{}, PriorityHigh)
+		}
+		for i := 0; i < 100; i++ {
+			cache.PutWithPriority("low"+strconv.Itoa(i), []byte{}, PriorityLow)
+		}
+
+		for i := 0; i < 3; i++ {
+			if _, hit := cache.Peek("high" + strconv.Itoa(i)); !hit {
+				t.Errorf("policy %d: high%d should survive eviction of low keys", policy, i)
+			}
+		}
+		if _, hit := cache.Peek("normal"); !hit {
+			t.Errorf("policy %d: normal key should survive eviction of low keys", policy)
+		}
+		if cache.Size() != capacity {
+			t.Errorf("policy %d: size should be %d, got %d", policy, capacity, cache.Size())
+		}
+
+		for i := 0; i < 100; i++ {
+			cache.Put("x"+strconv.Itoa(i), []byte{})
+		}
+		if _, hit := cache.Peek("normal"); hit {
+			t.Errorf("policy %d: normal key should be evicted among normal keys", policy)
+		}
+		for i := 0; i < 3; i++ {
+			if _, hit := cache.Peek("high" + strconv.Itoa(i)); !hit {
+				t.Errorf("policy %d: high%d should survive eviction of normal keys", policy, i)
+			}
+		}
+
+		var count int
+		cache.IterateLRU(func(key string, data []byte, expiresAt time.Time) bool {
+			count++
+			return true
+		})
+		if count != int(capacity) {
+			t.Errorf("policy %d: iteration should visit every keys, got %d", policy, count)
+		}
+	}
+}
+
+func TestPriorityReset(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 2
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.PutWithPriority("a", []byte("a"), PriorityHigh)
+	cache.Put("b", []byte("b"))
+	cache.Put("a", []byte("a"))
+	cache.Put("c", []byte("c"))
+	if _, hit := cache.Peek("b"); hit {
+		t.Error("a is put again with normal priority, so b should be evicted as least recently used")
+	}
+	if _, hit := cache.Peek("a"); !hit {
+		t.Error("a should be in storage")
+	}
+}
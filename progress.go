@@ -0,0 +1,88 @@
+package cstorage
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCanceled is returned by long running operations when ProgressFunc returns false.
+var ErrCanceled = errors.New("cstorage: canceled")
+
+// progressBatch is number of keys processed between calls of ProgressFunc. Functions which take ProgressFunc release the lock
+// between batches where possible, so other operations are not blocked during whole of long running operation.
+const progressBatch = 1024
+
+// ProgressFunc is callback of long running operations, such as RemoveExpiredWithProgress. done is number of keys processed so far,
+// and total is number of keys to be processed, which is 0 if it is not known in advance. Returning false cancels the operation,
+// and keys processed so far are not rolled back. It is called without holding the lock, so it can call functions of CStorage.
+type ProgressFunc func(done, total int64) (proceed bool)
+
+// RemoveExpiredWithProgress function is same as RemoveExpired, but expired keys are removed in batches, releasing the lock between them,
+// and progress is called after each batch. total is not known, since expired keys are found while they are removed.
+func (s *CStorage) RemoveExpiredWithProgress(progress ProgressFunc) (count int64) {
+	for {
+		removed := s.removeExpired(progressBatch)
+		count += removed
+		if removed < progressBatch {
+			progress(count, 0)
+			return count
+		}
+		if !progress(count, 0) {
+			return count
+		}
+	}
+}
+
+// removeExpired removes at most limit expired keys under single lock.
+func (s *CStorage) removeExpired(limit int64) (count int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.reclaim()
+	now := s.now()
+	for n := s.expired(now, nil); n != nil && count < limit; n = s.expired(now, nil) {
+		s.evict(n, false)
+		count++
+	}
+	s.stats.Expired += count
+	s.logSwept(count)
+	return count
+}
+
+// DeleteFuncWithProgress function is same as DeleteFunc, but keys are checked in batches, releasing the lock between them,
+// and progress is called after each batch with total number of keys when it started.
+// Unlike DeleteFunc, other operations can come in between batches; keys put after it started are not checked.
+func (s *CStorage) DeleteFuncWithProgress(fn func(key string, data []byte, expiresAt time.Time) bool, progress ProgressFunc) (count int) {
+	s.mutex.RLock()
+	nodes := make([]*node, 0, len(s.table))
+	s.each(func(n *node) bool {
+		nodes = append(nodes, n)
+		return true
+	})
+	s.mutex.RUnlock()
+
+	total := int64(len(nodes))
+	for start := 0; start < len(nodes); start += progressBatch {
+		end := start + progressBatch
+		if end > len(nodes) {
+			end = len(nodes)
+		}
+
+		s.mutex.Lock()
+		for _, n := range nodes[start:end] {
+			if s.table[n.key] == n && fn(n.key, s.value(n), n.ttl) {
+				s.evict(n, false)
+				count++
+			}
+		}
+		s.mutex.Unlock()
+
+		if !progress(int64(end), total) {
+			return count
+		}
+	}
+	if total == 0 {
+		progress(0, 0)
+	}
+	return count
+}
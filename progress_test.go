@@ -0,0 +1,101 @@
+package cstorage
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+func TestRemoveExpiredWithProgress(t *testing.T) {
+	ttl := time.Duration(time.Minute)
+	var capacity int64 = 3000
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock}
+	cache := New(config)
+
+	for i := 0; i < 2500; i++ {
+		cache.Put(strconv.Itoa(i), []byte("v"))
+	}
+	clock.Advance(time.Hour)
+
+	var calls []int64
+	removed := cache.RemoveExpiredWithProgress(func(done, total int64) bool {
+		calls = append(calls, done)
+		return true
+	})
+	if removed != 2500 || cache.Size() != 0 {
+		t.Errorf("every key should be removed, got %d and size %d", removed, cache.Size())
+	}
+	if len(calls) != 3 || calls[0] != 1024 || calls[2] != 2500 {
+		t.Errorf("progress should be called after each batch, got %v", calls)
+	}
+
+	for i := 0; i < 2500; i++ {
+		cache.Put(strconv.Itoa(i), []byte("v"))
+	}
+	clock.Advance(time.Hour)
+	removed = cache.RemoveExpiredWithProgress(func(done, total int64) bool {
+		return false
+	})
+	if removed != 1024 || cache.Size() != 2500-1024 {
+		t.Errorf("it should stop after first batch, got %d and size %d", removed, cache.Size())
+	}
+}
+
+func TestDeleteFuncWithProgress(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 3000
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	for i := 0; i < 2000; i++ {
+		cache.Put(strconv.Itoa(i), []byte(strconv.Itoa(i%2)))
+	}
+
+	var last, total int64
+	count := cache.DeleteFuncWithProgress(func(key string, data []byte, expiresAt time.Time) bool {
+		return string(data) == "0"
+	}, func(done, all int64) bool {
+		last, total = done, all
+		cache.Put("during", []byte("0"))
+		return true
+	})
+	if count != 1000 || last != 2000 || total != 2000 {
+		t.Errorf("1000 keys should be deleted with progress of 2000, got %d, %d/%d", count, last, total)
+	}
+	if _, hit := cache.Peek("during"); !hit {
+		t.Errorf("key put after it started should not be checked")
+	}
+}
+
+func TestSnapshotWithProgress(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 3000
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	for i := 0; i < 2000; i++ {
+		cache.Put(strconv.Itoa(i), []byte("v"))
+	}
+
+	var buf bytes.Buffer
+	var calls int
+	if _, err := cache.WriteSnapshotWithProgress(&buf, func(done, total int64) bool {
+		calls++
+		return total == 2000
+	}); err != nil || calls != 2 {
+		t.Fatalf("progress should be called twice, got %d, %v", calls, err)
+	}
+
+	restored := New(config)
+	count, err := restored.ReadSnapshotWithProgress(bytes.NewReader(buf.Bytes()), func(done, total int64) bool {
+		return false
+	})
+	if !errors.Is(err, ErrCanceled) || count != 1024 {
+		t.Errorf("read should be canceled after first batch, got %d, %v", count, err)
+	}
+}
@@ -0,0 +1,119 @@
+// Package prometheus provides Collector, which implements prometheus.Collector of client_golang for metrics of CStorage,
+// so they can be registered in existing Registry and served by promhttp with other metrics of the process.
+// It is separate module, since it depends on client_golang.
+package prometheus
+
+import (
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultBuckets are upper bounds of latency histogram in seconds.
+var DefaultBuckets = []float64{0.000001, 0.000005, 0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.01}
+
+// Collector structure wraps CStorage to publish its metrics. It implements prometheus.Collector, and metrics are read from Stats on each scrape.
+// Get, Put and Delete of Collector call the same function of CStorage and record its latency.
+// Calls made directly to CStorage are still counted in hit, size and eviction metrics, but not in latency histogram.
+type Collector struct {
+	cache     *cstorage.CStorage
+	metrics   []metric
+	evictions *prometheus.Desc
+	latencies *prometheus.HistogramVec
+}
+
+// metric is a metric which is read from Stats.
+type metric struct {
+	desc  *prometheus.Desc
+	typ   prometheus.ValueType
+	value func(st cstorage.Stats) float64
+}
+
+// NewCollector function returns Collector of cache. Metric names are prefixed with namespace, "cstorage" if empty.
+func NewCollector(cache *cstorage.CStorage, namespace string) *Collector {
+	if namespace == "" {
+		namespace = "cstorage"
+	}
+	c := &Collector{
+		cache:     cache,
+		evictions: prometheus.NewDesc(namespace+"_evictions_total", "Number of keys removed, by reason.", []string{"reason"}, nil),
+		latencies: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of cache operations.",
+			Buckets:   DefaultBuckets,
+		}, []string{"op"}),
+	}
+
+	add := func(name string, typ prometheus.ValueType, help string, value func(st cstorage.Stats) float64) {
+		c.metrics = append(c.metrics, metric{desc: prometheus.NewDesc(namespace+"_"+name, help, nil, nil), typ: typ, value: value})
+	}
+	counter, gauge := prometheus.CounterValue, prometheus.GaugeValue
+	add("hits_total", counter, "Number of cache hits.", func(st cstorage.Stats) float64 { return float64(st.Hits) })
+	add("misses_total", counter, "Number of cache misses.", func(st cstorage.Stats) float64 { return float64(st.Misses) })
+	add("hit_ratio", gauge, "Ratio of hits among gets.", func(st cstorage.Stats) float64 { return st.HitRatio() })
+	add("size", gauge, "Number of keys in cache.", func(st cstorage.Stats) float64 { return float64(st.Size) })
+	add("capacity", gauge, "Maximum number of keys in cache.", func(st cstorage.Stats) float64 { return float64(st.Capacity) })
+	add("pinned", gauge, "Number of pinned keys in cache.", func(st cstorage.Stats) float64 { return float64(st.Pinned) })
+	add("cardinality", gauge, "Estimated number of distinct keys ever written.", func(st cstorage.Stats) float64 { return float64(st.Cardinality) })
+	add("memory_bytes", gauge, "Estimated bytes held by keys in cache.", func(st cstorage.Stats) float64 { return float64(st.MemoryUsage) })
+	add("compression_saved_bytes", gauge, "Bytes saved by compression of data in cache.", func(st cstorage.Stats) float64 { return float64(st.CompressionSaved) })
+	add("oversized_total", counter, "Number of puts refused since value was too large.", func(st cstorage.Stats) float64 { return float64(st.Oversized) })
+	add("dropped_accesses_total", counter, "Number of Get hits which were not applied to eviction order since buffer was full.", func(st cstorage.Stats) float64 { return float64(st.DroppedAccesses) })
+	add("dropped_events_total", counter, "Number of events which were not sent since subscriber was behind.", func(st cstorage.Stats) float64 { return float64(st.DroppedEvents) })
+	add("corrupted_total", counter, "Number of keys removed since data didn't match its checksum.", func(st cstorage.Stats) float64 { return float64(st.Corrupted) })
+	add("refreshed_total", counter, "Number of keys loaded again before they expire.", func(st cstorage.Stats) float64 { return float64(st.Refreshed) })
+	add("refresh_failed_total", counter, "Number of failed loads of keys before they expire.", func(st cstorage.Stats) float64 { return float64(st.RefreshFailed) })
+	add("expired_total", counter, "Number of keys removed due to ttl.", func(st cstorage.Stats) float64 { return float64(st.Expired) })
+	return c
+}
+
+// Get calls Get of CStorage and records latency.
+func (c *Collector) Get(key string) (data []byte, hit bool) {
+	start := time.Now()
+	data, hit = c.cache.Get(key)
+	c.Observe("get", time.Since(start))
+	return data, hit
+}
+
+// Put calls Put of CStorage and records latency.
+func (c *Collector) Put(key string, data []byte) (hit bool) {
+	start := time.Now()
+	hit = c.cache.Put(key, data)
+	c.Observe("put", time.Since(start))
+	return hit
+}
+
+// Delete calls Delete of CStorage and records latency.
+func (c *Collector) Delete(key string) (hit bool) {
+	start := time.Now()
+	hit = c.cache.Delete(key)
+	c.Observe("delete", time.Since(start))
+	return hit
+}
+
+// Observe function records latency of operation which is done outside of Collector, e.g. batch operations.
+func (c *Collector) Observe(op string, d time.Duration) {
+	c.latencies.WithLabelValues(op).Observe(d.Seconds())
+}
+
+// Describe function sends descriptors of every metrics of Collector, as prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.metrics {
+		ch <- m.desc
+	}
+	ch <- c.evictions
+	c.latencies.Describe(ch)
+}
+
+// Collect function sends current metrics of CStorage, as prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	st := c.cache.Stats()
+	for _, m := range c.metrics {
+		ch <- prometheus.MustNewConstMetric(m.desc, m.typ, m.value(st))
+	}
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(st.Evicted), "capacity")
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(st.Expired), "expired")
+	c.latencies.Collect(ch)
+}
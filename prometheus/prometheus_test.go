@@ -0,0 +1,70 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestCollector(t *testing.T) {
+	cache := cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 1})
+	c := NewCollector(cache, "")
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(c); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Put("key1", []byte("1"))
+	c.Put("key2", []byte("2"))
+	c.Get("key1")
+	c.Get("key2")
+	c.Delete("key2")
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]*dto.MetricFamily)
+	for _, f := range families {
+		got[f.GetName()] = f
+	}
+	value := func(name string) float64 {
+		f, ok := got[name]
+		if !ok {
+			t.Errorf("%s should be collected", name)
+			return -1
+		}
+		m := f.GetMetric()[0]
+		if m.GetCounter() != nil {
+			return m.GetCounter().GetValue()
+		}
+		return m.GetGauge().GetValue()
+	}
+	for name, want := range map[string]float64{
+		"cstorage_hits_total":   1,
+		"cstorage_misses_total": 1,
+		"cstorage_hit_ratio":    0.5,
+		"cstorage_size":         0,
+		"cstorage_capacity":     1,
+	} {
+		if v := value(name); v != want {
+			t.Errorf("%s should be %v, got %v", name, want, v)
+		}
+	}
+
+	for _, m := range got["cstorage_evictions_total"].GetMetric() {
+		if m.GetLabel()[0].GetValue() == "capacity" && m.GetCounter().GetValue() != 1 {
+			t.Errorf("1 key should be evicted by capacity, got %v", m.GetCounter().GetValue())
+		}
+	}
+	counts := make(map[string]uint64)
+	for _, m := range got["cstorage_operation_duration_seconds"].GetMetric() {
+		counts[m.GetLabel()[0].GetValue()] = m.GetHistogram().GetSampleCount()
+	}
+	if counts["get"] != 2 || counts["put"] != 2 || counts["delete"] != 1 {
+		t.Errorf("latency of each operation should be observed, got %v", counts)
+	}
+}
@@ -0,0 +1,24 @@
+package cstorage
+
+import "time"
+
+// DeleteFunc function deletes every keys for which fn returns true, and returns number of deleted keys.
+// Keys are checked and deleted under a single lock, so no other operation can come in between. Expired keys are checked as well.
+// fn is called while holding the lock, so it must not call functions of CStorage, and data must not be modified or retained.
+func (s *CStorage) DeleteFunc(fn func(key string, data []byte, expiresAt time.Time) bool) (count int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var matched []*node
+	s.each(func(n *node) bool {
+		if fn(n.key, s.value(n), n.ttl) {
+			matched = append(matched, n)
+		}
+		return true
+	})
+	for _, n := range matched {
+		s.evict(n, false)
+		count++
+	}
+	return count
+}
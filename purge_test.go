@@ -0,0 +1,35 @@
+package cstorage
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDeleteFunc(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	for i := 0; i < 6; i++ {
+		cache.Put(strconv.Itoa(i), []byte("v"+strconv.Itoa(i%2+1)+":payload"))
+	}
+	cache.PutPinned("pinned", []byte("v1:payload"))
+
+	count := cache.DeleteFunc(func(key string, data []byte, expiresAt time.Time) bool {
+		return bytes.HasPrefix(data, []byte("v1:"))
+	})
+	if count != 4 {
+		t.Errorf("3 keys and pinned key of v1 should be deleted, got %d", count)
+	}
+	if cache.Size() != 3 {
+		t.Errorf("keys of v2 should be left, got size %d", cache.Size())
+	}
+	for i := 1; i < 6; i += 2 {
+		if _, hit := cache.Get(strconv.Itoa(i)); !hit {
+			t.Errorf("%d should be left", i)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package cstorage
+
+import (
+	"context"
+	"sync"
+)
+
+// Quiesce function waits for in-flight operations to be done, and blocks new operations until release is called,
+// so files written by CStorage(e.g. SnapshotPath) don't change while external agent takes backup of them.
+// Reads are blocked as well, since Get updates eviction order. release should be called as soon as backup is done, and calling it more than once is safe.
+// If in-flight operations are not done before ctx is done, it returns error of ctx and CStorage is not blocked.
+func (s *CStorage) Quiesce(ctx context.Context) (release func(), err error) {
+	locked := make(chan struct{})
+	go func() {
+		s.mutex.Lock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+	case <-ctx.Done():
+		go func() {
+			<-locked
+			s.mutex.Unlock()
+		}()
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	return func() { once.Do(s.mutex.Unlock) }, nil
+}
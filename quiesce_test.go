@@ -0,0 +1,64 @@
+package cstorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQuiesce(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	release, err := cache.Quiesce(context.Background())
+	if err != nil {
+		t.Fatalf("quiesce should succeed, got %v", err)
+	}
+
+	put := make(chan struct{})
+	go func() {
+		cache.Put("key", []byte("value"))
+		close(put)
+	}()
+	select {
+	case <-put:
+		t.Fatalf("put should be blocked while quiesced")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	release()
+	release()
+	<-put
+	if _, hit := cache.Get("key"); !hit {
+		t.Errorf("put should be done after release")
+	}
+}
+
+func TestQuiesceTimeout(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.mutex.RLock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := cache.Quiesce(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("quiesce should time out while operation is in flight, got %v", err)
+	}
+	cache.mutex.RUnlock()
+
+	done := make(chan struct{})
+	go func() {
+		cache.Put("key", []byte("value"))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("cache should not be blocked after quiesce timed out")
+	}
+}
@@ -0,0 +1,266 @@
+// Package readonly serves Gets from immutable snapshot files which are memory-mapped, for large datasets which are published
+// periodically(e.g. by batch job) and never modified by the cache. Data of snapshot stays in page cache instead of heap.
+//
+// Snapshot file is made by Write or WriteFile, and opened by Open. Store holds current Snapshot and swaps it atomically.
+package readonly
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// File layout, every integer is little endian
+// - header: magic "CSRO", version(4), number of keys(8)
+// - index: for each key in sorted order, offset of record(8), key length(4), data length(4)
+// - records: key followed by data
+const (
+	magic      = "CSRO"
+	version    = 1
+	headerSize = 16
+	indexSize  = 16
+)
+
+// ErrFormat is returned by Open when the file is not a snapshot or it is broken.
+var ErrFormat = errors.New("readonly: invalid snapshot file")
+
+// ErrClosed is returned by Store when it is closed.
+var ErrClosed = errors.New("readonly: store is closed")
+
+// Write function writes snapshot of entries to w.
+func Write(w io.Writer, entries map[string][]byte) error {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf := make([]byte, headerSize, headerSize+len(keys)*indexSize)
+	copy(buf, magic)
+	binary.LittleEndian.PutUint32(buf[4:], version)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(len(keys)))
+
+	offset := uint64(headerSize + len(keys)*indexSize)
+	var entry [indexSize]byte
+	for _, key := range keys {
+		binary.LittleEndian.PutUint64(entry[0:], offset)
+		binary.LittleEndian.PutUint32(entry[8:], uint32(len(key)))
+		binary.LittleEndian.PutUint32(entry[12:], uint32(len(entries[key])))
+		buf = append(buf, entry[:]...)
+		offset += uint64(len(key) + len(entries[key]))
+	}
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if _, err := io.WriteString(w, key); err != nil {
+			return err
+		}
+		if _, err := w.Write(entries[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFile function writes snapshot of entries to path. It writes to temporary file and renames it,
+// so the file at path is always complete snapshot, even for process which opens it while writing.
+func WriteFile(path string, entries map[string][]byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := Write(f, entries); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Snapshot structure is opened snapshot file. It is safe for concurrent use.
+type Snapshot struct {
+	data  []byte
+	count int
+	unmap func() error
+	refs  int64
+}
+
+// Open function maps snapshot file at path into memory. Where memory-mapping is not supported, the file is read into memory instead.
+func Open(path string) (*Snapshot, error) {
+	data, unmap, err := mmap(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{data: data, unmap: unmap, refs: 1}
+	if err := s.validate(); err != nil {
+		unmap()
+		return nil, err
+	}
+	return s, nil
+}
+
+// validate checks header and index of the snapshot.
+func (s *Snapshot) validate() error {
+	if len(s.data) < headerSize || string(s.data[:4]) != magic || binary.LittleEndian.Uint32(s.data[4:]) != version {
+		return ErrFormat
+	}
+	count := binary.LittleEndian.Uint64(s.data[8:])
+	if count > uint64(len(s.data)-headerSize)/indexSize {
+		return ErrFormat
+	}
+	s.count = int(count)
+
+	for i := 0; i < s.count; i++ {
+		offset, keyLen, dataLen := s.entry(i)
+		if offset+keyLen+dataLen > uint64(len(s.data)) {
+			return ErrFormat
+		}
+	}
+	return nil
+}
+
+// entry returns index entry of i-th key.
+func (s *Snapshot) entry(i int) (offset, keyLen, dataLen uint64) {
+	e := s.data[headerSize+i*indexSize:]
+	return binary.LittleEndian.Uint64(e), uint64(binary.LittleEndian.Uint32(e[8:])), uint64(binary.LittleEndian.Uint32(e[12:]))
+}
+
+// Get function returns data of key. Returned data refers to the mapped file, so it must not be modified,
+// and it must not be used after Close of the Snapshot.
+func (s *Snapshot) Get(key string) (data []byte, hit bool) {
+	k := []byte(key)
+	i := sort.Search(s.count, func(i int) bool {
+		offset, keyLen, _ := s.entry(i)
+		return bytes.Compare(s.data[offset:offset+keyLen], k) >= 0
+	})
+	if i == s.count {
+		return nil, false
+	}
+
+	offset, keyLen, dataLen := s.entry(i)
+	if !bytes.Equal(s.data[offset:offset+keyLen], k) {
+		return nil, false
+	}
+	start := offset + keyLen
+	return s.data[start : start+dataLen : start+dataLen], true
+}
+
+// Len function returns number of keys in the snapshot.
+func (s *Snapshot) Len() int {
+	return s.count
+}
+
+// Close function unmaps the snapshot. If it is held by Store, it is unmapped when the last reader releases it.
+func (s *Snapshot) Close() error {
+	return s.release()
+}
+
+// acquire adds reference of the snapshot. It returns false if the snapshot is already unmapped.
+func (s *Snapshot) acquire() bool {
+	for {
+		refs := atomic.LoadInt64(&s.refs)
+		if refs == 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&s.refs, refs, refs+1) {
+			return true
+		}
+	}
+}
+
+// release removes reference of the snapshot, and unmaps it when there is no reference.
+func (s *Snapshot) release() error {
+	if atomic.AddInt64(&s.refs, -1) == 0 {
+		return s.unmap()
+	}
+	return nil
+}
+
+// Store structure holds current Snapshot and swaps it to new one atomically, while Gets on old one can finish safely.
+// Zero value is empty Store, which misses every key.
+type Store struct {
+	mutex   sync.RWMutex
+	current *Snapshot
+	closed  bool
+}
+
+// Swap function opens snapshot at path and makes it current. Previous snapshot is unmapped once readers of it are done.
+func (st *Store) Swap(path string) error {
+	s, err := Open(path)
+	if err != nil {
+		return err
+	}
+
+	st.mutex.Lock()
+	if st.closed {
+		st.mutex.Unlock()
+		s.Close()
+		return ErrClosed
+	}
+	old := st.current
+	st.current = s
+	st.mutex.Unlock()
+
+	if old != nil {
+		return old.release()
+	}
+	return nil
+}
+
+// View function calls fn with current snapshot, which is valid until fn returns. Data returned by Get of the snapshot
+// must not be used after fn returns. It returns false without calling fn if Store has no snapshot.
+func (st *Store) View(fn func(s *Snapshot)) bool {
+	st.mutex.RLock()
+	s := st.current
+	if s == nil || !s.acquire() {
+		st.mutex.RUnlock()
+		return false
+	}
+	st.mutex.RUnlock()
+
+	defer s.release()
+	fn(s)
+	return true
+}
+
+// Get function returns copy of data of key in current snapshot. Use View to read data without copy.
+func (st *Store) Get(key string) (data []byte, hit bool) {
+	st.View(func(s *Snapshot) {
+		var d []byte
+		if d, hit = s.Get(key); hit {
+			data = append([]byte(nil), d...)
+		}
+	})
+	return data, hit
+}
+
+// Close function releases current snapshot. Swap fails after Close.
+func (st *Store) Close() error {
+	st.mutex.Lock()
+	old := st.current
+	st.current = nil
+	st.closed = true
+	st.mutex.Unlock()
+
+	if old != nil {
+		return old.release()
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package readonly
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.snap")
+	entries := map[string][]byte{"a": []byte("1"), "b": []byte("22"), "c": nil}
+	for i := 0; i < 100; i++ {
+		entries["key"+strconv.Itoa(i)] = []byte(strconv.Itoa(i))
+	}
+	if err := WriteFile(path, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if s.Len() != len(entries) {
+		t.Errorf("expected %d keys, got %d", len(entries), s.Len())
+	}
+	for key, data := range entries {
+		got, hit := s.Get(key)
+		if !hit || string(got) != string(data) {
+			t.Errorf("%s: expected %q, got %q, %v", key, data, got, hit)
+		}
+	}
+	for _, key := range []string{"", "0", "aa", "key100", "z"} {
+		if _, hit := s.Get(key); hit {
+			t.Errorf("%q shouldn't be hit", key)
+		}
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.snap")
+	if err := WriteFile(path, map[string][]byte{"key": []byte("data")}); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(path)
+	os.WriteFile(path, raw[:len(raw)-1], 0o644)
+
+	if _, err := Open(path); err != ErrFormat {
+		t.Errorf("truncated file should be ErrFormat, got %v", err)
+	}
+}
+
+func TestStoreSwap(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "1.snap")
+	second := filepath.Join(dir, "2.snap")
+	WriteFile(first, map[string][]byte{"key": []byte("first")})
+	WriteFile(second, map[string][]byte{"key": []byte("second")})
+
+	var st Store
+	if _, hit := st.Get("key"); hit {
+		t.Error("empty store shouldn't hit")
+	}
+	if err := st.Swap(first); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				st.View(func(s *Snapshot) {
+					data, _ := s.Get("key")
+					if string(data) != "first" && string(data) != "second" {
+						t.Errorf("unexpected data %q", data)
+					}
+				})
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		if err := st.Swap([]string{first, second}[i%2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	if data, _ := st.Get("key"); string(data) != "second" {
+		t.Errorf("expected second, got %q", data)
+	}
+	st.Close()
+	if err := st.Swap(first); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
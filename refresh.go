@@ -0,0 +1,61 @@
+package cstorage
+
+import "time"
+
+// defaultRefreshAhead is ratio of ttl after which key is refreshed, if CStorageConfig.RefreshAhead is not set.
+const defaultRefreshAhead = 0.8
+
+// refreshDue returns true if key of n should be refreshed by CStorageConfig.Refresh. Sliding keys are not refreshed, since Get renews them.
+// Caller should hold the mutex, read lock is enough.
+func (s *CStorage) refreshDue(n *node, now time.Time) bool {
+	if s.config.Refresh == nil || n.sliding || n.once || n.lifetime <= 0 {
+		return false
+	}
+	if _, ok := s.refreshing[n.key]; ok {
+		return false
+	}
+
+	ahead := s.config.RefreshAhead
+	if ahead <= 0 || ahead >= 1 {
+		ahead = defaultRefreshAhead
+	}
+	remaining := time.Duration(float64(n.lifetime) * (1 - ahead))
+	return !now.Before(n.ttl.Add(-remaining))
+}
+
+// refresh loads key of n again by CStorageConfig.Refresh in background, and puts it with the same lifetime.
+// If key is put or removed while it is loaded, loaded data is discarded, since it can be older than them. Caller should hold the mutex.
+func (s *CStorage) refresh(n *node) {
+	if s.refreshing == nil {
+		s.refreshing = make(map[string]uint64)
+	}
+	s.refreshes++
+	key, lifetime, id := n.key, n.lifetime, s.refreshes
+	s.refreshing[key] = id
+
+	s.spawn(func(done <-chan struct{}) {
+		data, err := s.config.Refresh(key)
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		if s.refreshing[key] != id {
+			return
+		}
+		delete(s.refreshing, key)
+		if err != nil {
+			s.stats.RefreshFailed++
+			return
+		}
+		if n, _ := s.put(key, data, lifetime, false); n != nil {
+			s.stats.Refreshed++
+		}
+	})
+}
+
+// cancelRefresh makes loaded data of key in progress to be discarded. Caller should hold the mutex.
+func (s *CStorage) cancelRefresh(key string) {
+	if s.refreshing != nil {
+		delete(s.refreshing, key)
+	}
+}
@@ -0,0 +1,81 @@
+package cstorage
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+func TestRefresh(t *testing.T) {
+	ttl := time.Duration(time.Minute)
+	var capacity int64 = 10
+	var loads int32
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock, Refresh: func(key string) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		return []byte("fresh"), nil
+	}}
+	cache := New(config)
+	defer cache.Close()
+
+	cache.Put("key", []byte("old"))
+	clock.Advance(30 * time.Second)
+	cache.Get("key")
+	if atomic.LoadInt32(&loads) != 0 {
+		t.Fatalf("key should not be refreshed before 80%% of ttl")
+	}
+
+	clock.Advance(20 * time.Second)
+	if data, hit := cache.Get("key"); !hit || string(data) != "old" {
+		t.Errorf("current data should be returned while it is refreshed, got %s", data)
+	}
+	cache.workers.Wait()
+
+	if data, _ := cache.Peek("key"); string(data) != "fresh" {
+		t.Errorf("key should be refreshed, got %s", data)
+	}
+	if remaining, _ := cache.Ttl("key"); remaining != ttl {
+		t.Errorf("refreshed key should have full ttl, got %v", remaining)
+	}
+	if stats := cache.Stats(); stats.Refreshed != 1 || loads != 1 {
+		t.Errorf("key should be refreshed once, got %d of %d loads", stats.Refreshed, loads)
+	}
+}
+
+func TestRefreshDiscarded(t *testing.T) {
+	ttl := time.Duration(time.Minute)
+	var capacity int64 = 10
+	release := make(chan struct{})
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock, RefreshAhead: 0.5, Refresh: func(key string) ([]byte, error) {
+		<-release
+		if key == "failing" {
+			return nil, errors.New("origin is down")
+		}
+		return []byte("loaded"), nil
+	}}
+	cache := New(config)
+	defer cache.Close()
+
+	cache.Put("key", []byte("old"))
+	cache.Put("failing", []byte("old"))
+	clock.Advance(31 * time.Second)
+	cache.Get("key")
+	cache.Get("failing")
+	cache.Put("key", []byte("newer"))
+	close(release)
+	cache.workers.Wait()
+
+	if data, _ := cache.Peek("key"); string(data) != "newer" {
+		t.Errorf("loaded data should be discarded when key is put meanwhile, got %s", data)
+	}
+	if data, _ := cache.Peek("failing"); string(data) != "old" {
+		t.Errorf("failed refresh should keep current data, got %s", data)
+	}
+	if stats := cache.Stats(); stats.Refreshed != 0 || stats.RefreshFailed != 1 {
+		t.Errorf("expected 0 refreshed and 1 failed, got %d and %d", stats.Refreshed, stats.RefreshFailed)
+	}
+}
@@ -0,0 +1,101 @@
+package replicated
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/hashicorp/raft"
+)
+
+// op is kind of command.
+type op string
+
+const (
+	opPut    op = "put"
+	opDelete op = "delete"
+	opClear  op = "clear"
+)
+
+// command is entry of raft log. ExpiresAt is absolute time in unix nanoseconds, so every node and replay of the log
+// agree on expiration. If it is 0, ttl of CStorageConfig is used.
+type command struct {
+	Op        op     `json:"op"`
+	Key       string `json:"key,omitempty"`
+	Data      []byte `json:"data,omitempty"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+}
+
+// fsm is raft.FSM applying commands to CStorage. applied is index of the last applied log.
+type fsm struct {
+	cache   *cstorage.CStorage
+	applied uint64
+}
+
+var _ raft.FSM = (*fsm)(nil)
+
+// Apply applies command of log. Returned error is response of the log.
+func (f *fsm) Apply(log *raft.Log) interface{} {
+	defer atomic.StoreUint64(&f.applied, log.Index)
+
+	var cmd command
+	if err := json.Unmarshal(log.Data, &cmd); err != nil {
+		return fmt.Errorf("replicated: invalid command at %d: %w", log.Index, err)
+	}
+	switch cmd.Op {
+	case opPut:
+		if cmd.ExpiresAt == 0 {
+			f.cache.Put(cmd.Key, cmd.Data)
+			return nil
+		}
+		// replayed put which has already expired removes older data of key, same as it would have expired
+		ttl := time.Until(time.Unix(0, cmd.ExpiresAt))
+		if ttl <= 0 {
+			f.cache.Delete(cmd.Key)
+			return nil
+		}
+		f.cache.PutWithTtl(cmd.Key, cmd.Data, ttl)
+	case opDelete:
+		f.cache.Delete(cmd.Key)
+	case opClear:
+		f.cache.Clear()
+	default:
+		return fmt.Errorf("replicated: unknown command %q at %d", cmd.Op, log.Index)
+	}
+	return nil
+}
+
+// Snapshot writes CStorage into memory, since raft applies later logs while snapshot is persisted.
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	var buf bytes.Buffer
+	if _, err := f.cache.WriteSnapshot(&buf); err != nil {
+		return nil, err
+	}
+	return &snapshot{data: buf.Bytes()}, nil
+}
+
+// Restore replaces every key of CStorage with snapshot.
+func (f *fsm) Restore(r io.ReadCloser) error {
+	defer r.Close()
+	f.cache.Clear()
+	_, err := f.cache.ReadSnapshot(r)
+	return err
+}
+
+type snapshot struct {
+	data []byte
+}
+
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	if _, err := sink.Write(s.data); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *snapshot) Release() {}
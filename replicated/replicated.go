@@ -0,0 +1,274 @@
+// Package replicated provides Cache, which replicates writes of CStorage by raft, so every node has same keys and reads are linearizable.
+// It is for caching coordination data, where a stale read is worse than a slower write. Writes go through the log of the leader,
+// and followers forward them to the leader over HTTP. Raft snapshots are snapshots of CStorage, in the format of WriteSnapshot.
+//
+// It is a separate module, since it depends on github.com/hashicorp/raft which users of the core module shouldn't pay for.
+package replicated
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/hashicorp/raft"
+)
+
+// ErrNotLeader is returned when this node is not the leader and the call can't be forwarded to the leader,
+// because there is no leader now or Options.LeaderURL is not set.
+var ErrNotLeader = errors.New("replicated: not the leader")
+
+// Options structure is configuration of Cache. Config, Transport and stores are passed to raft.NewRaft as they are.
+// - Config: raft configuration, LocalID is required
+// - Transport, Logs, Stable, Snapshots: raft transport and stores. Logs and Stable should be durable unless the whole cluster may restart empty
+// - Timeout: timeout of applying a write and of forwarding, 5s if 0
+// - LeaderURL: returns base URL of the leader where Cache is served by ServeHTTP. If nil, followers return ErrNotLeader instead of forwarding
+// - Client: HTTP client of forwarding, http.DefaultClient if nil
+type Options struct {
+	Config    *raft.Config
+	Transport raft.Transport
+	Logs      raft.LogStore
+	Stable    raft.StableStore
+	Snapshots raft.SnapshotStore
+	Timeout   time.Duration
+	LeaderURL func(id raft.ServerID, addr raft.ServerAddress) string
+	Client    *http.Client
+}
+
+// Cache structure is CStorage replicated by raft. CStorage should not be written directly, otherwise the node diverges from the others.
+type Cache struct {
+	cache   *cstorage.CStorage
+	raft    *raft.Raft
+	fsm     *fsm
+	options Options
+}
+
+// New function starts raft node whose state machine is cache. The cluster should be bootstrapped once, e.g. by Raft().BootstrapCluster.
+func New(cache *cstorage.CStorage, options Options) (*Cache, error) {
+	if options.Timeout == 0 {
+		options.Timeout = 5 * time.Second
+	}
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+	f := &fsm{cache: cache}
+	r, err := raft.NewRaft(options.Config, f, options.Logs, options.Stable, options.Snapshots, options.Transport)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{cache: cache, raft: r, fsm: f, options: options}, nil
+}
+
+// Raft function returns raft node, e.g. to bootstrap the cluster or to change its members.
+func (c *Cache) Raft() *raft.Raft {
+	return c.raft
+}
+
+// CStorage function returns local CStorage, which should only be read.
+func (c *Cache) CStorage() *cstorage.CStorage {
+	return c.cache
+}
+
+// Put function puts data of key on every node. ttl of CStorageConfig is counted from when each node applies it.
+func (c *Cache) Put(ctx context.Context, key string, data []byte) error {
+	return c.apply(ctx, command{Op: opPut, Key: key, Data: data})
+}
+
+// PutWithTtl function puts data of key on every node, which expires at same time on every node, ttl after now.
+func (c *Cache) PutWithTtl(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	return c.apply(ctx, command{Op: opPut, Key: key, Data: data, ExpiresAt: time.Now().Add(ttl).UnixNano()})
+}
+
+// Delete function deletes key on every node.
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	return c.apply(ctx, command{Op: opDelete, Key: key})
+}
+
+// Clear function deletes every key on every node.
+func (c *Cache) Clear(ctx context.Context) error {
+	return c.apply(ctx, command{Op: opClear})
+}
+
+// Get function returns data of key, which reflects every write completed before it is called. On the leader, it confirms leadership
+// and waits until committed writes are applied locally. On followers, it is forwarded to the leader.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if c.raft.State() != raft.Leader {
+		return c.forwardGet(ctx, key)
+	}
+	if err := c.readBarrier(); err != nil {
+		return nil, false, err
+	}
+	data, hit := c.cache.Get(key)
+	return data, hit, nil
+}
+
+// GetStale function returns data of key from local CStorage without asking the leader. It can miss recent writes.
+func (c *Cache) GetStale(key string) ([]byte, bool) {
+	return c.cache.Get(key)
+}
+
+// Close function shuts down raft node. CStorage is not closed.
+func (c *Cache) Close() error {
+	return c.raft.Shutdown().Error()
+}
+
+// readBarrier makes sure this node is still the leader, and its CStorage has every write committed before.
+func (c *Cache) readBarrier() error {
+	index := c.raft.CommitIndex()
+	if err := c.raft.VerifyLeader().Error(); err != nil {
+		return leaderError(err)
+	}
+	if atomic.LoadUint64(&c.fsm.applied) >= index {
+		return nil
+	}
+	return leaderError(c.raft.Barrier(c.options.Timeout).Error())
+}
+
+// apply applies cmd through the log of the leader, forwarding it if this node is a follower.
+func (c *Cache) apply(ctx context.Context, cmd command) error {
+	b, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	if c.raft.State() != raft.Leader {
+		return c.forwardApply(ctx, b)
+	}
+	f := c.raft.Apply(b, c.options.Timeout)
+	if err := f.Error(); err != nil {
+		return leaderError(err)
+	}
+	if err, ok := f.Response().(error); ok {
+		return err
+	}
+	return nil
+}
+
+// leaderError converts errors of raft which mean this node is not the leader to ErrNotLeader.
+func leaderError(err error) error {
+	if errors.Is(err, raft.ErrNotLeader) || errors.Is(err, raft.ErrLeadershipLost) {
+		return fmt.Errorf("%w: %v", ErrNotLeader, err)
+	}
+	return err
+}
+
+// leader returns base URL of the leader.
+func (c *Cache) leader() (string, error) {
+	addr, id := c.raft.LeaderWithID()
+	if c.options.LeaderURL == nil || id == "" {
+		return "", ErrNotLeader
+	}
+	return strings.TrimRight(c.options.LeaderURL(id, addr), "/"), nil
+}
+
+func (c *Cache) forwardApply(ctx context.Context, b []byte) error {
+	base, err := c.leader()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.options.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/apply", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	_, _, err = c.forward(req)
+	return err
+}
+
+func (c *Cache) forwardGet(ctx context.Context, key string) ([]byte, bool, error) {
+	base, err := c.leader()
+	if err != nil {
+		return nil, false, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.options.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/get?key="+url.QueryEscape(key), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	return c.forward(req)
+}
+
+// forward sends req to the leader. Forwarded request is not forwarded again, so the leader which lost leadership responds with 409.
+func (c *Cache) forward(req *http.Request) ([]byte, bool, error) {
+	resp, err := c.options.Client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, true, nil
+	case http.StatusNoContent:
+		return nil, true, nil
+	case http.StatusNotFound:
+		return nil, false, nil
+	case http.StatusConflict:
+		return nil, false, ErrNotLeader
+	}
+	return nil, false, fmt.Errorf("replicated: leader responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// ServeHTTP serves writes and reads forwarded by followers.
+//
+//	POST /apply       applies command in body, 204 if applied
+//	GET  /get?key=    returns data of key, 404 if missing
+//
+// Both respond with 409 if this node is not the leader.
+func (c *Cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/apply" && r.Method == http.MethodPost:
+		var cmd command
+		if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if c.raft.State() != raft.Leader {
+			http.Error(w, ErrNotLeader.Error(), http.StatusConflict)
+			return
+		}
+		if err := c.apply(r.Context(), cmd); err != nil {
+			httpError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/get" && r.Method == http.MethodGet:
+		if c.raft.State() != raft.Leader {
+			http.Error(w, ErrNotLeader.Error(), http.StatusConflict)
+			return
+		}
+		data, hit, err := c.Get(r.Context(), r.URL.Query().Get("key"))
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		if !hit {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func httpError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotLeader) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
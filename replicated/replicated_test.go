@@ -0,0 +1,198 @@
+package replicated
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/testutil"
+	"github.com/hashicorp/raft"
+)
+
+func newCache() *cstorage.CStorage {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity}
+	return cstorage.New(config)
+}
+
+// newCluster starts n nodes connected by in-memory transport, each serving forwarded calls by HTTP, and waits for the leader.
+func newCluster(t *testing.T, n int) []*Cache {
+	transports := make([]*raft.InmemTransport, n)
+	servers := make([]raft.Server, n)
+	for i := range transports {
+		addr, transport := raft.NewInmemTransport("")
+		transports[i] = transport
+		servers[i] = raft.Server{ID: raft.ServerID(fmt.Sprintf("node-%d", i)), Address: addr}
+	}
+	for _, a := range transports {
+		for _, b := range transports {
+			a.Connect(b.LocalAddr(), b)
+		}
+	}
+
+	urls := make(map[raft.ServerID]string)
+	caches := make([]*Cache, n)
+	for i := range caches {
+		config := raft.DefaultConfig()
+		config.LocalID = servers[i].ID
+		config.HeartbeatTimeout = 50 * time.Millisecond
+		config.ElectionTimeout = 50 * time.Millisecond
+		config.LeaderLeaseTimeout = 50 * time.Millisecond
+		config.CommitTimeout = 5 * time.Millisecond
+		config.LogOutput = io.Discard
+
+		c, err := New(newCache(), Options{
+			Config:    config,
+			Transport: transports[i],
+			Logs:      raft.NewInmemStore(),
+			Stable:    raft.NewInmemStore(),
+			Snapshots: raft.NewInmemSnapshotStore(),
+			LeaderURL: func(id raft.ServerID, addr raft.ServerAddress) string { return urls[id] },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+		srv := httptest.NewServer(c)
+		t.Cleanup(srv.Close)
+		urls[servers[i].ID] = srv.URL
+		caches[i] = c
+	}
+	if err := caches[0].Raft().BootstrapCluster(raft.Configuration{Servers: servers}).Error(); err != nil {
+		t.Fatal(err)
+	}
+	testutil.Eventually(t, func() bool { return leader(caches) != nil }, "leader should be elected")
+	return caches
+}
+
+func leader(caches []*Cache) *Cache {
+	for _, c := range caches {
+		if c.Raft().State() == raft.Leader {
+			return c
+		}
+	}
+	return nil
+}
+
+func follower(caches []*Cache) *Cache {
+	for _, c := range caches {
+		if c.Raft().State() == raft.Follower {
+			return c
+		}
+	}
+	return nil
+}
+
+// eventually waits until cond is true.
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	caches := newCluster(t, 3)
+
+	l, f := leader(caches), follower(caches)
+	if err := l.Put(ctx, "a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	// follower forwards writes and reads to the leader
+	if err := f.PutWithTtl(ctx, "b", []byte("2"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if data, hit, err := f.Get(ctx, "b"); err != nil || !hit || string(data) != "2" {
+		t.Errorf("b should be read from the leader, got %q %v %v", data, hit, err)
+	}
+	if data, hit, err := l.Get(ctx, "a"); err != nil || !hit || string(data) != "1" {
+		t.Errorf("a should hit on the leader, got %q %v %v", data, hit, err)
+	}
+	if _, hit, err := f.Get(ctx, "missing"); err != nil || hit {
+		t.Errorf("missing should miss, got %v %v", hit, err)
+	}
+	for _, c := range caches {
+		testutil.Eventually(t, func() bool { _, hit := c.GetStale("b"); return hit }, "b should be applied on every node")
+		if ttl, _ := c.CStorage().Ttl("b"); ttl > time.Minute {
+			t.Errorf("b should expire in a minute, got %v", ttl)
+		}
+	}
+
+	if err := f.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, hit, _ := l.Get(ctx, "a"); hit {
+		t.Error("a should be deleted")
+	}
+	if err := l.Clear(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range caches {
+		testutil.Eventually(t, func() bool { return c.CStorage().Stats().Size == 0 }, "every node should be cleared")
+	}
+}
+
+func TestNotLeader(t *testing.T) {
+	caches := newCluster(t, 3)
+	f := follower(caches)
+	f.options.LeaderURL = nil
+	if err := f.Put(context.Background(), "a", []byte("1")); err != ErrNotLeader {
+		t.Errorf("expected ErrNotLeader, got %v", err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	caches := newCluster(t, 3)
+	l := leader(caches)
+	l.Put(ctx, "a", []byte("1"))
+	l.PutWithTtl(ctx, "b", []byte("2"), time.Minute)
+
+	snap, err := l.fsm.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &memorySink{}
+	if err := snap.Persist(sink); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := &fsm{cache: newCache()}
+	restored.cache.Put("stale", []byte("x"))
+	if err := restored.Restore(io.NopCloser(&sink.Buffer)); err != nil {
+		t.Fatal(err)
+	}
+	if _, hit := restored.cache.Get("stale"); hit {
+		t.Error("restore should replace every key")
+	}
+	if data, hit := restored.cache.Get("b"); !hit || string(data) != "2" {
+		t.Errorf("b should be restored, got %q %v", data, hit)
+	}
+}
+
+func TestApplyExpired(t *testing.T) {
+	f := &fsm{cache: newCache()}
+	f.cache.Put("a", []byte("old"))
+	b := []byte(fmt.Sprintf(`{"op":"put","key":"a","data":"bmV3","expires_at":%d}`, time.Now().Add(-time.Second).UnixNano()))
+	if resp := f.Apply(&raft.Log{Index: 7, Data: b}); resp != nil {
+		t.Fatal(resp)
+	}
+	if _, hit := f.cache.Get("a"); hit {
+		t.Error("replayed put which has expired should remove key")
+	}
+	if f.applied != 7 {
+		t.Errorf("applied index should be 7, got %d", f.applied)
+	}
+	if resp := f.Apply(&raft.Log{Index: 8, Data: []byte(`{"op":"unknown"}`)}); resp == nil {
+		t.Error("unknown command should be error")
+	}
+}
+
+type memorySink struct {
+	bytes.Buffer
+}
+
+func (s *memorySink) ID() string    { return "memory" }
+func (s *memorySink) Cancel() error { return nil }
+func (s *memorySink) Close() error  { return nil }
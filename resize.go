@@ -0,0 +1,29 @@
+package cstorage
+
+import "time"
+
+// Resize function changes capacity of CStorage at runtime, so it can be re-tuned(e.g. by control plane) without losing keys.
+// If capacity is shrunk, keys are evicted right away until they fit, in the same manner as Put.
+// Size of TinyLFU admission filter is not changed, since its counters are sized when CStorage is made.
+func (s *CStorage) Resize(capacity int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.config.Capacity = capacity
+	s.policy.resize(capacity)
+	s.makeRoom(0, nil)
+}
+
+// SetDefaultTTL function changes CStorageConfig.Ttl at runtime. It applies to keys put afterward, and ttl of existing keys is kept.
+// Namespaces which don't have their own Ttl follow the new one.
+func (s *CStorage) SetDefaultTTL(ttl time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.config.Ttl = ttl
+	for name, ns := range s.namespaces {
+		if s.config.Namespaces[name].Ttl == 0 {
+			ns.config.Ttl = ttl
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package cstorage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestResize(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	for i := 0; i < 10; i++ {
+		cache.Put(strconv.Itoa(i), []byte(strconv.Itoa(i)))
+	}
+
+	cache.Resize(4)
+	if cache.Size() != 4 || cache.Cap() != 4 {
+		t.Fatalf("size and capacity should be 4, got %d and %d", cache.Size(), cache.Cap())
+	}
+	for i := 0; i < 6; i++ {
+		if _, hit := cache.Peek(strconv.Itoa(i)); hit {
+			t.Errorf("%d should be evicted", i)
+		}
+	}
+	if stats := cache.Stats(); stats.Evicted != 6 {
+		t.Errorf("6 keys should be evicted, got %d", stats.Evicted)
+	}
+
+	cache.Resize(8)
+	for i := 10; i < 14; i++ {
+		cache.Put(strconv.Itoa(i), []byte(strconv.Itoa(i)))
+	}
+	if cache.Size() != 8 {
+		t.Errorf("size should be 8 after growing, got %d", cache.Size())
+	}
+}
+
+func TestResizeSLRU(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Policy: PolicySLRU}
+	cache := New(config)
+
+	for i := 0; i < 10; i++ {
+		cache.Put(strconv.Itoa(i), []byte(strconv.Itoa(i)))
+		cache.Get(strconv.Itoa(i))
+	}
+
+	cache.Resize(5)
+	p := cache.policy.(*slru)
+	if p.protectedCap != 4 || p.protected.len != 4 {
+		t.Errorf("protected should be shrunk to 4, got capacity %d and length %d", p.protectedCap, p.protected.len)
+	}
+	if cache.Size() != 5 {
+		t.Errorf("size should be 5, got %d", cache.Size())
+	}
+}
+
+func TestSetDefaultTTL(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Namespaces: map[string]NamespaceConfig{"own": {Ttl: 2 * time.Hour}}}
+	cache := New(config)
+
+	cache.Put("before", []byte("v"))
+	users := cache.Namespace("users")
+	own := cache.Namespace("own")
+
+	cache.SetDefaultTTL(time.Minute)
+	cache.Put("after", []byte("v"))
+	users.Put("after", []byte("v"))
+	own.Put("after", []byte("v"))
+
+	if remaining, _ := cache.Ttl("before"); remaining <= time.Minute {
+		t.Errorf("ttl of existing key should be kept, got %v", remaining)
+	}
+	if remaining, _ := cache.Ttl("after"); remaining > time.Minute {
+		t.Errorf("new key should have new ttl, got %v", remaining)
+	}
+	if remaining, _ := cache.Ttl("users" + nsSeparator + "after"); remaining > time.Minute {
+		t.Errorf("namespace without its own ttl should follow new ttl, got %v", remaining)
+	}
+	if remaining, _ := cache.Ttl("own" + nsSeparator + "after"); remaining <= time.Hour {
+		t.Errorf("namespace with its own ttl should keep it, got %v", remaining)
+	}
+}
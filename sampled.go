@@ -0,0 +1,100 @@
+package cstorage
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// defaultSamples is number of keys sampled by PolicySampled if CStorageConfig.Samples is not set. It is same as default of Redis.
+const defaultSamples = 5
+
+// sampled is approximate LRU policy like Redis. Nodes are kept in a slice without order, with time of last access of each node.
+// Victim is the least recently accessed one among samples randomly chosen from the slice.
+// - nodes: every node, and node.pos is its index
+// - accessed: time of last access of node at the same index, which is kept here so other policies don't pay memory for it
+// - tick: logical clock which is increased on every access, so access time is distinct and deterministic
+// - candidate: victim chosen last time, which is kept until nodes change, so victim returns same node until it is removed
+type sampled struct {
+	nodes     []*node
+	accessed  []int64
+	tick      int64
+	samples   int
+	rand      *rand.Rand
+	candidate *node
+}
+
+// newSampled makes sampled. Samples are drawn from random source seeded by CStorageConfig.Seed, so eviction is deterministic with same seed.
+func newSampled(config CStorageConfig) *sampled {
+	samples := config.Samples
+	if samples <= 0 {
+		samples = defaultSamples
+	}
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &sampled{samples: samples, rand: rand.New(rand.NewSource(seed))}
+}
+
+func (p *sampled) adapt(key string) {}
+
+func (p *sampled) add(n *node) {
+	n.pos = len(p.nodes)
+	p.nodes = append(p.nodes, n)
+	p.accessed = append(p.accessed, 0)
+	p.access(n)
+}
+
+func (p *sampled) access(n *node) {
+	p.tick++
+	p.accessed[n.pos] = p.tick
+	p.candidate = nil
+}
+
+func (p *sampled) remove(n *node, evicted bool) {
+	end := len(p.nodes) - 1
+	last := p.nodes[end]
+	p.nodes[n.pos] = last
+	p.accessed[n.pos] = p.accessed[end]
+	last.pos = n.pos
+	p.nodes[end] = nil
+	p.nodes = p.nodes[:end]
+	p.accessed = p.accessed[:end]
+	p.candidate = nil
+}
+
+func (p *sampled) victim() *node {
+	if p.candidate != nil || len(p.nodes) == 0 {
+		return p.candidate
+	}
+	for i := 0; i < p.samples; i++ {
+		n := p.nodes[p.rand.Intn(len(p.nodes))]
+		if p.candidate == nil || p.accessed[n.pos] < p.accessed[p.candidate.pos] {
+			p.candidate = n
+		}
+	}
+	return p.candidate
+}
+
+// each calls fn in the order of access time, which is the order exact LRU would evict. It sorts copy of nodes, so it takes O(NlogN).
+func (p *sampled) each(fn func(n *node) bool) {
+	nodes := make([]*node, len(p.nodes))
+	copy(nodes, p.nodes)
+	sort.Slice(nodes, func(i, j int) bool {
+		return p.accessed[nodes[i].pos] < p.accessed[nodes[j].pos]
+	})
+	for _, n := range nodes {
+		if !fn(n) {
+			return
+		}
+	}
+}
+
+func (p *sampled) reset() {
+	p.nodes = nil
+	p.accessed = nil
+	p.candidate = nil
+}
+
+func (p *sampled) resize(capacity int64) {}
@@ -0,0 +1,70 @@
+package cstorage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSampled(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 3
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Policy: PolicySampled, Samples: 64, Seed: 1}
+	cache := New(config)
+
+	cache.Put("key1", []byte("1"))
+	cache.Put("key2", []byte("2"))
+	cache.Put("key3", []byte("3"))
+	cache.Get("key1")
+
+	cache.Put("key4", []byte("4"))
+	if _, hit := cache.Peek("key2"); hit {
+		t.Error("key2 is least recently accessed, it should be evicted")
+	}
+
+	var keys []string
+	cache.IterateLRU(func(key string, data []byte, expiresAt time.Time) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if len(keys) != 3 || keys[0] != "key3" || keys[1] != "key1" || keys[2] != "key4" {
+		t.Errorf("keys should be in order of access, got %v", keys)
+	}
+}
+
+func TestSampledApproximate(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 100
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Policy: PolicySampled, Seed: 1}
+	cache := New(config)
+
+	for i := 0; i < 100; i++ {
+		cache.Put(strconv.Itoa(i), []byte("v"))
+	}
+	for i := 50; i < 100; i++ {
+		cache.Get(strconv.Itoa(i))
+	}
+	for i := 100; i < 120; i++ {
+		cache.Put(strconv.Itoa(i), []byte("v"))
+	}
+
+	recent := 0
+	for i := 50; i < 100; i++ {
+		if _, hit := cache.Peek(strconv.Itoa(i)); hit {
+			recent++
+		}
+	}
+	if recent < 45 {
+		t.Errorf("recently accessed keys should mostly survive, got %d of 50", recent)
+	}
+
+	p := cache.policy.(*sampled)
+	if len(p.nodes) != int(cache.Size()) {
+		t.Fatalf("every key should be sampled, got %d for size %d", len(p.nodes), cache.Size())
+	}
+	for i, n := range p.nodes {
+		if n.pos != i {
+			t.Errorf("pos of %s should be %d, got %d", n.key, i, n.pos)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package cstorage
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotPrefix and snapshotSuffix enclose time of snapshot in names of files written to CStorageConfig.SnapshotDir,
+// so names sort in the order they are written.
+const (
+	snapshotPrefix = "cstorage-"
+	snapshotSuffix = ".snapshot"
+	snapshotLayout = "20060102T150405.000000000"
+)
+
+// defaultSnapshotRetain is default of CStorageConfig.SnapshotRetain.
+const defaultSnapshotRetain = 3
+
+// LoadLatestSnapshot function reads the latest snapshot written to dir by CStorageConfig.SnapshotDir, which is meant to be called on start.
+// If it can't be read(e.g. truncated by crash of machine), older ones are tried in order. It returns 0 without error if dir has no snapshot,
+// so new CStorage starts empty.
+func (s *CStorage) LoadLatestSnapshot(dir string) (count int, err error) {
+	files, err := snapshotFiles(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		if count, err = s.loadSnapshot(files[i]); err == nil || err == ErrClosed {
+			return count, err
+		}
+		// keys of broken snapshot read so far are dropped, so they don't mix with older one
+		s.Clear()
+	}
+	return 0, err
+}
+
+// loadSnapshot reads snapshot file at path.
+func (s *CStorage) loadSnapshot(path string) (count int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return s.ReadSnapshot(f)
+}
+
+// snapshotFiles returns paths of snapshots in dir, from the oldest to the latest.
+func snapshotFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() && strings.HasPrefix(name, snapshotPrefix) && strings.HasSuffix(name, snapshotSuffix) {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// scheduleSnapshots writes snapshot to CStorageConfig.SnapshotDir every interval until done is closed.
+func (s *CStorage) scheduleSnapshots(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mutex.RLock()
+			items := s.items()
+			s.mutex.RUnlock()
+			s.saveGeneration(items)
+		case <-done:
+			return
+		}
+	}
+}
+
+// saveGeneration writes items as new snapshot in CStorageConfig.SnapshotDir, and removes old ones beyond SnapshotRetain.
+// Snapshot is written to temporary file and renamed, so a crash never leaves partial snapshot with the name of snapshot.
+func (s *CStorage) saveGeneration(items []item) error {
+	dir := s.config.SnapshotDir
+	name := snapshotPrefix + s.now().UTC().Format(snapshotLayout) + snapshotSuffix
+	if err := s.saveSnapshot(filepath.Join(dir, name), items); err != nil {
+		return err
+	}
+
+	retain := s.config.SnapshotRetain
+	if retain == 0 {
+		retain = defaultSnapshotRetain
+	}
+	files, err := snapshotFiles(dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > retain {
+		os.Remove(files[0])
+		files = files[1:]
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package cstorage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScheduledSnapshot(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	dir := t.TempDir()
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, SnapshotDir: dir, SnapshotInterval: 5 * time.Millisecond, SnapshotRetain: 2}
+	cache := New(config)
+	cache.Put("a", []byte("1"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		files, _ := snapshotFiles(dir)
+		if len(files) >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("snapshots should be written in background")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cache.Put("b", []byte("2"))
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(CStorageConfig{Ttl: ttl, Capacity: capacity})
+	if count, err := restored.LoadLatestSnapshot(dir); err != nil || count != 2 {
+		t.Errorf("snapshot written on Close should be loaded, got %d %v", count, err)
+	}
+	if files, _ := snapshotFiles(dir); len(files) != 2 {
+		t.Errorf("only 2 snapshots should be kept, got %d", len(files))
+	}
+}
+
+func TestLoadLatestSnapshot(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	dir := t.TempDir()
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, SnapshotDir: dir}
+	cache := New(config)
+	cache.Put("a", []byte("1"))
+	cache.Close()
+
+	// latest snapshot is broken, e.g. by crash of machine before it is synced
+	broken := filepath.Join(dir, snapshotPrefix+"99991231T000000.000000000"+snapshotSuffix)
+	if err := os.WriteFile(broken, []byte("broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(CStorageConfig{Ttl: ttl, Capacity: capacity})
+	if count, err := restored.LoadLatestSnapshot(dir); err != nil || count != 1 {
+		t.Errorf("older snapshot should be loaded, got %d %v", count, err)
+	}
+	if data, _ := restored.Get("a"); string(data) != "1" {
+		t.Errorf("a should be loaded, got %q", data)
+	}
+
+	if count, err := restored.LoadLatestSnapshot(filepath.Join(dir, "missing")); err != nil || count != 0 {
+		t.Errorf("missing dir should be empty start, got %d %v", count, err)
+	}
+}
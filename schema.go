@@ -0,0 +1,35 @@
+package cstorage
+
+// PutWithSchema function is same as Put, but data is stamped with given schema version instead of CStorageConfig.SchemaVersion.
+// It is for writing data which is made by other schema, e.g. when importing data from other instance during rollout.
+func (s *CStorage) PutWithSchema(key string, data []byte, schema uint32) (hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, hit := s.put(key, data, s.config.Ttl, s.config.Sliding)
+	if n != nil {
+		n.schema = schema
+	}
+	return hit
+}
+
+// upgrade converts data of node into current schema version with Upgrader. It returns false if it can't be upgraded.
+// Caller should hold the mutex.
+func (s *CStorage) upgrade(n *node) bool {
+	if s.config.Upgrader == nil {
+		return false
+	}
+
+	data, ok := s.config.Upgrader(n.key, s.value(n), n.schema)
+	if !ok {
+		return false
+	}
+
+	stored, raw, err := s.encode(data)
+	if err != nil {
+		return false
+	}
+	s.store(n, stored, raw)
+	n.schema = s.config.SchemaVersion
+	return true
+}
@@ -0,0 +1,44 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchemaVersion(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, SchemaVersion: 2}
+	cache := New(config)
+
+	cache.Put("key1", []byte("1"))
+	cache.PutWithSchema("key2", []byte("2"), 1)
+
+	if _, hit := cache.Get("key1"); !hit {
+		t.Error("key1 has current schema, it should hit")
+	}
+	if _, hit := cache.Get("key2"); hit {
+		t.Error("key2 has older schema, it should miss")
+	}
+	if st := cache.Stats(); st.Stale != 1 || st.Size != 1 {
+		t.Errorf("key2 should be removed as stale, got %+v", st)
+	}
+
+	config.Upgrader = func(key string, data []byte, from uint32) ([]byte, bool) {
+		if from != 1 {
+			return nil, false
+		}
+		return append([]byte("v2:"), data...), true
+	}
+	cache = New(config)
+	cache.PutWithSchema("key1", []byte("1"), 1)
+	cache.PutWithSchema("key2", []byte("2"), 0)
+
+	info, hit := cache.GetWithInfo("key1")
+	if !hit || string(info.Data) != "v2:1" || info.Schema != 2 {
+		t.Errorf("key1 should be upgraded, got %+v", info)
+	}
+	if _, hit := cache.Get("key2"); hit {
+		t.Error("upgrader refused key2, it should miss")
+	}
+}
@@ -0,0 +1,54 @@
+package shm
+
+import (
+	"io"
+	"os"
+)
+
+// segment structure is file of shared segment.
+type segment struct {
+	file *os.File
+}
+
+// openSegment opens or makes file at path.
+func openSegment(path string) (*segment, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &segment{file: f}, nil
+}
+
+// size returns size of the file.
+func (s *segment) size() (int64, error) {
+	info, err := s.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// init extends empty file to size, and writes header.
+func (s *segment) init(header []byte, size int64) error {
+	if err := s.file.Truncate(size); err != nil {
+		return err
+	}
+	_, err := s.file.WriteAt(header, 0)
+	return err
+}
+
+// readHeader reads header of the file.
+func (s *segment) readHeader(header []byte) error {
+	if _, err := s.file.ReadAt(header, 0); err != nil {
+		if err == io.EOF {
+			return ErrFormat
+		}
+		return err
+	}
+	return nil
+}
+
+// close closes the file, which releases the lock as well.
+func (s *segment) close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,21 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package shm
+
+// lock returns ErrUnsupported, so Open fails on this platform.
+func (s *segment) lock(exclusive bool) error {
+	return ErrUnsupported
+}
+
+// unlock does nothing.
+func (s *segment) unlock() {}
+
+// mmap returns ErrUnsupported.
+func (s *segment) mmap(size int64) ([]byte, error) {
+	return nil, ErrUnsupported
+}
+
+// munmap does nothing.
+func (s *segment) munmap(data []byte) error {
+	return nil
+}
@@ -0,0 +1,34 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package shm
+
+import "syscall"
+
+// lock locks the file, exclusively if exclusive is true. Lock of the file is shared by goroutines, so caller should also hold mutex of Cache.
+func (s *segment) lock(exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		err := syscall.Flock(int(s.file.Fd()), how)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+// unlock unlocks the file.
+func (s *segment) unlock() {
+	syscall.Flock(int(s.file.Fd()), syscall.LOCK_UN)
+}
+
+// mmap maps the file read-write and shared, so writes are seen by other processes.
+func (s *segment) mmap(size int64) ([]byte, error) {
+	return syscall.Mmap(int(s.file.Fd()), 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
+
+// munmap unmaps data.
+func (s *segment) munmap(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,319 @@
+// Package shm is experimental cache whose hash table and values live in a shared memory segment(memory-mapped file),
+// so several processes on a host(e.g. forked worker per core) share a cache without network hop.
+// Processes are synchronized by file lock of the segment, and goroutines of a process by mutex.
+//
+// Unlike CStorage, the table has fixed number of slots with fixed size of key and value, which are decided when the segment is made.
+// Keys are placed by open addressing; when every slot a key can take is used, the one which expires first among them is overwritten.
+// Path should be on memory backed file system(e.g. /dev/shm on Linux), otherwise pages are written back to disk.
+package shm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// Segment layout, every integer is little endian
+// - header: magic "CSHM", version(4), number of slots(8), max key length(4), max value length(4)
+// - slots: state(1), padding(3), key length(4), value length(4), padding(4), expiration in unix nano(8), key, value, padding to 8 bytes
+const (
+	magic          = "CSHM"
+	version        = 1
+	headerSize     = 64
+	slotHeaderSize = 24
+)
+
+// states of slot. deleted slot is skipped by lookup, but it doesn't stop probing like empty slot.
+const (
+	empty byte = iota
+	used
+	deleted
+)
+
+// probeLimit is number of slots a key can take, starting from slot of its hash.
+const probeLimit = 32
+
+// Errors of Cache.
+var (
+	// ErrFormat is returned by Open when the file is not a segment or it is broken.
+	ErrFormat = errors.New("shm: invalid segment file")
+	// ErrMismatch is returned by Open when the segment exists with different size than Config.
+	ErrMismatch = errors.New("shm: segment has different size")
+	// ErrTooLarge is returned by Put when key or value is larger than the segment allows.
+	ErrTooLarge = errors.New("shm: key or value too large")
+	// ErrClosed is returned after Cache is closed.
+	ErrClosed = errors.New("shm: cache is closed")
+	// ErrUnsupported is returned by Open on platforms without shared memory-mapping and file lock.
+	ErrUnsupported = errors.New("shm: not supported on this platform")
+)
+
+// Config structure is size of segment and default ttl.
+// - Slots: number of keys the segment can hold. Less keys fit in practice, since a key can only take one of probeLimit slots.
+// - MaxKey, MaxValue: maximum length of key and value in bytes. Every slot takes this much memory.
+// - Ttl: default ttl of keys put by Put
+// If the segment already exists, Slots, MaxKey and MaxValue are taken from it when they are zero, and ErrMismatch is returned when they differ.
+type Config struct {
+	Slots    int64
+	MaxKey   int
+	MaxValue int
+	Ttl      time.Duration
+}
+
+// Cache structure is a process's handle of shared segment.
+type Cache struct {
+	mutex    sync.RWMutex
+	segment  *segment
+	data     []byte
+	slots    int64
+	maxKey   int
+	maxValue int
+	slotSize int
+	ttl      time.Duration
+}
+
+// Open function opens segment at path, which is made if it doesn't exist. Each process should open the segment by itself,
+// rather than inherit Cache from its parent, since lock of the segment is held by file opened by Open.
+func Open(path string, config Config) (*Cache, error) {
+	seg, err := openSegment(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := attach(seg, config)
+	if err != nil {
+		seg.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// attach reads or writes header of the segment, and maps it. The segment is locked exclusively while it is done,
+// so only one of processes which open new segment at once writes header.
+func attach(seg *segment, config Config) (*Cache, error) {
+	if err := seg.lock(true); err != nil {
+		return nil, err
+	}
+	defer seg.unlock()
+
+	size, err := seg.size()
+	if err != nil {
+		return nil, err
+	}
+
+	var header [headerSize]byte
+	if size == 0 {
+		if config.Slots <= 0 || config.MaxKey <= 0 || config.MaxValue < 0 {
+			return nil, fmt.Errorf("%w: slots and max key should be positive", ErrMismatch)
+		}
+		copy(header[:], magic)
+		binary.LittleEndian.PutUint32(header[4:], version)
+		binary.LittleEndian.PutUint64(header[8:], uint64(config.Slots))
+		binary.LittleEndian.PutUint32(header[16:], uint32(config.MaxKey))
+		binary.LittleEndian.PutUint32(header[20:], uint32(config.MaxValue))
+		size = headerSize + config.Slots*int64(slotSize(config.MaxKey, config.MaxValue))
+		if err := seg.init(header[:], size); err != nil {
+			return nil, err
+		}
+	} else if err := seg.readHeader(header[:]); err != nil {
+		return nil, err
+	}
+
+	if string(header[:4]) != magic || binary.LittleEndian.Uint32(header[4:]) != version {
+		return nil, ErrFormat
+	}
+	slots := int64(binary.LittleEndian.Uint64(header[8:]))
+	maxKey := int(binary.LittleEndian.Uint32(header[16:]))
+	maxValue := int(binary.LittleEndian.Uint32(header[20:]))
+	if slots <= 0 || size != headerSize+slots*int64(slotSize(maxKey, maxValue)) {
+		return nil, ErrFormat
+	}
+	if (config.Slots != 0 && config.Slots != slots) || (config.MaxKey != 0 && config.MaxKey != maxKey) || (config.MaxValue != 0 && config.MaxValue != maxValue) {
+		return nil, fmt.Errorf("%w: %d slots of key %d and value %d bytes", ErrMismatch, slots, maxKey, maxValue)
+	}
+
+	data, err := seg.mmap(size)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{
+		segment:  seg,
+		data:     data,
+		slots:    slots,
+		maxKey:   maxKey,
+		maxValue: maxValue,
+		slotSize: slotSize(maxKey, maxValue),
+		ttl:      config.Ttl,
+	}, nil
+}
+
+// slotSize returns bytes of a slot, aligned to 8 bytes.
+func slotSize(maxKey, maxValue int) int {
+	return (slotHeaderSize + maxKey + maxValue + 7) &^ 7
+}
+
+// Get function returns copy of data of key. Expired key is not removed by Get, since it only takes shared lock.
+func (c *Cache) Get(key string) (data []byte, hit bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.data == nil || c.segment.lock(false) != nil {
+		return nil, false
+	}
+	defer c.segment.unlock()
+
+	i, ok := c.find(key, time.Now().UnixNano())
+	if !ok {
+		return nil, false
+	}
+	slot := c.slot(i)
+	keyLen := binary.LittleEndian.Uint32(slot[4:])
+	dataLen := binary.LittleEndian.Uint32(slot[8:])
+	data = make([]byte, dataLen)
+	copy(data, slot[slotHeaderSize+int(keyLen):])
+	return data, true
+}
+
+// Put function puts data of key with ttl of Config. It returns hit=true if key was there.
+func (c *Cache) Put(key string, data []byte) (hit bool, err error) {
+	return c.PutWithTtl(key, data, c.ttl)
+}
+
+// PutWithTtl function is same as Put, but ttl of the key is given by caller.
+func (c *Cache) PutWithTtl(key string, data []byte, ttl time.Duration) (hit bool, err error) {
+	if len(key) > c.maxKey || len(data) > c.maxValue {
+		return false, ErrTooLarge
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.data == nil {
+		return false, ErrClosed
+	}
+	if err := c.segment.lock(true); err != nil {
+		return false, err
+	}
+	defer c.segment.unlock()
+
+	now := time.Now().UnixNano()
+	i, hit := c.find(key, now)
+	if !hit {
+		i = c.place(key, now)
+	}
+
+	slot := c.slot(i)
+	slot[0] = used
+	binary.LittleEndian.PutUint32(slot[4:], uint32(len(key)))
+	binary.LittleEndian.PutUint32(slot[8:], uint32(len(data)))
+	binary.LittleEndian.PutUint64(slot[16:], uint64(now+int64(ttl)))
+	copy(slot[slotHeaderSize:], key)
+	copy(slot[slotHeaderSize+len(key):], data)
+	return hit, nil
+}
+
+// Delete function deletes key. It returns hit=true if key was there.
+func (c *Cache) Delete(key string) (hit bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.data == nil || c.segment.lock(true) != nil {
+		return false
+	}
+	defer c.segment.unlock()
+
+	i, ok := c.find(key, time.Now().UnixNano())
+	if ok {
+		c.slot(i)[0] = deleted
+	}
+	return ok
+}
+
+// Len function returns number of keys which are not expired. It scans every slot.
+func (c *Cache) Len() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.data == nil || c.segment.lock(false) != nil {
+		return 0
+	}
+	defer c.segment.unlock()
+
+	now := time.Now().UnixNano()
+	count := 0
+	for i := int64(0); i < c.slots; i++ {
+		if slot := c.slot(i); slot[0] == used && !expired(slot, now) {
+			count++
+		}
+	}
+	return count
+}
+
+// Close function unmaps the segment. The segment file is kept for other processes, and it should be removed by caller when it is no longer used.
+func (c *Cache) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.data == nil {
+		return ErrClosed
+	}
+
+	err := c.segment.munmap(c.data)
+	c.data = nil
+	if cerr := c.segment.close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// find returns slot of key which is not expired. Caller should hold the locks.
+func (c *Cache) find(key string, now int64) (int64, bool) {
+	start := hash(key) % uint64(c.slots)
+	for p := uint64(0); p < probeLimit && p < uint64(c.slots); p++ {
+		i := int64((start + p) % uint64(c.slots))
+		slot := c.slot(i)
+		switch slot[0] {
+		case empty:
+			return 0, false
+		case used:
+			keyLen := binary.LittleEndian.Uint32(slot[4:])
+			if int(keyLen) == len(key) && string(slot[slotHeaderSize:slotHeaderSize+int(keyLen)]) == key {
+				return i, !expired(slot, now)
+			}
+		}
+	}
+	return 0, false
+}
+
+// place returns slot for new key; the first slot which is not used or expired, or the one which expires first if every slot is used.
+// Caller should hold the locks exclusively.
+func (c *Cache) place(key string, now int64) int64 {
+	start := hash(key) % uint64(c.slots)
+	victim := int64(-1)
+	var earliest int64
+	for p := uint64(0); p < probeLimit && p < uint64(c.slots); p++ {
+		i := int64((start + p) % uint64(c.slots))
+		slot := c.slot(i)
+		if slot[0] != used || expired(slot, now) {
+			return i
+		}
+		if at := int64(binary.LittleEndian.Uint64(slot[16:])); victim < 0 || at < earliest {
+			victim, earliest = i, at
+		}
+	}
+	return victim
+}
+
+// slot returns bytes of slot i.
+func (c *Cache) slot(i int64) []byte {
+	offset := headerSize + i*int64(c.slotSize)
+	return c.data[offset : offset+int64(c.slotSize)]
+}
+
+// expired returns true if key in slot is expired at now.
+func expired(slot []byte, now int64) bool {
+	return int64(binary.LittleEndian.Uint64(slot[16:])) <= now
+}
+
+// hash is FNV-1a of key.
+func hash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
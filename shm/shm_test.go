@@ -0,0 +1,128 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package shm
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.shm")
+	config := Config{Slots: 64, MaxKey: 16, MaxValue: 16, Ttl: time.Hour}
+
+	a, err := Open(path, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	// size is taken from the segment
+	b, err := Open(path, Config{Ttl: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if hit, err := a.Put("a", []byte("1")); hit || err != nil {
+		t.Fatalf("expected new key, got %v, %v", hit, err)
+	}
+	if data, hit := b.Get("a"); !hit || string(data) != "1" {
+		t.Errorf("expected 1 from other handle, got %q, %v", data, hit)
+	}
+	if hit, _ := b.Put("a", []byte("2")); !hit {
+		t.Error("expected a to be there")
+	}
+	if data, _ := a.Get("a"); string(data) != "2" {
+		t.Errorf("expected 2, got %q", data)
+	}
+	if !b.Delete("a") {
+		t.Error("expected a to be deleted")
+	}
+	if _, hit := a.Get("a"); hit {
+		t.Error("a shouldn't be hit after delete")
+	}
+
+	if _, err := Open(path, Config{Slots: 32}); !errors.Is(err, ErrMismatch) {
+		t.Errorf("expected ErrMismatch, got %v", err)
+	}
+	if _, err := a.Put("a", make([]byte, 17)); err != ErrTooLarge {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestSharedConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.shm")
+	config := Config{Slots: 1024, MaxKey: 16, MaxValue: 16, Ttl: time.Hour}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		c, err := Open(path, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				key := strconv.Itoa(w*100 + i)
+				c.Put(key, []byte(key))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	c, err := Open(path, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Len() != 400 {
+		t.Errorf("expected 400 keys, got %d", c.Len())
+	}
+	for i := 0; i < 400; i++ {
+		key := strconv.Itoa(i)
+		if data, hit := c.Get(key); !hit || string(data) != key {
+			t.Errorf("%s: expected hit, got %q, %v", key, data, hit)
+		}
+	}
+}
+
+func TestSharedExpire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.shm")
+	c, err := Open(path, Config{Slots: 4, MaxKey: 8, MaxValue: 8, Ttl: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.PutWithTtl("short", nil, time.Minute)
+	for i := 0; i < 3; i++ {
+		c.Put(strconv.Itoa(i), nil)
+	}
+	// every slot is used, so the key which expires first is overwritten
+	c.Put("new", nil)
+	if _, hit := c.Get("short"); hit {
+		t.Error("short should be overwritten")
+	}
+	if c.Len() != 4 {
+		t.Errorf("expected 4 keys, got %d", c.Len())
+	}
+
+	c.PutWithTtl("gone", nil, -time.Second)
+	if _, hit := c.Get("gone"); hit {
+		t.Error("gone should be expired")
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Put("a", nil); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package cstorage
+
+import "sync/atomic"
+
+// sieve is SIEVE policy. Nodes stay in insertion order, and access only sets node.visited.
+// hand points the next candidate of eviction; it moves from tail to head, clearing visited on its way,
+// and wraps around to tail when it reaches head.
+type sieve struct {
+	list list
+	hand *node
+}
+
+func (p *sieve) adapt(key string) {}
+
+func (p *sieve) add(n *node) {
+	atomic.StoreInt32(&n.visited, 0)
+	p.list.pushHead(n)
+}
+
+func (p *sieve) access(n *node) {
+	atomic.StoreInt32(&n.visited, 1)
+}
+
+func (p *sieve) remove(n *node, evicted bool) {
+	if p.hand == n {
+		p.hand = p.list.prev(n)
+	}
+	p.list.remove(n)
+}
+
+func (p *sieve) victim() *node {
+	n := p.hand
+	if n == nil {
+		n = p.list.last()
+	}
+	for n != nil && atomic.LoadInt32(&n.visited) == 1 {
+		atomic.StoreInt32(&n.visited, 0)
+		n = p.list.prev(n)
+		if n == nil {
+			n = p.list.last()
+		}
+	}
+	p.hand = n
+	return n
+}
+
+func (p *sieve) each(fn func(n *node) bool) {
+	p.list.each(fn)
+}
+
+func (p *sieve) reset() {
+	p.list.clear()
+	p.hand = nil
+}
+
+func (p *sieve) resize(capacity int64) {}
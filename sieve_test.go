@@ -0,0 +1,98 @@
+package cstorage
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSIEVE(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 3
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Policy: PolicySIEVE}
+	cache := New(config)
+
+	cache.Put("key1", []byte("1"))
+	cache.Put("key2", []byte("2"))
+	cache.Put("key3", []byte("3"))
+	cache.Get("key1")
+
+	cache.Put("key4", []byte("4"))
+	if _, hit := cache.Peek("key1"); !hit {
+		t.Error("key1 is visited, it should get second chance")
+	}
+	if _, hit := cache.Peek("key2"); hit {
+		t.Error("key2 is not visited, it should be evicted")
+	}
+
+	cache.Put("key5", []byte("5"))
+	if _, hit := cache.Peek("key3"); hit {
+		t.Error("hand should move on to key3")
+	}
+
+	var keys []string
+	cache.IterateLRU(func(key string, data []byte, expiresAt time.Time) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if len(keys) != 3 || keys[0] != "key1" || keys[2] != "key5" {
+		t.Errorf("keys should be in insertion order, got %v", keys)
+	}
+}
+
+func TestSIEVEConcurrentGet(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 100
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Policy: PolicySIEVE}
+	cache := New(config)
+
+	for i := 0; i < 100; i++ {
+		cache.Put(strconv.Itoa(i), []byte("v"))
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				cache.Get(strconv.Itoa((i + g) % 150))
+				if i%100 == 0 {
+					cache.Put(strconv.Itoa(100+i%50), []byte("w"))
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	st := cache.Stats()
+	if st.Hits+st.Misses != 8000 {
+		t.Errorf("every get should be counted, got %+v", st)
+	}
+}
+
+func benchmarkParallelGet(b *testing.B, policy Policy) {
+	config := CStorageConfig{Ttl: time.Hour, Capacity: 1000, Policy: policy}
+	cache := New(config)
+	for i := 0; i < 1000; i++ {
+		cache.Put(strconv.Itoa(i), []byte("v"))
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			cache.Get(strconv.Itoa(i % 1000))
+			i++
+		}
+	})
+}
+
+func BenchmarkParallelGetLRU(b *testing.B) {
+	benchmarkParallelGet(b, PolicyLRU)
+}
+
+func BenchmarkParallelGetSIEVE(b *testing.B) {
+	benchmarkParallelGet(b, PolicySIEVE)
+}
@@ -0,0 +1,156 @@
+// Package signing signs and verifies messages between nodes, such as webhooks, invalidations and replication, with HMAC-SHA256,
+// so spoofed or tampered messages are rejected in shared network.
+//
+// Keyring holds the key to sign with and every keys accepted for verification, so keys can be rotated without downtime:
+// add new key to every nodes with Add, switch signing key with Rotate, and then Retire old key.
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cocm1324/cstorage/httpapi"
+)
+
+// Errors returned by Open.
+var (
+	ErrMalformed  = errors.New("signing: malformed message")
+	ErrUnknownKey = errors.New("signing: unknown key")
+	ErrSignature  = errors.New("signing: invalid signature")
+	ErrExpired    = errors.New("signing: message is too old or from the future")
+)
+
+// Key structure is secret with its id. ID is sent with signature, so it must not be secret.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Sealed message layout
+// - version(1), key id length(1), key id, unix nano of signing(8), payload
+// - HMAC-SHA256 of everything above(32)
+const (
+	version = 1
+	macSize = sha256.Size
+)
+
+// Keyring structure holds signing key and accepted keys. It is safe for concurrent use.
+type Keyring struct {
+	mutex   sync.RWMutex
+	current Key
+	keys    map[string][]byte
+}
+
+// NewKeyring function returns Keyring which signs with current. Key ids should be shorter than 256 bytes.
+func NewKeyring(current Key) *Keyring {
+	return &Keyring{current: current, keys: map[string][]byte{current.ID: current.Secret}}
+}
+
+// Add function adds key to be accepted on verification, without signing with it.
+// It is the first step of rotation, so every nodes can verify messages signed with the key before anyone signs with it.
+func (k *Keyring) Add(key Key) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.keys[key.ID] = key.Secret
+}
+
+// Rotate function makes key the signing key. Previous signing key is still accepted until it is retired.
+func (k *Keyring) Rotate(key Key) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.keys[key.ID] = key.Secret
+	k.current = key
+}
+
+// Retire function stops accepting key of id. Signing key can't be retired.
+func (k *Keyring) Retire(id string) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if id != k.current.ID {
+		delete(k.keys, id)
+	}
+}
+
+// Lookup function returns accepted key of id.
+func (k *Keyring) Lookup(id string) (secret []byte, ok bool) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	secret, ok = k.keys[id]
+	return secret, ok
+}
+
+// signer returns signing key.
+func (k *Keyring) signer() Key {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	return k.current
+}
+
+// Seal function signs payload with signing key and returns sealed message, which has payload and signature.
+// Payload is not encrypted.
+func (k *Keyring) Seal(payload []byte) []byte {
+	key := k.signer()
+
+	msg := make([]byte, 0, 2+len(key.ID)+8+len(payload)+macSize)
+	msg = append(msg, version, byte(len(key.ID)))
+	msg = append(msg, key.ID...)
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(time.Now().UnixNano()))
+	msg = append(msg, ts[:]...)
+	msg = append(msg, payload...)
+
+	mac := hmac.New(sha256.New, key.Secret)
+	mac.Write(msg)
+	return mac.Sum(msg)
+}
+
+// Open function verifies sealed message and returns its payload. Message signed more than maxAge ago(or later than maxAge from now)
+// is rejected, to limit replay. maxAge of 0 doesn't check age.
+func (k *Keyring) Open(sealed []byte, maxAge time.Duration) (payload []byte, err error) {
+	if len(sealed) < 2+8+macSize || sealed[0] != version {
+		return nil, ErrMalformed
+	}
+	idLen := int(sealed[1])
+	if len(sealed) < 2+idLen+8+macSize {
+		return nil, ErrMalformed
+	}
+
+	secret, ok := k.Lookup(string(sealed[2 : 2+idLen]))
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	body, sig := sealed[:len(sealed)-macSize], sealed[len(sealed)-macSize:]
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), sig) {
+		return nil, ErrSignature
+	}
+
+	signed := time.Unix(0, int64(binary.BigEndian.Uint64(body[2+idLen:])))
+	if d := time.Since(signed); maxAge > 0 && (d > maxAge || d < -maxAge) {
+		return nil, ErrExpired
+	}
+	return body[2+idLen+8:], nil
+}
+
+// SignRequest function signs HTTP request with signing key, see httpapi.SignRequest.
+func (k *Keyring) SignRequest(r *http.Request) error {
+	key := k.signer()
+	return httpapi.SignRequest(r, key.ID, key.Secret)
+}
+
+// Authenticator function returns httpapi.HMAC which verifies requests with accepted keys of Keyring, including keys added later.
+func (k *Keyring) Authenticator(maxSkew time.Duration) httpapi.HMAC {
+	return httpapi.HMAC{Lookup: k.Lookup, MaxSkew: maxSkew}
+}
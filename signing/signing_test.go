@@ -0,0 +1,71 @@
+package signing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyring(t *testing.T) {
+	old := Key{ID: "2025", Secret: []byte("old secret")}
+	next := Key{ID: "2026", Secret: []byte("new secret")}
+	sender := NewKeyring(old)
+	receiver := NewKeyring(old)
+
+	sealed := sender.Seal([]byte("invalidate user:42"))
+	payload, err := receiver.Open(sealed, time.Minute)
+	if err != nil || string(payload) != "invalidate user:42" {
+		t.Fatalf("expected payload, got %q, %v", payload, err)
+	}
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-macSize-1] ^= 1
+	if _, err := receiver.Open(tampered, time.Minute); err != ErrSignature {
+		t.Errorf("tampered message should be rejected, got %v", err)
+	}
+	if _, err := receiver.Open(sealed[:5], time.Minute); err != ErrMalformed {
+		t.Errorf("short message should be malformed, got %v", err)
+	}
+	time.Sleep(time.Millisecond * 2)
+	if _, err := receiver.Open(sealed, time.Millisecond); err != ErrExpired {
+		t.Errorf("old message should be rejected, got %v", err)
+	}
+
+	// rotation: receiver accepts new key first, then sender switches to it
+	sender.Rotate(next)
+	if _, err := receiver.Open(sender.Seal(nil), time.Minute); err != ErrUnknownKey {
+		t.Errorf("message with unknown key should be rejected, got %v", err)
+	}
+	receiver.Add(next)
+	if _, err := receiver.Open(sender.Seal(nil), time.Minute); err != nil {
+		t.Errorf("message with new key should be accepted, got %v", err)
+	}
+	if _, err := receiver.Open(sealed, 0); err != nil {
+		t.Errorf("message with old key should be accepted until it is retired, got %v", err)
+	}
+	receiver.Rotate(next)
+	receiver.Retire("2025")
+	if _, err := receiver.Open(sealed, 0); err != ErrUnknownKey {
+		t.Errorf("message with retired key should be rejected, got %v", err)
+	}
+}
+
+func TestKeyringRequest(t *testing.T) {
+	ring := NewKeyring(Key{ID: "a", Secret: []byte("secret a")})
+	auth := ring.Authenticator(time.Minute)
+
+	r := httptest.NewRequest("PUT", "/keys/x", strings.NewReader("data"))
+	ring.SignRequest(r)
+	if principal, err := auth.Authenticate(r); err != nil || principal != "a" {
+		t.Errorf("signed request should be accepted, got %q, %v", principal, err)
+	}
+
+	ring.Rotate(Key{ID: "b", Secret: []byte("secret b")})
+	r = httptest.NewRequest(http.MethodDelete, "/keys/x", nil)
+	ring.SignRequest(r)
+	if principal, err := auth.Authenticate(r); err != nil || principal != "b" {
+		t.Errorf("request signed with rotated key should be accepted, got %q, %v", principal, err)
+	}
+}
@@ -0,0 +1,141 @@
+// Package simulate replays access trace against configurations of CStorage and reports hit ratio of each,
+// so configuration(policy, capacity and ttl) can be chosen from production traces instead of guessing.
+//
+// Replay is cache-aside: every access is Get, and key is put on miss. Time of trace is given to CStorage by manual clock,
+// so ttl works as in production without waiting. See ReadTrace for format of trace, and cmd/cstorage-simulate for command line tool.
+package simulate
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+// Forever is ttl which doesn't expire during any trace. Ttl 0 in Run means it.
+const Forever = 100 * 365 * 24 * time.Hour
+
+// Policy structure is named configuration of CStorage, which is simulated with each capacity and ttl. Capacity and Ttl of Config are overwritten.
+type Policy struct {
+	Name   string
+	Config cstorage.CStorageConfig
+}
+
+var policies = map[string]cstorage.Policy{
+	"lru":     cstorage.PolicyLRU,
+	"slru":    cstorage.PolicySLRU,
+	"arc":     cstorage.PolicyARC,
+	"sieve":   cstorage.PolicySIEVE,
+	"sampled": cstorage.PolicySampled,
+}
+
+// ParsePolicy function is to make Policy by name, which is one of "lru", "slru", "arc", "sieve" and "sampled".
+// Name with "+tinylfu"(e.g. "slru+tinylfu") puts TinyLFU admission filter in front of the policy, and "tinylfu" alone is "lru+tinylfu".
+func ParsePolicy(name string) (Policy, error) {
+	var config cstorage.CStorageConfig
+	base := strings.TrimSuffix(name, "+tinylfu")
+	if base == "tinylfu" {
+		base = "lru"
+	}
+	config.TinyLFU = base != name || name == "tinylfu"
+
+	policy, ok := policies[base]
+	if !ok {
+		return Policy{}, fmt.Errorf("simulate: unknown policy %q", name)
+	}
+	config.Policy = policy
+	return Policy{Name: name, Config: config}, nil
+}
+
+// Result structure is outcome of replay of trace with a configuration.
+type Result struct {
+	Policy   string
+	Capacity int64
+	Ttl      time.Duration
+	Hits     int64
+	Misses   int64
+	Expired  int64
+	Evicted  int64
+}
+
+// HitRatio function returns ratio of hits among accesses. It returns 0 if there was no access.
+func (r Result) HitRatio() float64 {
+	total := r.Hits + r.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(total)
+}
+
+// Simulate function is to replay trace against CStorage made by config, and returns the result.
+// Capacity and Ttl of config should be set, and Clock is replaced by manual clock which follows time of trace.
+func Simulate(trace []Access, config cstorage.CStorageConfig) Result {
+	start := time.Unix(0, 0)
+	clock := testutil.NewClock(start)
+	config.Clock = clock
+	cache := cstorage.New(config)
+	defer cache.Close()
+
+	var result Result
+	value := []byte{}
+	for _, a := range trace {
+		clock.Set(start.Add(a.Time))
+		if _, hit := cache.Get(a.Key); hit {
+			result.Hits++
+			continue
+		}
+		result.Misses++
+		cache.Put(a.Key, value)
+	}
+
+	st := cache.Stats()
+	result.Capacity = config.Capacity
+	result.Ttl = config.Ttl
+	result.Expired = st.Expired
+	result.Evicted = st.Evicted
+	return result
+}
+
+// Run function is to simulate every combination of policies, capacities and ttls, in parallel, and returns results in the order of
+// policies, then capacities, then ttls. Ttl 0 is Forever, and it is reported as 0.
+func Run(trace []Access, policies []Policy, capacities []int64, ttls []time.Duration) []Result {
+	if len(ttls) == 0 {
+		ttls = []time.Duration{0}
+	}
+
+	results := make([]Result, len(policies)*len(capacities)*len(ttls))
+	slots := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	i := 0
+	for _, p := range policies {
+		for _, capacity := range capacities {
+			for _, ttl := range ttls {
+				config := p.Config
+				config.Capacity = capacity
+				config.Ttl = ttl
+				if ttl == 0 {
+					config.Ttl = Forever
+				}
+
+				wg.Add(1)
+				slots <- struct{}{}
+				go func(i int, name string, ttl time.Duration) {
+					defer func() {
+						<-slots
+						wg.Done()
+					}()
+					results[i] = Simulate(trace, config)
+					results[i].Policy = name
+					results[i].Ttl = ttl
+				}(i, p.Name, ttl)
+				i++
+			}
+		}
+	}
+	wg.Wait()
+	return results
+}
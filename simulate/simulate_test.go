@@ -0,0 +1,84 @@
+package simulate
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadTrace(t *testing.T) {
+	trace, err := ReadTrace(strings.NewReader("# comment\n1700000000 a\n\n1700000001.5 b\nc\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Access{{0, "a"}, {1500 * time.Millisecond, "b"}, {1500 * time.Millisecond, "c"}}
+	if len(trace) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, trace)
+	}
+	for i := range expected {
+		if trace[i] != expected[i] {
+			t.Errorf("access %d: expected %v, got %v", i, expected[i], trace[i])
+		}
+	}
+
+	for _, bad := range []string{"x a\n", "2 a\n1 b\n", "1 a b\n"} {
+		if _, err := ReadTrace(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected error of %q", bad)
+		}
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, name := range []string{"lru", "slru", "arc", "sieve", "sampled", "tinylfu", "slru+tinylfu"} {
+		p, err := ParsePolicy(name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if tinyLFU := strings.Contains(name, "tinylfu"); p.Config.TinyLFU != tinyLFU {
+			t.Errorf("%s: expected TinyLFU %v", name, tinyLFU)
+		}
+	}
+	if _, err := ParsePolicy("lfu"); err == nil {
+		t.Error("expected error of unknown policy")
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	// loop over one more key than capacity is the worst case of LRU, every access misses
+	var trace []Access
+	for i := 0; i < 1000; i++ {
+		trace = append(trace, Access{Key: strconv.Itoa(i % 11)})
+	}
+
+	lru, _ := ParsePolicy("lru")
+	arc, _ := ParsePolicy("arc")
+	results := Run(trace, []Policy{lru, arc}, []int64{10, 20}, nil)
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+
+	if r := results[0]; r.Policy != "lru" || r.Capacity != 10 || r.Hits != 0 || r.Misses != 1000 {
+		t.Errorf("expected every access of lru to miss, got %+v", r)
+	}
+	if r := results[1]; r.Policy != "lru" || r.Capacity != 20 || r.Misses != 11 {
+		t.Errorf("expected only cold misses when every key fits, got %+v", r)
+	}
+	if r := results[2]; r.Policy != "arc" || r.HitRatio() <= results[0].HitRatio() {
+		t.Errorf("expected arc to beat lru on loop, got %+v", r)
+	}
+}
+
+func TestSimulateTtl(t *testing.T) {
+	trace := []Access{{0, "a"}, {time.Second, "a"}, {10 * time.Second, "a"}}
+
+	lru, _ := ParsePolicy("lru")
+	results := Run(trace, []Policy{lru}, []int64{10}, []time.Duration{0, 5 * time.Second})
+	if r := results[0]; r.Ttl != 0 || r.Hits != 2 {
+		t.Errorf("expected 2 hits without ttl, got %+v", r)
+	}
+	if r := results[1]; r.Ttl != 5*time.Second || r.Hits != 1 || r.Expired != 1 {
+		t.Errorf("expected key to expire, got %+v", r)
+	}
+}
@@ -0,0 +1,61 @@
+package simulate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Access structure is one read of trace. Time is time since the first access of trace.
+type Access struct {
+	Time time.Duration
+	Key  string
+}
+
+// ReadTrace function is to read trace from r. Each line is one access, which is either "key" or "time key",
+// where time is seconds(e.g. unix time "1700000000.25") and it is counted from time of the first access.
+// Access without time happens at the time of previous access. Keys can't contain whitespace.
+// Empty lines and lines starting with "#" are skipped.
+func ReadTrace(r io.Reader) ([]Access, error) {
+	var trace []Access
+	var start, last time.Duration
+	started := false
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		switch len(fields) {
+		case 1:
+			trace = append(trace, Access{Time: last, Key: fields[0]})
+		case 2:
+			seconds, err := strconv.ParseFloat(fields[0], 64)
+			if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+				return nil, fmt.Errorf("simulate: line %d: invalid time %q", line, fields[0])
+			}
+			at := time.Duration(seconds * float64(time.Second))
+			if !started {
+				start, started = at, true
+			}
+			if at-start < last {
+				return nil, fmt.Errorf("simulate: line %d: time goes backward", line)
+			}
+			last = at - start
+			trace = append(trace, Access{Time: last, Key: fields[1]})
+		default:
+			return nil, fmt.Errorf("simulate: line %d: expected \"key\" or \"time key\"", line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
@@ -0,0 +1,92 @@
+package cstorage
+
+// segments of slru, stored in node.segment
+const (
+	probation uint8 = iota
+	protected
+)
+
+// slru is segmented LRU policy. It has two lists; probation and protected.
+// - New node is put into probation
+// - Node in probation is promoted to protected when it is accessed again
+// - If protected exceeds its capacity, its least recently used node is demoted to probation
+// - Victim is the tail of probation, or tail of protected if probation is empty
+type slru struct {
+	probation    list
+	protected    list
+	protectedCap int64
+	ratio        float64
+}
+
+// newSLRU makes slru which gives ratio of capacity to protected segment.
+func newSLRU(capacity int64, ratio float64, ns *nodes) *slru {
+	if ratio <= 0 || ratio >= 1 {
+		ratio = 0.8
+	}
+	p := &slru{probation: newList(ns), protected: newList(ns), ratio: ratio}
+	p.resize(capacity)
+	return p
+}
+
+func (p *slru) adapt(key string) {}
+
+func (p *slru) add(n *node) {
+	n.segment = probation
+	p.probation.pushHead(n)
+}
+
+func (p *slru) access(n *node) {
+	if n.segment == protected {
+		p.protected.setHead(n)
+		return
+	}
+
+	p.probation.remove(n)
+	n.segment = protected
+	p.protected.pushHead(n)
+	p.demote()
+}
+
+// demote moves least recently used nodes of protected to probation while protected exceeds its capacity.
+func (p *slru) demote() {
+	for p.protected.len > p.protectedCap {
+		demoted := p.protected.last()
+		p.protected.remove(demoted)
+		demoted.segment = probation
+		p.probation.pushHead(demoted)
+	}
+}
+
+func (p *slru) remove(n *node, evicted bool) {
+	if n.segment == protected {
+		p.protected.remove(n)
+		return
+	}
+	p.probation.remove(n)
+}
+
+func (p *slru) victim() *node {
+	if n := p.probation.last(); n != nil {
+		return n
+	}
+	return p.protected.last()
+}
+
+func (p *slru) each(fn func(n *node) bool) {
+	if p.probation.each(fn) {
+		p.protected.each(fn)
+	}
+}
+
+func (p *slru) reset() {
+	p.probation.clear()
+	p.protected.clear()
+}
+
+func (p *slru) resize(capacity int64) {
+	p.protectedCap = int64(float64(capacity) * p.ratio)
+	if p.protectedCap < 1 {
+		p.protectedCap = 1
+	}
+	p.demote()
+}
@@ -0,0 +1,52 @@
+package cstorage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSLRU(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Policy: PolicySLRU, ProtectedRatio: 0.5}
+	cache := New(config)
+
+	for i := 0; i < 5; i++ {
+		key := "hot" + strconv.Itoa(i)
+		cache.Put(key, []byte(key))
+		cache.Get(key)
+	}
+
+	for i := 0; i < 100; i++ {
+		key := "scan" + strconv.Itoa(i)
+		cache.Put(key, []byte(key))
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, hit := cache.Get("hot" + strconv.Itoa(i)); !hit {
+			t.Errorf("hot%d is protected, it should survive scan", i)
+		}
+	}
+	if cache.Size() != 10 {
+		t.Errorf("size should be 10, got %d", cache.Size())
+	}
+
+	cache.Put("new", []byte("new"))
+	cache.Get("new")
+	var count int
+	cache.IterateLRU(func(key string, data []byte, expiresAt time.Time) bool {
+		count++
+		return true
+	})
+	if count != 10 {
+		t.Errorf("iteration should visit every keys, got %d", count)
+	}
+	if _, hit := cache.Peek("hot0"); !hit {
+		t.Error("demoted hot0 should still be in probation")
+	}
+}
+
+func BenchmarkHitRatioSLRU(b *testing.B) {
+	benchmarkHitRatio(b, CStorageConfig{Policy: PolicySLRU})
+}
@@ -0,0 +1,367 @@
+package cstorage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SnapshotVersion is version of snapshot format written by WriteSnapshot. Snapshot of newer version is refused by ReadSnapshot
+// with *SnapshotVersionError, and snapshot without header, which is written before versioning, is read as version 0.
+//
+// Snapshot is header and records. Header is magic "CSTSNAP\x00" | version(2) | number of records(8) | CRC32 of them(4),
+// and each record is framed by its length(4) and CRC32(4), same as records of append-only log, see CStorageConfig.AOFPath.
+// Since version 2, records have extension between key and data, which is lifetime and sliding of the key, and metadata given by PutWithMeta.
+// Metadata is not encrypted by CStorageConfig.Encryption, same as key.
+const SnapshotVersion = 2
+
+// snapshotMagic starts snapshot of version 1 and later. Snapshot of version 0 is gob stream, which can't start with it.
+var snapshotMagic = []byte("CSTSNAP\x00")
+
+// ErrSnapshotCorrupt is returned by ReadSnapshot when header or record of snapshot doesn't match its checksum.
+// Corrupt records can be skipped by CStorageConfig.SnapshotSkipCorrupt.
+var ErrSnapshotCorrupt = errors.New("cstorage: snapshot is corrupt")
+
+// ErrSnapshotTruncated is returned by ReadSnapshot when snapshot ends before the number of records in its header.
+// Keys of records before the end are put.
+var ErrSnapshotTruncated = errors.New("cstorage: snapshot is truncated")
+
+// SnapshotVersionError is returned by ReadSnapshot when snapshot is written by newer version of CStorage, which it can't read.
+type SnapshotVersionError struct {
+	Version uint16
+}
+
+func (e *SnapshotVersionError) Error() string {
+	return fmt.Sprintf("cstorage: snapshot version %d is not supported, up to %d", e.Version, SnapshotVersion)
+}
+
+// record is an entry of snapshot written by WriteSnapshot. Flags, Meta, Lifetime and Sliding are added later, and they are zero when snapshot
+// of older version is read. Sealed is true if Data is encrypted by CStorageConfig.Encryption.
+type record struct {
+	Key       string
+	Data      []byte
+	ExpiresAt time.Time
+	Flags     uint32
+	Sealed    bool
+	Meta      map[string]string
+	Lifetime  time.Duration
+	Sliding   bool
+}
+
+// WriteSnapshot function writes every key of CStorage to w in eviction order, so recency is kept when it is read back by ReadSnapshot.
+// Keys are copied under the lock, and written after the lock is released. See SnapshotVersion for the format.
+func (s *CStorage) WriteSnapshot(w io.Writer) (count int, err error) {
+	return s.WriteSnapshotWithProgress(w, nil)
+}
+
+// WriteSnapshotWithProgress function is same as WriteSnapshot, but progress is called while keys are written, with number of keys copied.
+// If progress returns false, it stops with ErrCanceled, and w has part of snapshot, which is read as truncated.
+func (s *CStorage) WriteSnapshotWithProgress(w io.Writer, progress ProgressFunc) (count int, err error) {
+	s.mutex.RLock()
+	if s.closed {
+		s.mutex.RUnlock()
+		return 0, ErrClosed
+	}
+	items := s.items()
+	s.mutex.RUnlock()
+
+	return s.writeSnapshot(w, items, progress)
+}
+
+// ReadSnapshot function puts keys in snapshot from r into CStorage with their remaining ttl. Expired keys are skipped.
+// It returns ErrSnapshotCorrupt or ErrSnapshotTruncated if snapshot is damaged, and *SnapshotVersionError if it is of newer format.
+// Snapshot written by older versions of CStorage, without header, is read as well, but damage of it may not be detected.
+func (s *CStorage) ReadSnapshot(r io.Reader) (count int, err error) {
+	return s.ReadSnapshotWithProgress(r, nil)
+}
+
+// ReadSnapshotWithProgress function is same as ReadSnapshot, but progress is called while keys are read, with number of records read so far
+// including expired ones, and number of records in snapshot. total is 0 for snapshot without header. If progress returns false, it stops
+// with ErrCanceled, and keys read so far are kept in CStorage.
+func (s *CStorage) ReadSnapshotWithProgress(r io.Reader, progress ProgressFunc) (count int, err error) {
+	start := time.Now()
+	count, err = s.readSnapshot(r, progress)
+	s.logSnapshot(false, count, time.Since(start), err)
+	return count, err
+}
+
+// readSnapshot reads snapshot from r. progress is optional.
+func (s *CStorage) readSnapshot(r io.Reader, progress ProgressFunc) (count int, err error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(snapshotMagic)); !bytes.Equal(magic, snapshotMagic) {
+		return s.readGobSnapshot(br, progress)
+	}
+
+	var header [22]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return 0, fmt.Errorf("%w: header is incomplete", ErrSnapshotTruncated)
+	}
+	if crc32.ChecksumIEEE(header[:18]) != binary.BigEndian.Uint32(header[18:]) {
+		return 0, fmt.Errorf("%w: header doesn't match checksum", ErrSnapshotCorrupt)
+	}
+	if version := binary.BigEndian.Uint16(header[8:10]); version > SnapshotVersion {
+		return 0, &SnapshotVersionError{Version: version}
+	}
+	total := int64(binary.BigEndian.Uint64(header[10:18]))
+
+	var frame [8]byte
+	var payload bytes.Buffer
+	for read := int64(0); read < total; read++ {
+		if progress != nil && read > 0 && read%progressBatch == 0 && !progress(read, total) {
+			return count, ErrCanceled
+		}
+
+		payload.Reset()
+		if _, err := io.ReadFull(br, frame[:]); err != nil {
+			return count, fmt.Errorf("%w: %d of %d records", ErrSnapshotTruncated, read, total)
+		}
+		// payload grows as it is read, so broken length doesn't allocate more than the rest of snapshot
+		if n, err := io.CopyN(&payload, br, int64(binary.BigEndian.Uint32(frame[:4]))); err != nil {
+			if err == io.EOF {
+				return count, fmt.Errorf("%w: %d of %d records", ErrSnapshotTruncated, read, total)
+			}
+			return count, err
+		} else if n == 0 {
+			return count, fmt.Errorf("%w: record %d is empty", ErrSnapshotCorrupt, read)
+		}
+
+		op, rec, err := decodeRecord(payload.Bytes())
+		if crc32.ChecksumIEEE(payload.Bytes()) != binary.BigEndian.Uint32(frame[4:]) || err != nil || (op != aofPut && op != aofPutExt) {
+			if !s.config.SnapshotSkipCorrupt {
+				return count, fmt.Errorf("%w: record %d doesn't match checksum", ErrSnapshotCorrupt, read)
+			}
+			s.mutex.Lock()
+			s.stats.CorruptRecords++
+			s.mutex.Unlock()
+			continue
+		}
+		s.mutex.Lock()
+		put, err := s.restore(rec)
+		s.mutex.Unlock()
+		if err != nil {
+			return count, err
+		}
+		if put {
+			count++
+		}
+	}
+	if progress != nil {
+		progress(total, total)
+	}
+	return count, nil
+}
+
+// readGobSnapshot reads snapshot of version 0 from r, which is gob stream of record. progress is optional.
+func (s *CStorage) readGobSnapshot(r io.Reader, progress ProgressFunc) (count int, err error) {
+	dec := gob.NewDecoder(r)
+	var read int64
+	for ; ; read++ {
+		if progress != nil && read > 0 && read%progressBatch == 0 && !progress(read, 0) {
+			return count, ErrCanceled
+		}
+
+		var rec record
+		if err := dec.Decode(&rec); err != nil {
+			if err == io.EOF {
+				if progress != nil {
+					progress(read, read)
+				}
+				return count, nil
+			}
+			return count, err
+		}
+		s.mutex.Lock()
+		put, err := s.restore(rec)
+		s.mutex.Unlock()
+		if err != nil {
+			return count, err
+		}
+		if put {
+			count++
+		}
+	}
+}
+
+// restore puts key of rec with its remaining ttl, opening data if it is sealed. It returns false if rec is expired or key is not put.
+// Caller should hold the mutex.
+func (s *CStorage) restore(rec record) (put bool, err error) {
+	if s.closed {
+		return false, ErrClosed
+	}
+	if rec.Sealed {
+		if s.sealer == nil {
+			return false, ErrDecrypt
+		}
+		if rec.Data, err = s.sealer.open(rec.Data); err != nil {
+			return false, err
+		}
+	}
+	ttl := rec.ExpiresAt.Sub(s.now())
+	if ttl <= 0 {
+		return false, nil
+	}
+	n := s.putRemaining(rec.Key, rec.Data, ttl, rec.Lifetime, rec.Sliding, rec.Meta)
+	if n == nil {
+		return false, nil
+	}
+	if rec.Flags != 0 {
+		n.flags = rec.Flags
+	}
+	if rec.Lifetime > 0 || rec.Flags != 0 {
+		s.logPut(n)
+	}
+	return true, nil
+}
+
+// putRemaining puts key which expires after ttl, with lifetime and sliding it was put with, so sliding key is renewed by its lifetime.
+// If lifetime is zero, which is the case of older snapshot or hand-written export, ttl becomes lifetime with Sliding of CStorageConfig.
+// It returns nil if key is not put. Caller should hold the mutex.
+func (s *CStorage) putRemaining(key string, data []byte, ttl, lifetime time.Duration, sliding bool, meta map[string]string) *node {
+	if lifetime <= 0 {
+		n, _ := s.putMeta(key, s.hash(key), data, ttl, s.config.Sliding, meta)
+		return n
+	}
+	n, _ := s.putMeta(key, s.hash(key), data, lifetime, sliding, meta)
+	if n != nil {
+		s.reschedule(n, s.now().Add(ttl))
+	}
+	return n
+}
+
+// writeSnapshot encodes items to w, and logs it. progress is optional.
+func (s *CStorage) writeSnapshot(w io.Writer, items []item, progress ProgressFunc) (count int, err error) {
+	start := time.Now()
+	count, err = s.encodeSnapshot(w, items, progress)
+	s.logSnapshot(true, count, time.Since(start), err)
+	return count, err
+}
+
+// encodeSnapshot encodes items to w. progress is optional.
+func (s *CStorage) encodeSnapshot(w io.Writer, items []item, progress ProgressFunc) (count int, err error) {
+	bw := bufio.NewWriter(w)
+	total := int64(len(items))
+	var header [22]byte
+	copy(header[:], snapshotMagic)
+	binary.BigEndian.PutUint16(header[8:], SnapshotVersion)
+	binary.BigEndian.PutUint64(header[10:], uint64(total))
+	binary.BigEndian.PutUint32(header[18:], crc32.ChecksumIEEE(header[:18]))
+	if _, err := bw.Write(header[:]); err != nil {
+		return 0, err
+	}
+
+	var frame, ext []byte
+	for _, it := range items {
+		if frame, err = s.encodeRecord(frame[:0], aofPutExt, it.key, it.data, it.expiresAt, it.flags, encodeExtension(ext[:0], it)); err != nil {
+			return count, err
+		}
+		if _, err := bw.Write(frame); err != nil {
+			return count, err
+		}
+		count++
+		if progress != nil && count%progressBatch == 0 && !progress(int64(count), total) {
+			bw.Flush()
+			return count, ErrCanceled
+		}
+	}
+	if progress != nil {
+		progress(total, total)
+	}
+	return count, bw.Flush()
+}
+
+// encodeExtension appends extension of record of it to ext, which is sliding(1) | lifetime in nanoseconds(uvarint) |
+// number of metadata(uvarint) | length(uvarint) and bytes of each name and value.
+func encodeExtension(ext []byte, it item) []byte {
+	var length [binary.MaxVarintLen64]byte
+	var sliding byte
+	if it.sliding {
+		sliding = 1
+	}
+	ext = append(ext, sliding)
+	ext = append(ext, length[:binary.PutUvarint(length[:], uint64(it.lifetime))]...)
+	appendString := func(str string) {
+		ext = append(ext, length[:binary.PutUvarint(length[:], uint64(len(str)))]...)
+		ext = append(ext, str...)
+	}
+	ext = append(ext, length[:binary.PutUvarint(length[:], uint64(len(it.meta)))]...)
+	for k, v := range it.meta {
+		appendString(k)
+		appendString(v)
+	}
+	return ext
+}
+
+// decodeExtension parses extension written by encodeExtension into rec.
+func decodeExtension(ext []byte, rec *record) error {
+	invalid := errors.New("cstorage: record has invalid extension")
+	readString := func() (string, bool) {
+		length, n := binary.Uvarint(ext)
+		if n <= 0 || uint64(len(ext)-n) < length {
+			return "", false
+		}
+		str := string(ext[n : n+int(length)])
+		ext = ext[n+int(length):]
+		return str, true
+	}
+	if len(ext) == 0 {
+		return invalid
+	}
+	rec.Sliding = ext[0] == 1
+	lifetime, n := binary.Uvarint(ext[1:])
+	if n <= 0 || lifetime > math.MaxInt64 {
+		return invalid
+	}
+	rec.Lifetime = time.Duration(lifetime)
+	ext = ext[1+n:]
+	count, n := binary.Uvarint(ext)
+	if n <= 0 || count > uint64(len(ext)) {
+		return invalid
+	}
+	ext = ext[n:]
+	if count > 0 {
+		rec.Meta = make(map[string]string, count)
+	}
+	for i := uint64(0); i < count; i++ {
+		k, ok := readString()
+		if !ok {
+			return invalid
+		}
+		v, ok := readString()
+		if !ok {
+			return invalid
+		}
+		rec.Meta[k] = v
+	}
+	return nil
+}
+
+// saveSnapshot writes items to path. It is written to temporary file and renamed, so path has either old or new snapshot even if the process crashes.
+func (s *CStorage) saveSnapshot(path string, items []item) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := s.writeSnapshot(f, items, nil); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
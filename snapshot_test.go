@@ -0,0 +1,182 @@
+package cstorage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"hash/crc32"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+func TestSnapshotCorruption(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+	cache.Put("a", []byte("1"))
+	cache.Put("b", []byte("2"))
+	cache.Put("c", []byte("3"))
+
+	var buf bytes.Buffer
+	if _, err := cache.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := buf.Bytes()
+
+	// flip the last byte, which is data of c
+	corrupt := append([]byte(nil), snapshot...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	restored := New(config)
+	if _, err := restored.ReadSnapshot(bytes.NewReader(corrupt)); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("corrupt record should be detected, got %v", err)
+	}
+
+	config.SnapshotSkipCorrupt = true
+	restored = New(config)
+	if count, err := restored.ReadSnapshot(bytes.NewReader(corrupt)); err != nil || count != 2 {
+		t.Errorf("corrupt record should be skipped, got %d %v", count, err)
+	}
+	if _, hit := restored.Get("c"); hit {
+		t.Error("corrupt c should not be put")
+	}
+	if corrupted := restored.Stats().CorruptRecords; corrupted != 1 {
+		t.Errorf("skipped record should be counted, got %d", corrupted)
+	}
+
+	restored = New(config)
+	if count, err := restored.ReadSnapshot(bytes.NewReader(snapshot[:len(snapshot)-3])); !errors.Is(err, ErrSnapshotTruncated) || count != 2 {
+		t.Errorf("truncation should be detected, got %d %v", count, err)
+	}
+
+	header := append([]byte(nil), snapshot...)
+	header[12] ^= 0xff
+	if _, err := New(config).ReadSnapshot(bytes.NewReader(header)); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("corrupt header should be detected, got %v", err)
+	}
+}
+
+func TestSnapshotVersion(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+	cache.Put("a", []byte("1"))
+
+	var buf bytes.Buffer
+	cache.WriteSnapshot(&buf)
+	newer := buf.Bytes()
+	binary.BigEndian.PutUint16(newer[8:], SnapshotVersion+1)
+	binary.BigEndian.PutUint32(newer[18:], crc32.ChecksumIEEE(newer[:18]))
+
+	var versionErr *SnapshotVersionError
+	if _, err := New(config).ReadSnapshot(bytes.NewReader(newer)); !errors.As(err, &versionErr) || versionErr.Version != SnapshotVersion+1 {
+		t.Errorf("newer version should be refused, got %v", err)
+	}
+}
+
+func TestSnapshotMeta(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Encryption: NewKeys(1, bytes.Repeat([]byte{1}, 32))}
+	cache := New(config)
+	cache.PutWithMeta("a", []byte("1"), map[string]string{"origin": "billing", "": "empty"})
+	cache.Put("b", []byte("2"))
+
+	var buf bytes.Buffer
+	if _, err := cache.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(config)
+	if count, err := restored.ReadSnapshot(&buf); err != nil || count != 2 {
+		t.Fatalf("expected 2 keys read, got %d %v", count, err)
+	}
+	if info, _ := restored.GetWithInfo("a"); string(info.Data) != "1" || info.Meta["origin"] != "billing" || info.Meta[""] != "empty" || len(info.Meta) != 2 {
+		t.Errorf("a should be read with metadata, got %q %v", info.Data, info.Meta)
+	}
+	if info, _ := restored.GetWithInfo("b"); string(info.Data) != "2" || info.Meta != nil {
+		t.Errorf("b should be read without metadata, got %q %v", info.Data, info.Meta)
+	}
+
+	// snapshot of version 1 has records without extension
+	var header [22]byte
+	copy(header[:], snapshotMagic)
+	binary.BigEndian.PutUint16(header[8:], 1)
+	binary.BigEndian.PutUint64(header[10:], 1)
+	binary.BigEndian.PutUint32(header[18:], crc32.ChecksumIEEE(header[:18]))
+	v1, err := cache.encodeRecord(header[:], aofPut, "c", []byte("3"), time.Now().Add(time.Minute), 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored = New(config)
+	if count, err := restored.ReadSnapshot(bytes.NewReader(v1)); err != nil || count != 1 {
+		t.Fatalf("snapshot of version 1 should be read, got %d %v", count, err)
+	}
+	if data, flags, _ := restored.GetWithFlags("c"); string(data) != "3" || flags != 5 {
+		t.Errorf("c should be read with flags, got %q %d", data, flags)
+	}
+}
+
+func TestSnapshotSliding(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock}
+	cache := New(config)
+	cache.PutSliding("a", []byte("1"))
+	cache.Put("b", []byte("2"))
+	clock.Advance(10 * time.Minute)
+
+	var buf bytes.Buffer
+	if _, err := cache.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	config.Sliding = true
+	restored := New(config)
+	if count, err := restored.ReadSnapshot(&buf); err != nil || count != 2 {
+		t.Fatalf("expected 2 keys read, got %d %v", count, err)
+	}
+	if remaining, _ := restored.Ttl("a"); remaining != 50*time.Minute {
+		t.Errorf("a should keep its expiration, got %v", remaining)
+	}
+	clock.Advance(5 * time.Minute)
+	restored.Get("a")
+	restored.Get("b")
+	if remaining, _ := restored.Ttl("a"); remaining != ttl {
+		t.Errorf("sliding a should be renewed with its lifetime, got %v", remaining)
+	}
+	if remaining, _ := restored.Ttl("b"); remaining != 45*time.Minute {
+		t.Errorf("b should not be sliding regardless of config, got %v", remaining)
+	}
+}
+
+func TestReadGobSnapshot(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+
+	// snapshot written before versioning is gob stream of record
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	enc := gob.NewEncoder(w)
+	enc.Encode(record{Key: "a", Data: []byte("1"), ExpiresAt: time.Now().Add(time.Minute)})
+	enc.Encode(record{Key: "b", Data: []byte("2"), ExpiresAt: time.Now().Add(-time.Minute)})
+	enc.Encode(record{Key: "c", Data: []byte("3"), ExpiresAt: time.Now().Add(time.Minute), Flags: 5})
+	enc.Encode(record{Key: "d", Data: []byte("4"), ExpiresAt: time.Now().Add(time.Minute), Meta: map[string]string{"origin": "billing"}})
+	w.Flush()
+
+	cache := New(config)
+	if count, err := cache.ReadSnapshot(&buf); err != nil || count != 3 {
+		t.Errorf("snapshot of version 0 should be read, got %d %v", count, err)
+	}
+	if data, flags, _ := cache.GetWithFlags("c"); string(data) != "3" || flags != 5 {
+		t.Errorf("c should be read with flags, got %q %d", data, flags)
+	}
+	if info, _ := cache.GetWithInfo("d"); info.Meta["origin"] != "billing" {
+		t.Errorf("d should be read with metadata, got %v", info.Meta)
+	}
+}
@@ -0,0 +1,254 @@
+// Package sqlcache provides helpers which cache results of database/sql queries in CStorage. QueryCached keys results by hash of
+// statement and arguments, and tags them by tables which the statement reads, so Exec, or Invalidate, of a statement writing a table
+// deletes every cached result of the table.
+//
+// Tables are found by names after FROM, JOIN, INTO and UPDATE, which covers plain statements. Tables read only in views or functions
+// are not seen, and should be invalidated by Invalidate.
+package sqlcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+func init() {
+	gob.Register(time.Time{})
+}
+
+// Queryer interface is satisfied by *sql.DB, *sql.Tx and *sql.Conn.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Execer interface is satisfied by *sql.DB, *sql.Tx and *sql.Conn.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Rows structure is result of query, which is read like sql.Rows by Next and Scan.
+type Rows struct {
+	Columns []string
+	Values  [][]interface{}
+	// cursor is index of current row, -1 before the first Next
+	cursor int
+}
+
+// QueryCached function returns cached result of query with args, or runs query on db and caches its result with ttl of cache,
+// tagged by tables of query. Every row is read into memory, so it suits queries of small results.
+func QueryCached(ctx context.Context, db Queryer, cache *cstorage.CStorage, query string, args ...interface{}) (*Rows, error) {
+	key, err := Key(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if data, hit := cache.Get(key); hit {
+		rows := &Rows{cursor: -1}
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(rows); err == nil {
+			return rows, nil
+		}
+	}
+
+	rows, err := read(ctx, db, query, args)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(rows); err != nil {
+		return nil, fmt.Errorf("sqlcache: encode rows: %w", err)
+	}
+	tags := Tables(query)
+	for i, table := range tags {
+		tags[i] = tag(table)
+	}
+	cache.PutTagged(key, b.Bytes(), tags...)
+	return rows, nil
+}
+
+// ExecInvalidate function runs query on db, and invalidates cached results of tables of query if it succeeds.
+func ExecInvalidate(ctx context.Context, db Execer, cache *cstorage.CStorage, query string, args ...interface{}) (sql.Result, error) {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	Invalidate(cache, Tables(query)...)
+	return result, nil
+}
+
+// Invalidate function deletes cached results of queries which read any of tables, and returns number of deleted results.
+func Invalidate(cache *cstorage.CStorage, tables ...string) (count int) {
+	for _, table := range tables {
+		count += cache.InvalidateTag(tag(table))
+	}
+	return count
+}
+
+// Key function returns key of cached result of query with args. Arguments which implement driver.Valuer are keyed by their values.
+func Key(query string, args ...interface{}) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(query))
+	for _, arg := range args {
+		if v, ok := arg.(driver.Valuer); ok {
+			value, err := v.Value()
+			if err != nil {
+				return "", err
+			}
+			arg = value
+		}
+		if t, ok := arg.(time.Time); ok {
+			arg = t.UTC().Format(time.RFC3339Nano)
+		}
+		fmt.Fprintf(h, "\x00%T:%v", arg, arg)
+	}
+	return "sql:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+var tablePattern = regexp.MustCompile("(?i)\\b(?:from|join|into|update)\\s+([`\"\\[]?[\\w.]+)")
+
+// Tables function returns lower case names of tables in query, in order of appearance without duplicates.
+func Tables(query string) []string {
+	var tables []string
+	seen := make(map[string]bool)
+	for _, m := range tablePattern.FindAllStringSubmatch(query, -1) {
+		table := strings.ToLower(strings.Trim(m[1], "`\"[]"))
+		if !seen[table] {
+			seen[table] = true
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+func tag(table string) string {
+	return "table:" + strings.ToLower(table)
+}
+
+// read runs query and reads every row.
+func read(ctx context.Context, db Queryer, query string, args []interface{}) (*Rows, error) {
+	rs, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	columns, err := rs.Columns()
+	if err != nil {
+		return nil, err
+	}
+	rows := &Rows{Columns: columns, cursor: -1}
+	for rs.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rs.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		rows.Values = append(rows.Values, values)
+	}
+	return rows, rs.Err()
+}
+
+// Next function moves to the next row, and returns false if there is no more row.
+func (r *Rows) Next() bool {
+	if r.cursor+1 >= len(r.Values) {
+		r.cursor = len(r.Values)
+		return false
+	}
+	r.cursor++
+	return true
+}
+
+// Reset function moves back before the first row, so rows can be read again.
+func (r *Rows) Reset() {
+	r.cursor = -1
+}
+
+// Scan function copies columns of current row into dest. Dest can be sql.Scanner(e.g. sql.NullString), *interface{},
+// or pointer to string, []byte, int64, int, float64, bool or time.Time of compatible value.
+func (r *Rows) Scan(dest ...interface{}) error {
+	if r.cursor < 0 || r.cursor >= len(r.Values) {
+		return errors.New("sqlcache: Scan called without calling Next")
+	}
+	row := r.Values[r.cursor]
+	if len(dest) != len(row) {
+		return fmt.Errorf("sqlcache: expected %d destination arguments in Scan, not %d", len(row), len(dest))
+	}
+	for i, d := range dest {
+		if err := assign(d, row[i]); err != nil {
+			return fmt.Errorf("sqlcache: column %q: %w", r.Columns[i], err)
+		}
+	}
+	return nil
+}
+
+// assign copies value of column into dest.
+func assign(dest, value interface{}) error {
+	if s, ok := dest.(sql.Scanner); ok {
+		return s.Scan(value)
+	}
+	switch d := dest.(type) {
+	case *interface{}:
+		*d = value
+		return nil
+	case *string:
+		switch v := value.(type) {
+		case string:
+			*d = v
+			return nil
+		case []byte:
+			*d = string(v)
+			return nil
+		}
+	case *[]byte:
+		switch v := value.(type) {
+		case []byte:
+			*d = append([]byte(nil), v...)
+			return nil
+		case string:
+			*d = []byte(v)
+			return nil
+		}
+	case *int64:
+		if v, ok := value.(int64); ok {
+			*d = v
+			return nil
+		}
+	case *int:
+		if v, ok := value.(int64); ok {
+			*d = int(v)
+			return nil
+		}
+	case *float64:
+		switch v := value.(type) {
+		case float64:
+			*d = v
+			return nil
+		case int64:
+			*d = float64(v)
+			return nil
+		}
+	case *bool:
+		if v, ok := value.(bool); ok {
+			*d = v
+			return nil
+		}
+	case *time.Time:
+		if v, ok := value.(time.Time); ok {
+			*d = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported conversion from %T to %T", value, dest)
+}
@@ -0,0 +1,129 @@
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+// fakeDriver answers every query with the same rows of users, and counts queries.
+type fakeDriver struct {
+	queries int32
+}
+
+var fake = &fakeDriver{}
+
+func init() {
+	sql.Register("sqlcache-fake", fake)
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, driver.ErrSkip }
+
+type fakeStmt struct{}
+
+func (fakeStmt) Close() error                                    { return nil }
+func (fakeStmt) NumInput() int                                   { return -1 }
+func (fakeStmt) Exec(args []driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	atomic.AddInt32(&fake.queries, 1)
+	return &fakeRows{values: [][]driver.Value{
+		{int64(1), "alice", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{int64(2), nil, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}}, nil
+}
+
+type fakeRows struct {
+	values [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return []string{"id", "name", "created"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestQueryCached(t *testing.T) {
+	ctx := context.Background()
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := cstorage.CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := cstorage.New(config)
+	db, err := sql.Open("sqlcache-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	atomic.StoreInt32(&fake.queries, 0)
+
+	query := "SELECT id, name, created FROM users u JOIN teams t ON u.team = t.id WHERE u.id > ?"
+	if _, err := QueryCached(ctx, db, cache, query, 0); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := QueryCached(ctx, db, cache, query, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queries := atomic.LoadInt32(&fake.queries); queries != 1 {
+		t.Errorf("second query should be cached, got %d queries", queries)
+	}
+
+	var ids []int
+	var names []sql.NullString
+	for rows.Next() {
+		var id int
+		var name sql.NullString
+		var created time.Time
+		if err := rows.Scan(&id, &name, &created); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+		names = append(names, name)
+	}
+	if !reflect.DeepEqual(ids, []int{1, 2}) || names[0].String != "alice" || names[1].Valid {
+		t.Errorf("unexpected rows %v %v", ids, names)
+	}
+
+	QueryCached(ctx, db, cache, query, 1)
+	if queries := atomic.LoadInt32(&fake.queries); queries != 2 {
+		t.Errorf("different args should be queried, got %d queries", queries)
+	}
+	if _, err := ExecInvalidate(ctx, db, cache, "UPDATE teams SET name = ? WHERE id = ?", "x", 1); err != nil {
+		t.Fatal(err)
+	}
+	QueryCached(ctx, db, cache, query, 0)
+	if queries := atomic.LoadInt32(&fake.queries); queries != 3 {
+		t.Errorf("update of joined table should invalidate result, got %d queries", queries)
+	}
+}
+
+func TestTables(t *testing.T) {
+	tests := map[string][]string{
+		"SELECT * FROM Users WHERE id IN (SELECT user FROM orders)": {"users", "orders"},
+		"insert into `audit.log` (a) values (?)":                    {"audit.log"},
+		`DELETE FROM "users" WHERE id = ?`:                          {"users"},
+		"SELECT 1":                                                  nil,
+	}
+	for query, expected := range tests {
+		if tables := Tables(query); !reflect.DeepEqual(tables, expected) {
+			t.Errorf("%s: expected %v, got %v", query, expected, tables)
+		}
+	}
+}
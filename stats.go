@@ -0,0 +1,79 @@
+package cstorage
+
+import "sync/atomic"
+
+// Stats structure holds counters of CStorage since it is created.
+// - Hits, Misses: result of Get family functions. Expired key counts as miss.
+// - Evicted: number of keys removed by eviction policy due to capacity
+// - Expired: number of keys removed due to ttl, either passively by Get or by RemoveExpired
+// - Rejected: number of new keys which are not put since TinyLFU admission filter refused them, or since every key is pinned
+// - Oversized: number of puts which are not done since data was longer than CStorageConfig.MaxValueBytes
+// - Compactions: number of compactions of arena, see CStorageConfig.ArenaSlabBytes
+// - DroppedAccesses: number of Get hits which are not applied to eviction policy since buffer was full, see CStorageConfig.BufferedAccess
+// - DroppedEvents: number of events which are not sent since channel of Subscribe was full
+// - Spilled, Recovered: number of keys spilled to Overflow by eviction, and read back from it on miss
+// - Corrupted: number of keys removed since data didn't match its checksum, see Checksum
+// - CorruptRecords: number of records of snapshot skipped since they didn't match checksum, see CStorageConfig.SnapshotSkipCorrupt
+// - Refreshed, RefreshFailed: number of keys loaded again by CStorageConfig.Refresh before they expire, and number of failed loads
+// - Stale: number of keys removed due to older schema version which couldn't be upgraded, or data encrypted by retired key
+// - Size, Capacity: same as Size() and Cap()
+// - Pinned: number of pinned keys, which are included in Size
+// - Weight: same as Weight()
+// - Cardinality: same as Cardinality()
+// - MemoryUsage: same as MemoryUsage()
+// - CompressionSaved: bytes saved by compression of data of current keys, see CStorageConfig.Compressor
+type Stats struct {
+	Hits             int64
+	Misses           int64
+	Evicted          int64
+	Expired          int64
+	Stale            int64
+	Corrupted        int64
+	CorruptRecords   int64
+	Rejected         int64
+	Oversized        int64
+	Compactions      int64
+	DroppedAccesses  int64
+	DroppedEvents    int64
+	Spilled          int64
+	Recovered        int64
+	Refreshed        int64
+	RefreshFailed    int64
+	Size             int64
+	Capacity         int64
+	Pinned           int64
+	Weight           int64
+	Cardinality      uint64
+	MemoryUsage      int64
+	CompressionSaved int64
+}
+
+// HitRatio function returns ratio of hits among Get calls. It returns 0 if there was no Get.
+func (st Stats) HitRatio() float64 {
+	total := st.Hits + st.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(st.Hits) / float64(total)
+}
+
+// Stats function returns copy of current counters of CStorage.
+func (s *CStorage) Stats() Stats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	st := s.stats
+	st.Size = s.size
+	st.Capacity = s.config.Capacity
+	st.Pinned = s.pinned.len
+	st.Weight = s.weight
+	st.MemoryUsage = s.memoryUsage()
+	st.CompressionSaved = s.saved
+	if s.buffer != nil {
+		st.DroppedAccesses = atomic.LoadInt64(&s.buffer.dropped)
+	}
+	if s.cardinality != nil {
+		st.Cardinality = uint64(s.cardinality.estimate())
+	}
+	return st
+}
@@ -0,0 +1,34 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 2
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.Put("key1", []byte("1"))
+	cache.Put("key2", []byte("2"))
+	cache.Put("key3", []byte("3"))
+	cache.PutWithTtl("key4", []byte("4"), time.Millisecond)
+	time.Sleep(time.Millisecond * 5)
+
+	cache.Get("key1")
+	cache.Get("key3")
+	cache.Get("key4")
+
+	st := cache.Stats()
+	if st.Hits != 1 || st.Misses != 2 || st.Evicted != 2 || st.Expired != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	if st.Size != 1 || st.Capacity != 2 {
+		t.Errorf("unexpected size %+v", st)
+	}
+	if ratio := st.HitRatio(); ratio < 0.33 || ratio > 0.34 {
+		t.Errorf("hit ratio should be 1/3, got %f", ratio)
+	}
+}
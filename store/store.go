@@ -0,0 +1,471 @@
+// Package store binds CStorage to backing Store such as database, which is the system of record of keys.
+// Get of Cache loads misses from Store, and Put and Delete write to Store either synchronously(WriteThrough)
+// or asynchronously through bounded queue(WriteBehind), which coalesces writes of same key and is flushed by pool of workers.
+package store
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+// Store interface is backing storage of Cache. Implementations should be safe for concurrent use.
+// Get returns found=false without error if key doesn't exist.
+type Store interface {
+	Get(ctx context.Context, key string) (data []byte, found bool, err error)
+	Set(ctx context.Context, key string, data []byte) error
+	Delete(ctx context.Context, key string) error
+}
+
+// WriteMode decides when Put and Delete of Cache reach Store.
+type WriteMode int
+
+const (
+	// WriteThrough writes Store and then CStorage, and returns error of Store.
+	WriteThrough WriteMode = iota
+	// WriteBehind writes CStorage and queues the write, which is written to Store in background.
+	// Writes of a key which is still queued are coalesced, so only the latest one is written. Writes of a key are written in order,
+	// but writes of different keys can be written in any order by Options.Workers.
+	// Errors of Store are retried, and given to Options.OnError when retries run out, since caller has already returned.
+	WriteBehind
+)
+
+// Defaults of Options.
+const (
+	defaultQueueSize    = 1024
+	defaultWriteTimeout = 5 * time.Second
+	defaultWorkers      = 1
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 100 * time.Millisecond
+)
+
+// Options structure is configuration of Cache.
+// - Mode: when Put and Delete reach Store
+// - QueueSize: number of keys queued in WriteBehind, default 1024. Put and Delete of other keys wait for room when it is full, so Store is not overrun
+// - WriteTimeout: timeout of each write to Store in WriteBehind, default 5s
+// - Workers: number of goroutines writing queued writes to Store in WriteBehind, default 1
+// - MaxRetries: number of retries of failed write in WriteBehind, default 3. If negative, failed writes are not retried
+// - RetryBackoff: wait before the first retry, default 100ms. It doubles on each retry
+// - OnError: called with error of write to Store in WriteBehind after retries run out, optional
+type Options struct {
+	Mode         WriteMode
+	QueueSize    int
+	WriteTimeout time.Duration
+	Workers      int
+	MaxRetries   int
+	RetryBackoff time.Duration
+	OnError      func(key string, err error)
+}
+
+// Stats structure is counters of Cache.
+// - Loads, LoadErrors: Gets which missed CStorage and called Store, and those which failed
+// - Writes, WriteErrors: writes and deletes of Store, and those which failed after retries
+// - Coalesced: writes in WriteBehind which replaced queued write of same key, instead of being queued
+// - Retries: retries of failed writes in WriteBehind
+// - Queued, InFlight: keys waiting in the queue, and keys being written to Store by workers at the moment
+type Stats struct {
+	Loads       int64
+	LoadErrors  int64
+	Writes      int64
+	WriteErrors int64
+	Coalesced   int64
+	Retries     int64
+	Queued      int64
+	InFlight    int64
+}
+
+// write is Put or Delete queued in WriteBehind. elem is its element in the queue.
+type write struct {
+	key    string
+	data   []byte
+	delete bool
+	elem   *list.Element
+}
+
+// Cache structure is CStorage bound to Store.
+type Cache struct {
+	cache   *cstorage.CStorage
+	store   Store
+	options Options
+	loads   flights
+
+	// mutex guards the queue and stats. changed is closed and replaced whenever the queue changes, so waiters can also wait for ctx.
+	// queued has write of each key waiting in order, and inflight has writes taken by workers.
+	// loading has generation of each key being loaded, which Delete bumps so the load doesn't put deleted data back.
+	mutex    sync.Mutex
+	loading  map[string]uint64
+	closed   bool
+	order    *list.List
+	queued   map[string]*write
+	inflight map[string]*write
+	changed  chan struct{}
+	done     chan struct{}
+	wg       sync.WaitGroup
+	stats    Stats
+}
+
+// Bind function returns Cache which binds cache to store. In WriteBehind, Close should be called to flush queued writes.
+func Bind(cache *cstorage.CStorage, store Store, options Options) *Cache {
+	c := &Cache{cache: cache, store: store, options: options, loads: flights{calls: make(map[string]*call)}, loading: make(map[string]uint64)}
+	if options.Mode == WriteBehind {
+		if c.options.QueueSize <= 0 {
+			c.options.QueueSize = defaultQueueSize
+		}
+		if c.options.WriteTimeout <= 0 {
+			c.options.WriteTimeout = defaultWriteTimeout
+		}
+		if c.options.Workers <= 0 {
+			c.options.Workers = defaultWorkers
+		}
+		if c.options.MaxRetries == 0 {
+			c.options.MaxRetries = defaultMaxRetries
+		}
+		if c.options.RetryBackoff <= 0 {
+			c.options.RetryBackoff = defaultRetryBackoff
+		}
+		c.order = list.New()
+		c.queued = make(map[string]*write)
+		c.inflight = make(map[string]*write)
+		c.changed = make(chan struct{})
+		c.done = make(chan struct{})
+		for i := 0; i < c.options.Workers; i++ {
+			c.wg.Add(1)
+			go c.writeLoop()
+		}
+	}
+	return c
+}
+
+// Get function returns data of key from CStorage, or loads it from Store on miss and puts it into CStorage.
+// Concurrent misses of a key load it once, and loaded data doesn't replace key which is put or deleted while it is loaded.
+// In WriteBehind, key which is not written to Store yet is returned from the queue. hit is false if key is in none of them.
+func (c *Cache) Get(ctx context.Context, key string) (data []byte, hit bool, err error) {
+	if data, hit := c.cache.Get(key); hit {
+		return data, true, nil
+	}
+	if data, deleted, ok := c.pending(key); ok {
+		return data, !deleted, nil
+	}
+
+	v, err := c.loads.do(key, func() (flight, error) {
+		c.mutex.Lock()
+		c.stats.Loads++
+		generation := c.loading[key]
+		c.loading[key] = generation
+		c.mutex.Unlock()
+
+		data, found, err := c.store.Get(ctx, key)
+
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		deleted := c.loading[key] != generation
+		delete(c.loading, key)
+		if err != nil {
+			c.stats.LoadErrors++
+			return flight{}, err
+		}
+		// data loaded before key is deleted would put deleted data back, so it is returned but not cached
+		if found && !deleted {
+			c.cache.PutIfAbsent(key, data)
+		}
+		return flight{data: data, found: found}, nil
+	})
+	return v.data, v.found, err
+}
+
+// Put function writes data of key to Store and CStorage by Mode.
+// In WriteThrough, CStorage is not written if writing Store fails, so CStorage never has data which Store doesn't.
+// In WriteBehind, it waits for room of the queue until ctx is done, unless write of key is already queued.
+func (c *Cache) Put(ctx context.Context, key string, data []byte) error {
+	if c.options.Mode == WriteBehind {
+		return c.enqueue(ctx, key, data, false)
+	}
+
+	c.count(&c.stats.Writes)
+	if err := c.store.Set(ctx, key, data); err != nil {
+		c.count(&c.stats.WriteErrors)
+		return err
+	}
+	c.cache.Put(key, data)
+	return nil
+}
+
+// Delete function deletes key from Store and CStorage by Mode, same as Put.
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	if c.options.Mode == WriteBehind {
+		return c.enqueue(ctx, key, nil, true)
+	}
+
+	c.count(&c.stats.Writes)
+	if err := c.store.Delete(ctx, key); err != nil {
+		c.count(&c.stats.WriteErrors)
+		return err
+	}
+	c.mutex.Lock()
+	c.invalidate(key)
+	c.mutex.Unlock()
+	c.cache.Delete(key)
+	return nil
+}
+
+// CStorage function returns CStorage bound to Store.
+func (c *Cache) CStorage() *cstorage.CStorage {
+	return c.cache
+}
+
+// Stats function returns copy of counters of Cache.
+func (c *Cache) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stats := c.stats
+	stats.Queued = int64(len(c.queued))
+	stats.InFlight = int64(len(c.inflight))
+	return stats
+}
+
+// Drain function waits until every write queued in WriteBehind is written to Store, including writes queued while it waits.
+// It is for shutdown after writers are stopped, and returns error of ctx if the queue is not drained before ctx is done.
+func (c *Cache) Drain(ctx context.Context) error {
+	if c.options.Mode != WriteBehind {
+		return nil
+	}
+	c.mutex.Lock()
+	for len(c.queued)+len(c.inflight) > 0 {
+		changed := c.changed
+		c.mutex.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		c.mutex.Lock()
+	}
+	c.mutex.Unlock()
+	return nil
+}
+
+// Close function stops accepting writes, and waits for queued writes to be written to Store in WriteBehind.
+// In WriteBehind, Put and Delete return cstorage.ErrClosed after it. CStorage is not closed.
+func (c *Cache) Close() error {
+	if c.options.Mode != WriteBehind {
+		return nil
+	}
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mutex.Unlock()
+
+	c.Drain(context.Background())
+	close(c.done)
+	c.wg.Wait()
+	return nil
+}
+
+// enqueue queues write of key, or replaces write of key which is already queued. CStorage is written under the lock,
+// so concurrent writes of a key leave the same data in CStorage and in Store.
+func (c *Cache) enqueue(ctx context.Context, key string, data []byte, remove bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for {
+		if c.closed {
+			return cstorage.ErrClosed
+		}
+		if w, ok := c.queued[key]; ok {
+			w.data, w.delete = data, remove
+			c.stats.Coalesced++
+			break
+		}
+		if len(c.queued) < c.options.QueueSize {
+			w := &write{key: key, data: data, delete: remove}
+			w.elem = c.order.PushBack(w)
+			c.queued[key] = w
+			c.notify()
+			break
+		}
+
+		changed := c.changed
+		c.mutex.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			c.mutex.Lock()
+			return ctx.Err()
+		}
+		c.mutex.Lock()
+	}
+
+	if remove {
+		c.invalidate(key)
+		c.cache.Delete(key)
+	} else {
+		c.cache.Put(key, data)
+	}
+	return nil
+}
+
+// invalidate bumps generation of key if it is being loaded, so the load doesn't put data of key. Caller should hold the mutex.
+func (c *Cache) invalidate(key string) {
+	if generation, ok := c.loading[key]; ok {
+		c.loading[key] = generation + 1
+	}
+}
+
+// pending returns data of the latest write of key which is not written to Store yet. Fields of write are copied under the mutex,
+// since enqueue replaces them when it coalesces writes.
+func (c *Cache) pending(key string) (data []byte, deleted bool, ok bool) {
+	if c.options.Mode != WriteBehind {
+		return nil, false, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	w, ok := c.queued[key]
+	if !ok {
+		w, ok = c.inflight[key]
+	}
+	if !ok {
+		return nil, false, false
+	}
+	return w.data, w.delete, true
+}
+
+// notify wakes up goroutines waiting for change of the queue. Caller should hold the mutex.
+func (c *Cache) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// writeLoop writes queued writes to Store until Close.
+func (c *Cache) writeLoop() {
+	defer c.wg.Done()
+
+	for {
+		w, ok := c.next()
+		if !ok {
+			return
+		}
+		c.flush(w)
+	}
+}
+
+// next takes the oldest queued write whose key is not being written by other worker, so writes of a key are written in order.
+// It returns false when Cache is closed.
+func (c *Cache) next() (*write, bool) {
+	c.mutex.Lock()
+	for {
+		for e := c.order.Front(); e != nil; e = e.Next() {
+			w := e.Value.(*write)
+			if _, ok := c.inflight[w.key]; ok {
+				continue
+			}
+			c.order.Remove(e)
+			delete(c.queued, w.key)
+			c.inflight[w.key] = w
+			c.notify()
+			c.mutex.Unlock()
+			return w, true
+		}
+
+		changed := c.changed
+		c.mutex.Unlock()
+		select {
+		case <-changed:
+		case <-c.done:
+			return nil, false
+		}
+		c.mutex.Lock()
+	}
+}
+
+// flush writes w to Store, retrying with backoff. Retry stops if newer write of the key is queued, since it overwrites w anyway.
+func (c *Cache) flush(w *write) {
+	backoff := c.options.RetryBackoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), c.options.WriteTimeout)
+		if w.delete {
+			err = c.store.Delete(ctx, w.key)
+		} else {
+			err = c.store.Set(ctx, w.key, w.data)
+		}
+		cancel()
+		if err == nil || attempt >= c.options.MaxRetries || c.superseded(w.key) {
+			break
+		}
+		c.count(&c.stats.Retries)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	superseded := err != nil && c.superseded(w.key)
+	c.mutex.Lock()
+	delete(c.inflight, w.key)
+	c.stats.Writes++
+	if err != nil && !superseded {
+		c.stats.WriteErrors++
+	}
+	c.notify()
+	c.mutex.Unlock()
+	if err != nil && !superseded && c.options.OnError != nil {
+		c.options.OnError(w.key, err)
+	}
+}
+
+// superseded returns true if newer write of key is queued.
+func (c *Cache) superseded(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, ok := c.queued[key]
+	return ok
+}
+
+// count increments counter of Stats.
+func (c *Cache) count(counter *int64) {
+	c.mutex.Lock()
+	*counter++
+	c.mutex.Unlock()
+}
+
+// flight is result of loading a key.
+type flight struct {
+	data  []byte
+	found bool
+}
+
+type call struct {
+	wg  sync.WaitGroup
+	v   flight
+	err error
+}
+
+// flights runs a function once for concurrent callers of same key.
+type flights struct {
+	mutex sync.Mutex
+	calls map[string]*call
+}
+
+func (f *flights) do(key string, fn func() (flight, error)) (flight, error) {
+	f.mutex.Lock()
+	if c, ok := f.calls[key]; ok {
+		f.mutex.Unlock()
+		c.wg.Wait()
+		return c.v, c.err
+	}
+	c := &call{}
+	c.wg.Add(1)
+	f.calls[key] = c
+	f.mutex.Unlock()
+
+	c.v, c.err = fn()
+	f.mutex.Lock()
+	delete(f.calls, key)
+	f.mutex.Unlock()
+	c.wg.Done()
+	return c.v, c.err
+}
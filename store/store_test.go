@@ -0,0 +1,336 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+type mapStore struct {
+	mutex    sync.Mutex
+	data     map[string][]byte
+	gets     int
+	fail     error
+	failures int
+	release  chan struct{}
+	// loaded is called after Get reads data, if it is set
+	loaded func()
+}
+
+func newMapStore() *mapStore {
+	return &mapStore{data: make(map[string][]byte)}
+}
+
+func (m *mapStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mutex.Lock()
+	m.gets++
+	d, ok := m.data[key]
+	fail := m.fail
+	m.mutex.Unlock()
+	if m.loaded != nil {
+		m.loaded()
+	}
+	return d, ok, fail
+}
+
+func (m *mapStore) Set(ctx context.Context, key string, data []byte) error {
+	if m.release != nil {
+		<-m.release
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.failures > 0 {
+		m.failures--
+		return errors.New("temporary")
+	}
+	if m.fail != nil {
+		return m.fail
+	}
+	m.data[key] = data
+	return nil
+}
+
+func (m *mapStore) Delete(ctx context.Context, key string) error {
+	if m.release != nil {
+		<-m.release
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.fail != nil {
+		return m.fail
+	}
+	delete(m.data, key)
+	return nil
+}
+
+func (m *mapStore) get(key string) ([]byte, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	d, ok := m.data[key]
+	return d, ok
+}
+
+func TestReadThrough(t *testing.T) {
+	ctx := context.Background()
+	st := newMapStore()
+	st.data["a"] = []byte("1")
+	cache := Bind(cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 10}), st, Options{})
+
+	for i := 0; i < 2; i++ {
+		if data, hit, err := cache.Get(ctx, "a"); err != nil || !hit || string(data) != "1" {
+			t.Errorf("a should be loaded from store, got %q %v %v", data, hit, err)
+		}
+	}
+	if _, hit, err := cache.Get(ctx, "missing"); err != nil || hit {
+		t.Errorf("missing key should miss, got %v %v", hit, err)
+	}
+	if st.gets != 2 {
+		t.Errorf("a should be loaded once, got %d loads", st.gets)
+	}
+
+	st.fail = errors.New("down")
+	if _, _, err := cache.Get(ctx, "b"); err != st.fail {
+		t.Errorf("error of store should be returned, got %v", err)
+	}
+	if stats := cache.Stats(); stats.Loads != 3 || stats.LoadErrors != 1 {
+		t.Errorf("loads should be counted, got %+v", stats)
+	}
+}
+
+func TestWriteThrough(t *testing.T) {
+	ctx := context.Background()
+	st := newMapStore()
+	cache := Bind(cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 10}), st, Options{})
+
+	if err := cache.Put(ctx, "a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := st.get("a"); string(data) != "1" {
+		t.Errorf("a should be written to store, got %q", data)
+	}
+	if data, _ := cache.CStorage().Get("a"); string(data) != "1" {
+		t.Errorf("a should be written to cache, got %q", data)
+	}
+
+	st.fail = errors.New("down")
+	if err := cache.Put(ctx, "b", []byte("2")); err != st.fail {
+		t.Errorf("error of store should be returned, got %v", err)
+	}
+	if _, hit := cache.CStorage().Get("b"); hit {
+		t.Error("b should not be cached when store fails")
+	}
+	if err := cache.Delete(ctx, "a"); err != st.fail {
+		t.Errorf("error of store should be returned, got %v", err)
+	}
+	if _, hit := cache.CStorage().Get("a"); !hit {
+		t.Error("a should be kept in cache when store fails")
+	}
+
+	st.fail = nil
+	if err := cache.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, hit := st.get("a"); hit {
+		t.Error("a should be deleted from store")
+	}
+}
+
+func TestDeleteDuringLoad(t *testing.T) {
+	ctx := context.Background()
+	st := newMapStore()
+	st.data["a"] = []byte("1")
+	cache := Bind(cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 10}), st, Options{})
+
+	loaded, resume := make(chan struct{}), make(chan struct{})
+	st.loaded = func() {
+		close(loaded)
+		<-resume
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.Get(ctx, "a")
+	}()
+	<-loaded
+	if err := cache.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	close(resume)
+	<-done
+
+	if _, hit := cache.CStorage().Get("a"); hit {
+		t.Error("a loaded before it is deleted should not be cached")
+	}
+	st.loaded = nil
+	if _, hit, err := cache.Get(ctx, "a"); hit || err != nil {
+		t.Errorf("deleted a should miss, got %v %v", hit, err)
+	}
+}
+
+func TestWriteBehind(t *testing.T) {
+	ctx := context.Background()
+	st := newMapStore()
+	st.release = make(chan struct{})
+	cache := Bind(cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 1}), st, Options{Mode: WriteBehind, QueueSize: 2})
+
+	cache.Put(ctx, "a", []byte("1"))
+	testutil.Eventually(t, func() bool { return cache.Stats().InFlight == 1 }, "a should be in flight")
+	cache.Put(ctx, "b", []byte("2"))
+	// a is evicted from cache before it is written, so it is served from the queue instead of store
+	if data, hit, err := cache.Get(ctx, "a"); err != nil || !hit || string(data) != "1" {
+		t.Errorf("a being written should be returned, got %q %v %v", data, hit, err)
+	}
+	if st.gets != 0 {
+		t.Errorf("queued key should not be loaded, got %d loads", st.gets)
+	}
+
+	cache.Delete(ctx, "b")
+	cache.Put(ctx, "c", []byte("3"))
+	if _, hit, _ := cache.Get(ctx, "b"); hit {
+		t.Error("queued delete of b should be returned")
+	}
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := cache.Put(timeout, "d", []byte("4")); err != context.DeadlineExceeded {
+		t.Errorf("full queue should block Put, got %v", err)
+	}
+	// c is already queued, so it doesn't need room
+	if err := cache.Put(timeout, "c", []byte("5")); err != nil {
+		t.Errorf("queued key should be coalesced, got %v", err)
+	}
+	if stats := cache.Stats(); stats.Queued != 2 || stats.Coalesced != 2 {
+		t.Errorf("b and c should be queued once each, got %+v", stats)
+	}
+
+	close(st.release)
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := st.get("a"); string(data) != "1" {
+		t.Errorf("a should be written, got %q", data)
+	}
+	if _, hit := st.get("b"); hit {
+		t.Error("only delete of b should be written")
+	}
+	if data, _ := st.get("c"); string(data) != "5" {
+		t.Errorf("the latest c should be flushed on Close, got %q", data)
+	}
+	if _, hit := st.get("d"); hit {
+		t.Error("d which timed out should not be written")
+	}
+	if stats := cache.Stats(); stats.Writes != 3 || stats.WriteErrors != 0 || stats.Queued != 0 || stats.InFlight != 0 {
+		t.Errorf("3 writes should succeed, got %+v", stats)
+	}
+	if err := cache.Put(ctx, "e", []byte("6")); err != cstorage.ErrClosed {
+		t.Errorf("Put after Close should fail, got %v", err)
+	}
+}
+
+func TestWriteBehindConcurrentGet(t *testing.T) {
+	ctx := context.Background()
+	st := newMapStore()
+	st.release = make(chan struct{})
+	cache := Bind(cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 10}), st, Options{Mode: WriteBehind})
+	cache.Put(ctx, "a", []byte("first"))
+	cache.CStorage().Delete("a")
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10000; i++ {
+			cache.Put(ctx, "a", []byte(strconv.Itoa(i)))
+			// a is dropped from CStorage, so Get of a is served from the queue while Put coalesces it
+			cache.CStorage().Delete("a")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10000; i++ {
+			if _, hit, err := cache.Get(ctx, "a"); !hit || err != nil {
+				t.Errorf("a should be returned from the queue, got %v %v", hit, err)
+			}
+		}
+	}()
+	wg.Wait()
+	close(st.release)
+	cache.Close()
+}
+
+func TestWriteBehindRetry(t *testing.T) {
+	ctx := context.Background()
+	st := newMapStore()
+	st.failures = 2
+	var mutex sync.Mutex
+	var failed []string
+	cache := Bind(cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 10}), st, Options{
+		Mode:         WriteBehind,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+		OnError: func(key string, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			failed = append(failed, key)
+		},
+	})
+	defer cache.Close()
+
+	cache.Put(ctx, "a", []byte("1"))
+	if err := cache.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := st.get("a"); string(data) != "1" {
+		t.Errorf("a should be written after retries, got %q", data)
+	}
+
+	st.mutex.Lock()
+	st.fail = errors.New("down")
+	st.mutex.Unlock()
+	cache.Put(ctx, "b", []byte("2"))
+	cache.Drain(ctx)
+	mutex.Lock()
+	if len(failed) != 1 || failed[0] != "b" {
+		t.Errorf("b should be given up after retries, got %v", failed)
+	}
+	mutex.Unlock()
+	if stats := cache.Stats(); stats.Writes != 2 || stats.WriteErrors != 1 || stats.Retries != 4 {
+		t.Errorf("retries should be counted, got %+v", stats)
+	}
+}
+
+func TestWriteBehindWorkers(t *testing.T) {
+	ctx := context.Background()
+	st := newMapStore()
+	cache := Bind(cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 100}), st, Options{Mode: WriteBehind, Workers: 4, QueueSize: 8})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cache.Put(ctx, strconv.Itoa(i), []byte(strconv.Itoa(j)))
+				cache.Put(ctx, "other"+strconv.Itoa(i*100+j), []byte("v"))
+			}
+		}(i)
+	}
+	wg.Wait()
+	timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := cache.Drain(timeout); err != nil {
+		t.Fatal(err)
+	}
+	// writes of a key are written in order even by several workers
+	for i := 0; i < 4; i++ {
+		if data, _ := st.get(strconv.Itoa(i)); string(data) != "99" {
+			t.Errorf("the latest write of %d should be written, got %q", i, data)
+		}
+	}
+	cache.Close()
+}
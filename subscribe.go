@@ -0,0 +1,165 @@
+package cstorage
+
+import (
+	"strconv"
+	"time"
+)
+
+// EventType is kind of mutation of CStorage, see Event.
+type EventType int
+
+const (
+	// EventPut is sent when key is put, either new or updated.
+	EventPut EventType = iota
+	// EventDelete is sent when key is removed by Delete family functions, or removed by Get since its data is stale or corrupted.
+	EventDelete
+	// EventEvict is sent when key is evicted by capacity.
+	EventEvict
+	// EventExpire is sent when key is removed since its ttl has passed, either by Get or by RemoveExpired.
+	EventExpire
+	// EventClear is sent when every key is removed by Clear. Key of the event is empty.
+	EventClear
+)
+
+var eventTypes = [...]string{"put", "delete", "evict", "expire", "clear"}
+
+func (t EventType) String() string {
+	if t < 0 || int(t) >= len(eventTypes) {
+		return "EventType(" + strconv.Itoa(int(t)) + ")"
+	}
+	return eventTypes[t]
+}
+
+// Event structure is mutation of CStorage delivered by Subscribe. Data is set only if CStorageConfig.EventValues is set;
+// it is data put by EventPut, or data removed by the other types, and it is shared by subscribers so it must not be modified.
+// Time is time of the mutation by CStorageConfig.Clock. Meta is copy of metadata of the key given by PutWithMeta, which is set for every type but EventClear.
+type Event struct {
+	Type EventType
+	Key  string
+	Data []byte
+	Meta map[string]string
+	Time time.Time
+}
+
+// subscription is receiver of events made by Subscribe, or by Watch if watch is true, which receives only events of key and EventClear.
+type subscription struct {
+	events chan Event
+	key    string
+	watch  bool
+}
+
+// Subscribe function is to receive every mutation of CStorage as Event, e.g. to drive invalidation of near caches or audit pipelines.
+// Events are sent in the order of mutations, to channel buffered by CStorageConfig.EventBuffer. If the channel is full, events are dropped
+// instead of blocking CStorage, and they are counted by Stats.DroppedEvents, so receiver should keep up with mutations.
+// cancel stops the subscription and closes the channel, and calling it more than once is safe. Close of CStorage closes the channel as well.
+func (s *CStorage) Subscribe() (events <-chan Event, cancel func()) {
+	return s.subscribe(&subscription{})
+}
+
+// subscribe adds subscription sub and returns its channel, which is closed right away if CStorage is closed.
+func (s *CStorage) subscribe(sub *subscription) (<-chan Event, func()) {
+	size := s.config.EventBuffer
+	if size == 0 {
+		size = 1024
+	}
+	sub.events = make(chan Event, size)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		close(sub.events)
+		return sub.events, func() {}
+	}
+	if sub.watch {
+		if s.watchers == nil {
+			s.watchers = make(map[string][]*subscription)
+		}
+		s.watchers[sub.key] = append(s.watchers[sub.key], sub)
+	} else {
+		s.subscribers = append(s.subscribers, sub)
+	}
+	return sub.events, func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.unsubscribe(sub)
+	}
+}
+
+// unsubscribe removes sub and closes its channel, if it is not removed yet. Caller should hold the mutex.
+func (s *CStorage) unsubscribe(sub *subscription) {
+	if !sub.watch {
+		s.subscribers = without(s.subscribers, sub)
+		return
+	}
+	if watchers := without(s.watchers[sub.key], sub); len(watchers) > 0 {
+		s.watchers[sub.key] = watchers
+	} else {
+		delete(s.watchers, sub.key)
+	}
+}
+
+// without removes sub from subs and closes its channel, if sub is in subs.
+func without(subs []*subscription, sub *subscription) []*subscription {
+	for i, other := range subs {
+		if other == sub {
+			close(sub.events)
+			return append(subs[:i], subs[i+1:]...)
+		}
+	}
+	return subs
+}
+
+// publish sends event of type t of node n to subscribers. n is nil for EventClear. Caller should hold the mutex,
+// and call it before data of n is released.
+func (s *CStorage) publish(t EventType, n *node) {
+	var watchers []*subscription
+	if n != nil {
+		watchers = s.watchers[n.key]
+	}
+	if len(s.subscribers) == 0 && len(watchers) == 0 && (t != EventClear || len(s.watchers) == 0) {
+		return
+	}
+
+	e := Event{Type: t, Time: s.now()}
+	if n != nil {
+		e.Key = n.key
+		e.Meta = copyMeta(n.meta)
+		if s.config.EventValues {
+			e.Data = s.value(n)
+		}
+	}
+	for _, sub := range s.subscribers {
+		s.send(sub, e)
+	}
+	for _, sub := range watchers {
+		s.send(sub, e)
+	}
+	if t == EventClear {
+		for _, watchers := range s.watchers {
+			for _, sub := range watchers {
+				s.send(sub, e)
+			}
+		}
+	}
+}
+
+// send sends e to sub, or drops it if the channel is full. Caller should hold the mutex.
+func (s *CStorage) send(sub *subscription, e Event) {
+	select {
+	case sub.events <- e:
+	default:
+		s.stats.DroppedEvents++
+	}
+}
+
+// removal returns type of event when n is removed by evict. Caller should hold the mutex.
+func (s *CStorage) removal(n *node, evicted bool) EventType {
+	if evicted {
+		return EventEvict
+	}
+	if n.ttl.Before(s.now()) {
+		return EventExpire
+	}
+	return EventDelete
+}
@@ -0,0 +1,138 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+// receive returns events which are in the channel now.
+func receive(events <-chan Event) []Event {
+	var received []Event
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return received
+			}
+			received = append(received, e)
+		default:
+			return received
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	ttl := time.Duration(time.Minute)
+	var capacity int64 = 2
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock}
+	cache := New(config)
+
+	events, cancel := cache.Subscribe()
+	defer cancel()
+
+	cache.Put("a", []byte("1"))
+	cache.Put("b", []byte("2"))
+	cache.Put("c", []byte("3"))
+	cache.Delete("b")
+	clock.Advance(2 * time.Minute)
+	cache.Get("c")
+	cache.Clear()
+
+	expected := []Event{{Type: EventPut, Key: "a"}, {Type: EventPut, Key: "b"}, {Type: EventEvict, Key: "a"}, {Type: EventPut, Key: "c"},
+		{Type: EventDelete, Key: "b"}, {Type: EventExpire, Key: "c"}, {Type: EventClear}}
+	received := receive(events)
+	if len(received) != len(expected) {
+		t.Fatalf("expected %d events, got %+v", len(expected), received)
+	}
+	for i, e := range expected {
+		if received[i].Type != e.Type || received[i].Key != e.Key || received[i].Data != nil {
+			t.Errorf("event %d: expected %v %q, got %+v", i, e.Type, e.Key, received[i])
+		}
+	}
+	if !received[len(received)-1].Time.Equal(clock.Now()) {
+		t.Errorf("event should have time of clock, got %v", received[len(received)-1].Time)
+	}
+}
+
+func TestSubscribeValuesAndDrops(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, EventBuffer: 2, EventValues: true}
+	cache := New(config)
+
+	events, cancel := cache.Subscribe()
+	cache.Put("a", []byte("1"))
+	cache.Delete("a")
+	cache.Put("b", []byte("2"))
+
+	received := receive(events)
+	if len(received) != 2 || string(received[0].Data) != "1" || received[1].Type != EventDelete || string(received[1].Data) != "1" {
+		t.Errorf("unexpected events %+v", received)
+	}
+	if st := cache.Stats(); st.DroppedEvents != 1 {
+		t.Errorf("expected 1 dropped event, got %d", st.DroppedEvents)
+	}
+
+	cancel()
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("channel should be closed by cancel")
+	}
+	cache.Put("c", []byte("3"))
+}
+
+func TestSubscribeClose(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	events, cancel := cache.Subscribe()
+	cache.Close()
+	if _, ok := <-events; ok {
+		t.Error("channel should be closed by Close")
+	}
+	cancel()
+
+	if events, _ := cache.Subscribe(); len(receive(events)) != 0 {
+		t.Error("subscription after Close should be closed")
+	}
+}
+
+func TestSubscribeMeta(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	events, cancel := cache.Subscribe()
+	defer cancel()
+
+	meta := map[string]string{"origin": "billing"}
+	cache.PutWithMeta("a", []byte("1"), meta)
+	meta["origin"] = "changed"
+	cache.Delete("a")
+	cache.Put("b", []byte("2"))
+
+	received := receive(events)
+	if len(received) != 3 {
+		t.Fatalf("expected 3 events, got %+v", received)
+	}
+	for i, e := range received[:2] {
+		if e.Meta["origin"] != "billing" {
+			t.Errorf("event %d should have metadata of the key, got %+v", i, e)
+		}
+	}
+	if received[2].Meta != nil {
+		t.Errorf("event of key without metadata should have no metadata, got %+v", received[2])
+	}
+}
+
+func TestEventType(t *testing.T) {
+	if EventExpire.String() != "expire" || EventType(10).String() != "EventType(10)" {
+		t.Errorf("unexpected names %v, %v", EventExpire, EventType(10))
+	}
+}
@@ -0,0 +1,63 @@
+package cstorage
+
+// PutTagged function is same as Put, but it associates tags with the key, so the key can be deleted by InvalidateTag.
+// Tags are replaced whenever data is put, same as metadata.
+func (s *CStorage) PutTagged(key string, data []byte, tags ...string) (hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, hit := s.put(key, data, s.config.Ttl, s.config.Sliding)
+	if n != nil {
+		s.tag(n, tags)
+	}
+	return hit
+}
+
+// InvalidateTag function deletes every keys which have tag, and returns number of deleted keys.
+// It takes time proportional to the number of keys with tag, not the size of CStorage.
+func (s *CStorage) InvalidateTag(tag string) (count int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for n := range s.tags[tag] {
+		s.evict(n, false)
+		count++
+	}
+	return count
+}
+
+// tag associates tags with node. Caller should hold the mutex.
+func (s *CStorage) tag(n *node, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	if s.tags == nil {
+		s.tags = make(map[string]map[*node]struct{})
+	}
+
+	n.tags = make([]string, 0, len(tags))
+	for _, tag := range tags {
+		nodes, ok := s.tags[tag]
+		if !ok {
+			nodes = make(map[*node]struct{})
+			s.tags[tag] = nodes
+		}
+		if _, dup := nodes[n]; dup {
+			continue
+		}
+		nodes[n] = struct{}{}
+		n.tags = append(n.tags, tag)
+	}
+}
+
+// untag removes every tags of node. Caller should hold the mutex.
+func (s *CStorage) untag(n *node) {
+	for _, tag := range n.tags {
+		nodes := s.tags[tag]
+		delete(nodes, n)
+		if len(nodes) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+	n.tags = nil
+}
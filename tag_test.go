@@ -0,0 +1,46 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTag(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 3
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.PutTagged("profile", []byte("1"), "user:42")
+	cache.PutTagged("feed", []byte("2"), "user:42", "feed", "user:42")
+	cache.PutTagged("other", []byte("3"), "user:7")
+
+	info, _ := cache.GetWithInfo("feed")
+	if len(info.Tags) != 2 {
+		t.Errorf("duplicated tag should be ignored, got %v", info.Tags)
+	}
+
+	if count := cache.InvalidateTag("user:42"); count != 2 {
+		t.Errorf("2 keys should be invalidated, got %d", count)
+	}
+	if cache.Size() != 1 {
+		t.Errorf("only other should remain, got size %d", cache.Size())
+	}
+	if count := cache.InvalidateTag("feed"); count != 0 {
+		t.Errorf("deleted key shouldn't be invalidated again, got %d", count)
+	}
+
+	cache.PutTagged("a", []byte("a"), "tag")
+	cache.Put("a", []byte("a"))
+	cache.PutTagged("b", []byte("b"), "tag")
+	cache.PutTagged("c", []byte("c"), "tag")
+	if count := cache.InvalidateTag("tag"); count != 2 {
+		t.Errorf("tag of a is replaced by Put, expected 2, got %d", count)
+	}
+	if _, hit := cache.Get("a"); !hit {
+		t.Error("a shouldn't be invalidated")
+	}
+	if len(cache.tags) != 0 {
+		t.Errorf("tags of removed keys should be forgotten, got %v", cache.tags)
+	}
+}
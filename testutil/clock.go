@@ -0,0 +1,43 @@
+// Package testutil provides helpers for testing code which uses CStorage.
+package testutil
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is manual clock which can be given to CStorageConfig.Clock. Time doesn't pass unless Advance or Set is called,
+// so ttl can be tested instantly and deterministically, without time.Sleep.
+type Clock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewClock function returns Clock which starts at now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now function returns current time of Clock.
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// Advance function moves Clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// Set function moves Clock to now, which can be earlier than current time of Clock.
+func (c *Clock) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = now
+}
@@ -0,0 +1,32 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+var _ cstorage.Clock = (*Clock)(nil)
+
+func TestClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewClock(start)
+	cache := cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 10, Clock: clock})
+
+	cache.Put("key", []byte("value"))
+	clock.Advance(59 * time.Minute)
+	if ttl, hit := cache.Ttl("key"); !hit || ttl != time.Minute {
+		t.Errorf("a minute should be left, got %v", ttl)
+	}
+
+	clock.Advance(time.Minute + time.Nanosecond)
+	if _, hit := cache.Get("key"); hit {
+		t.Errorf("key should be expired")
+	}
+
+	clock.Set(start)
+	if !clock.Now().Equal(start) {
+		t.Errorf("clock should be set to start, got %v", clock.Now())
+	}
+}
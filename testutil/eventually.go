@@ -0,0 +1,17 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+)
+
+// Eventually function waits until cond returns true, checking it every millisecond, and fails t with msg if it doesn't in 5 seconds.
+// It is for asserting effects of background goroutines, such as replication or write-behind, without fixed sleeps.
+func Eventually(t testing.TB, cond func() bool, msg string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Package redis provides tiered.Backend which stores data in Redis.
+// It speaks Redis protocol directly, so it doesn't add dependency on Redis client library.
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/cocm1324/cstorage/internal/resp"
+	"github.com/cocm1324/cstorage/tiered"
+)
+
+// Backend structure is tiered.Backend of single Redis server. Keys are prefixed with Prefix.
+type Backend struct {
+	client *resp.Client
+	Prefix string
+}
+
+var (
+	_ tiered.Backend = (*Backend)(nil)
+	_ tiered.Toucher = (*Backend)(nil)
+)
+
+// New function returns Backend of Redis server at addr(host:port), which keeps at most maxIdle idle connections.
+func New(addr string, maxIdle int) *Backend {
+	return &Backend{client: resp.NewClient(addr, maxIdle)}
+}
+
+// Get runs GET.
+func (b *Backend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, err := b.client.Do(ctx, "GET", b.Prefix+key)
+	if err != nil {
+		return nil, false, err
+	}
+	data, _ := v.([]byte)
+	if data == nil {
+		return nil, false, nil
+	}
+	return data, true, nil
+}
+
+// Put runs SET, with PX if ttl is positive.
+func (b *Backend) Put(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	args := []string{"SET", b.Prefix + key, string(data)}
+	if ttl > 0 {
+		args = append(args, "PX", milliseconds(ttl))
+	}
+	_, err := b.client.Do(ctx, args...)
+	return err
+}
+
+// Delete runs DEL.
+func (b *Backend) Delete(ctx context.Context, key string) error {
+	_, err := b.client.Do(ctx, "DEL", b.Prefix+key)
+	return err
+}
+
+// Touch runs PEXPIRE for every keys in single pipeline.
+func (b *Backend) Touch(ctx context.Context, keys []string, ttl time.Duration) error {
+	ms := milliseconds(ttl)
+	cmds := make([][]string, len(keys))
+	for i, key := range keys {
+		cmds[i] = []string{"PEXPIRE", b.Prefix + key, ms}
+	}
+	_, err := b.client.Pipeline(ctx, cmds)
+	return err
+}
+
+// milliseconds formats ttl for PX and PEXPIRE. Positive ttl under 1ms is rounded up to 1, since Redis refuses PX 0 and deletes key by PEXPIRE 0.
+func milliseconds(ttl time.Duration) string {
+	ms := ttl.Milliseconds()
+	if ttl > 0 && ms == 0 {
+		ms = 1
+	}
+	return strconv.FormatInt(ms, 10)
+}
+
+// Close closes idle connections.
+func (b *Backend) Close() error {
+	return b.client.Close()
+}
@@ -0,0 +1,52 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/internal/resp/resptest"
+)
+
+func TestBackend(t *testing.T) {
+	srv, err := resptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	ctx := context.Background()
+	b := New(srv.Addr, 2)
+	b.Prefix = "app:"
+	defer b.Close()
+
+	if _, hit, err := b.Get(ctx, "key1"); hit || err != nil {
+		t.Errorf("key1 should miss, got %v %v", hit, err)
+	}
+	if err := b.Put(ctx, "key1", []byte("one\r\ntwo"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if data, hit, err := b.Get(ctx, "key1"); !hit || err != nil || string(data) != "one\r\ntwo" {
+		t.Errorf("key1 should hit, got %q %v %v", data, hit, err)
+	}
+	if srv.Expiration("app:key1").IsZero() {
+		t.Error("key1 should be put with PX")
+	}
+	before := srv.Expiration("app:key1")
+	time.Sleep(time.Millisecond * 5)
+	if err := b.Touch(ctx, []string{"key1", "missing"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if !srv.Expiration("app:key1").After(before) {
+		t.Error("touch should renew expiration")
+	}
+	if err := b.Put(ctx, "key2", []byte("2"), time.Microsecond*500); err != nil {
+		t.Errorf("ttl under 1ms should be rounded up, got %v", err)
+	}
+	if err := b.Delete(ctx, "key1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, hit, _ := b.Get(ctx, "key1"); hit {
+		t.Error("key1 should be deleted")
+	}
+}
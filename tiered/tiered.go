@@ -0,0 +1,204 @@
+// Package tiered provides two-tier cache, small hot in-memory CStorage as L1 backed by larger remote Backend as L2.
+// L1 misses fall through to L2 and populate L1.
+package tiered
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+// Backend interface is L2 storage of Tiered. Implementations should be safe for concurrent use.
+type Backend interface {
+	Get(ctx context.Context, key string) (data []byte, hit bool, err error)
+	Put(ctx context.Context, key string, data []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Toucher interface is optionally implemented by Backend which can renew ttl of keys without rewriting them.
+// Keys are given in batch, so implementation can send them in single round trip.
+type Toucher interface {
+	Touch(ctx context.Context, keys []string, ttl time.Duration) error
+}
+
+// WriteMode decides how Put of Tiered treats L1.
+type WriteMode int
+
+const (
+	// WriteThrough writes data to L2 and then to L1.
+	WriteThrough WriteMode = iota
+	// WriteInvalidate writes data to L2 and deletes key from L1, so L1 is populated by next Get.
+	// It is for the case other instances also write L2, and L1 should not keep value which might be overwritten soon.
+	WriteInvalidate
+)
+
+// Options structure is configuration of Tiered.
+// - Ttl: ttl of data written to L2. If 0, Ttl of L1 is not known to Tiered, so L2 data doesn't expire.
+// - Mode: how Put treats L1
+// - TouchOnHit: if true and L2 implements Toucher, L1 hits renew ttl of the key in L2 asynchronously, so shared keys don't expire in L2 while they are hot locally. Ttl should be set.
+// - TouchInterval, TouchBatch: touches are collected and sent every TouchInterval(default 1s), or as soon as TouchBatch(default 100) keys are collected
+type Options struct {
+	Ttl           time.Duration
+	Mode          WriteMode
+	TouchOnHit    bool
+	TouchInterval time.Duration
+	TouchBatch    int
+}
+
+// Tiered structure is two-tier cache.
+type Tiered struct {
+	l1      *cstorage.CStorage
+	l2      Backend
+	options Options
+
+	toucher Toucher
+	mutex   sync.Mutex
+	pending map[string]struct{}
+	flush   chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New function returns Tiered with l1 and l2. If touching is enabled, Close should be called to stop background goroutine.
+func New(l1 *cstorage.CStorage, l2 Backend, options Options) *Tiered {
+	t := &Tiered{l1: l1, l2: l2, options: options}
+
+	toucher, ok := l2.(Toucher)
+	if options.TouchOnHit && ok && options.Ttl > 0 {
+		if t.options.TouchInterval <= 0 {
+			t.options.TouchInterval = time.Second
+		}
+		if t.options.TouchBatch <= 0 {
+			t.options.TouchBatch = 100
+		}
+		t.toucher = toucher
+		t.pending = make(map[string]struct{})
+		t.flush = make(chan struct{}, 1)
+		t.done = make(chan struct{})
+		t.wg.Add(1)
+		go t.touchLoop()
+	}
+	return t
+}
+
+// Get function looks up L1 first, and then L2. Data found in L2 is put into L1.
+func (t *Tiered) Get(ctx context.Context, key string) (data []byte, hit bool, err error) {
+	if data, hit := t.l1.Get(key); hit {
+		t.touch(key)
+		return data, true, nil
+	}
+
+	data, hit, err = t.l2.Get(ctx, key)
+	if err != nil || !hit {
+		return nil, false, err
+	}
+
+	t.l1.Put(key, data)
+	return data, true, nil
+}
+
+// Put function writes data to L2, and then to L1 according to Mode.
+// If writing L2 fails, L1 is not touched, so L1 never has data which L2 doesn't.
+func (t *Tiered) Put(ctx context.Context, key string, data []byte) error {
+	if err := t.l2.Put(ctx, key, data, t.options.Ttl); err != nil {
+		return err
+	}
+
+	switch t.options.Mode {
+	case WriteInvalidate:
+		t.l1.Delete(key)
+	default:
+		t.l1.Put(key, data)
+	}
+	return nil
+}
+
+// Delete function deletes key from L2, and then from L1, same order as Put. If L1 was deleted first, Get could fill it again
+// from L2 in between. If deleting L2 fails, L1 is kept, since L2 still has the key.
+func (t *Tiered) Delete(ctx context.Context, key string) error {
+	if err := t.l2.Delete(ctx, key); err != nil {
+		return err
+	}
+	t.l1.Delete(key)
+	return nil
+}
+
+// Invalidate function deletes key only from L1. It is for applying invalidation notified by other instances.
+func (t *Tiered) Invalidate(key string) {
+	t.l1.Delete(key)
+}
+
+// L1 returns in-memory tier.
+func (t *Tiered) L1() *cstorage.CStorage {
+	return t.l1
+}
+
+// Close stops background touching after sending pending touches.
+func (t *Tiered) Close() error {
+	if t.toucher == nil {
+		return nil
+	}
+	close(t.done)
+	t.wg.Wait()
+	return nil
+}
+
+// touch queues key to be touched in L2. Same key is touched only once per batch.
+func (t *Tiered) touch(key string) {
+	if t.toucher == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	t.pending[key] = struct{}{}
+	full := len(t.pending) >= t.options.TouchBatch
+	t.mutex.Unlock()
+
+	if full {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *Tiered) touchLoop() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(t.options.TouchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.flush:
+		case <-t.done:
+			t.sendTouches()
+			return
+		}
+		t.sendTouches()
+	}
+}
+
+// sendTouches sends pending touches in batches of TouchBatch. Errors are ignored, since touch is best effort.
+func (t *Tiered) sendTouches() {
+	t.mutex.Lock()
+	keys := make([]string, 0, len(t.pending))
+	for key := range t.pending {
+		keys = append(keys, key)
+	}
+	t.pending = make(map[string]struct{})
+	t.mutex.Unlock()
+
+	for len(keys) > 0 {
+		n := t.options.TouchBatch
+		if n > len(keys) {
+			n = len(keys)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), t.options.TouchInterval)
+		t.toucher.Touch(ctx, keys[:n], t.options.Ttl)
+		cancel()
+		keys = keys[n:]
+	}
+}
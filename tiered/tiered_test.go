@@ -0,0 +1,130 @@
+package tiered
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage"
+)
+
+type mapBackend struct {
+	mutex   sync.Mutex
+	data    map[string][]byte
+	gets    int
+	touches [][]string
+}
+
+func (m *mapBackend) Touch(ctx context.Context, keys []string, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.touches = append(m.touches, append([]string(nil), keys...))
+	return nil
+}
+
+func (m *mapBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.gets++
+	d, ok := m.data[key]
+	return d, ok, nil
+}
+
+func (m *mapBackend) Put(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.data[key] = data
+	return nil
+}
+
+func (m *mapBackend) Delete(ctx context.Context, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.data, key)
+	return nil
+}
+
+func TestTiered(t *testing.T) {
+	ctx := context.Background()
+	l1 := cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 10})
+	l2 := &mapBackend{data: map[string][]byte{"key1": []byte("1")}}
+	c := New(l1, l2, Options{})
+
+	if data, hit, _ := c.Get(ctx, "key1"); !hit || string(data) != "1" {
+		t.Error("key1 should fall through to L2")
+	}
+	c.Get(ctx, "key1")
+	if l2.gets != 1 {
+		t.Errorf("second get should hit L1, L2 got %d gets", l2.gets)
+	}
+
+	c.Put(ctx, "key2", []byte("2"))
+	if _, hit := l1.Get("key2"); !hit || string(l2.data["key2"]) != "2" {
+		t.Error("write through should write both tiers")
+	}
+
+	c = New(l1, l2, Options{Mode: WriteInvalidate})
+	c.Put(ctx, "key2", []byte("22"))
+	if _, hit := l1.Get("key2"); hit {
+		t.Error("write invalidate should delete L1")
+	}
+
+	c.Delete(ctx, "key1")
+	if _, hit, _ := c.Get(ctx, "key1"); hit {
+		t.Error("key1 should be deleted from both tiers")
+	}
+}
+
+func TestTouchOnHit(t *testing.T) {
+	ctx := context.Background()
+	l1 := cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 10})
+	l2 := &mapBackend{data: map[string][]byte{"key1": []byte("1"), "key2": []byte("2")}}
+	c := New(l1, l2, Options{Ttl: time.Minute, TouchOnHit: true, TouchInterval: time.Hour, TouchBatch: 2})
+
+	c.Get(ctx, "key1")
+	c.Get(ctx, "key2")
+	for i := 0; i < 3; i++ {
+		c.Get(ctx, "key1")
+	}
+	c.Get(ctx, "key2")
+	c.Close()
+
+	l2.mutex.Lock()
+	defer l2.mutex.Unlock()
+	total := 0
+	for _, batch := range l2.touches {
+		if len(batch) > 2 {
+			t.Errorf("batch should have at most 2 keys, got %v", batch)
+		}
+		total += len(batch)
+	}
+	if total == 0 || total > 4 {
+		t.Errorf("hits should be coalesced into few touches, got %v", l2.touches)
+	}
+}
+
+// racingBackend calls beforeDelete before deleting key, to run Get between the tiers being deleted.
+type racingBackend struct {
+	*mapBackend
+	beforeDelete func()
+}
+
+func (r *racingBackend) Delete(ctx context.Context, key string) error {
+	r.beforeDelete()
+	return r.mapBackend.Delete(ctx, key)
+}
+
+func TestDeleteRace(t *testing.T) {
+	ctx := context.Background()
+	l1 := cstorage.New(cstorage.CStorageConfig{Ttl: time.Hour, Capacity: 10})
+	l2 := &racingBackend{mapBackend: &mapBackend{data: map[string][]byte{"key1": []byte("1")}}}
+	c := New(l1, l2, Options{})
+	l2.beforeDelete = func() { c.Get(ctx, "key1") }
+
+	c.Get(ctx, "key1")
+	c.Delete(ctx, "key1")
+	if _, hit := l1.Get("key1"); hit {
+		t.Error("Get during Delete should not fill L1 with deleted key")
+	}
+}
@@ -0,0 +1,129 @@
+package cstorage
+
+// tinyLFU is admission filter which estimates access frequency of keys, so that new key which is accessed only once
+// (e.g. by scan traffic) doesn't evict valuable key. It consists of
+// - doorkeeper: bloom filter which absorbs first access of keys, so one-hit-wonders don't take counters
+// - sketch: count-min sketch with 4 rows of 4 bit counters
+// Counters are halved and doorkeeper is reset after every sampleSize accesses(aging), so old popularity fades away.
+type tinyLFU struct {
+	seeds      [4]uint64
+	sketch     [4][]uint8
+	doorkeeper []uint64
+	mask       uint64
+	doorMask   uint64
+	additions  int
+	sampleSize int
+}
+
+// maxWidth is maximum number of counters in a row of sketch.
+const maxWidth = 1 << 22
+
+// newTinyLFU makes tinyLFU sized for capacity. seeds are drawn from rand of CStorage, so it is deterministic with CStorageConfig.Seed.
+// Each row has 4 counters per key of capacity, and doorkeeper has 8 bits per key, to keep collisions low.
+// Width is limited to maxWidth, since capacity can be total weight(e.g. bytes) rather than number of keys.
+func newTinyLFU(capacity int64, seeds [4]uint64) *tinyLFU {
+	width := uint64(64)
+	for int64(width) < capacity*4 && width < maxWidth {
+		width <<= 1
+	}
+
+	samples := capacity
+	if samples > int64(width/4) {
+		samples = int64(width / 4)
+	}
+
+	t := &tinyLFU{
+		seeds:      seeds,
+		doorkeeper: make([]uint64, width*2/64),
+		mask:       width - 1,
+		doorMask:   width*2 - 1,
+		sampleSize: int(samples) * 10,
+	}
+	for i := range t.sketch {
+		t.sketch[i] = make([]uint8, width)
+	}
+	return t
+}
+
+// hash mixes hash of key with seed, so each row and doorkeeper get independent hash without hashing key again.
+func (t *tinyLFU) hash(h uint64, seed uint64) uint64 {
+	h ^= seed
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// record counts access of key of hash h.
+func (t *tinyLFU) record(h uint64) {
+	if t.doorkeep(h) {
+		for i := range t.sketch {
+			c := &t.sketch[i][t.hash(h, t.seeds[i])&t.mask]
+			if *c < 15 {
+				*c++
+			}
+		}
+	}
+	t.additions++
+
+	if t.additions >= t.sampleSize {
+		t.age()
+	}
+}
+
+// doorkeep returns true if key of hash h was already in doorkeeper, otherwise it adds key to doorkeeper.
+func (t *tinyLFU) doorkeep(h uint64) bool {
+	seen := true
+	for i := 0; i < 2; i++ {
+		bit := t.hash(h, t.seeds[i]+1) & t.doorMask
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if t.doorkeeper[word]&mask == 0 {
+			seen = false
+			t.doorkeeper[word] |= mask
+		}
+	}
+	return seen
+}
+
+// estimate returns estimated access frequency of key of hash h.
+func (t *tinyLFU) estimate(h uint64) int {
+	min := uint8(15)
+	for i := range t.sketch {
+		if c := t.sketch[i][t.hash(h, t.seeds[i])&t.mask]; c < min {
+			min = c
+		}
+	}
+
+	freq := int(min)
+	inDoorkeeper := true
+	for i := 0; i < 2; i++ {
+		bit := t.hash(h, t.seeds[i]+1) & t.doorMask
+		if t.doorkeeper[bit/64]&(uint64(1)<<(bit%64)) == 0 {
+			inDoorkeeper = false
+		}
+	}
+	if inDoorkeeper {
+		freq++
+	}
+	return freq
+}
+
+// admit returns true if candidate is estimated to be accessed more than victim. Both are hashes of keys.
+func (t *tinyLFU) admit(candidate, victim uint64) bool {
+	return t.estimate(candidate) > t.estimate(victim)
+}
+
+// age halves every counters and resets doorkeeper.
+func (t *tinyLFU) age() {
+	for i := range t.sketch {
+		for j := range t.sketch[i] {
+			t.sketch[i][j] >>= 1
+		}
+	}
+	for i := range t.doorkeeper {
+		t.doorkeeper[i] = 0
+	}
+	t.additions = 0
+}
@@ -0,0 +1,73 @@
+package cstorage
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTinyLFU(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, TinyLFU: true, Seed: 1}
+	cache := New(config)
+
+	for i := 0; i < 10; i++ {
+		key := "hot" + strconv.Itoa(i)
+		cache.Put(key, []byte(key))
+		for j := 0; j < 5; j++ {
+			cache.Get(key)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		key := "scan" + strconv.Itoa(i)
+		cache.Put(key, []byte(key))
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, hit := cache.Get("hot" + strconv.Itoa(i)); !hit {
+			t.Errorf("hot%d should survive scan", i)
+		}
+	}
+	if st := cache.Stats(); st.Rejected != 100 {
+		t.Errorf("every scan key should be rejected, got %d", st.Rejected)
+	}
+}
+
+// benchmarkHitRatio runs zipfian reads mixed with scans of unique keys, and reports hit ratio of reads.
+func benchmarkHitRatio(b *testing.B, config CStorageConfig) {
+	config.Ttl = time.Hour
+	config.Capacity = 1000
+	config.Seed = 1
+	cache := New(config)
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.1, 1, 100000)
+
+	var hits, gets int
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%3 == 0 {
+			key := "scan" + strconv.Itoa(i)
+			cache.Put(key, nil)
+			continue
+		}
+		key := strconv.FormatUint(zipf.Uint64(), 10)
+		gets++
+		if _, hit := cache.Get(key); hit {
+			hits++
+		} else {
+			cache.Put(key, nil)
+		}
+	}
+	b.ReportMetric(float64(hits)/float64(gets), "hit-ratio")
+}
+
+func BenchmarkHitRatioLRU(b *testing.B) {
+	benchmarkHitRatio(b, CStorageConfig{})
+}
+
+func BenchmarkHitRatioTinyLFU(b *testing.B) {
+	benchmarkHitRatio(b, CStorageConfig{TinyLFU: true})
+}
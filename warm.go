@@ -0,0 +1,76 @@
+package cstorage
+
+// Warm function puts entries in bulk to preload CStorage on start, e.g. from snapshot or database, while other writes are already flowing.
+// Each entry is put with its ExpiresAt, or with its Ttl if ExpiresAt is zero, and expired entries are skipped.
+// Warm never replaces or evicts keys which are already in CStorage, since they are newer than preloaded entries:
+// - If key of entry exists, the entry is skipped
+// - If there is no room for the entry, it is skipped instead of evicting other keys
+// Entries should be ordered from the least recent one, same as snapshot. The lock is released between batches of entries,
+// so other operations are not blocked during whole of Warm. It returns number of entries put.
+func (s *CStorage) Warm(entries []Entry) (count int, err error) {
+	return s.WarmWithProgress(entries, nil)
+}
+
+// WarmWithProgress function is same as Warm, but progress is called after each batch of entries. total is len(entries).
+// If progress returns false, it stops with ErrCanceled, and entries put so far are kept.
+func (s *CStorage) WarmWithProgress(entries []Entry, progress ProgressFunc) (count int, err error) {
+	total := int64(len(entries))
+	for done := 0; done < len(entries); done += progressBatch {
+		end := done + progressBatch
+		if end > len(entries) {
+			end = len(entries)
+		}
+		put, err := s.warm(entries[done:end])
+		count += put
+		if err != nil {
+			return count, err
+		}
+		if progress != nil && !progress(int64(end), total) && end < len(entries) {
+			return count, ErrCanceled
+		}
+	}
+	return count, nil
+}
+
+// warm puts entries which are not expired, don't exist, and fit without eviction, under single lock.
+func (s *CStorage) warm(entries []Entry) (count int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return 0, ErrClosed
+	}
+	now := s.now()
+	for _, e := range entries {
+		ttl := e.Ttl
+		if ttl == 0 {
+			ttl = s.config.Ttl
+		}
+		if !e.ExpiresAt.IsZero() {
+			ttl = e.ExpiresAt.Sub(now)
+		}
+		if ttl <= 0 {
+			continue
+		}
+		if n, ok := s.table[e.Key]; ok && n.ttl.After(now) {
+			continue
+		}
+		if s.full(s.weigh(e.Key, e.Data)) {
+			continue
+		}
+		h := e.Hash
+		if h == 0 {
+			h = s.hash(e.Key)
+		}
+		n, _ := s.putMeta(e.Key, h, e.Data, ttl, s.config.Sliding, copyMeta(e.Meta))
+		if n == nil {
+			continue
+		}
+		if e.Flags != 0 {
+			n.flags = e.Flags
+			s.logPut(n)
+		}
+		count++
+	}
+	return count, nil
+}
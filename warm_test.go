@@ -0,0 +1,82 @@
+package cstorage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+func TestWarm(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 4
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock}
+	cache := New(config)
+
+	// written while warming, so it is newer than preloaded entry
+	cache.Put("a", []byte("live"))
+	count, err := cache.Warm([]Entry{
+		{Key: "a", Data: []byte("old")},
+		{Key: "b", Data: []byte("2"), ExpiresAt: clock.Now().Add(time.Minute)},
+		{Key: "expired", Data: []byte("3"), ExpiresAt: clock.Now().Add(-time.Minute)},
+		{Key: "c", Data: []byte("4"), Flags: 9},
+		{Key: "d", Data: []byte("5")},
+		{Key: "e", Data: []byte("6")},
+	})
+	if err != nil || count != 3 {
+		t.Errorf("b, c and d should be warmed, got %d %v", count, err)
+	}
+	if data, _ := cache.Get("a"); string(data) != "live" {
+		t.Errorf("existing key should be kept, got %q", data)
+	}
+	if remaining, _ := cache.Ttl("b"); remaining != time.Minute {
+		t.Errorf("b should keep its expiration, got %v", remaining)
+	}
+	if _, flags, _ := cache.GetWithFlags("c"); flags != 9 {
+		t.Errorf("c should be warmed with flags, got %d", flags)
+	}
+	if _, hit := cache.Get("e"); hit {
+		t.Error("e should be dropped since cache is full")
+	}
+	if evicted := cache.Stats().Evicted; evicted != 0 {
+		t.Errorf("warm should not evict keys, got %d", evicted)
+	}
+}
+
+func TestWarmWithProgress(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 3000
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	entries := make([]Entry, 2500)
+	for i := range entries {
+		entries[i] = Entry{Key: strconv.Itoa(i), Data: []byte("v")}
+	}
+	var calls []int64
+	count, err := cache.WarmWithProgress(entries, func(done, total int64) bool {
+		calls = append(calls, done)
+		return total == 2500
+	})
+	if err != nil || count != 2500 {
+		t.Errorf("every entry should be warmed, got %d %v", count, err)
+	}
+	if len(calls) != 3 || calls[0] != 1024 || calls[2] != 2500 {
+		t.Errorf("progress should be called after each batch, got %v", calls)
+	}
+
+	cache.Clear()
+	count, err = cache.WarmWithProgress(entries, func(done, total int64) bool {
+		return false
+	})
+	if err != ErrCanceled || count != 1024 {
+		t.Errorf("it should stop after first batch, got %d %v", count, err)
+	}
+
+	cache.Close()
+	if _, err := cache.Warm(entries); err != ErrClosed {
+		t.Errorf("closed cache should not be warmed, got %v", err)
+	}
+}
@@ -0,0 +1,9 @@
+package cstorage
+
+// Watch function is to receive events of key, when it is put, deleted, evicted or expires, so goroutines can react to change of key
+// (e.g. config blob cached under known key) without polling. EventClear is sent as well, since Clear removes the key.
+// Expiry is noticed when expired key is removed, by Get or RemoveExpired, so set CStorageConfig.CleanupInterval to be notified soon after ttl passes.
+// Like Subscribe, events are dropped if the channel is full, and cancel stops watching and closes the channel.
+func (s *CStorage) Watch(key string) (events <-chan Event, cancel func()) {
+	return s.subscribe(&subscription{key: key, watch: true})
+}
@@ -0,0 +1,75 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cocm1324/cstorage/testutil"
+)
+
+func TestWatch(t *testing.T) {
+	ttl := time.Duration(time.Minute)
+	var capacity int64 = 10
+	clock := testutil.NewClock(time.Now())
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Clock: clock, EventValues: true}
+	cache := New(config)
+
+	events, cancel := cache.Watch("config")
+	other, cancelOther := cache.Watch("config")
+
+	cache.Put("config", []byte("v1"))
+	cache.Put("other", []byte("x"))
+	cache.Put("config", []byte("v2"))
+	cache.Delete("other")
+	cache.Delete("config")
+	cache.Put("config", []byte("v3"))
+	clock.Advance(2 * time.Minute)
+	cache.RemoveExpired()
+	cache.Clear()
+
+	expected := []Event{{Type: EventPut, Key: "config", Data: []byte("v1")}, {Type: EventPut, Key: "config", Data: []byte("v2")},
+		{Type: EventDelete, Key: "config", Data: []byte("v2")}, {Type: EventPut, Key: "config", Data: []byte("v3")},
+		{Type: EventExpire, Key: "config", Data: []byte("v3")}, {Type: EventClear}}
+	for _, ch := range []<-chan Event{events, other} {
+		received := receive(ch)
+		if len(received) != len(expected) {
+			t.Fatalf("expected %d events, got %+v", len(expected), received)
+		}
+		for i, e := range expected {
+			if received[i].Type != e.Type || received[i].Key != e.Key || string(received[i].Data) != string(e.Data) {
+				t.Errorf("event %d: expected %+v, got %+v", i, e, received[i])
+			}
+		}
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("channel should be closed by cancel")
+	}
+	cache.Put("config", []byte("v4"))
+	if received := receive(other); len(received) != 1 {
+		t.Errorf("other watcher should still receive events, got %+v", received)
+	}
+
+	cancelOther()
+	if len(cache.watchers) != 0 {
+		t.Errorf("watchers should be removed, got %v", cache.watchers)
+	}
+}
+
+func TestWatchClose(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	a, _ := cache.Watch("a")
+	b, _ := cache.Watch("a")
+	c, _ := cache.Watch("c")
+	cache.Close()
+	for _, events := range []<-chan Event{a, b, c} {
+		if _, ok := <-events; ok {
+			t.Error("channel should be closed by Close")
+		}
+	}
+}
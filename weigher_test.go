@@ -0,0 +1,59 @@
+package cstorage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWeigher(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity, Weigher: func(key string, data []byte) int64 {
+		return int64(len(data))
+	}}
+	cache := New(config)
+
+	for i := 0; i < 5; i++ {
+		cache.Put(strconv.Itoa(i), []byte("ab"))
+	}
+	if cache.Size() != 5 || cache.Weight() != 10 {
+		t.Fatalf("expected size 5 and weight 10, got %d and %d", cache.Size(), cache.Weight())
+	}
+
+	cache.Put("big", []byte("abcde"))
+	if cache.Weight() > capacity {
+		t.Errorf("weight %d exceeds capacity", cache.Weight())
+	}
+	for i := 0; i < 3; i++ {
+		if _, hit := cache.Peek(strconv.Itoa(i)); hit {
+			t.Errorf("%d should be evicted to free weight for big key", i)
+		}
+	}
+	if _, hit := cache.Peek("3"); !hit {
+		t.Error("3 shouldn't be evicted since there was enough room")
+	}
+
+	cache.Put("3", []byte("abcd"))
+	if cache.Weight() != 9 || cache.Size() != 2 {
+		t.Errorf("update should evict other keys to free weight, got weight %d and size %d", cache.Weight(), cache.Size())
+	}
+
+	cache.Put("huge", make([]byte, 11))
+	if _, hit := cache.Peek("huge"); hit {
+		t.Error("key heavier than capacity shouldn't be put")
+	}
+	if cache.Size() != 2 {
+		t.Error("key heavier than capacity shouldn't evict others")
+	}
+
+	cache.Put("3", make([]byte, 11))
+	if _, hit := cache.Peek("3"); hit {
+		t.Error("key updated to be heavier than capacity should be removed")
+	}
+
+	cache.Delete("big")
+	if cache.Weight() != 0 || cache.Stats().Weight != 0 {
+		t.Errorf("weight should be 0, got %d", cache.Weight())
+	}
+}
@@ -0,0 +1,45 @@
+package cstorage
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrContention is returned by GetWithin when the lock couldn't be acquired in time.
+var ErrContention = errors.New("cstorage: lock contention")
+
+// GetWithin function is same as Get, but it gives up when the lock can't be acquired within d, and returns ErrContention.
+// It is for latency critical path which prefers a miss to waiting.
+func (s *CStorage) GetWithin(key string, d time.Duration) (data []byte, hit bool, err error) {
+	if !s.tryLock(d) {
+		return nil, false, ErrContention
+	}
+	defer s.mutex.Unlock()
+
+	n, ok := s.get(key)
+	if !ok {
+		return nil, false, nil
+	}
+
+	return s.value(n), true, nil
+}
+
+// tryLock tries to acquire the mutex until d passes. Wait between tries doubles up to 100 microseconds.
+func (s *CStorage) tryLock(d time.Duration) bool {
+	if s.mutex.TryLock() {
+		return true
+	}
+
+	deadline := time.Now().Add(d)
+	wait := time.Microsecond
+	for time.Now().Before(deadline) {
+		time.Sleep(wait)
+		if s.mutex.TryLock() {
+			return true
+		}
+		if wait < 100*time.Microsecond {
+			wait *= 2
+		}
+	}
+	return false
+}
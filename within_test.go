@@ -0,0 +1,29 @@
+package cstorage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetWithin(t *testing.T) {
+	ttl := time.Duration(time.Hour)
+	var capacity int64 = 10
+	config := CStorageConfig{Ttl: ttl, Capacity: capacity}
+	cache := New(config)
+
+	cache.Put("key1", []byte("1"))
+	if data, hit, err := cache.GetWithin("key1", time.Millisecond); err != nil || !hit || string(data) != "1" {
+		t.Errorf("key1 should hit, got %s %v %v", data, hit, err)
+	}
+
+	cache.mutex.Lock()
+	start := time.Now()
+	_, _, err := cache.GetWithin("key1", time.Millisecond*5)
+	cache.mutex.Unlock()
+	if err != ErrContention {
+		t.Errorf("locked cache should return ErrContention, got %v", err)
+	}
+	if time.Since(start) > time.Millisecond*100 {
+		t.Error("GetWithin should give up around the deadline")
+	}
+}